@@ -12,9 +12,8 @@ import (
 // title Introduction to Channels
 // subtitle
 // Demystifying Concurrency
-
-// GopherCon Europe 2026
 // !subtitle
+// event GopherCon Europe 2026
 
 // heading Prelude: The Collatz Conjecture
 
@@ -172,9 +171,11 @@ func f5a() {
 // question
 // What happens to the first goroutine if there is a timeout?
 // answer
+// timeline
 // 1. `time.After` case executes
 // 2. `select` finishes
 // 3. goroutine tries to send to `c`
+// !timeline
 //
 // - The GC does not collect `c`: there is still a reference to it.
 // - The GC does not collect goroutines: they must terminate.
@@ -229,7 +230,8 @@ func f6() {
 // !cols
 
 ////////////////////////////////////
-// heading Exercise: replacing time.After
+// heading Replacing time.After
+// exercise
 
 // text
 // What if `time.After` didn't exist?
@@ -650,7 +652,8 @@ func collatzWithTimeout(ctx context.Context, n int, tm time.Duration) (int, erro
 // !code
 
 ////////////////////////////////////
-// heading Exercise: Hedging
+// heading Hedging
+// exercise
 
 // cols
 
@@ -16,9 +16,8 @@ import (
 // title Introduction to Synchronization
 // subtitle
 // Demystifying Concurrency
-
-// GopherCon Europe 2026
 // !subtitle
+// event GopherCon Europe 2026
 
 // heading Preparation
 // text
@@ -105,14 +104,11 @@ func f2() {
 
 What we want (G1, G2 are goroutines):
 
-<div class="interleave" style="font-size: 70%">
-
 | G1 | G2 |
 | -- | -- |
 | c++ |  |
 |  | c++ |
-
-</div>
+{.interleave}
 */
 // html <div style="width: 25vw"></div>
 
@@ -120,14 +116,12 @@ What we want (G1, G2 are goroutines):
 /* text
 What we might get:
 
-<div class="interleave" style="font-size: 70%">
-
 | G1 | G2 |
 | -- | -- |
 | R0 = c | R0 = c |
 | R0++ | R0++ |
 | c = R0 | c = R0 |
-</div>
+{.interleave}
 */
 // !cols
 
@@ -250,11 +244,13 @@ func F(x int) int {
 // !code
 
 ////////////////////////////////////////////////
-// heading Exercise: Bank Account
+// heading Bank Account
+// exercise
 
 // link ../../../exercises/account/account.go Code
 // html <br/><br/><br/>
 // link ../../../exercises/account/solution/account.go Solution
+// solution ../../exercises/account
 
 ////////////////////////////////////////////////
 // heading Synchronization: more than interleavings
@@ -945,6 +941,7 @@ func (l *Logger) Printf(format string, v ...any) {
 // link ../../../exercises/logger/logger.go Code
 // html <br/><br/><br/>
 // link ../../../exercises/logger/solution/logger.go Solution
+// solution ../../exercises/logger
 
 ////////////////////////////////////
 // heading  And now ...
@@ -1236,7 +1233,7 @@ func g() {
 // html <br/>
 // text That is only one interleaving!
 
-// !col
+// !cols
 
 // //////////////////////////////////
 // heading Solution 1: coarser granularity
@@ -1332,6 +1329,7 @@ func (a *Account_d4) TransferTo(b *Account_d4, amount int) {
 }
 
 // !code
+// !cols
 
 // heading Checklocks
 
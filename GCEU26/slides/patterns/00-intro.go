@@ -3,9 +3,8 @@ package patterns
 // title Concurrency Patterns
 // subtitle
 // Demystifying Concurrency
-
-// GopherCon Europe 2026
 // !subtitle
+// event GopherCon Europe 2026
 
 ////////////////////////////////////
 // heading The Patterns
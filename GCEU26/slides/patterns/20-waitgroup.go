@@ -177,16 +177,13 @@ func (g *WaitGroup_3) Wait() {
 
 // text `Wait` should actually wait.
 //
-// question
+// question How can `Wait` wait until `count` is zero?
+// hint
 // What synchronization feature will make a goroutine
 // wait until something happens?
+// hint A channel.
 // answer
-// A channel.
-// !question
-//
-// question How should we use it?
-// answer
-// We should close it when `count` is zero, to broadcast to
+// Close a channel when `count` is zero, to broadcast to
 // all waiting goroutines.
 // code
 type WaitGroup_answer struct {
@@ -244,7 +241,8 @@ func (g *WaitGroup_5) Wait() {
 
 // !cols
 
-// heading Exercise: Improvements
+// heading Improvements
+// exercise
 
 // text See exercises/waitgroup in github.com/jba/concurrency-workshop.
 
@@ -272,3 +270,4 @@ func (g *WaitGroup_5) Wait() {
 // include ../../exercises/waitgroup/solution/waitgroup.go /^func.*Wait\(/ /^\}$/
 // !code
 // !cols
+// solution ../../exercises/waitgroup
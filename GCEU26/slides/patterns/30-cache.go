@@ -117,6 +117,7 @@ func (m *Memo_2[In, Out]) Call(in In) Out {
 }
 
 // !code
+// !cols
 
 ////////////////////////////////////
 // heading A concurrent Memo
@@ -5,7 +5,35 @@
 // Lines that are not inside a directive block are ignored (unless inside a code or
 // other block section).
 //
-// A single file can produce multiple slides; each "heading" directive starts a new one.
+// A single file can produce multiple slides; each "heading" or "slide"
+// directive starts a new one.
+//
+// The directives that describe a whole slide or file (title, author, date,
+// event, heading, hold, exercise, label, same-as, optional, time, todo and
+// rename) are directives only outside sections. Inside a code block,
+// "// hold the lock" is just a comment.
+//
+// Text, note, output, subtitle and diagram sections can also be written as block
+// comments, so long passages don't need "//" on every line: "/* note"
+// opens the section, and every line up to the "*/" that closes the comment
+// is its content.
+//
+// The directives of code, text, question, hint, answer, output, subtitle,
+// poll, quiz, timeline, stepper, diagram and sequence sections can begin with a
+// class list, as in "// text .small .right": the classes are added to the
+// element that wraps the section, for one-off styling without an html
+// directive.
+//
+// Directives can also be written in source files other than Go. Their
+// comments begin with "#" for files ending in .py, .sh, .rb, .yaml or .yml,
+// and with "--" for .sql and .lua files; the -comment flag sets the prefix
+// for every file. Such files have no block comment form. Their code can be
+// indented with any number of spaces or tabs, while Go code must be
+// indented with tabs, or multiples of 4 spaces.
+//
+// The -footer flag puts a license or attribution notice, written in
+// markdown, at the foot of every slide, including those of -scroll decks
+// printed as handouts.
 //
 // # Directives
 //
@@ -13,32 +41,87 @@
 //
 //	Set the slide's heading to TEXT. Each heading starts a new slide.
 //
-// code [OPTIONS] / !code
+// title TEXT
+//
+//	Start the deck's title slide, with TEXT as its title. A subtitle section
+//	can follow, and these directives, each once, in any order:
+//
+//	  author TEXT - who is presenting
+//	  event TEXT  - where, like "GopherCon Europe 2026"
+//	  date TEXT   - when
+//
+//	Each TEXT is markdown, so it can link to a web page.
+//
+// divider TEXT
+//
+//	Start a slide that divides the deck into chapters, like "Part 2:
+//	Channels". Its only content is TEXT, shown large. Decks built from a
+//	manifest with named parts get dividers without this directive.
+//
+// toc [HEADING]
+//
+//	Start a slide with a table of contents, headed HEADING or "Contents".
+//	It links to each chapter, begun by a divider, and to the slides in it;
+//	in a deck without dividers, it links to each slide with a new heading.
+//	The -toc flag adds the slide after the title slide of a deck without a
+//	toc directive. Decks built from a manifest with named parts get one
+//	before the first part.
+//
+// slide [TEXT]
+//
+//	Start a new slide. If TEXT is present, it is the new slide's heading;
+//	otherwise the new slide has the same heading as the previous one, so a
+//	long example can continue across several slides.
+//
+// code [ATTRIBUTES] / !code
 //
 //	Begin and end a code block. Lines between these directives are rendered
-//	as preformatted source code. Comments in the code are syntax-highlighted.
-//	Type and function definitions are highlighted as well.
+//	as preformatted source code. Go code is syntax-highlighted by its
+//	tokens: keywords, strings, numbers, comments, and the names of type and
+//	function definitions. `Backquoted` text in a comment is rendered as code,
+//	as in markdown. Code in other languages, and noescape code, has only its
+//	comments and definitions highlighted.
 //
-//	OPTIONS is a space-separated list of words that can include:
+//	ATTRIBUTES is a space-separated list of words that can include:
 //	  bad       - Render the code block with a red border (incorrect code).
 //	  weak      - Render the code block with a yellow border.
 //	  small     - Use a font size 20% smaller than default.
 //	  smaller   - Use a font size 30% smaller than default.
-//    large     - Use a font size larger than default.
+//	  large     - Use a font size larger than default.
 //	  nonumbers - Omit line numbers in the output.
 //	  nonum     - Synonym for "nonumbers".
+//	  align     - Line up trailing comments in a column.
+//	  play      - Make the code editable, with a button to run it.
+//	              The code must be a complete program. Served decks
+//	              run it only with -play (see Serving).
+//	  noescape  - Don't escape HTML in the code, so it can contain markup.
+//	  diff      - Emphasize the lines that are new since the code block in
+//	              the same place on the previous slide of the file: the
+//	              first code block diffs against the first, and so on.
+//	  critical  - Shade the critical sections of the code: the lines from
+//	              each Lock or RLock call to the matching unlock, or with a
+//	              deferred unlock, to the end of the block.
+//	  fit       - Shrink the font of code too long for the slide until it
+//	              fits.
+//	  scroll    - Scroll code too long for the slide in a pane of its own.
+//	  split     - Continue code too long for the slide on the slides after
+//	              it, headed "(cont.)". See "Long code" below.
 //
 // note / !note
 //
 //	Begin and end a presenter note block. Lines between these directives are
 //	rendered as markdown. Notes are only included in the output when the
-//	-notes flag is set.
+//	-notes flag is set. With -presenter, pressing 'N' opens a window that
+//	shows the current slide and its notes, and -notes-file writes all the
+//	notes to a document of their own.
 //
 // text / !text
 //
 //	Begin and end a text block. Lines between these directives are rendered
-//	as markdown. A line containing only "*/" also closes a text block (to
-//	support /* text ... */ style comments).
+//	as markdown.
+//
+//	A markdown table in a text section is centered on the slide. To style
+//	a table, follow it with a line of classes, like "{.interleave}".
 //
 // text CONTENT (inline form)
 //
@@ -46,33 +129,175 @@
 //	a single-line text section rendered as markdown. There is no matching
 //	"!text" for this form.
 //
-// output / !output
+// diagram [LANGUAGE] / !diagram
+//
+//	Begin and end a diagram, like one of goroutines and the channels between
+//	them, to draw next to the code it describes. The lines between these
+//	directives are the diagram's source, in LANGUAGE: mermaid (the default),
+//	dot or d2. The build renders it to SVG with the language's program, mmdc,
+//	dot or d2, and puts the SVG in the slide. Rendered diagrams are cached
+//	in the -output-cache directory, so a build only needs the program for
+//	new or changed diagrams. Like output, diagram has a block comment form.
+//
+// sequence [TESTNAME] [FLAG | KEY=VALUE ...]
+//
+//	Draw a sequence diagram of the channel operations of the package in the
+//	directory of the source file, as they happen when it runs, like a
+//	diagram section. The package is run as for "output auto", but with its
+//	sends, receives and closes instrumented to report themselves. Each
+//	goroutine has a lifeline, and an arrow goes from each send to the
+//	receive that took its value: level for an unbuffered channel, and
+//	sloping down to the later receive for a buffered one. Operations in
+//	select statements and receives by range loops aren't drawn. Diagrams
+//	are cached like output.
+//
+// footnote / !footnote (or footnote CONTENT)
+//
+//	Add a footnote, rendered as markdown in small text at the bottom of the
+//	slide, for caveats that shouldn't interrupt the slide's main point.
+//	Like text, footnote has an inline form. A slide's footnotes are shown
+//	in order, wherever they appear in the slide.
+//
+// output [CONDITIONS] / !output
 //
 //	Begin and end an output block. Lines between these directives are rendered
 //	as preformatted text with a dark background, representing program output.
+//	The "//" that begins each line and one space after it are removed; the
+//	rest of the line, including indentation, is displayed as written.
+//	CONDITIONS, if present, describe how the output was produced, like
+//	"-race GOMAXPROCS=1", and are displayed above it. The word
+//	"nondeterministic" among them adds a badge saying the output varies.
+//
+// output auto [TESTNAME] [FLAG | KEY=VALUE ...]
+//
+//	Instead of output pasted by hand, show the output of the package in the
+//	directory of the source file, captured when the deck is built: what
+//	"go run FLAG... ." writes to stdout, or with TESTNAME, the output of
+//	"go test -run ^TESTNAME$ FLAG...", which must pass. KEY=VALUE arguments
+//	and "nondeterministic" are as for testfail, and the program runs in the
+//	same sandbox, with the same limits; as for testfail, commands that only
+//	read the deck don't run it. There is no "!output" for this form.
+//
+//	The output is cached in the -output-cache directory, and reused until
+//	a file in the package's directory changes. For builds that must not run
+//	code, like those without a network, -no-exec uses only cached output.
+//
+// compare / !compare
+//
+//	Show the two output sections between these directives side by side,
+//	with the lines of each that aren't in the other marked, as for the
+//	output of a program with and without -race. The outputs can be output
+//	blocks, output auto or testfail directives, and their conditions are
+//	shown above them.
+//
+// testfail TESTNAME [FLAG | KEY=VALUE ...]
+//
+//	Run "go test -run ^TESTNAME$ FLAG..." in the directory of the source file
+//	when the deck is built, and render its output as an output block. The
+//	test is not run by commands that only read the deck, like -lint or
+//	grep, nor for slides left out of the build. KEY=VALUE arguments set
+//	environment variables for the run, like GOMAXPROCS=2 or GOGC=off; use
+//	GOTOOLCHAIN=go1.24.0 to pin the Go version. The flags and settings are
+//	shown with the output, so readers know how it was produced.
+//
+//	With -check-determinism N, the test is run N times, and it is an error
+//	if its output is not the same each time. Fix the test, or add the word
+//	"nondeterministic" to the directive to mark the output as varying.
+//
+//	The test is expected to fail, as when demonstrating a data race with
+//	-race; it is an error if it passes. The output is truncated to
+//	-transcript-lines lines, and paths and timings are scrubbed so the
+//	slide doesn't change between builds.
+//	The test runs in the sandbox selected by -sandbox: "local" runs it
+//	directly, and "docker" and "gvisor" run it in a container without
+//	network access, using -sandbox-image. The container reads modules
+//	from the host's module cache, or the module's vendor directory, and
+//	can't download them: a pinned GOTOOLCHAIN must be in the module cache
+//	(run "GOTOOLCHAIN=go1.24.0 go version" to put it there) or be the
+//	version the image's tag names.
+//	A test that runs longer than -exec-timeout or writes more than
+//	-exec-output bytes is stopped, and its partial output is shown with a
+//	note saying why. At most -exec-parallel tests run at once.
 //
 // question / answer / !question
 //
 //	Define a question-and-answer section. "question" starts the question text,
 //	"answer" ends the question and starts the answer, and "!question" closes
-//	the whole block. The answer is hidden behind a <details> toggle. Both
-//	question and answer content are rendered as markdown. Code blocks can be
-//	nested inside the answer section.
+//	the whole block. The answer is hidden behind a <details> toggle, which
+//	the presenter can open with 'A'. Both question and answer content are
+//	rendered as markdown. Code blocks can be nested inside the answer
+//	section. A question with no text of its own, asked by the code before
+//	it, is revealed by clicking the -answer-summary text.
+//
+// hint [TEXT]
+//
+//	Between a question and its answer, give a hint. Each hint is revealed
+//	in turn, inside the one before it, and the answer only after the last
+//	hint, so students can ask for as much help as they need. Like question
+//	and answer, hint has an inline form.
 //
 // html CONTENT
 //
-//	Emit CONTENT as raw HTML in the slide.
+//	Emit CONTENT as raw HTML in the slide. An element can be opened by one
+//	html directive and closed by a later one, but the tags of a slide's
+//	HTML must balance, so an unclosed <div> is an error rather than the
+//	end of every slide after it. -lint reports classes that the HTML uses
+//	and styles.css, in the -static directory, and the -theme stylesheet
+//	don't define.
+//
+// todo TEXT
+//
+//	Record a reminder that the slide is unfinished. TODOs are never rendered.
+//	The -todos flag lists them (along with any section that mentions "TODO")
+//	instead of building the deck, and -forbid-todo makes them fatal.
 //
 // line CONTENT
 //
 //	Emit CONTENT directly to the output followed by <br/>. The CONTENT is
 //	processed as markdown (just like the "text" directive), but the outer
 //	<p>...</p> tags are stripped so it can appear as a single line.
-
-// image FILENAME (or img FILENAME)
+//
+// subheading TEXT (or heading2 TEXT)
+//
+//	Emit TEXT as a heading smaller than the slide's, to divide the slide
+//	into sections. TEXT is processed as markdown, like the line directive.
+//
+// image FILENAME [ALT] (or img FILENAME [ALT])
 //
 //	Emit an <img> tag with FILENAME as the source. FILENAME is interpreted
-//	relative to the directory containing the current source file.
+//	relative to the directory containing the current source file. ALT, the
+//	rest of the line, describes the image for those who can't see it; an
+//	image without it has its file name for alt text, and with -require-alt
+//	is an error.
+//
+// caption TEXT
+//
+//	Put the markdown TEXT under the image before it, as its caption. The
+//	image and caption become a <figure>.
+//
+// include FILENAME [ADDRESS]
+//
+//	Insert part of another file, interpreted relative to the directory
+//	containing the current source file, so that a slide can show runnable
+//	code that lives elsewhere without a copy that can drift from it. Inside
+//	a section, the lines become part of the section; outside, they are HTML.
+//	ADDRESS selects the lines to include:
+//	  /RE1/ [/RE2/] - From the first line matching RE1 through the next line
+//	                  matching RE2, or through the end of the file.
+//	                  A comma may separate the two.
+//	  N[,M]         - Line N, or lines N through M, counting from 1.
+//	  NAME          - The lines between "// region NAME" and
+//	                  "// !region NAME".
+//	Without ADDRESS, the whole file is included. Region markers are never
+//	included.
+//
+// solution DIR
+//
+//	For the instructor, show a side-by-side diff between each Go file of
+//	the exercise in DIR and the file of the same name in DIR/solution, to
+//	use when going over the exercise. DIR is interpreted relative to the
+//	directory containing the current source file. Like notes, the diffs are
+//	only included in the output when the -notes flag is set.
 //
 // link FILENAME TEXT
 //
@@ -86,7 +311,18 @@
 //
 // em / !em
 //
-//	Inside a code block, these directives bold (emphasize) the enclosed lines.
+//	Inside a code block, these directives emphasize the enclosed lines.
+//	All forms of emphasis render as <span class="em">; the -em-element
+//	and -em-class flags change the element and class.
+//
+// em.STYLE / !em.STYLE
+//
+//	Emphasize the enclosed lines in a named style, to tell kinds of lines
+//	apart, like the added and removed lines of an evolving implementation.
+//	The style adds the class em-STYLE. styles.css has em.add for added
+//	lines, em.del for removed ones and em.red for problems; a theme can
+//	define more. The inline form of em takes a style too, as in
+//	"// em.red mu.Lock".
 //
 // em REGEXP,REGEXP,... (inline form)
 //
@@ -97,957 +333,599 @@
 //	entire line is emphasized. The "// em ..." suffix is stripped from the
 //	output. There is no matching "// !em" for this form.
 //
+//	A "// em REGEXP,..." line by itself applies its patterns to the code
+//	line before it, for when a trailing comment would make that line too
+//	long.
+//
+// highlight N[-M] ...
+//
+//	Inside a code block, emphasize whole lines by their numbers, as a
+//	shorter way than em and !em to mark long stretches of code, or several
+//	of them: "// highlight 3,5-7" emphasizes line 3 and lines 5 through 7.
+//	Lines are numbered as the line numbers show them, counting only lines
+//	with code, even when the numbers are hidden with nonumbers. The
+//	directive can go anywhere in the block, and there can be several.
+//
+// callout TEXT (inline form)
+//
+//	Inside a code block, a trailing "// callout TEXT" on a code line puts
+//	TEXT in the margin after the line, with an arrow pointing at it, like
+//	"// callout TOCTOU race here" after the Load of a counter. A
+//	"// callout TEXT" line by itself is for the code line before it. The
+//	directive is stripped from the code, and a line has one callout.
+//
+// exercise
+//
+//	Mark the slide as an exercise. Its heading is labeled with the
+//	exercise's number in the deck, like "Exercise 3: Bank Account". Questions
+//	are numbered the same way, as "Question 7". Numbering runs through the
+//	whole deck, or with -number-by-part, starts again in each part.
+//
+// label NAME
+//
+//	Name the slide, so that text on other slides can link to it with a
+//	Markdown link to "#NAME", like "[as we saw](#waitgroup)". The build
+//	replaces the name with the slide's position in the deck, and fails if
+//	a link names no slide.
+//
+// same-as [FILE]
+//
+//	Check that the code blocks of the slide are the same as those of the
+//	previous slide, or with FILE, of the last slide of FILE, for slides
+//	that show the same code to say something new about it. The build fails
+//	if the code has drifted. Emphasis, steps and trailing space don't
+//	count, so a slide can highlight different lines of the same code.
+//	FILE is interpreted relative to the directory containing the current
+//	source file.
+//
+// hold NAME
+//
+//	Leave the slide out of the deck until NAME is released, so solutions
+//	stay hidden until the exercise period ends. Build with -release NAME
+//	to include it. Decks built with -notes include all held slides. When
+//	serving, the presenter can press 'R' to release slides, now or after a
+//	delay, and viewers' decks reload to show them.
+//
+// poll [CHOICE ...]
+//
+//	Add buttons for viewers of a served deck to vote with, usually after a
+//	question. The presenter's deck shows the votes as a bar chart. The
+//	choices default to A, B, C and D. A slide can have only one poll.
+//
+// quiz / !quiz
+//
+//	Ask a multiple-choice question, like "What does this program print?",
+//	that viewers answer by clicking a choice. A wrong choice is marked
+//	wrong at once, so they can try again; the correct choice is marked
+//	right. Lines beginning "- [ ]" are choices, and lines beginning "- [x]"
+//	are correct choices; the lines before the first choice are the prompt.
+//	All are markdown. Unlike a poll, a quiz doesn't need code2slides
+//	serve.
+//
+// optional [TAG ...]
+//
+//	Mark the slide as optional. During a talk, pressing 'O' makes the arrow
+//	keys skip optional slides, for when time is short; Shift-O chooses the
+//	TAGs whose slides are skipped.
+//
+// time DURATION
+//
+//	Plan to spend DURATION on the slide, like "3m" or "1m30s", to pace a
+//	long workshop. The presenter window of a deck built with -presenter
+//	shows the slide's time, and how much of the deck's planned time should
+//	have passed when it began. The -timing flag reports the planned time
+//	of each file and part, and of the whole deck, instead of building it.
+//
+// if [!]TAG / !if
+//
+//	Keep the enclosed lines only when TAG is one of the tags given by
+//	-tags, or with "!TAG", only when it isn't. The lines can be directives,
+//	whole slides or lines of code, so one file can hold the beginner and
+//	advanced variants of a slide. Conditions can be nested. A comment that
+//	begins with "if" followed by more than one word is not a directive.
+//
+// cols [WIDTH ...] / nextcol / !cols
+//
+//	Lay out the enclosed sections side by side: cols starts the first
+//	column, each nextcol starts another, and !cols ends the layout. The
+//	markers must balance, and columns can't be nested or span slides.
+//	Columns share the width of the slide equally, unless cols gives a
+//	relative width for each of them: "cols 60 40" makes the first column
+//	half again as wide as the second.
+//
+// livetest [FLAG | KEY=VALUE ...]
+//
+//	Add a pane with a button that runs "go test FLAG..." in the directory
+//	of the source file and streams the output into the pane, colored by
+//	result. KEY=VALUE arguments set environment variables, as for testfail.
+//	The pane only works when the deck is shown by "code2slides serve", which
+//	runs only the tests named by livetest directives in the deck.
+//
+// rename OLD=NEW ...
+//
+//	Display identifier OLD as NEW in every code block in the file. Identifiers
+//	that aren't renamed have any underscore suffix removed, so "foo_2" is
+//	displayed as "foo". Use rename for other variants, like "nc1=nc". It is
+//	an error if a rename makes two different identifiers in the same code
+//	block look the same. -lint reports a function or method whose name or
+//	receiver is renamed, or that is on a same-as slide, if it is displayed
+//	with a different signature than on an earlier slide.
+//
+// step
+//
+//	Inside a code block, begin a step: the lines after it are hidden until
+//	the presenter moves forward, which shows the next step before going on
+//	to the next slide. Moving back hides the last step shown. So one slide
+//	can build up code, like a struct, then the goroutines that use it, then
+//	the Wait, where otherwise it would take a slide for each stage. The
+//	hidden lines keep their place, so the code doesn't move as it appears.
+//
+// goroutine NAME / !goroutine
+//
+//	Inside a code block, mark the enclosed lines as running on the goroutine
+//	NAME, like G1 or main, with NAME in a colored gutter beside them. Marks
+//	can nest, as when a function started with "go" is written in the middle
+//	of the code that starts it: its body runs elsewhere. A mark that isn't
+//	closed lasts to the end of the block.
+//
+// timeline / !timeline
+//
+//	Walk through an interleaving, like how a goroutine comes to leak, one
+//	step at a time. Each line that begins with a number and a period, like
+//	"1.", or with "-", starts a step, rendered as markdown; other lines
+//	continue the step before them. The steps are numbered down a timeline
+//	and revealed one per press of the right arrow, after any steps of the
+//	slide's code. A timeline can be inside an answer, where its steps are
+//	revealed once the answer is open.
+//
+// stepper [VAR=VALUE ...] / !stepper
+//
+//	Step through an interleaving of a tiny program, like the increments of
+//	two goroutines that race, with buttons to go forward and back. Each
+//	line names a goroutine and the statement it executes next, like
+//	"G1 R0 = c". The widget shows each goroutine's statements, marking the
+//	one just executed, with the values of its registers (R0, R1 and so
+//	on), and the values of the shared variables, which start at zero or at
+//	the given VALUEs. See interleave.go for the statements.
+//
 // elide / !elide
 //
 //	Inside a code block, lines between these directives are replaced with
-//	"// ..." in the output. The indentation of the elide marker is preserved.
+//	a vertical ellipsis, like a "// ..." line, at the indentation of the
+//	elide marker.
+//
+// ...
+//
+//	Inside a code block, a "// ..." line is shown as a vertical ellipsis,
+//	at the line's indentation, to mark code left out of the slide without
+//	showing a comment. Unlike elide, it replaces no lines of the source.
+//
+// omit / !omit
+//
+//	Inside a code block, lines between these directives are left out of the
+//	output entirely, leaving no marker. Use it to hide boilerplate like
+//	imports or error handling that the code needs to compile.
+//
+// # Documentation links
+//
+// With "-doc-links sync,time,sync/atomic", references to the exported names
+// of those packages, like sync.WaitGroup or atomic.Int64.Add, link to their
+// documentation on pkg.go.dev. References are found in code blocks, other
+// than runnable ones, and in the code spans of text, like `time.After`.
+// Each deck names the packages it wants linked, so a deck about a package
+// of its own can leave its names alone.
+//
+// # Channel and mutex operations
+//
+// With -chan-ops, channel operations in code blocks, like sends, receives,
+// close and make(chan), are styled with the chanop class, so a deck about
+// channels can show where goroutines communicate without emphasizing each
+// operation by hand.
+//
+// With -mutex-ops, calls of Lock, Unlock, RLock and RUnlock are styled
+// with the mutexop class, and fields under a "mutex hat" get a subtle
+// background: by convention, the fields that follow a mutex in a struct,
+// up to a blank line, are the ones it guards.
+//
+// # Long code
+//
+// Code too long for its slide is cut off, unless its code directive says
+// what to do with it: fit, scroll or split. The -overflow flag chooses one
+// of them for code sections that don't. Split code continues after
+// -split-lines lines (20, fewer for large code and more for small), at a
+// blank line if there is one near there, on slides that repeat the
+// heading with "(cont.)" after it. The numbering of the lines continues
+// too, and the sections after the code follow it on the last slide. Code
+// in columns, a div or an answer isn't split, nor is it in decks built
+// with -scroll, which have room for it.
+//
+// # Refresher decks
+//
+// "-since REF" builds a deck of only the slides that are new or have
+// changed since the git commit REF, like the tag of last year's edition of
+// the workshop, for a session on what's new. Each file is compared with its
+// version at REF slide by slide, by heading, so editing one slide leaves
+// the others of its file out. Title slides stay, and a contents slide
+// lists the rest. Unlike -changes-since, -since needs no change feed.
+//
+// # Dry runs
+//
+// With -dry-run, the build then checks the deck as it will be presented:
+// the files it refers to, like scripts and images, must exist relative to
+// the deck, or for static/ files, to the -static directory, as with
+// serve. If Chrome is installed (or named by $CHROME), a headless
+// Chrome presses the keys of a talk from the first slide to the last and
+// back, through every step, answer and hint, and any JavaScript error
+// fails the build.
+//
+// # Analytics
+//
+// Each slide's article has a data-slide attribute naming it by its label,
+// or by its file and place in the file, like "20-waitgroup.go:3", and
+// exercises have data-exercise with their number. The parts of a slide
+// have data-kind attributes with their section kinds, like "code" or
+// "question". With "-analytics URL", the deck POSTs a JSON event to URL
+// for each slide it shows, with how long it was shown, and for each answer
+// or hint revealed, so that slides that confuse can be found across many
+// runs of a workshop. See static/analytics.js for the events.
+//
+// # Unused imports
+//
+// Editing a slide can remove the last use of an imported package, which
+// breaks the build of the slides' package. Building a deck fails if its Go
+// files have unused imports; "code2slides -fix-imports <file>..." removes
+// them.
+//
+// # Freshness
+//
+// A deck written for one Go release can go stale in the next: an API on a
+// slide is deprecated, or time.After behaves differently than the slide
+// says. "code2slides -fresh <file>..." type-checks the deck's Go files and
+// reports each use in code, and each mention in text like `time.After`, of
+// a standard API that the Go release running it has deprecated, or whose
+// behavior changed after the go line of the slides' go.mod, with the slide
+// it is on. The changes come from the release's own metadata: the api
+// directory of $GOROOT for deprecations, and the GODEBUG history in
+// $GOROOT/doc/godebug.md for changes in behavior.
+//
+// # Finding flaky tests
+//
+// "code2slides flaky [flags] <package>..." runs the packages' tests -count
+// times for each of several GOMAXPROCS values (see -procs), with the race
+// detector unless -race=false, and reports how often each test failed.
+// Timing-dependent tests that pass on a fast laptop may fail on a slow
+// machine; this quantifies how often.
+//
+// # Headings files
+//
+// A file's slides before its first heading directive are headed with the
+// file's name, or with -heading-fallback name, with a heading made from
+// it: "Worker pool" for "20-worker-pool.go". For slides whose sources
+// can't be edited, like those generated from a third-party codebase,
+// "-headings headings.yaml" gives headings and subtitles from outside:
+//
+//	20-worker.go: The worker pool
+//	30-shutdown.go:
+//	  subtitle: Draining the queue
+//	  slides:
+//	    - Shutting down
+//	    - ~
+//	    - Waiting for workers
+//
+// The headings file's entries override the sources' headings; "~" keeps
+// one. Only this subset of YAML is understood.
+//
+// # Shared slides
+//
+// Slides used by several decks, such as an introduction to the race
+// detector, can live in a library directory that the decks' manifests name
+// with "library DIR" and draw from with "shared PATTERN" (see -manifest).
+// "code2slides shared <manifest>..." reports which decks use each file in
+// their libraries, and which files no deck uses.
+//
+// # Code from other modules
+//
+// A manifest line like "golang.org/x/sync@v0.10.0//errgroup/*.go" adds the
+// files of a version of another module to the deck, so it can present code
+// from a real project without a vendored copy that goes stale. The pattern
+// after "//" is relative to the module's root. The go command downloads the
+// module through the module proxy (see GOPROXY) into the module cache,
+// where the files are read. Since they can't be edited, a headings file
+// (see -headings) is the way to give their slides headings.
+//
+// # Profiles
+//
+// One set of slides often makes several decks: a 45-minute conference talk
+// and a full-day workshop, say. A manifest can end with profiles that
+// describe them, each with the files to skip, the build flags to use and
+// a theme, a stylesheet loaded after styles.css:
+//
+//	profile conference-45min
+//	flags -o conference.slides -tags short -title "Concurrency in 45 minutes"
+//	theme static/themes/conference.css
+//	skip 50-actors.go
+//
+// "code2slides -manifest m.txt -profile conference-45min" builds that
+// variant. Flags on the command line override the profile's.
+//
+// # Transforms
+//
+// "-transform COMMAND" runs the program COMMAND, split into words, on the
+// slides of each file after they are scanned, to rewrite their sections
+// before they are rendered: to add a company's compliance note to every
+// module, say, without a fork of code2slides. The program reads the file's
+// slides as JSON on its standard input and writes them back, changed, on
+// its standard output:
+//
+//	{"file": "mutexes.go", "slides": [{"heading": "Mutexes", "sections": [
+//		{"kind": "code", "content": "var mu sync.Mutex", "attrs": ["small"], "line": 12},
+//		{"kind": "note", "content": "Ask who has used one."}]}]}
+//
+// Sections have the kinds of the directives that make them, and the
+// program can change, add, remove and reorder them, but not the slides.
+// The flag can be repeated, to run several programs in order. Serve takes
+// it too.
+//
+// # New decks
+//
+// "code2slides new-module [-dir slides] [-title T] <name>" starts the slides
+// for a new deck in dir/name, laid out like the existing decks: a doc.go,
+// slide files numbered by tens beginning with the title slide, a test file
+// for the code on the slides, and a manifest for building the deck.
+//
+// "code2slides new-slide [-heading H] <module dir> <name>" adds the slide
+// file NN-name.go, numbered ten past the module's last slide file, and adds
+// it to the module's manifest.txt if that doesn't already include it. With
+// -renumber, it first renumbers the module's slide files by tens from 00,
+// in order, with "git mv" so their history follows them, and updates the
+// manifest to match. The name can be omitted with -renumber.
+//
+// "code2slides dups [-threshold f] [-merge] <canonical dir> <dir>..." finds
+// slide files in the dirs that duplicate files in the canonical dir, after
+// copies in separate trees have drifted apart. Files are compared line by
+// line, ignoring blank lines, indentation and package clauses, and each is
+// reported with its best match at least -threshold alike. With -merge,
+// files that are the same as a canonical file are removed with "git rm",
+// files with no match are moved with "git mv" to the end of the canonical
+// sequence, and files that are only alike are listed to merge by hand.
+//
+// # Searching
+//
+// "code2slides grep [-i] [-kind K] [-manifest file] <pattern> [<file or dir>...]"
+// prints the slide headings and the lines of sections that match the
+// regular expression, as parsed, so it finds "time.After" in code even
+// where it is emphasized, and says which slide and kind of section each
+// match is in. Directories are searched for Go files, other than tests.
+// With -kind, it searches only sections of that kind, like code or note,
+// or only headings with "-kind heading". It exits with status 1 if
+// nothing matches.
+//
+// "code2slides rewrite -rule R [-w] <file or dir>..." makes a mechanical
+// change to the code in slide sources, keeping directive comments where
+// they are. Rule wg-go changes "wg.Add(1)" followed by a goroutine that
+// begins with "defer wg.Done()" to a call of wg.Go, and wg-add changes it
+// back. Without -w, it only lists what it would change. Code with a comment
+// in the way is skipped and listed, to be changed by hand.
+//
+// "code2slides fmt [-l] [-w] <file or dir>..." formats the directive
+// comments of slide sources, as gofmt formats Go: one space after "//" and
+// between the options of a directive, code attributes in the order of the
+// list above and optional tags sorted, at most one blank line between
+// lines outside sections, and one before each slide. It leaves code and
+// the contents of sections alone. With -l it lists the files whose
+// formatting differs, and with -w it rewrites them; otherwise it prints
+// the formatted sources.
+//
+// # Exercises
+//
+// "code2slides workspace [-o dir] [-go version] <exercises dir>" writes a
+// copy of the exercises for students, without their solutions. Each exercise
+// is a module of a go.work workspace, so one that doesn't compile doesn't
+// stop the others from building.
+//
+// "code2slides attendee [-o dir] [-exercises dir] [-static dir] <deck>..."
+// writes a repo for the students of a cohort, to push where they can
+// clone it: the workspace of exercises, a module of the runnable examples
+// of the decks (the code with the play attribute), and the decks as
+// handouts, built with -scroll and without notes or solutions.
+// Each deck is a manifest, if its name ends in .txt, or a slide file or
+// directory.
+//
+// # Grading
+//
+// "code2slides grader [-o dir] [-image name] [-go version] <exercises dir>"
+// writes a grader, so partners can grade students' exercises on their own
+// machines: the tests of each exercise's solution, in modules like those
+// of the workspace, and the build context of a container image holding
+// the Go toolchain given by -go (by default, the one running code2slides).
+// With -image, it also builds the image with docker.
+//
+// "code2slides grade [flags] <grader dir> <submission dir>" runs the tests
+// of each exercise, with the race detector, against the student's code for
+// it in the submission, ignoring the student's own tests. It runs them in
+// the -sandbox, under the -exec limits (though -exec-timeout defaults to
+// 10m), and prints PASS or FAIL for each exercise, with the output of
+// failing tests. To grade with the pinned toolchain and no network access,
+// grade the exercises in the current directory with
+//
+//	code2slides grade -sandbox docker -sandbox-image NAME grader .
+//
+// # Serving
+//
+// "code2slides serve [flags] <file>..." serves the deck over HTTP, rebuilding
+// it when a source file changes. It serves static/ (see -static) and the
+// files in the current directory that the deck refers to, like images and
+// the targets of links, but no others. Use -auth user:password for basic
+// authentication, or -token T to require T, given once as ?token=T and
+// remembered in a cookie. With -cert and -key, it serves HTTPS.
+//
+// The server exposes Prometheus metrics at /metrics: the number of connected
+// viewers, the slides they are on, how many voted in each poll, and how
+// long rebuilds take.
+// With -presenter-key K, the browser that opens the deck with ?presenter=K
+// shares its laser pointer or spotlight position with all viewers, and
+// can release held slides (see the hold directive). The presenter's
+// drawings on the slides (see the deck's help text) are also shared
+// through the server, and saved to the -annotations file if one is given;
+// other viewers' drawings stay in their browser, and decks not served
+// this way save drawings in the browser's local storage.
+// Code with the play attribute runs only with -play, which runs it with
+// "go run" in the -sandbox, under the same -exec limits as commands in
+// the deck.
+// On interrupt, it ends viewers' event streams and waits up to -drain
+// for in-flight requests before exiting.
 package main
 
 import (
-	"bufio"
-	"errors"
+	"context"
 	"flag"
 	"fmt"
-	"html"
-	"io"
 	"os"
-	"path/filepath"
-	"regexp"
-	"slices"
 	"strings"
 
-	"rsc.io/markdown"
-)
-
-type Slide struct {
-	isTitle  bool
-	heading  string // or main title
-	sections []section
-}
-
-func (s *Slide) dump() {
-	fmt.Printf("----------------\n")
-	fmt.Printf("# %s\n", s.heading)
-	for _, sec := range s.sections {
-		sec.dump()
-	}
-}
-
-type sectionKind int
-
-const (
-	sectionUndefined sectionKind = iota
-	sectionNote
-	sectionCode
-	sectionQuestion
-	sectionAnswer
-	sectionText
-	sectionHTML
-	sectionOutput
-	sectionSubtitle
-	sectionLine
-)
-
-func (k sectionKind) String() string {
-	switch k {
-	case sectionNote:
-		return "note"
-	case sectionCode:
-		return "code"
-	case sectionQuestion:
-		return "question"
-	case sectionAnswer:
-		return "answer"
-	case sectionText:
-		return "text"
-	case sectionHTML:
-		return "html"
-	case sectionOutput:
-		return "output"
-	case sectionSubtitle:
-		return "subtitle"
-	case sectionLine:
-		return "line"
-	default:
-		return "unknown"
-	}
-}
-
-var simpleOpens = map[string]sectionKind{
-	"note":     sectionNote,
-	"code":     sectionCode,
-	"output":   sectionOutput,
-	"subtitle": sectionSubtitle,
-}
-
-var simpleCloses = map[string]sectionKind{
-	"note":     sectionNote,
-	"text":     sectionText,
-	"output":   sectionOutput,
-	"subtitle": sectionSubtitle,
-}
-
-type section struct {
-	kind     sectionKind
-	options  []string
-	content  string
-	inAnswer bool // true if this section is inside an answer (for code in answer)
-}
-
-func (s section) dump() {
-	fmt.Printf("-- %s --\n", s.kind)
-	fmt.Printf("%s", s.content)
-	fmt.Printf("^^^^\n")
-}
-
-func (s section) equal(other section) bool {
-	return s.kind == other.kind &&
-		s.content == other.content &&
-		slices.Equal(s.options, other.options) &&
-		s.inAnswer == other.inAnswer
-}
-
-var (
-	includeNotes bool
-	debug        bool
+	"github.com/jba/concurrency-workshop/code2slides"
 )
 
 func main() {
-	outputFile := flag.String("o", "output.slides", "output file name")
-	title := flag.String("title", "Title", "HTML page title")
-	flag.BoolVar(&includeNotes, "notes", false, "include notes and answers in output")
-	flag.BoolVar(&debug, "debug", false, "debug output")
-	flag.Parse()
-
-	if flag.NArg() < 1 {
-		fmt.Fprintln(os.Stderr, "usage: code2slides [-o output.html] [-notes] <file>...")
-		os.Exit(1)
-	}
-
-	if err := run(*outputFile, *title, flag.Args()); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
-	}
-}
-
-type indentWriter struct {
-	w     io.Writer
-	level int
-	err   error
-}
-
-func (w *indentWriter) indent() {
-	for range w.level {
-		io.WriteString(w, "  ")
-	}
-}
-
-func (w *indentWriter) open(s string) {
-	w.indent()
-	io.WriteString(w, s)
-	fmt.Fprintln(w)
-	w.level++
-}
-
-func (w *indentWriter) close(s string) {
-	w.level--
-	w.indent()
-	io.WriteString(w, s)
-	fmt.Fprintln(w)
-}
-
-func (w *indentWriter) linef(format string, args ...any) {
-	w.indent()
-	fmt.Fprintf(w, format, args...)
-	fmt.Fprintln(w)
-}
-
-func (w *indentWriter) lines(lines string) {
-	for line := range strings.Lines(lines) {
-		w.indent()
-		fmt.Fprint(w, line)
-	}
-}
-
-func (w *indentWriter) Write(data []byte) (int, error) {
-	if w.err != nil {
-		return 0, w.err
-	}
-	n, err := w.w.Write(data)
-	if err != nil {
-		w.err = err
-	}
-	return n, err
-}
-
-func (w *indentWriter) Err() error { return w.err }
-
-func run(outputFile, title string, files []string) (err error) {
-	// First pass: collect all slides from all files
-	type fileSlides struct {
-		filename string
-		slides   []*Slide
-	}
-	var allFiles []fileSlides
-	totalSlides := 0
-	for _, filename := range files {
-		slides, err := scanFile(filename)
-		if err != nil {
-			return fmt.Errorf("error processing %s: %w", filename, err)
-		}
-		allFiles = append(allFiles, fileSlides{filename, slides})
-		totalSlides += len(slides)
-	}
-
-	outFile, err := os.Create(outputFile)
-	if err != nil {
-		return fmt.Errorf("error creating output file: %w", err)
-	}
-	defer func() { err = errors.Join(err, outFile.Close()) }()
-
-	iw := &indentWriter{w: outFile}
-
-	fmt.Fprintf(iw, top, title)
-
-	pageNum := 1
-	for _, fs := range allFiles {
-		iw.linef("\n<!-- %s -->", fs.filename)
-		for _, slide := range fs.slides {
-			if debug {
-				slide.dump()
+	if len(os.Args) > 1 {
+		if cmd := code2slides.Command(os.Args[1]); cmd != nil {
+			if err := cmd(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
 			}
-			isLast := pageNum == totalSlides
-			writeSlideHTML(iw, slide, pageNum, isLast)
-			pageNum++
+			return
 		}
 	}
 
-	fmt.Fprintln(iw, bottom)
-
-	return iw.Err()
-}
-
-func scanFile(filename string) (_ []*Slide, err error) {
-	content, err := os.ReadFile(filename)
-	if err != nil {
-		return nil, err
-	}
-
-	slide := &Slide{
-		heading: filepath.Base(filename),
-	}
-	var slides []*Slide
-
-	scanner := bufio.NewScanner(strings.NewReader(string(content)))
-	var (
-		current    strings.Builder
-		kind       sectionKind
-		options    []string
-		divClass   string
-		eliding    bool
-		parentKind sectionKind // for nested code in answer
-	)
-	lineNum := 0
-
-	defer func() {
-		if err != nil {
-			err = fmt.Errorf("%s:%d: %v", filename, lineNum, err)
-		}
-	}()
-
-	add := func(k sectionKind, opts []string, c string, inAnswer bool) {
-		slide.sections = append(slide.sections, section{
-			kind:     k,
-			options:  opts,
-			content:  c,
-			inAnswer: inAnswer,
-		})
-	}
-
-	addCurrent := func(k sectionKind, opts []string, inAnswer bool) {
-		if current.Len() > 0 {
-			add(k, opts, current.String(), inAnswer)
-			current.Reset()
-		}
-	}
-
-	for scanner.Scan() {
-		lineNum++
-		line := scanner.Text()
-		first, rest, _ := splitFirstWord(line)
-		matchFirst := true
-		if sec, ok := simpleOpens[first]; ok {
-			// Allow code inside answer
-			if kind == sectionAnswer && sec == sectionCode {
-				addCurrent(sectionAnswer, nil, false)
-				parentKind = sectionAnswer
-				kind = sectionCode
-				options = strings.Fields(rest)
-				if err := validateCodeOptions(options); err != nil {
-					return nil, err
-				}
-				continue
-			}
-			if kind != sectionUndefined {
-				return nil, fmt.Errorf("%s inside %s", sec, kind)
-			}
-			kind = sec
-			options = strings.Fields(rest)
-			if kind == sectionCode {
-				if err := validateCodeOptions(options); err != nil {
-					return nil, err
-				}
-			}
-			continue
-		}
-		if strings.HasPrefix(first, "!") {
-			if sec, ok := simpleCloses[first[1:]]; ok {
-				if kind != sec {
-					return nil, fmt.Errorf("%s without matching %s", first, first[1:])
-				}
-				addCurrent(sec, options, false)
-				kind = sectionUndefined
-				options = nil
-				continue
-			}
+	var cfg code2slides.Config
+	outputFile := flag.String("o", "output.slides", "output file name")
+	flag.StringVar(&cfg.Title, "title", "Title", "HTML page title")
+	flag.BoolVar(&cfg.Notes, "notes", false, "include notes and answers in output")
+	flag.BoolVar(&cfg.Presenter, "presenter", false, "show notes in a presenter window, opened with 'N'")
+	flag.StringVar(&cfg.Analytics, "analytics", "", "report slide viewing times and revealed answers to `URL`")
+	flag.StringVar(&cfg.NotesFile, "notes-file", "", "also write the notes of each slide to `file`")
+	flag.Func("release", "include the slides held under the comma-separated `names`", listFlag(&cfg.Release))
+	flag.Func("tags", "keep the lines in if directives for the comma-separated `tags`", listFlag(&cfg.Tags))
+	flag.BoolVar(&cfg.NumberByPart, "number-by-part", false, "number exercises and questions from 1 in each part")
+	flag.BoolVar(&cfg.TOC, "toc", false, "add a table of contents after the title slide, unless the deck has a toc directive")
+	flag.StringVar(&cfg.Headings, "headings", "", "take headings and subtitles for slide files from the headings `file`")
+	flag.StringVar(&cfg.HeadingFallback, "heading-fallback", "file", "head a file's first slide, if it has no heading, with its `file` name, or a name made from it")
+	flag.StringVar(&cfg.Overflow, "overflow", "", "`fit`, scroll or split code too long for its slide, unless its code directive says")
+	flag.IntVar(&cfg.SplitLines, "split-lines", 20, "split code onto the next slide after `n` lines")
+	flag.Func("transform", "rewrite the slides of each file with the `command`, as JSON on its stdin and stdout (repeatable)", func(s string) error {
+		if strings.TrimSpace(s) == "" {
+			return fmt.Errorf("empty transform command")
 		}
+		cfg.Transforms = append(cfg.Transforms, s)
+		return nil
+	})
+	flag.StringVar(&cfg.Theme, "theme", "", "style the deck with the stylesheet at `URL`, relative to the deck, after styles.css")
+	flag.StringVar(&cfg.AnswerSummary, "answer-summary", "Answer", "show `text` to reveal an answer that follows hints or has no question text")
+	flag.Func("doc-links", "link references to the comma-separated `packages` to their documentation", listFlag(&cfg.DocLinks))
+	flag.BoolVar(&cfg.ChanOps, "chan-ops", false, "style channel operations in code")
+	flag.BoolVar(&cfg.MutexOps, "mutex-ops", false, "style mutex operations and the fields that mutexes guard in code")
+	flag.BoolVar(&cfg.Debug, "debug", false, "debug output")
+	flag.BoolVar(&cfg.Offline, "offline", false, "vendor external assets so the deck needs no network")
+	flag.BoolVar(&cfg.Scroll, "scroll", false, "render slides as one scrolling page, without slide navigation")
+	flag.StringVar(&cfg.Feed, "feed", "", "update a JSON feed of changed slides in `file`")
+	flag.DurationVar(&cfg.FeedSince, "feed-since", 0, "with -feed, only list changes made within this `duration`")
+	flag.StringVar(&cfg.Version, "version", "", "with -feed, save a snapshot of the deck as `version`")
+	flag.StringVar(&cfg.ChangesSince, "changes-since", "", "with -feed, add a slide listing what changed since `version`")
+	flag.StringVar(&cfg.Since, "since", "", "build only the slides that are new or changed since the git `ref`")
+	checkOffline := flag.Bool("check-offline", false, "fail if the deck, or the static scripts and stylesheets it loads, would make network requests")
+	dryRun := flag.Bool("dry-run", false, "walk the built deck's slides, steps and answers, failing on missing files or JavaScript errors")
+	flag.StringVar(&cfg.Static, "static", "", "with -dry-run or -lint, find the deck's static/ files in `dir`, as serve does")
+	flag.StringVar(&cfg.Footer, "footer", "", "put the license or attribution `markdown` at the foot of every slide")
+	flag.StringVar(&cfg.Comment, "comment", "", "directives follow line comments beginning with `prefix`, in every file")
+	flag.StringVar(&cfg.EmElement, "em-element", "span", "HTML element for emphasized code")
+	flag.StringVar(&cfg.EmClass, "em-class", "em", "CSS class for emphasized code (may be empty)")
+	flag.IntVar(&cfg.TranscriptLines, "transcript-lines", 30, "maximum lines of testfail output")
+	flag.IntVar(&cfg.CheckDeterminism, "check-determinism", 0, "run each testfail `n` times and fail if its output varies")
+	cfg.SandboxFlags(flag.CommandLine)
+	flag.BoolVar(&cfg.ForbidTodo, "forbid-todo", false, "fail if the deck contains TODOs")
+	flag.BoolVar(&cfg.RequireAlt, "require-alt", false, "fail if an image has no alt text")
+	todos := flag.Bool("todos", false, "list the deck's TODOs instead of building it")
+	timing := flag.Bool("timing", false, "report the planned time of each file, part and the deck instead of building it")
+	lint := flag.Bool("lint", false, "check the deck for problems instead of building it")
+	fixImports := flag.Bool("fix-imports", false, "remove the unused imports of the deck's files instead of building it")
+	flag.IntVar(&cfg.MinAnswer, "min-answer", 10, "with -lint, minimum length of an answer")
+	fresh := flag.Bool("fresh", false, "report the deck's references to standard APIs that are deprecated or changed since its go.mod's Go version, instead of building it")
+	flag.StringVar(&cfg.Manifest, "manifest", "", "read the deck's files and parts from `file`")
+	flag.StringVar(&cfg.Profile, "profile", "", "build the variant of the deck that the manifest's profile `name` describes")
+	flag.Parse()
 
-		switch first {
-		case "title":
-			if rest == "" {
-				return nil, errors.New("missing heading")
-			}
-			if len(slide.sections) > 0 {
-				slides = append(slides, slide)
-				slide = &Slide{}
-			}
-			slide.isTitle = true
-			slide.heading = rest
-
-		case "heading":
-			if rest == "" {
-				return nil, errors.New("missing heading")
-			}
-			if slide.isTitle || len(slide.sections) > 0 {
-				slides = append(slides, slide)
-				slide = &Slide{}
-			}
-			slide.heading = rest
-
-		case "text":
-			if kind != sectionUndefined {
-				return nil, fmt.Errorf("text inside %s", kind)
-			}
-			if rest != "" {
-				add(sectionText, nil, rest+"\n", false)
-			} else {
-				kind = sectionText
-			}
-
-		case "html":
-			add(sectionHTML, nil, rest, false)
-
-		case "line":
-			if kind != sectionUndefined {
-				return nil, fmt.Errorf("line inside %s", kind)
-			}
-			add(sectionLine, nil, rest+"\n", false)
-
-		case "image", "img":
-			if rest == "" {
-				return nil, errors.New("missing image filename")
-			}
-			// Compute path relative to the directory containing the source file
-			imgPath := filepath.Join(filepath.Dir(filename), rest)
-			add(sectionHTML, nil, fmt.Sprintf("<img src=%q alt=%q />", imgPath, rest), false)
-
-		case "include":
-			if rest == "" {
-				return nil, errors.New("missing include filename")
-			}
-			// Handle potential */ at the end if it was a /* ... */ comment
-			rest = strings.TrimSuffix(rest, "*/")
-			rest = strings.TrimSpace(rest)
-
-			// Parse: FILENAME [/RE1/ [/RE2/]]
-			incFile := rest
-			var re1, re2 string
-			if i := strings.Index(rest, " /"); i >= 0 {
-				incFile = strings.TrimSpace(rest[:i])
-				reParts := strings.Split(rest[i:], "/")
-				if len(reParts) > 1 {
-					re1 = strings.TrimSpace(reParts[1])
-				}
-				if len(reParts) > 3 {
-					re2 = strings.TrimSpace(reParts[3])
-				}
-			}
-
-			incPath := filepath.Join(filepath.Dir(filename), incFile)
-			incContent, err := os.ReadFile(incPath)
-			if err != nil {
-				return nil, fmt.Errorf("error reading include file %s: %w", incPath, err)
-			}
-			incContent, err = includeRange(incContent, re1, re2)
-			if err != nil {
-				return nil, fmt.Errorf("error processing include range for %s: %w", incFile, err)
-			}
-
-			if kind == sectionUndefined {
-				add(sectionHTML, nil, string(incContent), false)
-			} else {
-				current.Write(incContent)
-				if len(incContent) > 0 && incContent[len(incContent)-1] != '\n' {
-					current.WriteByte('\n')
-				}
-			}
-
-		case "link":
-			if rest == "" {
-				return nil, errors.New("missing link filename")
-			}
-			linkFile, linkText, _ := strings.Cut(rest, " ")
-			if linkText == "" {
-				return nil, errors.New("missing link text")
-			}
-			// Compute path relative to the directory containing the source file
-			linkPath := filepath.Join(filepath.Dir(filename), linkFile)
-			add(sectionHTML, nil, fmt.Sprintf("<a href=%q>%s</a>", linkPath, html.EscapeString(linkText)), false)
-
-		case "!code":
-			if kind != sectionCode {
-				return nil, errors.New("!code without matching code")
-			}
-			// Trim trailing blank line; mark inAnswer if nested in answer
-			add(kind, options, strings.TrimSuffix(current.String(), "\n"), parentKind == sectionAnswer)
-			current.Reset()
-			if parentKind != sectionUndefined {
-				kind = parentKind
-				parentKind = sectionUndefined
-			} else {
-				kind = sectionUndefined
-			}
-			options = nil
-
-		case "question":
-			if kind != sectionUndefined {
-				return nil, fmt.Errorf("question inside %s", kind)
-			}
-			if rest != "" {
-				add(sectionQuestion, nil, rest+"\n", false)
-			} else {
-				kind = sectionQuestion
-			}
-
-		case "answer":
-			if kind == sectionQuestion {
-				addCurrent(sectionQuestion, nil, false)
-			} else if kind != sectionUndefined {
-				return nil, fmt.Errorf("answer inside %s", kind)
-			}
-			if rest != "" {
-				add(sectionAnswer, nil, rest+"\n", false)
-			} else {
-				kind = sectionAnswer
-			}
-
-		case "!question":
-			if kind != sectionQuestion && kind != sectionAnswer {
-				return nil, errors.New("!question without matching question")
-			}
-			if kind == sectionQuestion {
-				return nil, errors.New("!question without answer")
-			}
-			addCurrent(sectionAnswer, options, false)
-			kind = sectionUndefined
-			options = nil
-
-		case "cols":
-			add(sectionHTML, nil, "<div class=\"flex\"><div>", false)
-
-		case "!cols":
-			add(sectionHTML, nil, "</div></div> <!-- flex -->", false)
-
-		case "nextcol":
-			add(sectionHTML, nil, "</div>", false)
-			add(sectionHTML, nil, "<div> <!-- next col -->", false)
-
-		default:
-			matchFirst = false
+	if cfg.Profile != "" {
+		if cfg.Manifest == "" {
+			fmt.Fprintln(os.Stderr, "-profile requires -manifest")
+			os.Exit(2)
 		}
-		if !matchFirst {
-			if d, c, ok := strings.Cut(first, "."); ok {
-				if d == "div" {
-					add(sectionHTML, nil, fmt.Sprintf("<div class=%q>", c), false)
-					divClass = c
-					continue
-				} else if d == "!div" {
-					if c != divClass {
-						return nil, fmt.Errorf("mismatched div class: start %q, end %q", divClass, c)
-					}
-					add(sectionHTML, nil, fmt.Sprintf("</div> <!-- %s -->", c), false)
-					divClass = ""
-					// fmt.Printf("## !div %q\n", c)
-					continue
-				}
-			}
-			switch line {
-			case "*/":
-				if kind == sectionText {
-					addCurrent(sectionText, options, false)
-					kind = sectionUndefined
-					options = nil
-					continue
-				}
-				fallthrough
-			default:
-				if kind == sectionCode {
-					trimmed := strings.TrimLeft(line, " \t")
-					switch trimmed {
-					case "// em":
-						current.WriteString("\x00em\x00")
-					case "// !em":
-						// Trim trailing blank line before closing em
-						s := strings.TrimSuffix(current.String(), "\n")
-						current.Reset()
-						current.WriteString(s)
-						current.WriteString("\x00/em\x00")
-						current.WriteByte('\n')
-					case "// elide":
-						eliding = true
-					case "// !elide":
-						eliding = false
-						// Preserve indentation from the elide line
-						indent := line[:len(line)-len(trimmed)]
-						current.WriteString(indent)
-						current.WriteString("// ...")
-						current.WriteByte('\n')
-					default:
-						if eliding {
-							break
-						}
-						// Check for inline em: code // em PATTERN,PATTERN,... or code // em (whole line)
-						if before, after, ok := strings.Cut(line, "// em"); ok {
-							suffix := after
-							if suffix == "" || suffix[0] == ' ' || suffix[0] == '\t' {
-								codePart := strings.TrimRight(before, " \t")
-								patternsStr := strings.TrimSpace(suffix)
-								if patternsStr == "" {
-									// No pattern: highlight the whole line
-									current.WriteString("\x00em\x00" + codePart + "\x00/em\x00")
-									current.WriteByte('\n')
-									break
-								}
-								// Split by comma and apply each pattern
-								patterns := strings.Split(patternsStr, ",")
-								marked := codePart
-								for _, pattern := range patterns {
-									pattern = strings.TrimSpace(pattern)
-									if pattern == "" {
-										continue
-									}
-									re, err := regexp.Compile(pattern)
-									if err != nil {
-										return nil, fmt.Errorf("invalid em regexp %q: %w", pattern, err)
-									}
-									marked = re.ReplaceAllStringFunc(marked, func(m string) string {
-										return "\x00em\x00" + m + "\x00/em\x00"
-									})
-								}
-								current.WriteString(marked)
-								current.WriteByte('\n')
-								break
-							}
-						}
-						current.WriteString(line)
-						current.WriteByte('\n')
-					}
-				} else if kind != sectionUndefined {
-					// Strip // prefix if present
-					text := strings.TrimSpace(strings.TrimPrefix(line, "//"))
-					current.WriteString(text)
-					current.WriteByte('\n')
-				}
-			}
+		if err := code2slides.ApplyProfile(flag.CommandLine, os.Args[1:], cfg.Manifest, cfg.Profile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
 	}
-	if err := scanner.Err(); err != nil {
-		return nil, err
+	if (cfg.Version != "" || cfg.ChangesSince != "") && cfg.Feed == "" {
+		fmt.Fprintln(os.Stderr, "-version and -changes-since require -feed")
+		os.Exit(2)
 	}
-	if kind != sectionUndefined {
-		return nil, fmt.Errorf("unclosed %s section", kind)
-	}
-	if divClass != "" {
-		return nil, fmt.Errorf("unclosed div with class %q", divClass)
+	cfg.Files = flag.Args()
+	if cfg.Manifest == "" && len(cfg.Files) < 1 {
+		fmt.Fprintln(os.Stderr, "usage: code2slides [-o output.html] [-notes] [-manifest file] <file>...")
+		os.Exit(1)
 	}
 
-	slides = append(slides, slide)
-	return slides, nil
-}
-
-func includeRange(content []byte, re1, re2 string) ([]byte, error) {
-	if re1 == "" {
-		return content, nil
+	ctx := context.Background()
+	var err error
+	ok := true
+	switch {
+	case *todos:
+		err = code2slides.Todos(ctx, os.Stdout, cfg)
+	case *timing:
+		err = code2slides.Timing(ctx, os.Stdout, cfg)
+	case *fixImports:
+		err = code2slides.FixImports(os.Stdout, cfg)
+	case *fresh:
+		ok, err = code2slides.Fresh(ctx, os.Stdout, cfg)
+	case *lint:
+		ok, err = code2slides.Lint(ctx, os.Stdout, cfg)
+	default:
+		err = build(ctx, cfg, *outputFile, cfg.Offline || *checkOffline, *dryRun)
 	}
-	lines := strings.Split(string(content), "\n")
-	r1, err := regexp.Compile(re1)
 	if err != nil {
-		return nil, fmt.Errorf("invalid re1 %q: %w", re1, err)
-	}
-	start := -1
-	for i, line := range lines {
-		if r1.MatchString(line) {
-			start = i
-			break
-		}
-	}
-	if start == -1 {
-		return nil, fmt.Errorf("regexp %q not found", re1)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-
-	end := len(lines)
-	if re2 != "" {
-		r2, err := regexp.Compile(re2)
-		if err != nil {
-			return nil, fmt.Errorf("invalid re2 %q: %w", re2, err)
-		}
-		found := false
-		for i := start + 1; i < len(lines); i++ {
-			if r2.MatchString(lines[i]) {
-				end = i + 1
-				found = true
-				break
-			}
-		}
-		if !found {
-			return nil, fmt.Errorf("regexp %q not found after match for %q", re2, re1)
-		}
+	if !ok {
+		os.Exit(1)
 	}
-	return []byte(strings.Join(lines[start:end], "\n")), nil
 }
 
-// splitFirst word splits s into a first word and the remaining part.
-// A word is a sequence of nonblank characters.
-// s must be a comment line, whose first nonblank characters are "//" or
-// "/*". If not, the third return value is false.
-func splitFirstWord(s string) (string, string, bool) {
-	s = strings.TrimSpace(s)
-	if !strings.HasPrefix(s, "//") && !strings.HasPrefix(s, "/*") {
-		return "", "", false
-	}
-	s = strings.TrimSpace(s[2:])
-	i := strings.IndexAny(s, " \t")
-	if i < 0 {
-		return s, "", true
+// build builds the deck that cfg describes into outputFile, then checks
+// it as -check-offline and -dry-run say.
+func build(ctx context.Context, cfg code2slides.Config, outputFile string, checkOffline, dryRun bool) error {
+	if err := code2slides.BuildFile(ctx, cfg, outputFile); err != nil {
+		return err
 	}
-	return s[:i], strings.TrimSpace(s[i+1:]), true
-}
-
-func validateCodeOptions(options []string) error {
-	nsizes := 0
-	for _, opt := range options {
-		switch opt {
-		case "small", "smaller", "large":
-			nsizes++
-		case "weak", "bad", "nonumbers", "nonum":
-			// allowed
-		default:
-			return fmt.Errorf("invalid code option %q", opt)
+	if checkOffline {
+		if err := code2slides.CheckOffline(outputFile, cfg.Static); err != nil {
+			return err
 		}
-
 	}
-	if nsizes > 1 {
-		return errors.New("cannot use multiple sizes")
+	if dryRun {
+		return code2slides.DryRun(outputFile, cfg.Static)
 	}
 	return nil
 }
 
-func writeSlideHTML(w *indentWriter, slide *Slide, pageNum int, isLast bool) {
-	// 	for _, st := range slide.subtitles {
-	// 		w.linef("<div class='subtitle-text'>%s<br/></div>", html.EscapeString(st))
-	// 	}
-	// 	w.close("</article>")
-	// 	return
-	// }
-
-	w.linef("\n<!-- slide %d -->", pageNum)
-	eh := html.EscapeString(slide.heading)
-	if slide.isTitle {
-		w.open("<article class='title-slide'>")
-		w.linef("<div class='title-text'>%s</div>", eh)
-	} else {
-		w.open("<article>")
-		w.linef("<h1>%s</h1>", eh)
-	}
-	for i, sec := range slide.sections {
-		// Check if next section continues inside the answer
-		nextInAnswer := i+1 < len(slide.sections) && slide.sections[i+1].inAnswer
-
-		switch sec.kind {
-		case sectionCode:
-			classes := append([]string{"code"}, sec.options...)
-			w.open(fmt.Sprintf("<div class='%s'><pre>", strings.Join(classes, " ")))
-			showLineNumbers := !slices.Contains(sec.options, "nonumbers") && !slices.Contains(sec.options, "nonum")
-			fmt.Fprint(w, renderCode(sec.content, showLineNumbers))
-
-			if sec.inAnswer {
-				// Code inside answer: render without outer div structure
-				w.close("</pre></div>")
-			} else {
-				fmt.Fprintln(w, "</pre>") // don't use close, avoid blank line
-				w.close("</div>")
-			}
-		case sectionText:
-			w.open("<div class='text'>")
-			// Don't use w.lines, because the markdown may render
-			// with a <pre> and then the indentation will show up.
-			fmt.Fprint(w, renderMarkdown(sec.content))
-			w.close("</div>")
-		case sectionQuestion:
-			w.open("<details>")
-			w.open("<summary>")
-			fmt.Fprint(w, stripPara(renderMarkdown(sec.content)))
-			w.close("</summary>")
-		case sectionAnswer:
-			w.open("<div class='answer'>")
-			fmt.Fprint(w, renderMarkdown(sec.content))
-			w.close("</div>")
-			// Only close details if not followed by more answer content
-			if !nextInAnswer {
-				w.close("</details>")
-			}
-		case sectionOutput:
-			// Avoid two consecutive inline-block divs from appearing
-			// next to each other.
-			fmt.Fprintln(w, "<div></div>")
-			w.open("<div class='output'><pre>")
-			fmt.Fprint(w, sec.content)
-			fmt.Fprintln(w, "</pre>") // indenting adds a blank line
-			w.close("</div>")
-		case sectionNote:
-			if includeNotes {
-				fmt.Fprint(w, renderMarkdown(sec.content))
-			}
-		case sectionHTML:
-			w.linef("%s", sec.content)
-		case sectionLine:
-			w.linef("%s<br/>", stripPara(renderMarkdown(sec.content)))
-
-		case sectionSubtitle:
-			w.open("<div class='subtitle-text'>")
-			w.lines(renderMarkdown(sec.content))
-			w.close("</div>")
-		}
-	}
-	if isLast {
-		w.linef("<span class='pagenumber'>%d and last</span>", pageNum)
-	} else {
-		w.linef("<span class='pagenumber'>%d</span>", pageNum)
-	}
-	w.close("</article>")
-}
-
-var identRe = regexp.MustCompile(`[a-zA-Z_][a-zA-Z0-9_]*`)
-
-// stripUnderscoreSuffixes removes underscore suffixes from identifiers.
-// For example, "foo_3x" becomes "foo". Identifiers starting with an
-// underscore (like "_private") are left unchanged.
-func stripUnderscoreSuffixes(s string) string {
-	return identRe.ReplaceAllStringFunc(s, func(m string) string {
-		if i := strings.Index(m, "_"); i > 0 {
-			return m[:i]
-		}
-		return m
-	})
-}
-
-func renderCode(s string, showLineNumbers bool) string {
-	s = strings.ReplaceAll(s, "\t", "    ")
-	lines := strings.Split(s, "\n")
-
-	// Find minimum indentation across all non-empty lines
-	minIndent := -1
-	for _, line := range lines {
-		// Strip em marker prefix to find actual content
-		content := line
-		for strings.HasPrefix(content, "\x00em\x00") || strings.HasPrefix(content, "\x00/em\x00") {
-			if strings.HasPrefix(content, "\x00em\x00") {
-				content = content[len("\x00em\x00"):]
-			} else {
-				content = content[len("\x00/em\x00"):]
-			}
-		}
-		if strings.TrimSpace(content) == "" {
-			continue
-		}
-		indent := len(content) - len(strings.TrimLeft(content, " "))
-		if minIndent < 0 || indent < minIndent {
-			minIndent = indent
-		}
-	}
-	// Remove common indentation
-	if minIndent > 0 {
-		for i, line := range lines {
-			// Extract em marker prefix
-			prefix := ""
-			content := line
-			for strings.HasPrefix(content, "\x00em\x00") || strings.HasPrefix(content, "\x00/em\x00") {
-				if strings.HasPrefix(content, "\x00em\x00") {
-					prefix += "\x00em\x00"
-					content = content[len("\x00em\x00"):]
-				} else {
-					prefix += "\x00/em\x00"
-					content = content[len("\x00/em\x00"):]
-				}
-			}
-			if len(content) >= minIndent {
-				lines[i] = prefix + content[minIndent:]
-			}
-		}
-	}
-
-	var result strings.Builder
-	nonBlankLineNum := 0
-	for i, line := range lines {
-		if i > 0 {
-			result.WriteByte('\n')
-		}
-		line = stripUnderscoreSuffixes(line)
-		// Split off comment if present
-		code, comment := line, ""
-		if idx := strings.Index(line, "//"); idx >= 0 {
-			code, comment = line[:idx], line[idx:]
-		}
-		// Render code portion with definition highlighting
-		// and line numbers.
-		if len(code) > 0 && showLineNumbers {
-			nonBlankLineNum++
-		}
-		lineNum := 0
-		if showLineNumbers {
-			lineNum = nonBlankLineNum
-		}
-		result.WriteString(renderCodeLine(code, lineNum))
-		// Render comment if present
-		if comment != "" {
-			result.WriteString("<comment>")
-			result.WriteString(html.EscapeString(comment))
-			result.WriteString("</comment>")
-		}
-	}
-	out := result.String()
-	out = strings.ReplaceAll(out, "\x00em\x00", "<span class=\"em\">")
-	out = strings.ReplaceAll(out, "\x00/em\x00", "</span>")
-	return out
-}
-
-func renderCodeLine(line string, num int) string {
-	prefix := ""
-	// Non-blank lines begin with a line number.
-	if len(line) > 0 && num > 0 {
-		prefix = fmt.Sprintf("<span class='codenum'>%d</span>", num)
-	}
-
-	// Handle emphasis markers that may prefix the line.
-	if strings.HasPrefix(line, "\x00em\x00") {
-		prefix += "\x00em\x00"
-		line = strings.TrimPrefix(line, "\x00em\x00")
-	} else if strings.HasPrefix(line, "\x00/em\x00") {
-		prefix += "\x00/em\x00"
-		line = strings.TrimPrefix(line, "\x00/em\x00")
-	}
-
-	trimmed := strings.TrimLeft(line, " \t")
-	indent := line[:len(line)-len(trimmed)]
-	if strings.ContainsRune(indent, '\t') {
-		panic(fmt.Sprintf("tab in indent: %q", line))
-	}
-	if len(indent)%4 != 0 {
-		panic(fmt.Sprintf("indent length not a multiple of 4: %q", line))
-	}
-	if indent != "" {
-		// 3 spaces per indent level
-		line = indent[len(indent)/4:] + trimmed
-	}
-
-	// Check for type definition: "type NAME"
-	if name, ok := strings.CutPrefix(trimmed, "type "); ok {
-		// Extract the type name (first word)
-		parts := strings.Fields(name)
-		if len(parts) > 0 {
-			typeName := parts[0]
-			rest := strings.TrimPrefix(name, typeName)
-			return prefix + html.EscapeString(indent) + "type <defn>" + html.EscapeString(typeName) + "</defn>" + html.EscapeString(rest)
-		}
-	}
-
-	// Check for func/method definition: "func NAME(" or "func (receiver) NAME("
-	if rest, ok := strings.CutPrefix(trimmed, "func "); ok {
-		// Check if it's a method (starts with receiver)
-		if strings.HasPrefix(rest, "(") {
-			// Find closing paren of receiver
-			if idx := strings.Index(rest, ") "); idx >= 0 {
-				receiver := rest[:idx+1]
-				afterReceiver := rest[idx+2:]
-				// Extract method name
-				if parenIdx := strings.Index(afterReceiver, "("); parenIdx >= 0 {
-					methodName := afterReceiver[:parenIdx]
-					afterName := afterReceiver[parenIdx:]
-					return prefix + html.EscapeString(indent) + "func " + html.EscapeString(receiver+" ") + "<defn>" + html.EscapeString(methodName) + "</defn>" + html.EscapeString(afterName)
-				}
-			}
-		} else {
-			// Regular function: "func NAME("
-			if parenIdx := strings.Index(rest, "("); parenIdx >= 0 {
-				funcName := rest[:parenIdx]
-				afterName := rest[parenIdx:]
-				return prefix + html.EscapeString(indent) + "func <defn>" + html.EscapeString(funcName) + "</defn>" + html.EscapeString(afterName)
+// listFlag returns the function of a flag that adds the items of a
+// comma-separated list to *list.
+func listFlag(list *[]string) func(string) error {
+	return func(s string) error {
+		for item := range strings.SplitSeq(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*list = append(*list, item)
 			}
 		}
+		return nil
 	}
-
-	return prefix + html.EscapeString(line)
 }
-
-func renderMarkdown(s string) string {
-	var p markdown.Parser
-	p.Table = true
-	doc := p.Parse(s)
-	return markdown.ToHTML(doc)
-}
-
-func stripPara(s string) string {
-	s = strings.TrimSpace(s)
-	s = strings.TrimPrefix(s, "<p>")
-	return strings.TrimSuffix(s, "</p>")
-}
-
-const top = `<!DOCTYPE html>
-<html>
-  <head>
-    <title>%s</title>
-    <meta charset='utf-8'>
-    <link rel='icon' type='image/svg+xml' href='static/favicon.svg'>
-    <script>
-      var notesEnabled =  false ;
-    </script>
-    <script src='static/slides.js'></script>
-  </head>
-
-  <body style='display: none'>
-    <section class='slides'>
-`
-
-const bottom = `
-    <div id="help">
-      Use the left and right arrow keys or click the left and right
-      edges of the page to navigate between slides.<br>
-      (Press 'H' or navigate to hide this message.)
-    </div>
-    <script type="application/javascript" src='static/play.js'></script>
-	<script type="module">
-	   import mermaid from 'https://cdn.jsdelivr.net/npm/mermaid@11/dist/mermaid.esm.min.mjs';
-	   mermaid.initialize({ startOnLoad: true });
-	</script>
-  </body>
-</html>`
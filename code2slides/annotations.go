@@ -0,0 +1,121 @@
+package code2slides
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// An annotationStore holds the drawings made on a served deck's slides.
+// If file is set, the drawings are saved there, so they survive restarts.
+type annotationStore struct {
+	file string
+
+	mu     sync.Mutex
+	slides map[string]json.RawMessage // by slide number
+}
+
+// maxAnnotationSize limits the size of a slide's drawing.
+const maxAnnotationSize = 1 << 20
+
+// load reads the drawings in the store's file, if it exists.
+func (as *annotationStore) load() error {
+	as.slides = map[string]json.RawMessage{}
+	if as.file == "" {
+		return nil
+	}
+	data, err := os.ReadFile(as.file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &as.slides)
+}
+
+// serveAll serves all drawings, as a JSON object keyed by slide number.
+func (as *annotationStore) serveAll(w http.ResponseWriter, r *http.Request) {
+	as.mu.Lock()
+	data, err := json.Marshal(as.slides)
+	as.mu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// serveAnnotation replaces the drawing on one slide of the deck. Only the
+// presenter may draw for every viewer.
+func (ds *deckServer) serveAnnotation(w http.ResponseWriter, r *http.Request) {
+	if ds.live.presenterKey == "" || !secretEqual(r.URL.Query().Get("key"), ds.live.presenterKey) {
+		http.Error(w, "not the presenter", http.StatusForbidden)
+		return
+	}
+	slide := r.PathValue("slide")
+	if ok, err := ds.hasSlide(slide); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	} else if !ok {
+		http.Error(w, "bad slide number", http.StatusBadRequest)
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxAnnotationSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if !json.Valid(data) {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := ds.annotations.put(slide, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// put replaces the drawing on slide, and saves the drawings.
+func (as *annotationStore) put(slide string, data json.RawMessage) error {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	if as.slides == nil {
+		as.slides = map[string]json.RawMessage{}
+	}
+	as.slides[slide] = data
+	return as.save()
+}
+
+// save writes the drawings to the store's file. The caller must hold as.mu.
+func (as *annotationStore) save() error {
+	if as.file == "" {
+		return nil
+	}
+	data, err := json.Marshal(as.slides)
+	if err != nil {
+		return err
+	}
+	// Write a temporary file and rename it, so a crash can't leave
+	// a partly written file.
+	tmp, err := os.CreateTemp(filepath.Dir(as.file), filepath.Base(as.file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), as.file)
+}
@@ -0,0 +1,271 @@
+package code2slides
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// An attendee repo is what the students of one cohort get, ready to push
+// to a public repository:
+//
+//	README.md
+//	exercises/  the exercises without their solutions, as a workspace
+//	examples/   a module with a program for each runnable example
+//	handouts/   each deck as a scrolling page, and its static files
+//
+// The examples are the code sections with the play attribute, which are
+// complete programs. The handouts are built as with -scroll and without
+// -notes, so they have no instructor notes or solutions.
+
+// attendeeCommand writes an attendee repo.
+func attendeeCommand(args []string) error {
+	fs := flag.NewFlagSet("attendee", flag.ExitOnError)
+	out := fs.String("o", "attendee", "write the repo to `dir`, which must not exist")
+	exDir := fs.String("exercises", "", "include the exercises in `dir`")
+	staticDir := fs.String("static", "static", "copy the handouts' static files from `dir`")
+	title := fs.String("title", "Workshop", "title of the README")
+	goVersion := fs.String("go", strings.TrimPrefix(runtime.Version(), "go"), "require Go `version` in each go.mod")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: code2slides attendee [flags] <manifest, slide file or dir>...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 && *exDir == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if _, err := os.Stat(*out); err == nil {
+		return fmt.Errorf("%s already exists", *out)
+	}
+	var decks []attendeeDeck
+	for _, arg := range fs.Args() {
+		d, err := readAttendeeDeck(arg)
+		if err != nil {
+			return err
+		}
+		decks = append(decks, d)
+	}
+	// Handouts are built like the decks served with -scroll, for reading.
+	o, err := Config{Scroll: true}.options(context.Background())
+	if err != nil {
+		return err
+	}
+	return writeAttendeeRepo(o, *out, *title, *exDir, *staticDir, *goVersion, decks)
+}
+
+// An attendeeDeck is a deck of an attendee repo.
+type attendeeDeck struct {
+	name  string // for its files in the repo
+	parts []part
+}
+
+// readAttendeeDeck returns the deck named by arg: a manifest, if it ends
+// in .txt, or else a slide file or a directory of them.
+func readAttendeeDeck(arg string) (attendeeDeck, error) {
+	name := strings.TrimSuffix(filepath.Base(arg), filepath.Ext(arg))
+	if filepath.Ext(arg) == ".txt" {
+		if name == "manifest" {
+			name = filepath.Base(filepath.Dir(arg))
+		}
+		parts, err := readManifest(arg)
+		return attendeeDeck{name, parts}, err
+	}
+	files, err := slideFiles([]string{arg})
+	if err != nil {
+		return attendeeDeck{}, err
+	}
+	if len(files) == 0 {
+		return attendeeDeck{}, fmt.Errorf("no slide files in %s", arg)
+	}
+	return attendeeDeck{name, []part{{files: files}}}, nil
+}
+
+// writeAttendeeRepo writes to dir an attendee repo, titled title, of the
+// exercises in exercisesDir, if it isn't empty, and of decks, whose
+// handouts are built with o and use the files in staticDir. Its modules
+// use Go goVersion.
+func writeAttendeeRepo(o *options, dir, title, exercisesDir, staticDir, goVersion string, decks []attendeeDeck) error {
+	lang, err := goLang(goVersion)
+	if err != nil {
+		return err
+	}
+	var readme strings.Builder
+	fmt.Fprintf(&readme, "# %s\n", title)
+	if exercisesDir != "" {
+		if err := writeWorkspace(filepath.Join(dir, "exercises"), exercisesDir, goVersion); err != nil {
+			return err
+		}
+		fmt.Fprintf(&readme, "\n## Exercises\n\nThe exercises are in [exercises](exercises). Each is a module of the workspace there,\nso one that doesn't compile doesn't stop the others from building.\n")
+	}
+	if len(decks) == 0 {
+		return os.WriteFile(filepath.Join(dir, "README.md"), []byte(readme.String()), 0o644)
+	}
+
+	handouts := filepath.Join(dir, "handouts")
+	if err := copyDir(filepath.Join(handouts, "static"), staticDir, nil); err != nil {
+		return err
+	}
+	var examples []string
+	fmt.Fprintf(&readme, "\n## Handouts\n\n")
+	for _, d := range decks {
+		slides, err := scanFiles(o, partFiles(d.parts))
+		if err != nil {
+			return err
+		}
+		deckTitle := d.name
+		if len(slides) > 0 && slides[0].isTitle {
+			deckTitle = slides[0].heading
+		}
+		if err := buildFile(o, filepath.Join(handouts, d.name+".html"), deckTitle, d.parts); err != nil {
+			return err
+		}
+		fmt.Fprintf(&readme, "- [%s](handouts/%s.html)\n", deckTitle, d.name)
+		// Decks refer to their images by paths relative to where they
+		// are built, which is where the handouts are.
+		for _, imgDir := range deckDirs(d.parts) {
+			if err := copyDir(filepath.Join(handouts, imgDir), imgDir, isImage); err != nil {
+				return err
+			}
+		}
+		exs, err := writeExamples(filepath.Join(dir, "examples", d.name), slides)
+		if err != nil {
+			return err
+		}
+		for _, ex := range exs {
+			examples = append(examples, d.name+"/"+ex)
+		}
+	}
+	if len(examples) > 0 {
+		gomod := fmt.Sprintf("module workshop/examples\n\ngo %s\n", lang)
+		if err := os.WriteFile(filepath.Join(dir, "examples", "go.mod"), []byte(gomod), 0o644); err != nil {
+			return err
+		}
+		fmt.Fprintf(&readme, "\n## Examples\n\nThe runnable examples from the slides are in [examples](examples). Run one with\n\n\tcd examples\n\tgo run ./%s\n\n", examples[0])
+		for _, ex := range examples {
+			fmt.Fprintf(&readme, "- [%s](examples/%s)\n", ex, ex)
+		}
+	}
+	return os.WriteFile(filepath.Join(dir, "README.md"), []byte(readme.String()), 0o644)
+}
+
+// scanFiles returns the slides of files, in order.
+func scanFiles(o *options, files []string) ([]*Slide, error) {
+	var slides []*Slide
+	for _, f := range files {
+		ss, err := scanFile(o, f)
+		if err != nil {
+			return nil, err
+		}
+		slides = append(slides, ss...)
+	}
+	return slides, nil
+}
+
+// nonSlugRe matches the runs of characters that slugs replace with "-".
+var nonSlugRe = regexp.MustCompile(`[^a-z0-9]+`)
+
+// writeExamples writes each runnable example in slides to main.go in its
+// own directory of dir, named for its slide's heading, and returns the
+// directories' names.
+func writeExamples(dir string, slides []*Slide) ([]string, error) {
+	var names []string
+	seen := map[string]int{}
+	for _, s := range slides {
+		for _, sec := range codeSections(s) {
+			if !slices.Contains(sec.attrs, attrPlay) {
+				continue
+			}
+			name := strings.Trim(nonSlugRe.ReplaceAllString(strings.ToLower(s.heading), "-"), "-")
+			if name == "" {
+				name = "example"
+			}
+			seen[name]++
+			if n := seen[name]; n > 1 {
+				name = fmt.Sprintf("%s-%d", name, n)
+			}
+			exDir := filepath.Join(dir, name)
+			if err := os.MkdirAll(exDir, 0o755); err != nil {
+				return nil, err
+			}
+			if err := os.WriteFile(filepath.Join(exDir, "main.go"), exampleCode(sec, s.renames), 0o644); err != nil {
+				return nil, err
+			}
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// exampleCode returns the program in sec, a code section with the play
+// attribute, as the deck displays and runs it.
+func exampleCode(sec section, renames map[string]string) []byte {
+	s, _ := splitSteps(stripCallouts(sec.content))
+	s, _ = splitGoroutines(s)
+	var b strings.Builder
+	for _, line := range parseEm(strings.TrimSpace(s)) {
+		line = line.mapIdents(func(id string) string { return renderIdent(id, renames) })
+		b.WriteString(strings.ReplaceAll(line.text, ellipsisMark, "// ..."))
+		b.WriteByte('\n')
+	}
+	src := []byte(b.String())
+	if formatted, err := format.Source(src); err == nil {
+		return formatted
+	}
+	return src
+}
+
+// deckDirs returns the directories of the files in parts that are below
+// the current directory.
+func deckDirs(parts []part) []string {
+	var dirs []string
+	for _, f := range partFiles(parts) {
+		dir := filepath.Dir(f)
+		if filepath.IsLocal(dir) && dir != "." && !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// isImage reports whether the file name is that of an image.
+func isImage(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".png", ".jpg", ".jpeg", ".gif", ".svg", ".webp":
+		return true
+	}
+	return false
+}
+
+// copyDir copies the regular files in src for which keep returns true,
+// or all of them if keep is nil, to dst, which it creates.
+// Subdirectories aren't copied.
+func copyDir(dst, src string, keep func(name string) bool) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		return err
+	}
+	var errs []error
+	for _, e := range entries {
+		if !e.Type().IsRegular() || keep != nil && !keep(e.Name()) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(src, e.Name()))
+		if err == nil {
+			err = os.WriteFile(filepath.Join(dst, e.Name()), data, 0o644)
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
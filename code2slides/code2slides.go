@@ -0,0 +1,3426 @@
+// Package code2slides builds HTML slide decks from Go source files
+// annotated with directive comments. It is the code2slides command, in
+// github.com/jba/concurrency-workshop/cmd/code2slides, whose documentation
+// describes the directives; Build lets other programs build decks without
+// running the command.
+//
+//	deck, err := code2slides.Build(ctx, code2slides.Config{
+//		Files: []string{"slides/mutexes/mutexes.go"},
+//		Title: "Mutexes",
+//	})
+//	if err != nil {
+//		...
+//	}
+//	err = deck.WriteHTML(w)
+package code2slides
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"rsc.io/markdown"
+)
+
+type Slide struct {
+	isTitle   bool
+	isDivider bool   // begins a part: generated, or from the divider directive
+	isTOC     bool   // begins with the table of contents
+	heading   string // or main title
+	filename  string // source file
+	pageLabel string // page number to display, if not the slide's position
+	sections  []section
+	todos     []todo
+	renames   map[string]string // from rename directives; shared by a file's slides
+	optional  bool              // may be skipped when time is short
+	tags      []string          // from the optional directive
+	hold      string            // if set, omit the slide until this is released
+	comment   string            // prefix of line comments in the source file
+	exercise  bool              // from the exercise directive
+	label     string            // from the label directive
+	sameAs    *sameAs           // from the same-as directive
+	id        string            // stable name for analytics; see writeDeck
+	exNum     int               // the exercise's number, if it is one
+	planned   time.Duration     // from the time directive
+	titleInfo []titleInfo       // from author, date and event directives
+}
+
+// A titleInfo is a detail shown on a title slide, like its author.
+type titleInfo struct {
+	kind string // directive: "author", "date" or "event"
+	text string // markdown
+}
+
+// A sameAs records a same-as directive.
+type sameAs struct {
+	line int
+	file string // if empty, the previous slide
+}
+
+// A todo is a reminder left by a "todo" directive. It is never rendered.
+type todo struct {
+	line int
+	text string
+}
+
+func (s *Slide) dump() {
+	fmt.Printf("----------------\n")
+	fmt.Printf("# %s\n", s.heading)
+	for _, sec := range s.sections {
+		sec.dump()
+	}
+}
+
+type sectionKind int
+
+const (
+	sectionUndefined sectionKind = iota
+	sectionNote
+	sectionCode
+	sectionQuestion
+	sectionAnswer
+	sectionText
+	sectionHTML
+	sectionOutput
+	sectionSubtitle
+	sectionLine
+	sectionLiveTest
+	sectionColumns
+	sectionSolution
+	sectionPoll
+	sectionHint
+	sectionFootnote
+	sectionDiagram
+	sectionTimeline
+	sectionQuiz
+	sectionStepper
+	sectionCompare
+)
+
+func (k sectionKind) String() string {
+	switch k {
+	case sectionNote:
+		return "note"
+	case sectionCode:
+		return "code"
+	case sectionQuestion:
+		return "question"
+	case sectionAnswer:
+		return "answer"
+	case sectionText:
+		return "text"
+	case sectionHTML:
+		return "html"
+	case sectionOutput:
+		return "output"
+	case sectionSubtitle:
+		return "subtitle"
+	case sectionLine:
+		return "line"
+	case sectionLiveTest:
+		return "livetest"
+	case sectionColumns:
+		return "columns"
+	case sectionSolution:
+		return "solution"
+	case sectionPoll:
+		return "poll"
+	case sectionHint:
+		return "hint"
+	case sectionFootnote:
+		return "footnote"
+	case sectionDiagram:
+		return "diagram"
+	case sectionTimeline:
+		return "timeline"
+	case sectionQuiz:
+		return "quiz"
+	case sectionStepper:
+		return "stepper"
+	case sectionCompare:
+		return "compare"
+	default:
+		return "unknown"
+	}
+}
+
+var simpleOpens = map[string]sectionKind{
+	"note":     sectionNote,
+	"code":     sectionCode,
+	"output":   sectionOutput,
+	"subtitle": sectionSubtitle,
+	"diagram":  sectionDiagram,
+	"timeline": sectionTimeline,
+	"quiz":     sectionQuiz,
+	"stepper":  sectionStepper,
+}
+
+var simpleCloses = map[string]sectionKind{
+	"note":     sectionNote,
+	"text":     sectionText,
+	"footnote": sectionFootnote,
+	"output":   sectionOutput,
+	"subtitle": sectionSubtitle,
+	"diagram":  sectionDiagram,
+	"timeline": sectionTimeline,
+	"quiz":     sectionQuiz,
+	"stepper":  sectionStepper,
+}
+
+// classDirectives holds the directives of sections that can begin with a
+// class list, the classes to add to the element that wraps the section.
+var classDirectives = map[string]bool{
+	"code":     true,
+	"text":     true,
+	"question": true,
+	"hint":     true,
+	"answer":   true,
+	"output":   true,
+	"subtitle": true,
+	"poll":     true,
+	"quiz":     true,
+	"timeline": true,
+	"diagram":  true,
+	"sequence": true,
+	"stepper":  true,
+}
+
+// slideDirectives holds the directives that describe the whole slide, or
+// the file, rather than adding to it. They are common words, so inside a
+// section they are just content: "// time out after a second" in code is
+// a comment. (Directives that add sections are errors inside others.)
+var slideDirectives = map[string]bool{
+	"title":    true,
+	"author":   true,
+	"date":     true,
+	"event":    true,
+	"heading":  true,
+	"hold":     true,
+	"exercise": true,
+	"label":    true,
+	"same-as":  true,
+	"optional": true,
+	"time":     true,
+	"todo":     true,
+	"rename":   true,
+}
+
+// classRe matches an element of a class list.
+var classRe = regexp.MustCompile(`^\.[A-Za-z_][\w-]*$`)
+
+// cutClasses splits the class list at the beginning of rest, the words
+// after a directive, from what follows it.
+func cutClasses(rest string) ([]string, string) {
+	var classes []string
+	for {
+		word, after, _ := strings.Cut(rest, " ")
+		if !classRe.MatchString(word) {
+			return classes, rest
+		}
+		classes = append(classes, word[1:])
+		rest = strings.TrimSpace(after)
+	}
+}
+
+// classAttr returns the class attribute for an element with the classes
+// base, if not empty, and those of the section's class list.
+func (s section) classAttr(base string) string {
+	classes := s.classes
+	if base != "" {
+		classes = append([]string{base}, classes...)
+	}
+	if len(classes) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" class='%s'", strings.Join(classes, " "))
+}
+
+type section struct {
+	kind     sectionKind
+	options  []string
+	attrs    []codeAttr // for code sections
+	content  string
+	inAnswer bool     // true if this section is inside an answer (for code in answer)
+	line     int      // line number in the source file where the section starts
+	bodyLine int      // line number of the first line of content
+	num      int      // for questions, the number in the deck
+	classes  []string // from a class list like ".small .right"
+
+	outputs   []section   // for compare sections, the two outputs compared
+	run       *codeRun    // for output and sequence sections, the code to run for their content
+	highlight []lineRange // for code sections, from highlight directives
+	rendered  string      // for code sections, their HTML, if split by splitOverflow
+}
+
+func (s section) dump() {
+	fmt.Printf("-- %s --\n", s.kind)
+	fmt.Printf("%s", s.content)
+	fmt.Printf("^^^^\n")
+}
+
+func (s section) equal(other section) bool {
+	return s.kind == other.kind &&
+		s.content == other.content &&
+		slices.Equal(s.options, other.options) &&
+		slices.Equal(s.attrs, other.attrs) &&
+		slices.Equal(s.highlight, other.highlight) &&
+		slices.EqualFunc(s.outputs, other.outputs, section.equal) &&
+		s.run.equal(other.run) &&
+		s.inAnswer == other.inAnswer
+}
+
+// commands are the subcommands of code2slides. Without one,
+// code2slides builds a deck.
+var commands = map[string]func(args []string) error{
+	"serve":      serveCommand,
+	"flaky":      flakyCommand,
+	"shared":     sharedCommand,
+	"grade":      gradeCommand,
+	"grader":     graderCommand,
+	"workspace":  workspaceCommand,
+	"attendee":   attendeeCommand,
+	"new-module": newModuleCommand,
+	"new-slide":  newSlideCommand,
+	"dups":       dupsCommand,
+	"grep":       grepCommand,
+	"rewrite":    rewriteCommand,
+	"fmt":        fmtCommand,
+}
+
+// Command returns the subcommand of code2slides with the given name,
+// which runs with the arguments that follow the name, or nil if there is
+// none.
+func Command(name string) func(args []string) error {
+	return commands[name]
+}
+
+type indentWriter struct {
+	w     io.Writer
+	level int
+	err   error
+}
+
+func (w *indentWriter) indent() {
+	for range w.level {
+		io.WriteString(w, "  ")
+	}
+}
+
+func (w *indentWriter) open(s string) {
+	w.indent()
+	io.WriteString(w, s)
+	fmt.Fprintln(w)
+	w.level++
+}
+
+func (w *indentWriter) close(s string) {
+	w.level--
+	w.indent()
+	io.WriteString(w, s)
+	fmt.Fprintln(w)
+}
+
+func (w *indentWriter) linef(format string, args ...any) {
+	w.indent()
+	fmt.Fprintf(w, format, args...)
+	fmt.Fprintln(w)
+}
+
+func (w *indentWriter) lines(lines string) {
+	for line := range strings.Lines(lines) {
+		w.indent()
+		fmt.Fprint(w, line)
+	}
+}
+
+func (w *indentWriter) Write(data []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.w.Write(data)
+	if err != nil {
+		w.err = err
+	}
+	return n, err
+}
+
+func (w *indentWriter) Err() error { return w.err }
+
+// writeDeck writes the HTML for a deck made from parts to w, and returns
+// the deck's slides in order.
+// deckURL is the deck's URL relative to its static directory.
+func writeDeck(o *options, w io.Writer, deckURL, title string, parts []part) ([]*Slide, error) {
+	// First pass: collect all slides from all files
+	type fileSlides struct {
+		filename string
+		slides   []*Slide
+	}
+	type partSlides struct {
+		name  string
+		files []fileSlides
+	}
+	var allParts []partSlides
+	hasTOC := false // from a toc directive
+	if o.sinceRef != "" && len(parts) > 0 && len(parts[0].files) > 0 {
+		if err := checkGitRef(filepath.Dir(parts[0].files[0]), o.sinceRef); err != nil {
+			return nil, err
+		}
+	}
+	for _, p := range parts {
+		ps := partSlides{name: p.name}
+		changed := false
+		for _, filename := range p.files {
+			slides, err := scanFile(o, filename)
+			if err != nil {
+				return nil, fmt.Errorf("error processing %s: %w", filename, err)
+			}
+			if !o.includeNotes {
+				t := now()
+				slides = slices.DeleteFunc(slides, func(s *Slide) bool {
+					return s.hold != "" && !o.releases.released(s.hold, t)
+				})
+			}
+			if o.sinceRef != "" {
+				slides = changedSince(o, filename, slides, o.sinceRef)
+				changed = changed || slices.ContainsFunc(slides, func(s *Slide) bool { return !s.isTitle })
+			}
+			// Only the slides that are built run their code.
+			if err := runCode(o, filename, slides); err != nil {
+				return nil, fmt.Errorf("error processing %s: %w", filename, err)
+			}
+			if len(o.transforms) > 0 {
+				slides, err = transformSlides(o, filename, slides)
+				if err != nil {
+					return nil, fmt.Errorf("error processing %s: %w", filename, err)
+				}
+			}
+			hasTOC = hasTOC || slices.ContainsFunc(slides, func(s *Slide) bool { return s.isTOC })
+			ps.files = append(ps.files, fileSlides{filename, slides})
+		}
+		if o.sinceRef != "" && ps.name != "" && !changed {
+			continue // no divider for a part with nothing new
+		}
+		allParts = append(allParts, ps)
+	}
+	if o.forbidTodo {
+		var todos []string
+		for _, ps := range allParts {
+			for _, fs := range ps.files {
+				todos = append(todos, findTodos(fs.slides)...)
+			}
+		}
+		if len(todos) > 0 {
+			return nil, fmt.Errorf("deck has TODOs:\n%s", strings.Join(todos, "\n"))
+		}
+	}
+
+	// Lay out the deck. Named parts begin with a divider slide and are
+	// numbered separately, and a contents slide precedes the first one.
+	type entry struct {
+		comment string // HTML comment before the slide, if any
+		slide   *Slide
+	}
+	var (
+		entries []entry
+		partNum int
+		exNum   int // exercises so far
+		qNum    int // questions so far
+	)
+	for _, ps := range allParts {
+		n := 0
+		if o.numberByPart {
+			exNum, qNum = 0, 0
+		}
+		if ps.name != "" {
+			if !hasTOC {
+				entries = append(entries, entry{"contents", &Slide{heading: "Contents", isTOC: true}})
+				hasTOC = true
+			}
+			partNum++
+			div := &Slide{
+				isTitle:   true,
+				isDivider: true,
+				heading:   fmt.Sprintf("Part %d: %s", partNum, ps.name),
+				pageLabel: fmt.Sprint(partNum),
+			}
+			entries = append(entries, entry{"part " + ps.name, div})
+		}
+		for _, fs := range ps.files {
+			for i, slide := range fs.slides {
+				e := entry{slide: slide}
+				if i == 0 {
+					e.comment = fs.filename
+				}
+				if ps.name != "" {
+					n++
+					slide.pageLabel = fmt.Sprintf("%d.%d", partNum, n)
+				}
+				if slide.exercise {
+					exNum++
+					slide.exNum = exNum
+					slide.heading = fmt.Sprintf("Exercise %d: %s", exNum, slide.heading)
+				}
+				// Positions change as the deck is edited, so slides are
+				// known to analytics by their label or place in their file.
+				slide.id = cmp.Or(slide.label, fmt.Sprintf("%s:%d", filepath.Base(fs.filename), i+1))
+				for j := range slide.sections {
+					if slide.sections[j].kind == sectionQuestion {
+						qNum++
+						slide.sections[j].num = qNum
+					}
+				}
+				entries = append(entries, e)
+				for k, cont := range splitOverflow(o, slide) {
+					if ps.name != "" {
+						n++
+						cont.pageLabel = fmt.Sprintf("%d.%d", partNum, n)
+					}
+					cont.id = fmt.Sprintf("%s+%d", slide.id, k+1)
+					entries = append(entries, entry{slide: cont})
+				}
+			}
+		}
+	}
+	// So do the contents, with -toc, and the slide of changes since an
+	// earlier version.
+	afterTitle := 0
+	if len(entries) > 0 && entries[0].slide.isTitle {
+		afterTitle = 1
+	}
+	if o.addTOC && !hasTOC {
+		entries = slices.Insert(entries, afterTitle, entry{"contents", &Slide{heading: "Contents", isTOC: true}})
+	} else if o.sinceRef != "" && !hasTOC {
+		entries = slices.Insert(entries, afterTitle, entry{"contents", &Slide{heading: "What's new since " + o.sinceRef, isTOC: true}})
+	}
+	var changes *Slide
+	var snap map[string]string
+	if o.changesSince != "" {
+		var err error
+		snap, err = versionSnapshot(o.feedFile, o.changesSince)
+		if err != nil {
+			return nil, err
+		}
+		changes = &Slide{heading: "What changed since " + o.changesSince}
+		entries = slices.Insert(entries, afterTitle, entry{"changes", changes})
+	}
+	var slides []*Slide
+	for _, e := range entries {
+		slides = append(slides, e.slide)
+	}
+	if err := resolveLabels(slides); err != nil {
+		return nil, err
+	}
+	for _, s := range slides {
+		if !s.isTOC {
+			continue
+		}
+		// The contents replace the placeholder of a toc directive.
+		toc := section{kind: sectionHTML, content: tocHTML(slides)}
+		if len(s.sections) == 0 {
+			s.sections = []section{toc}
+		} else {
+			s.sections[0] = toc
+		}
+	}
+	if changes != nil {
+		changes.sections = []section{{kind: sectionHTML, content: changesHTML(slides, snap)}}
+	}
+
+	iw := &indentWriter{w: w}
+
+	fontURL := defaultFontURL
+	if o.offline {
+		fontURL = ""
+	}
+	if o.scroll {
+		links := ""
+		if fontURL != "" {
+			links = fmt.Sprintf("<link rel='stylesheet' href=%q>", fontURL)
+		}
+		if o.themeURL != "" {
+			links += fmt.Sprintf("\n    <link rel='stylesheet' href=%q>", o.themeURL)
+		}
+		fmt.Fprintf(iw, scrollTop, title, links)
+	} else {
+		fmt.Fprintf(iw, top, title, o.presenterNotes, fontURL, o.themeURL)
+	}
+
+	for i, e := range entries {
+		if e.comment != "" {
+			iw.linef("\n<!-- %s -->", e.comment)
+		}
+		if o.debug {
+			e.slide.dump()
+		}
+		writeSlideHTML(o, iw, e.slide, i+1, i == len(entries)-1)
+	}
+
+	if o.feedFile != "" {
+		if err := writeFeed(o, o.feedFile, title, deckURL, slides); err != nil {
+			return nil, err
+		}
+	}
+
+	if !o.scroll {
+		fmt.Fprintln(iw, bottom)
+		if hasPlay(slides) {
+			fmt.Fprintln(iw, playScripts)
+		}
+		if o.presenterNotes {
+			if err := writeTimingScript(iw, slides); err != nil {
+				return nil, err
+			}
+			if err := writePresenterScripts(o, iw, slides, hasPlay(slides)); err != nil {
+				return nil, err
+			}
+		}
+		if o.analyticsURL != "" {
+			u, err := json.Marshal(o.analyticsURL)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(iw, "    <script>var analyticsURL = %s;</script>\n", u)
+			fmt.Fprintln(iw, "    <script src='static/analytics.js'></script>")
+		}
+	}
+	if o.offline {
+		fmt.Fprintln(iw, mermaidOffline)
+	} else {
+		fmt.Fprintln(iw, mermaidCDN)
+	}
+	fmt.Fprintln(iw, end)
+
+	return slides, iw.Err()
+}
+
+// hasPlay reports whether any of the slides has runnable code.
+func hasPlay(slides []*Slide) bool {
+	for _, s := range slides {
+		for _, sec := range s.sections {
+			if slices.Contains(sec.attrs, attrPlay) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// labelRefRe matches a Markdown link to a label, like "](#waitgroup)".
+var labelRefRe = regexp.MustCompile(`\]\(#([^)\s]+)\)`)
+
+// resolveLabels replaces the links to labels in the text of slides with
+// links to the labeled slides' positions. A link to a number is already
+// a position, and is left alone.
+func resolveLabels(slides []*Slide) error {
+	pos := map[string]int{}
+	for i, s := range slides {
+		if s.label == "" {
+			continue
+		}
+		if _, ok := pos[s.label]; ok {
+			return fmt.Errorf("%s: label %q used twice", s.filename, s.label)
+		}
+		pos[s.label] = i + 1
+	}
+	var errs []error
+	for _, s := range slides {
+		for i := range s.sections {
+			sec := &s.sections[i]
+			if sec.kind == sectionCode || sec.kind == sectionOutput {
+				continue
+			}
+			sec.content = labelRefRe.ReplaceAllStringFunc(sec.content, func(m string) string {
+				name := labelRefRe.FindStringSubmatch(m)[1]
+				if _, err := strconv.Atoi(name); err == nil {
+					return m
+				}
+				n, ok := pos[name]
+				if !ok {
+					errs = append(errs, fmt.Errorf("%s:%d: no slide labeled %q", s.filename, sec.line, name))
+					return m
+				}
+				return fmt.Sprintf("](#%d)", n)
+			})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// tocHTML returns a table of contents for a deck made of slides,
+// listing each part and the headings of the slides in it, or in a deck
+// without parts, the headings of its slides.
+// Links refer to slides by position.
+func tocHTML(slides []*Slide) string {
+	var b strings.Builder
+	b.WriteString("<ul class='toc'>")
+	hasParts := slices.ContainsFunc(slides, func(s *Slide) bool { return s.isDivider })
+	inPart := false
+	for i, s := range slides {
+		link := fmt.Sprintf("<a href='#%d'>%s</a>", i+1, html.EscapeString(s.heading))
+		newHeading := i == 0 || s.heading != slides[i-1].heading
+		switch {
+		case s.isDivider:
+			if inPart {
+				b.WriteString("</ul></li>")
+			}
+			fmt.Fprintf(&b, "<li>%s<ul>", link)
+			inPart = true
+		case inPart && newHeading:
+			fmt.Fprintf(&b, "<li>%s</li>", link)
+		case !hasParts && newHeading && !s.isTitle && !s.isTOC:
+			fmt.Fprintf(&b, "<li>%s</li>", link)
+		}
+	}
+	if inPart {
+		b.WriteString("</ul></li>")
+	}
+	b.WriteString("</ul>")
+	return b.String()
+}
+
+func scanFile(o *options, filename string) ([]*Slide, error) {
+	return scanSlides(o, filename, true)
+}
+
+// scanSlides is scanFile, but it checks same-as directives only if
+// checkSameAs is true, so that checking them can scan another file
+// without checking that file's, which could lead back to this one.
+func scanSlides(o *options, filename string, checkSameAs bool) ([]*Slide, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return scanContent(o, filename, content, checkSameAs)
+}
+
+// scanContent is scanSlides, but with the content of filename given, as
+// for an earlier version of the file.
+func scanContent(o *options, filename string, content []byte, checkSameAs bool) (_ []*Slide, err error) {
+	renames := map[string]string{}
+	prefix := commentPrefix(o, filename)
+	slide := &Slide{
+		heading:  fallbackHeading(o, filename),
+		filename: filename,
+		renames:  renames,
+		comment:  prefix,
+	}
+	var slides []*Slide
+
+	scanner := bufio.NewScanner(strings.NewReader(string(content)))
+	var (
+		current    strings.Builder
+		kind       sectionKind
+		options    []string
+		attrs      []codeAttr  // of the current code section
+		highlight  []lineRange // of the current code section
+		divClass   string
+		inCols     bool     // between cols and !cols
+		comparing  *Slide   // the slide of an open compare, or nil
+		compareAt  int      // index of the first section after compare
+		colWidths  []string // from the cols directive
+		colNum     int      // of the current column, from 0
+		inBlock    bool     // in a section opened with "/*"
+		eliding    bool
+		omitting   bool
+		goroutines int         // open goroutine marks in the current code section
+		ifs        []bool      // conditions of the enclosing if directives
+		parentKind sectionKind // for nested code in answer
+
+		// The classes of the directive on the line being scanned, of the
+		// section being scanned, and of its parent answer.
+		lineClasses, classes, parentClasses []string
+	)
+	lineNum := 0
+	startLine := 0 // line where the current section began
+
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("%s:%d: %v", filename, lineNum, err)
+		}
+	}()
+
+	add := func(k sectionKind, opts []string, c string, inAnswer bool) {
+		sec := section{
+			kind:     k,
+			options:  opts,
+			content:  c,
+			inAnswer: inAnswer,
+			line:     startLine,
+			bodyLine: startLine,
+		}
+		// The content of a block follows its directive's line; that of
+		// an inline section, like "// text CONTENT", is on it.
+		if k == kind || k == parentKind {
+			sec.bodyLine++
+		}
+		if k == sectionCode {
+			sec.attrs = attrs
+			sec.highlight = highlight
+		}
+		switch {
+		case k == kind:
+			sec.classes = classes
+		case k == parentKind:
+			sec.classes = parentClasses
+		default:
+			sec.classes = lineClasses
+		}
+		slide.sections = append(slide.sections, sec)
+	}
+
+	addCurrent := func(k sectionKind, opts []string, inAnswer bool) {
+		if current.Len() > 0 {
+			add(k, opts, current.String(), inAnswer)
+			current.Reset()
+		}
+	}
+
+	// A question is added even if it has no text, so that its answer
+	// still has a summary to click on.
+	addQuestion := func() {
+		add(sectionQuestion, nil, current.String(), false)
+		current.Reset()
+	}
+
+	for scanner.Scan() {
+		lineNum++
+		if kind == sectionUndefined {
+			startLine = lineNum
+		}
+		// Scan other languages' comments as if they began with "//",
+		// but show code as written.
+		orig := scanner.Text()
+		line := slashComments(orig, prefix)
+		if inBlock {
+			// Inside a section opened with "/*", every line is content,
+			// up to the "*/" that closes the section.
+			text, end := strings.CutSuffix(strings.TrimRight(line, " \t"), "*/")
+			if kind != sectionOutput && kind != sectionDiagram {
+				text = strings.TrimSpace(text)
+			}
+			if !end || strings.TrimSpace(text) != "" {
+				current.WriteString(text)
+				current.WriteByte('\n')
+			}
+			if end {
+				addCurrent(kind, options, parentKind == sectionAnswer)
+				kind, classes = parentKind, parentClasses
+				parentKind, parentClasses = sectionUndefined, nil
+				options = nil
+				inBlock = false
+			}
+			continue
+		}
+		first, rest, _ := splitFirstWord(line)
+		switch first {
+		case "if":
+			// A comment like "// if the function is." isn't a directive.
+			tag, not := strings.CutPrefix(rest, "!")
+			if tag == "" || strings.ContainsAny(tag, " \t") {
+				break
+			}
+			ifs = append(ifs, slices.Contains(o.buildTags, tag) != not)
+			continue
+		case "!if":
+			if len(ifs) == 0 {
+				return nil, errors.New("!if without if")
+			}
+			ifs = ifs[:len(ifs)-1]
+			continue
+		}
+		if slices.Contains(ifs, false) {
+			continue
+		}
+		opensBlock := strings.HasPrefix(strings.TrimSpace(line), "/*")
+		lineClasses = nil
+		if classDirectives[first] {
+			lineClasses, rest = cutClasses(rest)
+		}
+		matchFirst := true
+		if args := strings.Fields(rest); first == "output" && len(args) > 0 && args[0] == "auto" {
+			if kind != sectionUndefined {
+				return nil, fmt.Errorf("output auto inside %s", kind)
+			}
+			args = args[1:]
+			nondet := false
+			if i := slices.Index(args, "nondeterministic"); i >= 0 {
+				nondet = true
+				args = slices.Delete(args, i, i+1)
+			}
+			run := &codeRun{
+				directive: "output auto",
+				dir:       filepath.Dir(filename),
+				args:      args,
+				nondet:    nondet,
+				line:      lineNum,
+			}
+			if len(args) > 0 && testNameRe.MatchString(args[0]) {
+				args = args[1:]
+			}
+			var options []string
+			if len(args) > 0 {
+				options = []string{conditions(args)}
+			}
+			if nondet {
+				options = append(options, "nondeterministic")
+			}
+			add(sectionOutput, options, "", false)
+			slide.sections[len(slide.sections)-1].run = run
+			continue
+		}
+		if sec, ok := simpleOpens[first]; ok {
+			// Allow code and timelines inside answer
+			if kind == sectionAnswer && (sec == sectionCode || sec == sectionTimeline) {
+				addCurrent(sectionAnswer, nil, false)
+				parentKind, parentClasses = sectionAnswer, classes
+				kind, classes = sec, lineClasses
+				if sec == sectionCode {
+					attrs, err = parseCodeAttrs(strings.Fields(rest))
+					if err != nil {
+						return nil, err
+					}
+				} else {
+					inBlock = opensBlock
+				}
+				continue
+			}
+			if kind != sectionUndefined {
+				return nil, fmt.Errorf("%s inside %s", sec, kind)
+			}
+			kind, classes = sec, lineClasses
+			if kind == sectionCode {
+				if opensBlock {
+					return nil, errors.New("code cannot be in a /* comment")
+				}
+				attrs, err = parseCodeAttrs(strings.Fields(rest))
+				if err != nil {
+					return nil, err
+				}
+			} else {
+				options = strings.Fields(rest)
+			}
+			inBlock = opensBlock
+			continue
+		}
+		if strings.HasPrefix(first, "!") {
+			if sec, ok := simpleCloses[first[1:]]; ok {
+				if kind != sec {
+					return nil, fmt.Errorf("%s without matching %s", first, first[1:])
+				}
+				addCurrent(sec, options, parentKind == sectionAnswer)
+				kind, classes = parentKind, parentClasses
+				parentKind, parentClasses = sectionUndefined, nil
+				options = nil
+				continue
+			}
+		}
+
+		directive := first
+		if kind != sectionUndefined && slideDirectives[first] {
+			directive = ""
+		}
+		switch directive {
+		case "title":
+			if rest == "" {
+				return nil, errors.New("missing heading")
+			}
+			if len(slide.sections) > 0 {
+				slides = append(slides, slide)
+				slide = &Slide{filename: filename, renames: renames, comment: prefix}
+			}
+			slide.isTitle = true
+			slide.heading = rest
+
+		case "author", "date", "event":
+			if !slide.isTitle || slide.isDivider {
+				return nil, fmt.Errorf("%s outside a title slide", first)
+			}
+			if rest == "" {
+				return nil, fmt.Errorf("missing %s", first)
+			}
+			if slices.ContainsFunc(slide.titleInfo, func(t titleInfo) bool { return t.kind == first }) {
+				return nil, fmt.Errorf("title slide has two %s directives", first)
+			}
+			slide.titleInfo = append(slide.titleInfo, titleInfo{first, rest})
+
+		case "toc":
+			if kind != sectionUndefined {
+				return nil, fmt.Errorf("toc inside %s", kind)
+			}
+			if inCols {
+				return nil, errors.New("toc inside cols")
+			}
+			if slide.isTitle || len(slide.sections) > 0 {
+				slides = append(slides, slide)
+				slide = &Slide{filename: filename, renames: renames, comment: prefix}
+			}
+			slide.isTOC = true
+			slide.heading = cmp.Or(rest, "Contents")
+			// A placeholder for the contents, which need the whole deck.
+			add(sectionHTML, nil, "", false)
+
+		case "divider":
+			if rest == "" {
+				return nil, errors.New("missing divider text")
+			}
+			if kind != sectionUndefined {
+				return nil, fmt.Errorf("divider inside %s", kind)
+			}
+			if inCols {
+				return nil, errors.New("divider inside cols")
+			}
+			if slide.isTitle || len(slide.sections) > 0 {
+				slides = append(slides, slide)
+				slide = &Slide{filename: filename, renames: renames, comment: prefix}
+			}
+			slide.isTitle = true
+			slide.isDivider = true
+			slide.heading = rest
+
+		case "heading":
+			if rest == "" {
+				return nil, errors.New("missing heading")
+			}
+			if inCols {
+				return nil, errors.New("heading inside cols")
+			}
+			if slide.isTitle || len(slide.sections) > 0 {
+				slides = append(slides, slide)
+				slide = &Slide{filename: filename, renames: renames, comment: prefix}
+			}
+			slide.heading = rest
+
+		case "slide":
+			if kind != sectionUndefined {
+				return nil, fmt.Errorf("slide inside %s", kind)
+			}
+			if inCols {
+				return nil, errors.New("slide inside cols")
+			}
+			heading := cmp.Or(rest, slide.heading)
+			if heading == "" {
+				return nil, errors.New("missing heading")
+			}
+			if slide.isTitle || len(slide.sections) > 0 {
+				slides = append(slides, slide)
+				slide = &Slide{filename: filename, renames: renames, comment: prefix}
+			}
+			slide.heading = heading
+
+		case "text":
+			if kind != sectionUndefined {
+				return nil, fmt.Errorf("text inside %s", kind)
+			}
+			if rest != "" {
+				add(sectionText, nil, rest+"\n", false)
+			} else {
+				kind, classes = sectionText, lineClasses
+				inBlock = opensBlock
+			}
+
+		case "footnote":
+			if kind != sectionUndefined {
+				return nil, fmt.Errorf("footnote inside %s", kind)
+			}
+			if rest != "" {
+				add(sectionFootnote, nil, rest+"\n", false)
+			} else {
+				kind = sectionFootnote
+				inBlock = opensBlock
+			}
+
+		case "html":
+			add(sectionHTML, nil, rest, false)
+
+		case "hold":
+			if rest == "" {
+				return nil, errors.New("missing release name")
+			}
+			slide.hold = rest
+
+		case "exercise":
+			// "// exercise the slow path" is a comment.
+			if rest != "" {
+				matchFirst = false
+				break
+			}
+			slide.exercise = true
+
+		case "label":
+			if len(strings.Fields(rest)) != 1 {
+				return nil, errors.New("label needs one name")
+			}
+			if slide.label != "" {
+				return nil, errors.New("slide has two labels")
+			}
+			slide.label = rest
+
+		case "same-as":
+			if len(strings.Fields(rest)) > 1 {
+				return nil, errors.New("same-as takes at most one file")
+			}
+			if slide.sameAs != nil {
+				return nil, errors.New("slide has two same-as directives")
+			}
+			slide.sameAs = &sameAs{lineNum, rest}
+
+		case "optional":
+			slide.optional = true
+			slide.tags = append(slide.tags, strings.Fields(rest)...)
+
+		case "time":
+			if slide.planned != 0 {
+				return nil, errors.New("slide has two times")
+			}
+			d, err := parsePlannedTime(rest)
+			if err != nil {
+				return nil, err
+			}
+			slide.planned = d
+
+		case "todo":
+			slide.todos = append(slide.todos, todo{lineNum, rest})
+
+		case "line":
+			if kind != sectionUndefined {
+				return nil, fmt.Errorf("line inside %s", kind)
+			}
+			add(sectionLine, nil, rest+"\n", false)
+
+		case "subheading", "heading2":
+			if kind != sectionUndefined {
+				return nil, fmt.Errorf("%s inside %s", first, kind)
+			}
+			if rest == "" {
+				return nil, fmt.Errorf("missing %s", first)
+			}
+			add(sectionHTML, nil, fmt.Sprintf("<h2 class='subheading'>%s</h2>", stripPara(renderMarkdown(o, rest))), false)
+
+		case "image", "img":
+			if rest == "" {
+				return nil, errors.New("missing image filename")
+			}
+			imgFile, alt, _ := strings.Cut(rest, " ")
+			alt = strings.TrimSpace(alt)
+			if alt == "" {
+				if o.requireAlt {
+					return nil, errors.New("image needs alt text")
+				}
+				alt = imgFile
+			}
+			// Compute path relative to the directory containing the source file
+			imgPath := filepath.Join(filepath.Dir(filename), imgFile)
+			add(sectionHTML, nil, fmt.Sprintf("<img src=%q alt=%q />", imgPath, alt), false)
+
+		case "caption":
+			if rest == "" {
+				return nil, errors.New("missing caption text")
+			}
+			n := len(slide.sections)
+			if kind != sectionUndefined || n == 0 || slide.sections[n-1].kind != sectionHTML || !strings.HasPrefix(slide.sections[n-1].content, "<img ") {
+				return nil, errors.New("caption without a preceding image")
+			}
+			img := &slide.sections[n-1]
+			img.content = fmt.Sprintf("<figure>%s<figcaption>%s</figcaption></figure>", img.content, stripPara(renderMarkdown(o, rest)))
+
+		case "include":
+			if rest == "" {
+				return nil, errors.New("missing include filename")
+			}
+			// Handle potential */ at the end if it was a /* ... */ comment
+			rest = strings.TrimSuffix(rest, "*/")
+			rest = strings.TrimSpace(rest)
+
+			// Parse: FILENAME [ADDRESS]
+			incFile, addr, _ := strings.Cut(rest, " ")
+			incPath := filepath.Join(filepath.Dir(filename), incFile)
+			incContent, err := os.ReadFile(incPath)
+			if err != nil {
+				return nil, fmt.Errorf("error reading include file %s: %w", incPath, err)
+			}
+			incContent, err = includePart(incContent, strings.TrimSpace(addr), commentPrefix(o, incPath))
+			if err != nil {
+				return nil, fmt.Errorf("error processing include range for %s: %w", incFile, err)
+			}
+
+			if kind == sectionUndefined {
+				add(sectionHTML, nil, string(incContent), false)
+			} else {
+				current.Write(incContent)
+				if len(incContent) > 0 && incContent[len(incContent)-1] != '\n' {
+					current.WriteByte('\n')
+				}
+			}
+
+		case "testfail":
+			if kind != sectionUndefined {
+				return nil, fmt.Errorf("testfail inside %s", kind)
+			}
+			args := strings.Fields(rest)
+			if len(args) == 0 {
+				return nil, errors.New("missing test name")
+			}
+			nondet := false
+			if i := slices.Index(args, "nondeterministic"); i > 0 {
+				nondet = true
+				args = slices.Delete(args, i, i+1)
+			}
+			var options []string
+			if len(args) > 1 {
+				options = []string{conditions(args[1:])}
+			}
+			if nondet {
+				options = append(options, "nondeterministic")
+			}
+			add(sectionOutput, options, "", false)
+			slide.sections[len(slide.sections)-1].run = &codeRun{
+				directive: "testfail",
+				dir:       filepath.Dir(filename),
+				args:      args,
+				nondet:    nondet,
+				line:      lineNum,
+			}
+
+		case "sequence":
+			if kind != sectionUndefined {
+				return nil, fmt.Errorf("sequence inside %s", kind)
+			}
+			add(sectionDiagram, []string{"sequence"}, "", false)
+			slide.sections[len(slide.sections)-1].run = &codeRun{
+				directive: "sequence",
+				dir:       filepath.Dir(filename),
+				args:      strings.Fields(rest),
+				line:      lineNum,
+			}
+
+		case "livetest":
+			if kind != sectionUndefined {
+				return nil, fmt.Errorf("livetest inside %s", kind)
+			}
+			add(sectionLiveTest, strings.Fields(rest), filepath.Dir(filename), false)
+
+		case "solution":
+			if kind != sectionUndefined {
+				return nil, fmt.Errorf("solution inside %s", kind)
+			}
+			if rest == "" {
+				return nil, errors.New("missing exercise directory")
+			}
+			diffs, err := solutionDiffHTML(filepath.Join(filepath.Dir(filename), rest))
+			if err != nil {
+				return nil, err
+			}
+			add(sectionSolution, nil, diffs, false)
+
+		case "poll":
+			if kind != sectionUndefined {
+				return nil, fmt.Errorf("poll inside %s", kind)
+			}
+			if slices.ContainsFunc(slide.sections, func(s section) bool { return s.kind == sectionPoll }) {
+				return nil, errors.New("more than one poll on a slide")
+			}
+			choices := strings.Fields(rest)
+			if len(choices) == 0 {
+				choices = []string{"A", "B", "C", "D"}
+			}
+			add(sectionPoll, choices, "", false)
+
+		case "rename":
+			if rest == "" {
+				return nil, errors.New("missing rename")
+			}
+			for _, r := range strings.Fields(rest) {
+				old, new, ok := strings.Cut(r, "=")
+				if !ok || !isIdent(old) || !isIdent(new) {
+					return nil, fmt.Errorf("bad rename %q: want OLD=NEW", r)
+				}
+				if prev, ok := renames[old]; ok && prev != new {
+					return nil, fmt.Errorf("%s renamed to both %s and %s", old, prev, new)
+				}
+				renames[old] = new
+			}
+
+		case "link":
+			if rest == "" {
+				return nil, errors.New("missing link filename")
+			}
+			linkFile, linkText, _ := strings.Cut(rest, " ")
+			if linkText == "" {
+				return nil, errors.New("missing link text")
+			}
+			// Compute path relative to the directory containing the source file
+			linkPath := filepath.Join(filepath.Dir(filename), linkFile)
+			add(sectionHTML, nil, fmt.Sprintf("<a href=%q>%s</a>", linkPath, html.EscapeString(linkText)), false)
+
+		case "!code":
+			if kind != sectionCode {
+				return nil, errors.New("!code without matching code")
+			}
+			if omitting {
+				return nil, errors.New("omit without matching !omit")
+			}
+			// Trim trailing blank line; mark inAnswer if nested in answer
+			add(kind, nil, strings.TrimSuffix(current.String(), "\n"), parentKind == sectionAnswer)
+			current.Reset()
+			if parentKind != sectionUndefined {
+				kind, classes = parentKind, parentClasses
+				parentKind, parentClasses = sectionUndefined, nil
+			} else {
+				kind = sectionUndefined
+			}
+			attrs = nil
+			highlight = nil
+			goroutines = 0
+
+		case "question":
+			if kind != sectionUndefined {
+				return nil, fmt.Errorf("question inside %s", kind)
+			}
+			if rest != "" {
+				add(sectionQuestion, nil, rest+"\n", false)
+			} else {
+				kind, classes = sectionQuestion, lineClasses
+			}
+
+		case "hint":
+			switch kind {
+			case sectionQuestion:
+				addQuestion()
+			case sectionHint:
+				addCurrent(kind, nil, false)
+			case sectionUndefined:
+				// After an inline question or hint.
+				n := len(slide.sections)
+				if n == 0 || slide.sections[n-1].kind != sectionQuestion && slide.sections[n-1].kind != sectionHint {
+					return nil, errors.New("hint without question")
+				}
+			default:
+				return nil, fmt.Errorf("hint inside %s", kind)
+			}
+			startLine = lineNum
+			if rest != "" {
+				add(sectionHint, nil, rest+"\n", false)
+				kind = sectionUndefined
+			} else {
+				kind, classes = sectionHint, lineClasses
+			}
+
+		case "answer":
+			if kind == sectionQuestion {
+				addQuestion()
+			} else if kind == sectionHint {
+				addCurrent(kind, nil, false)
+			} else if kind != sectionUndefined {
+				return nil, fmt.Errorf("answer inside %s", kind)
+			}
+			startLine = lineNum
+			if rest != "" {
+				add(sectionAnswer, nil, rest+"\n", false)
+			} else {
+				kind, classes = sectionAnswer, lineClasses
+			}
+
+		case "!question":
+			if kind != sectionQuestion && kind != sectionHint && kind != sectionAnswer {
+				return nil, errors.New("!question without matching question")
+			}
+			if kind == sectionQuestion || kind == sectionHint {
+				return nil, errors.New("!question without answer")
+			}
+			// Always add the answer, even if empty, so the details element
+			// is closed and the linter can see it.
+			add(sectionAnswer, options, current.String(), false)
+			current.Reset()
+			kind = sectionUndefined
+			options = nil
+
+		case "compare":
+			if kind != sectionUndefined {
+				return nil, fmt.Errorf("compare inside %s", kind)
+			}
+			if comparing != nil {
+				return nil, errors.New("compare inside compare")
+			}
+			comparing, compareAt = slide, len(slide.sections)
+
+		case "!compare":
+			if kind != sectionUndefined {
+				return nil, fmt.Errorf("!compare inside %s", kind)
+			}
+			if comparing == nil {
+				return nil, errors.New("!compare without matching compare")
+			}
+			if comparing != slide {
+				return nil, errors.New("compare spans slides")
+			}
+			outs := slices.Clone(slide.sections[compareAt:])
+			if len(outs) != 2 || outs[0].kind != sectionOutput || outs[1].kind != sectionOutput {
+				return nil, errors.New("compare needs two outputs, and nothing else")
+			}
+			slide.sections = append(slide.sections[:compareAt], section{
+				kind:    sectionCompare,
+				content: compareContent(outs),
+				line:    outs[0].line,
+				outputs: outs,
+			})
+			comparing = nil
+
+		case "cols", "nextcol", "!cols":
+			if kind != sectionUndefined {
+				return nil, fmt.Errorf("%s inside %s", first, kind)
+			}
+			switch {
+			case first == "cols" && inCols:
+				return nil, errors.New("cols inside cols")
+			case first != "cols" && !inCols:
+				return nil, fmt.Errorf("%s without matching cols", first)
+			}
+			var width []string
+			switch first {
+			case "cols":
+				colWidths = strings.Fields(rest)
+				for _, w := range colWidths {
+					if f, err := strconv.ParseFloat(w, 64); err != nil || f <= 0 {
+						return nil, fmt.Errorf("cols: bad width %q: want a positive number", w)
+					}
+				}
+				colNum = 0
+			case "nextcol":
+				colNum++
+			case "!cols":
+				if len(colWidths) > 0 && len(colWidths) != colNum+1 {
+					return nil, fmt.Errorf("cols has %d widths but %d columns", len(colWidths), colNum+1)
+				}
+			}
+			if colNum < len(colWidths) && first != "!cols" {
+				width = colWidths[colNum : colNum+1]
+			}
+			inCols = first != "!cols"
+			add(sectionColumns, width, first, false)
+
+		default:
+			matchFirst = false
+		}
+		if !matchFirst {
+			if d, c, ok := strings.Cut(first, "."); ok {
+				if d == "div" {
+					add(sectionHTML, nil, fmt.Sprintf("<div class=%q>", c), false)
+					divClass = c
+					continue
+				} else if d == "!div" {
+					if c != divClass {
+						return nil, fmt.Errorf("mismatched div class: start %q, end %q", divClass, c)
+					}
+					add(sectionHTML, nil, fmt.Sprintf("</div> <!-- %s -->", c), false)
+					divClass = ""
+					// fmt.Printf("## !div %q\n", c)
+					continue
+				}
+			}
+			switch line {
+			case "*/":
+				if kind == sectionText {
+					addCurrent(sectionText, options, false)
+					kind = sectionUndefined
+					options = nil
+					continue
+				}
+				fallthrough
+			default:
+				if kind == sectionCode {
+					trimmed := strings.TrimLeft(line, " \t")
+					// "// em.STYLE" and "// !em.STYLE" are the block forms
+					// of styled emphasis.
+					style := emPlain
+					if d, st, ok := cutEmStyle(trimmed); ok {
+						trimmed, style = d, st
+					}
+					switch trimmed {
+					case "// em":
+						current.WriteString(emStyleStart(style))
+					case "// !em":
+						// Trim trailing blank line before closing em
+						s := strings.TrimSuffix(current.String(), "\n")
+						current.Reset()
+						current.WriteString(s)
+						current.WriteString(emEnd)
+						current.WriteByte('\n')
+					case "// step":
+						current.WriteString(stepMark + "\n")
+					case "// !goroutine":
+						if goroutines == 0 {
+							return nil, errors.New("!goroutine without matching goroutine")
+						}
+						goroutines--
+						current.WriteString(goroutineMark + "\n")
+					case "// ...":
+						if !eliding && !omitting {
+							current.WriteString(line[:len(line)-len(trimmed)] + ellipsisMark + "\n")
+						}
+					case "// elide":
+						eliding = true
+					case "// omit":
+						omitting = true
+					case "// !omit":
+						omitting = false
+					case "// !elide":
+						eliding = false
+						// The elided lines look like a "// ..." line, at
+						// the indentation of the elide line.
+						if !omitting {
+							current.WriteString(line[:len(line)-len(trimmed)] + ellipsisMark + "\n")
+						}
+					default:
+						if spec, ok := strings.CutPrefix(trimmed, "// highlight "); ok {
+							rs, err := parseLineRanges(spec)
+							if err != nil {
+								return nil, err
+							}
+							highlight = append(highlight, rs...)
+							break
+						}
+						if eliding || omitting {
+							break
+						}
+						if name, ok := strings.CutPrefix(trimmed, "// goroutine "); ok {
+							if len(strings.Fields(name)) != 1 {
+								return nil, errors.New("goroutine needs one name")
+							}
+							goroutines++
+							current.WriteString(goroutineMark + strings.TrimSpace(name) + "\n")
+							break
+						}
+						if before, text, ok := strings.Cut(line, "// callout "); ok {
+							text = strings.TrimSpace(text)
+							if text == "" {
+								return nil, errors.New("callout needs text")
+							}
+							codePart := strings.TrimRight(before, " \t")
+							if strings.TrimSpace(codePart) == "" {
+								// On a line by itself, the callout is for
+								// the preceding line.
+								prev := strings.TrimSuffix(current.String(), "\n")
+								i := strings.LastIndexByte(prev, '\n') + 1
+								if strings.TrimSpace(stripEm(prev[i:])) == "" || isMarkLine(prev[i:]) {
+									return nil, errors.New("callout without a preceding code line")
+								}
+								current.Reset()
+								current.WriteString(prev[:i])
+								codePart = prev[i:]
+							}
+							if strings.Contains(codePart, calloutMark) {
+								return nil, errors.New("line has two callouts")
+							}
+							current.WriteString(codePart + calloutMark + text + "\n")
+							break
+						}
+						// Check for inline em: code // em PATTERN,PATTERN,... or code // em (whole line),
+						// or the same with a style, as in "// em.red".
+						if before, after, ok := strings.Cut(line, "// em"); ok {
+							suffix, style := cutInlineEmStyle(after)
+							if suffix == "" || suffix[0] == ' ' || suffix[0] == '\t' {
+								codePart := strings.TrimRight(before, " \t")
+								patternsStr := strings.TrimSpace(suffix)
+								if patternsStr == "" {
+									// No pattern: highlight the whole line
+									current.WriteString(emStyleStart(style) + codePart + emEnd)
+									current.WriteByte('\n')
+									break
+								}
+								res, err := emRegexps(patternsStr)
+								if err != nil {
+									return nil, err
+								}
+								if strings.TrimSpace(codePart) == "" {
+									// On a line by itself, the patterns apply
+									// to the preceding line.
+									text := strings.TrimSuffix(current.String(), "\n")
+									i := strings.LastIndexByte(text, '\n') + 1
+									if strings.TrimSpace(text[i:]) == "" {
+										return nil, errors.New("em pattern without a preceding code line")
+									}
+									// Keep the emphasis out of the line's callout.
+									code, callout, ok := strings.Cut(text[i:], calloutMark)
+									if ok {
+										callout = calloutMark + callout
+									}
+									current.Reset()
+									current.WriteString(text[:i])
+									current.WriteString(markEm(code, res, style) + callout)
+								} else {
+									current.WriteString(markEm(codePart, res, style))
+								}
+								current.WriteByte('\n')
+								break
+							}
+						}
+						current.WriteString(orig)
+						current.WriteByte('\n')
+					}
+				} else if kind == sectionOutput || kind == sectionDiagram {
+					// Keep the indentation of output, like stack traces,
+					// and diagrams, after the "// " prefix.
+					text := strings.TrimPrefix(strings.TrimPrefix(line, "//"), " ")
+					current.WriteString(strings.TrimRight(text, " \t"))
+					current.WriteByte('\n')
+				} else if kind != sectionUndefined {
+					// Strip // prefix if present
+					text := strings.TrimSpace(strings.TrimPrefix(line, "//"))
+					current.WriteString(text)
+					current.WriteByte('\n')
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if kind != sectionUndefined {
+		return nil, fmt.Errorf("unclosed %s section", kind)
+	}
+	if divClass != "" {
+		return nil, fmt.Errorf("unclosed div with class %q", divClass)
+	}
+	if len(ifs) > 0 {
+		return nil, errors.New("if without !if")
+	}
+	if inCols {
+		return nil, errors.New("unclosed cols")
+	}
+	if comparing != nil {
+		return nil, errors.New("unclosed compare")
+	}
+
+	slides = append(slides, slide)
+	if err := applyHeadings(o, filename, slides); err != nil {
+		return nil, err
+	}
+	for i, s := range slides {
+		n := 0 // code sections so far
+		for j, sec := range s.sections {
+			if sec.kind != sectionCode {
+				continue
+			}
+			n++
+			if slices.Contains(sec.attrs, attrDiff) {
+				var prev []section
+				if i > 0 {
+					prev = codeSections(slides[i-1])
+				}
+				if len(prev) < n {
+					lineNum = sec.line
+					return nil, fmt.Errorf("code diff: previous slide has no code block %d", n)
+				}
+				s.sections[j].content = markNewLines(prev[n-1].content, sec.content)
+			}
+			if err := checkRenameCollisions(sec.content, renames); err != nil {
+				lineNum = sec.line
+				return nil, err
+			}
+		}
+	}
+	for _, s := range slides {
+		if line, err := checkSlideHTML(s); err != nil {
+			lineNum = line
+			return nil, err
+		}
+		for _, sec := range s.sections {
+			var err error
+			switch sec.kind {
+			case sectionQuiz:
+				_, _, err = parseQuiz(sec.content)
+			case sectionStepper:
+				_, err = interleave(sec.options, sec.content)
+			case sectionCode:
+				if commentPrefix(o, filename) == "//" {
+					err = checkCodeIndent(sec.content)
+				}
+			}
+			if err != nil {
+				lineNum = sec.line
+				return nil, err
+			}
+		}
+	}
+	for _, s := range slides {
+		for j, sec := range s.sections {
+			// Sequence diagrams are drawn when the deck is built.
+			if sec.kind != sectionDiagram || slices.Equal(sec.options, []string{"sequence"}) {
+				continue
+			}
+			lang := "mermaid"
+			if len(sec.options) > 0 {
+				lang = sec.options[0]
+			}
+			svg, err := renderDiagram(o, lang, sec.content)
+			if err != nil {
+				lineNum = sec.line
+				return nil, err
+			}
+			s.sections[j].content = svg
+		}
+	}
+	if checkSameAs {
+		for i, s := range slides {
+			if s.sameAs == nil {
+				continue
+			}
+			if err := checkSame(o, filename, slides[:i], s); err != nil {
+				lineNum = s.sameAs.line
+				return nil, err
+			}
+		}
+	}
+	return slides, nil
+}
+
+// checkSame checks the code of s, a slide of filename, against the slide
+// that its same-as directive names. prev holds the slides before s.
+func checkSame(o *options, filename string, prev []*Slide, s *Slide) error {
+	what := "the previous slide"
+	if f := s.sameAs.file; f != "" {
+		var err error
+		prev, err = scanSlides(o, filepath.Join(filepath.Dir(filename), f), false)
+		if err != nil {
+			return err
+		}
+		what = "the last slide of " + f
+	}
+	if len(prev) == 0 {
+		return fmt.Errorf("same-as: there is no %s", strings.TrimPrefix(what, "the "))
+	}
+	want := codeSections(prev[len(prev)-1])
+	got := codeSections(s)
+	if len(got) != len(want) {
+		return fmt.Errorf("same-as: slide has %d code blocks, but %s has %d", len(got), what, len(want))
+	}
+	for i := range got {
+		if d := codeDifference(want[i].content, got[i].content); d != "" {
+			return fmt.Errorf("same-as: code block %d differs from %s:\n%s", i+1, what, d)
+		}
+	}
+	return nil
+}
+
+// codeDifference returns the first difference between the code sections
+// a and b, as a line to delete from a or add to it, or the empty string if
+// they show the same code.
+func codeDifference(a, b string) string {
+	lines := func(s string) []string {
+		var ls []string
+		for line := range strings.Lines(stripCallouts(stripEm(s))) {
+			line = strings.TrimRight(line, " \t\n")
+			if !isMarkLine(line) {
+				ls = append(ls, line)
+			}
+		}
+		for len(ls) > 0 && ls[len(ls)-1] == "" {
+			ls = ls[:len(ls)-1]
+		}
+		return ls
+	}
+	for _, d := range diffLines(lines(a), lines(b)) {
+		switch d.op {
+		case diffDelete:
+			return "\t- " + d.text
+		case diffInsert:
+			return "\t+ " + d.text
+		}
+	}
+	return ""
+}
+
+// isIdent reports whether s is a Go identifier.
+func isIdent(s string) bool {
+	return identRe.FindString(s) == s && s != ""
+}
+
+// renderIdent returns the name that identifier id is displayed as.
+func renderIdent(id string, renames map[string]string) string {
+	if r, ok := renames[id]; ok {
+		return r
+	}
+	return stripUnderscoreSuffix(id)
+}
+
+// checkRenameCollisions returns an error if a rename would make two
+// different identifiers in code display the same.
+// Identifiers that differ only by underscore suffix are meant to look
+// the same, so they don't count.
+func checkRenameCollisions(code string, renames map[string]string) error {
+	if len(renames) == 0 {
+		return nil
+	}
+	shown := map[string]string{} // rendered name to an identifier that renders that way
+	for _, id := range identRe.FindAllString(code, -1) {
+		r := renderIdent(id, renames)
+		other, ok := shown[r]
+		if !ok {
+			shown[r] = id
+			continue
+		}
+		_, renamed := renames[id]
+		_, otherRenamed := renames[other]
+		if other != id && (renamed || otherRenamed) {
+			return fmt.Errorf("rename collision: %s and %s both display as %s", other, id, r)
+		}
+	}
+	return nil
+}
+
+// findTodos returns "FILE:LINE: TEXT" for each todo directive in slides,
+// and for each section whose content mentions TODO.
+func findTodos(slides []*Slide) []string {
+	type entry struct {
+		file string
+		line int
+		msg  string
+	}
+	var es []entry
+	for _, s := range slides {
+		for _, t := range s.todos {
+			es = append(es, entry{s.filename, t.line, "todo " + t.text})
+		}
+		for _, sec := range s.sections {
+			i := 0
+			for line := range strings.Lines(sec.content) {
+				if strings.Contains(line, "TODO") {
+					es = append(es, entry{s.filename, sec.bodyLine + i, fmt.Sprintf("%s: %s", sec.kind, strings.TrimSpace(line))})
+				}
+				i++
+			}
+		}
+	}
+	slices.SortStableFunc(es, func(a, b entry) int { return a.line - b.line })
+	var res []string
+	for _, e := range es {
+		res = append(res, fmt.Sprintf("%s:%d: %s", e.file, e.line, e.msg))
+	}
+	return res
+}
+
+// reportTodos writes the TODOs in files to w.
+func reportTodos(o *options, w io.Writer, files []string) error {
+	for _, filename := range files {
+		slides, err := scanFile(o, filename)
+		if err != nil {
+			return fmt.Errorf("error processing %s: %w", filename, err)
+		}
+		for _, t := range findTodos(slides) {
+			fmt.Fprintln(w, t)
+		}
+	}
+	return nil
+}
+
+// includePart returns the part of content selected by addr, without any
+// region markers. See the include directive. Line comments in content
+// begin with prefix.
+func includePart(content []byte, addr, prefix string) ([]byte, error) {
+	var err error
+	switch m := lineRangeRe.FindStringSubmatch(addr); {
+	case addr == "":
+	case strings.HasPrefix(addr, "/"):
+		// /RE1/ [/RE2/], optionally with a comma between.
+		var re1, re2 string
+		reParts := strings.Split(addr, "/")
+		if len(reParts) > 1 {
+			re1 = strings.TrimSpace(reParts[1])
+		}
+		if len(reParts) > 3 {
+			re2 = strings.TrimSpace(reParts[3])
+		}
+		content, err = includeRange(content, re1, re2)
+	case m != nil:
+		from, _ := strconv.Atoi(m[1])
+		to := from
+		if m[2] != "" {
+			to, _ = strconv.Atoi(m[2])
+		}
+		content, err = includeLines(content, from, to)
+	default:
+		content, err = includeRegion(content, addr, prefix)
+	}
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(string(content), "\n")
+	lines = slices.DeleteFunc(lines, func(l string) bool {
+		first, _, _ := splitFirstWord(slashComments(l, prefix))
+		return first == "region" || first == "!region"
+	})
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// lineRangeRe matches an include address of the form N or N,M.
+var lineRangeRe = regexp.MustCompile(`^(\d+)(?:,(\d+))?$`)
+
+// includeLines returns lines from through to of content, counting from 1.
+func includeLines(content []byte, from, to int) ([]byte, error) {
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	if from < 1 || to < from || to > len(lines) {
+		return nil, fmt.Errorf("lines %d,%d out of range: file has %d lines", from, to, len(lines))
+	}
+	return []byte(strings.Join(lines[from-1:to], "\n")), nil
+}
+
+// includeRegion returns the lines of content between "// region NAME"
+// and "// !region NAME", where the comments begin with prefix.
+func includeRegion(content []byte, name, prefix string) ([]byte, error) {
+	lines := strings.Split(string(content), "\n")
+	start, end := -1, -1
+	for i, l := range lines {
+		first, rest, _ := splitFirstWord(slashComments(l, prefix))
+		if rest != name {
+			continue
+		}
+		if first == "region" && start < 0 {
+			start = i + 1
+		} else if first == "!region" && start >= 0 {
+			end = i
+			break
+		}
+	}
+	if start < 0 {
+		return nil, fmt.Errorf("no region %q", name)
+	}
+	if end < 0 {
+		return nil, fmt.Errorf("region %q has no !region", name)
+	}
+	return []byte(strings.Join(lines[start:end], "\n")), nil
+}
+
+func includeRange(content []byte, re1, re2 string) ([]byte, error) {
+	if re1 == "" {
+		return content, nil
+	}
+	lines := strings.Split(string(content), "\n")
+	r1, err := regexp.Compile(re1)
+	if err != nil {
+		return nil, fmt.Errorf("invalid re1 %q: %w", re1, err)
+	}
+	start := -1
+	for i, line := range lines {
+		if r1.MatchString(line) {
+			start = i
+			break
+		}
+	}
+	if start == -1 {
+		return nil, fmt.Errorf("regexp %q not found", re1)
+	}
+
+	end := len(lines)
+	if re2 != "" {
+		r2, err := regexp.Compile(re2)
+		if err != nil {
+			return nil, fmt.Errorf("invalid re2 %q: %w", re2, err)
+		}
+		found := false
+		for i := start + 1; i < len(lines); i++ {
+			if r2.MatchString(lines[i]) {
+				end = i + 1
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("regexp %q not found after match for %q", re2, re1)
+		}
+	}
+	return []byte(strings.Join(lines[start:end], "\n")), nil
+}
+
+// docRefRe matches a reference to an exported name of a package, like
+// "sync.WaitGroup" or "sync.WaitGroup.Add". The first group is the package
+// name and the second the name in it.
+var docRefRe = regexp.MustCompile(`\b([a-z][a-z0-9]*)\.([A-Z]\w*(?:\.[A-Z]\w*)?)\b`)
+
+// docRefs returns the byte ranges of the references in s to names in the
+// packages of links, which maps package names to import paths, and the
+// URLs of their documentation.
+func docRefs(s string, links map[string]string) (ranges [][2]int, urls []string) {
+	if len(links) == 0 {
+		return nil, nil
+	}
+	for _, m := range docRefRe.FindAllStringSubmatchIndex(s, -1) {
+		// Skip selectors of selectors, like x.sync.Mutex.
+		if m[0] > 0 && s[m[0]-1] == '.' {
+			continue
+		}
+		imp, ok := links[s[m[2]:m[3]]]
+		if !ok {
+			continue
+		}
+		ranges = append(ranges, [2]int{m[0], m[1]})
+		urls = append(urls, fmt.Sprintf("https://pkg.go.dev/%s#%s", imp, s[m[4]:m[5]]))
+	}
+	return ranges, urls
+}
+
+// codeSpanRe matches the code spans of rendered markdown, with the link
+// or preformatted block that may surround them.
+var codeSpanRe = regexp.MustCompile(`(?s)(<a [^>]*>|<pre>)?<code>(.*?)</code>`)
+
+// linkDocRefs links the references to names in the packages of links in
+// the code spans of h, which is rendered markdown. Code in links and code
+// blocks is left alone.
+func linkDocRefs(h string, links map[string]string) string {
+	if len(links) == 0 {
+		return h
+	}
+	return codeSpanRe.ReplaceAllStringFunc(h, func(span string) string {
+		m := codeSpanRe.FindStringSubmatch(span)
+		if m[1] != "" {
+			return span
+		}
+		code := m[2]
+		ranges, urls := docRefs(code, links)
+		var b strings.Builder
+		last := 0
+		for i, r := range ranges {
+			fmt.Fprintf(&b, "%s<a class='doc' href=%q>%s</a>", code[last:r[0]], urls[i], code[r[0]:r[1]])
+			last = r[1]
+		}
+		b.WriteString(code[last:])
+		return "<code>" + b.String() + "</code>"
+	})
+}
+
+// commentPrefixes maps the extensions of source files that aren't Go
+// to the prefix of their line comments.
+var commentPrefixes = map[string]string{
+	".py":   "#",
+	".sh":   "#",
+	".rb":   "#",
+	".yaml": "#",
+	".yml":  "#",
+	".sql":  "--",
+	".lua":  "--",
+}
+
+// commentPrefix returns the prefix of line comments in filename.
+func commentPrefix(o *options, filename string) string {
+	if o.comment != "" {
+		return o.comment
+	}
+	if p, ok := commentPrefixes[filepath.Ext(filename)]; ok {
+		return p
+	}
+	return "//"
+}
+
+// slashComments rewrites a line whose comments begin with prefix so that
+// they begin with "//", so it can be scanned like Go. It rewrites a comment
+// that makes up the whole line, or a trailing em directive.
+func slashComments(line, prefix string) string {
+	if prefix == "//" {
+		return line
+	}
+	trimmed := strings.TrimLeft(line, " \t")
+	if rest, ok := strings.CutPrefix(trimmed, prefix); ok {
+		return line[:len(line)-len(trimmed)] + "//" + rest
+	}
+	if before, after, ok := strings.Cut(line, " "+prefix+" em"); ok {
+		if after == "" || after[0] == ' ' || after[0] == '\t' {
+			return before + " // em" + after
+		}
+	}
+	return line
+}
+
+// splitFirst word splits s into a first word and the remaining part.
+// A word is a sequence of nonblank characters.
+// s must be a comment line, whose first nonblank characters are "//" or
+// "/*". If not, the third return value is false.
+func splitFirstWord(s string) (string, string, bool) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "//") && !strings.HasPrefix(s, "/*") {
+		return "", "", false
+	}
+	s = strings.TrimSpace(s[2:])
+	i := strings.IndexAny(s, " \t")
+	if i < 0 {
+		return s, "", true
+	}
+	return s[:i], strings.TrimSpace(s[i+1:]), true
+}
+
+// A codeAttr is an attribute of a code section, written as a word
+// after the code directive. Attributes change how the section looks
+// or behaves.
+type codeAttr string
+
+const (
+	attrBad       codeAttr = "bad"
+	attrWeak      codeAttr = "weak"
+	attrSmall     codeAttr = "small"
+	attrSmaller   codeAttr = "smaller"
+	attrLarge     codeAttr = "large"
+	attrNoNumbers codeAttr = "nonumbers"
+	attrAlign     codeAttr = "align"
+	attrPlay      codeAttr = "play"
+	attrNoEscape  codeAttr = "noescape"
+	attrDiff      codeAttr = "diff"
+	attrCritical  codeAttr = "critical"
+	attrFit       codeAttr = "fit"
+	attrScroll    codeAttr = "scroll"
+	attrSplit     codeAttr = "split"
+)
+
+// codeAttrs maps each word allowed after the code directive to its attribute.
+var codeAttrs = map[string]codeAttr{
+	"bad":       attrBad,
+	"weak":      attrWeak,
+	"small":     attrSmall,
+	"smaller":   attrSmaller,
+	"large":     attrLarge,
+	"nonumbers": attrNoNumbers,
+	"nonum":     attrNoNumbers,
+	"align":     attrAlign,
+	"play":      attrPlay,
+	"noescape":  attrNoEscape,
+	"diff":      attrDiff,
+	"critical":  attrCritical,
+	"fit":       attrFit,
+	"scroll":    attrScroll,
+	"split":     attrSplit,
+}
+
+// class returns the CSS class that the attribute adds to a code section.
+func (a codeAttr) class() string {
+	if a == attrPlay {
+		// The class that play.js looks for.
+		return "playground"
+	}
+	return string(a)
+}
+
+// parseCodeAttrs parses the words after a code directive.
+func parseCodeAttrs(words []string) ([]codeAttr, error) {
+	var attrs []codeAttr
+	nsizes, noverflows := 0, 0
+	for _, w := range words {
+		a, ok := codeAttrs[w]
+		if !ok {
+			return nil, fmt.Errorf("invalid code option %q", w)
+		}
+		switch a {
+		case attrSmall, attrSmaller, attrLarge:
+			nsizes++
+		case attrFit, attrScroll, attrSplit:
+			noverflows++
+		}
+		attrs = append(attrs, a)
+	}
+	if nsizes > 1 {
+		return nil, errors.New("cannot use multiple sizes")
+	}
+	if noverflows > 1 {
+		return nil, errors.New("cannot use more than one of fit, scroll and split")
+	}
+	return attrs, nil
+}
+
+func writeSlideHTML(o *options, w *indentWriter, slide *Slide, pageNum int, isLast bool) {
+	// 	for _, st := range slide.subtitles {
+	// 		w.linef("<div class='subtitle-text'>%s<br/></div>", html.EscapeString(st))
+	// 	}
+	// 	w.close("</article>")
+	// 	return
+	// }
+
+	w.linef("\n<!-- slide %d -->", pageNum)
+	eh := html.EscapeString(slide.heading)
+	var classes []string
+	if slide.isDivider {
+		classes = append(classes, "title-slide", "divider")
+	} else if slide.isTitle {
+		classes = append(classes, "title-slide")
+	}
+	if slide.optional {
+		classes = append(classes, "optional")
+	}
+	if slide.hold != "" {
+		classes = append(classes, "held")
+	}
+	open := "<article"
+	if len(classes) > 0 {
+		open += fmt.Sprintf(" class='%s'", strings.Join(classes, " "))
+	}
+	if len(slide.tags) > 0 {
+		open += fmt.Sprintf(" data-tags='%s'", html.EscapeString(strings.Join(slide.tags, " ")))
+	}
+	if slide.id != "" {
+		open += fmt.Sprintf(" data-slide='%s'", html.EscapeString(slide.id))
+	}
+	if slide.exNum > 0 {
+		open += fmt.Sprintf(" data-exercise='%d'", slide.exNum)
+	}
+	if slide.planned > 0 {
+		open += fmt.Sprintf(" data-time='%d'", int(slide.planned.Seconds()))
+	}
+	w.open(open + ">")
+	if slide.isTitle {
+		w.linef("<div class='title-text'>%s</div>", eh)
+	} else {
+		w.linef("<h1>%s</h1>", eh)
+	}
+	hints := 0          // hints of the current question, still open
+	answerOpen := false // the answer of a question with hints is open
+	for i, sec := range slide.sections {
+		// Check if next section continues inside the answer
+		nextInAnswer := i+1 < len(slide.sections) && slide.sections[i+1].inAnswer
+
+		switch sec.kind {
+		case sectionCode:
+			classes := []string{"code"}
+			for _, a := range sec.attrs {
+				classes = append(classes, a.class())
+			}
+			if a := overflowAttr(o, sec.attrs); a != "" && !slices.Contains(sec.attrs, a) {
+				classes = append(classes, a.class())
+			}
+			classes = append(classes, sec.classes...)
+			pre := "<pre>"
+			if slices.Contains(sec.attrs, attrPlay) {
+				pre = "<pre contenteditable='true' spellcheck='false'>"
+			}
+			w.open(fmt.Sprintf("<div class='%s' data-kind='code'>%s", strings.Join(classes, " "), pre))
+			fmt.Fprint(w, codeHTML(o, sec, slide))
+
+			if sec.inAnswer {
+				// Code inside answer: render without outer div structure
+				w.close("</pre></div>")
+			} else {
+				fmt.Fprintln(w, "</pre>") // don't use close, avoid blank line
+				w.close("</div>")
+			}
+		case sectionText:
+			w.open("<div" + sec.classAttr("text") + " data-kind='text'>")
+			// Don't use w.lines, because the markdown may render
+			// with a <pre> and then the indentation will show up.
+			fmt.Fprint(w, renderMarkdown(o, sec.content))
+			w.close("</div>")
+		case sectionQuestion:
+			w.open("<details" + sec.classAttr("") + " data-kind='question'>")
+			w.open("<summary>")
+			if sec.num > 0 {
+				w.linef("<span class='question-number'>Question %d.</span>", sec.num)
+			}
+			if strings.TrimSpace(sec.content) == "" {
+				// The question is asked elsewhere, like in a code comment.
+				fmt.Fprint(w, html.EscapeString(o.answerSummary))
+			} else {
+				fmt.Fprint(w, stripPara(renderMarkdown(o, sec.content)))
+			}
+			w.close("</summary>")
+		case sectionHint:
+			// Each hint holds the next hint, and the last holds the answer.
+			hints++
+			w.open("<details" + sec.classAttr("hint") + " data-kind='hint'>")
+			w.linef("<summary>Hint %d</summary>", hints)
+			w.open("<div class='hint'>")
+			fmt.Fprint(w, renderMarkdown(o, sec.content))
+			w.close("</div>")
+		case sectionAnswer:
+			if hints > 0 && !sec.inAnswer && !answerOpen {
+				w.open("<details class='hint' data-kind='answer'>")
+				w.linef("<summary>%s</summary>", html.EscapeString(o.answerSummary))
+				answerOpen = true
+			}
+			w.open("<div" + sec.classAttr("answer") + " data-kind='answer'>")
+			fmt.Fprint(w, renderMarkdown(o, sec.content))
+			w.close("</div>")
+			// Only close details if not followed by more answer content
+			if !nextInAnswer {
+				if answerOpen {
+					w.close("</details>")
+				}
+				for range hints {
+					w.close("</details>")
+				}
+				w.close("</details>")
+				hints, answerOpen = 0, false
+			}
+		case sectionOutput:
+			// Avoid two consecutive inline-block divs from appearing
+			// next to each other.
+			fmt.Fprintln(w, "<div></div>")
+			w.open("<div" + sec.classAttr("output") + " data-kind='output'>" + outputConditions(sec) + "<pre>")
+			fmt.Fprint(w, html.EscapeString(sec.content))
+			fmt.Fprintln(w, "</pre>") // indenting adds a blank line
+			w.close("</div>")
+		case sectionLiveTest:
+			args := html.EscapeString(strings.Join(sec.options, " "))
+			w.open(fmt.Sprintf("<div class='livetest' data-dir='%s' data-args='%s'>", html.EscapeString(sec.content), args))
+			w.linef("<button>go test %s</button>", args)
+			w.linef("<pre>Live test output needs code2slides serve.</pre>")
+			w.close("</div>")
+		case sectionPoll:
+			w.open(fmt.Sprintf("<div%s data-poll='%d'>", sec.classAttr("poll"), pageNum))
+			for _, c := range sec.options {
+				w.linef("<button data-choice='%s'>%[1]s</button>", html.EscapeString(c))
+			}
+			w.linef("<div class='poll-chart'>Polls need code2slides serve.</div>")
+			w.close("</div>")
+		case sectionNote:
+			if o.includeNotes {
+				fmt.Fprint(w, renderMarkdown(o, sec.content))
+			}
+		case sectionHTML:
+			w.linef("%s", sec.content)
+		case sectionQuiz:
+			prompt, choices, _ := parseQuiz(sec.content)
+			w.open("<div" + sec.classAttr("quiz") + " data-kind='quiz'>")
+			if prompt != "" {
+				w.linef("<div class='quiz-prompt'>%s</div>", strings.TrimSpace(renderMarkdown(o, prompt)))
+			}
+			for _, c := range choices {
+				correct := ""
+				if c.correct {
+					correct = " data-correct='true'"
+				}
+				w.linef("<button class='quiz-choice'%s>%s</button>", correct, strings.TrimSpace(stripPara(renderMarkdown(o, c.text))))
+			}
+			w.close("</div>")
+		case sectionStepper:
+			il, _ := interleave(sec.options, sec.content)
+			w.open(fmt.Sprintf("<div%s data-kind='stepper' data-steps='%s'>", sec.classAttr("stepper"), html.EscapeString(il.dataJSON())))
+			w.open("<div class='stepper-goroutines'>")
+			for g, gr := range il.Goroutines {
+				w.open("<div class='stepper-goroutine'>")
+				w.linef("<div class='stepper-name'>%s</div>", html.EscapeString(gr.Name))
+				for i, stmt := range gr.Stmts {
+					w.linef("<div class='stepper-stmt' data-g='%d' data-stmt='%d'>%s</div>", g, i, html.EscapeString(stmt))
+				}
+				w.linef("<div class='stepper-vars' data-g='%d'></div>", g)
+				w.close("</div>")
+			}
+			w.close("</div>")
+			w.linef("<div class='stepper-vars stepper-shared'></div>")
+			w.linef("<button class='stepper-prev'>&#9664;</button> <button class='stepper-next'>&#9654;</button>")
+			w.close("</div>")
+		case sectionTimeline:
+			w.open("<ol" + sec.classAttr("timeline") + " data-kind='timeline'>")
+			for _, step := range timelineSteps(sec.content) {
+				w.linef("<li class='tstep'>%s</li>", strings.TrimSpace(stripPara(renderMarkdown(o, step))))
+			}
+			w.close("</ol>")
+		case sectionDiagram:
+			w.open("<div" + sec.classAttr("diagram") + " data-kind='diagram'>")
+			w.linef("%s", sec.content)
+			w.close("</div>")
+		case sectionSolution:
+			if o.includeNotes {
+				w.linef("%s", sec.content)
+			}
+		case sectionColumns:
+			// A column's width, relative to the others, is its flex-grow.
+			col := "<div>"
+			if len(sec.options) > 0 {
+				col = fmt.Sprintf("<div style='flex: %s 1 0'>", sec.options[0])
+			}
+			switch sec.content {
+			case "cols":
+				w.linef(`<div class="flex">%s`, col)
+			case "nextcol":
+				w.linef("</div>")
+				w.linef("%s <!-- next col -->", col)
+			case "!cols":
+				w.linef("</div></div> <!-- flex -->")
+			}
+		case sectionCompare:
+			fmt.Fprintln(w, "<div></div>")
+			writeCompareHTML(w, sec)
+
+		case sectionLine:
+			w.linef("%s<br/>", stripPara(renderMarkdown(o, sec.content)))
+
+		case sectionSubtitle:
+			w.open("<div" + sec.classAttr("subtitle-text") + ">")
+			w.lines(renderMarkdown(o, sec.content))
+			w.close("</div>")
+		}
+	}
+	if len(slide.titleInfo) > 0 {
+		w.open("<div class='title-info'>")
+		for _, t := range slide.titleInfo {
+			w.linef("<div class='%s'>%s</div>", t.kind, stripPara(renderMarkdown(o, t.text)))
+		}
+		w.close("</div>")
+	}
+	var footnotes []string
+	for _, sec := range slide.sections {
+		if sec.kind == sectionFootnote {
+			footnotes = append(footnotes, sec.content)
+		}
+	}
+	if len(footnotes) > 0 {
+		w.open("<div class='footnotes'>")
+		for _, f := range footnotes {
+			fmt.Fprint(w, renderMarkdown(o, f))
+		}
+		w.close("</div>")
+	}
+	if o.footer != "" {
+		w.linef("<div class='footer'>%s</div>", stripPara(renderMarkdown(o, o.footer)))
+	}
+	label := fmt.Sprint(pageNum)
+	if slide.pageLabel != "" {
+		label = slide.pageLabel
+	}
+	if isLast {
+		w.linef("<span class='pagenumber'>%s and last</span>", label)
+	} else {
+		w.linef("<span class='pagenumber'>%s</span>", label)
+	}
+	w.close("</article>")
+}
+
+var identRe = regexp.MustCompile(`[a-zA-Z_][a-zA-Z0-9_]*`)
+
+// stripUnderscoreSuffix removes an underscore suffix from an identifier.
+// For example, "foo_3x" becomes "foo". Identifiers starting with an
+// underscore (like "_private") are left unchanged.
+func stripUnderscoreSuffix(id string) string {
+	if i := strings.Index(id, "_"); i > 0 {
+		return id[:i]
+	}
+	return id
+}
+
+const (
+	emStart = "\x00em\x00"
+	emEnd   = "\x00/em\x00"
+)
+
+// An emStyle is the emphasis of a byte of code: none, if it is empty,
+// the plain emphasis of the em directive, or a named style, like "red"
+// for "em.red".
+type emStyle string
+
+const emPlain emStyle = "."
+
+// emStyleRe matches the name of an emphasis style.
+var emStyleRe = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// emMarkRe matches an emphasis marker, with the style of a start marker.
+var emMarkRe = regexp.MustCompile("^\x00(/?)em(?:\\.([a-z][a-z0-9-]*))?\x00")
+
+// emStyleStart returns the marker that begins emphasis in style.
+func emStyleStart(style emStyle) string {
+	if style == emPlain || style == "" {
+		return emStart
+	}
+	return "\x00em." + string(style) + "\x00"
+}
+
+// stripEm returns s without its emphasis markers.
+func stripEm(s string) string {
+	if !strings.Contains(s, "\x00") {
+		return s
+	}
+	var b strings.Builder
+	for len(s) > 0 {
+		if m := emMarkRe.FindString(s); m != "" {
+			s = s[len(m):]
+			continue
+		}
+		b.WriteByte(s[0])
+		s = s[1:]
+	}
+	return b.String()
+}
+
+// cutEmStyle splits the block form of a styled em directive, like
+// "// em.red" or "// !em.red", into the directive without its style and
+// the style.
+func cutEmStyle(line string) (directive string, style emStyle, ok bool) {
+	d, name, ok := strings.Cut(line, ".")
+	if !ok || (d != "// em" && d != "// !em") || !emStyleRe.MatchString(name) {
+		return "", "", false
+	}
+	return d, emStyle(name), true
+}
+
+// cutInlineEmStyle returns what follows "// em" in the inline form of an
+// em directive, without the style if it has one, and the style. A suffix
+// that begins with something other than a style is returned as is.
+func cutInlineEmStyle(after string) (string, emStyle) {
+	name, ok := strings.CutPrefix(after, ".")
+	if !ok {
+		return after, emPlain
+	}
+	if i := strings.IndexAny(name, " \t"); i >= 0 {
+		name = name[:i]
+	}
+	if !emStyleRe.MatchString(name) {
+		return after, emPlain
+	}
+	return after[1+len(name):], emStyle(name)
+}
+
+// timelineStepRe matches the beginning of a step of a timeline.
+var timelineStepRe = regexp.MustCompile(`^(?:\d+\.|[-*])\s+`)
+
+// timelineSteps returns the markdown of the steps of a timeline section,
+// without their list markers. Lines that don't begin a step continue the
+// step before them.
+func timelineSteps(s string) []string {
+	var steps []string
+	for line := range strings.Lines(s) {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case timelineStepRe.MatchString(line) || len(steps) == 0:
+			steps = append(steps, timelineStepRe.ReplaceAllString(line, "")+"\n")
+		default:
+			steps[len(steps)-1] += line + "\n"
+		}
+	}
+	return steps
+}
+
+// quizChoiceRe matches the beginning of a choice of a quiz, capturing
+// whether it is marked correct.
+var quizChoiceRe = regexp.MustCompile(`^[-*]\s+\[([ xX])\]\s+`)
+
+// A quizChoice is one of the choices of a quiz.
+type quizChoice struct {
+	text    string // markdown
+	correct bool
+}
+
+// parseQuiz parses the content of a quiz section into the markdown of its
+// prompt, the lines before the first choice, and its choices. Lines after
+// a choice that don't begin another continue it.
+func parseQuiz(s string) (prompt string, choices []quizChoice, err error) {
+	var b strings.Builder
+	ncorrect := 0
+	for line := range strings.Lines(s) {
+		m := quizChoiceRe.FindStringSubmatch(line)
+		switch {
+		case m != nil:
+			c := quizChoice{text: line[len(m[0]):], correct: m[1] != " "}
+			if c.correct {
+				ncorrect++
+			}
+			choices = append(choices, c)
+		case len(choices) == 0:
+			b.WriteString(line)
+		case strings.TrimSpace(line) != "":
+			choices[len(choices)-1].text += line
+		}
+	}
+	if len(choices) < 2 {
+		return "", nil, errors.New(`quiz needs at least two choices, like "- [ ] CHOICE"`)
+	}
+	if ncorrect == 0 {
+		return "", nil, errors.New(`quiz has no correct choice: mark one with "- [x]"`)
+	}
+	return strings.TrimSpace(b.String()), choices, nil
+}
+
+// stepMark is the line that a step directive leaves in a code section.
+const stepMark = "\x00step\x00"
+
+// goroutineMark begins the line that a goroutine directive leaves in a
+// code section, followed by the goroutine's name. By itself, it is the line
+// left by !goroutine.
+const goroutineMark = "\x00goroutine\x00"
+
+// isMarkLine reports whether line, without its newline, is one of the
+// lines that directives leave in code sections to mark the lines after
+// them, rather than a line of code.
+func isMarkLine(line string) bool {
+	return line == stepMark || strings.HasPrefix(line, goroutineMark)
+}
+
+// splitGoroutines removes the goroutine marks from s, the content of a
+// code section, and returns the rest and the goroutine that each of its
+// lines runs on, or "" if it isn't marked.
+func splitGoroutines(s string) (string, []string) {
+	var b strings.Builder
+	var gs, stack []string
+	for line := range strings.Lines(s) {
+		if name, ok := strings.CutPrefix(strings.TrimSuffix(line, "\n"), goroutineMark); ok {
+			if name != "" {
+				stack = append(stack, name)
+			} else if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			continue
+		}
+		b.WriteString(line)
+		g := ""
+		if len(stack) > 0 {
+			g = stack[len(stack)-1]
+		}
+		gs = append(gs, g)
+	}
+	return b.String(), gs
+}
+
+// ellipsisMark is what a "// ..." line leaves in a code section, after
+// its indentation. It has no identifier characters, so renames can't
+// change it.
+const ellipsisMark = "\x00\u22ee\x00"
+
+// calloutMark separates a line of code from the text of the callout that
+// a callout directive attaches to it, to the end of the line.
+const calloutMark = "\x00callout\x00"
+
+// splitCallouts removes the callouts from s, the content of a code
+// section, and returns the rest and the callout of each of its lines, or
+// "" if it has none.
+func splitCallouts(s string) (string, []string) {
+	var b strings.Builder
+	var callouts []string
+	for line := range strings.Lines(s) {
+		code, text, _ := strings.Cut(strings.TrimSuffix(line, "\n"), calloutMark)
+		b.WriteString(code)
+		if strings.HasSuffix(line, "\n") {
+			b.WriteByte('\n')
+		}
+		callouts = append(callouts, text)
+	}
+	return b.String(), callouts
+}
+
+// stripCallouts returns s without its callouts.
+func stripCallouts(s string) string {
+	if !strings.Contains(s, calloutMark) {
+		return s
+	}
+	s, _ = splitCallouts(s)
+	return s
+}
+
+// splitSteps removes the step marks from s, the content of a code section,
+// and returns the rest and the step of each of its lines: 0 before the
+// first mark, 1 after it, and so on.
+func splitSteps(s string) (string, []int) {
+	var b strings.Builder
+	var steps []int
+	step := 0
+	for line := range strings.Lines(s) {
+		if strings.TrimSuffix(line, "\n") == stepMark {
+			step++
+			continue
+		}
+		b.WriteString(line)
+		steps = append(steps, step)
+	}
+	return b.String(), steps
+}
+
+// A codeLine is a line of code along with which of its bytes are emphasized.
+// Emphasis is represented in section content by emStart and emEnd markers,
+// which may span lines and nest. Working with a codeLine instead of the
+// markers lets us edit the text without worrying about where the markers are,
+// and lets us emit well-formed HTML when emphasis crosses a comment or
+// definition.
+type codeLine struct {
+	text string
+	em   []emStyle // len(em) == len(text)
+}
+
+// parseEm splits s into lines and removes its emphasis markers.
+func parseEm(s string) []codeLine {
+	var lines []codeLine
+	var styles []emStyle // of the open emphasis, innermost last
+	for _, line := range strings.Split(s, "\n") {
+		var cl codeLine
+		var text strings.Builder
+		for len(line) > 0 {
+			if m := emMarkRe.FindStringSubmatch(line); m != nil {
+				if m[1] == "/" {
+					if len(styles) > 0 {
+						styles = styles[:len(styles)-1]
+					}
+				} else {
+					styles = append(styles, cmp.Or(emStyle(m[2]), emPlain))
+				}
+				line = line[len(m[0]):]
+			} else {
+				var style emStyle
+				if len(styles) > 0 {
+					style = styles[len(styles)-1]
+				}
+				text.WriteByte(line[0])
+				cl.em = append(cl.em, style)
+				line = line[1:]
+			}
+		}
+		cl.text = text.String()
+		lines = append(lines, cl)
+	}
+	return lines
+}
+
+// codeSections returns the code sections of s.
+func codeSections(s *Slide) []section {
+	var secs []section
+	for _, sec := range s.sections {
+		if sec.kind == sectionCode {
+			secs = append(secs, sec)
+		}
+	}
+	return secs
+}
+
+// markNewLines returns the code cur with emphasis on its lines that aren't
+// in prev. Lines are compared without emphasis or surrounding space, and
+// blank lines are never emphasized.
+func markNewLines(prev, cur string) string {
+	key := func(s string) []string {
+		var keys []string
+		for line := range strings.Lines(s) {
+			if k := strings.TrimSpace(stripEm(stripCallouts(line))); k != "" && !isMarkLine(k) {
+				keys = append(keys, k)
+			}
+		}
+		return keys
+	}
+	var isNew []bool // for the nonblank lines of cur
+	for _, d := range diffLines(key(prev), key(cur)) {
+		if d.op != diffDelete {
+			isNew = append(isNew, d.op == diffInsert)
+		}
+	}
+	var b strings.Builder
+	i := 0
+	for line := range strings.Lines(cur) {
+		k := strings.TrimSpace(stripEm(line))
+		if k == "" || isMarkLine(k) {
+			b.WriteString(line)
+			continue
+		}
+		if isNew[i] {
+			text := strings.TrimSuffix(line, "\n")
+			code, callout, ok := strings.Cut(text, calloutMark)
+			if ok {
+				callout = calloutMark + callout
+			}
+			rest := strings.TrimLeft(code, " \t")
+			line = code[:len(code)-len(rest)] + emStart + rest + emEnd + callout + line[len(text):]
+		}
+		b.WriteString(line)
+		i++
+	}
+	return b.String()
+}
+
+// emRegexps compiles the comma-separated patterns of an em directive.
+func emRegexps(patterns string) ([]*regexp.Regexp, error) {
+	var res []*regexp.Regexp
+	for _, pattern := range strings.Split(patterns, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid em regexp %q: %w", pattern, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// markEm returns code with every match of each of res wrapped in emphasis
+// markers for style. Overlapping and adjacent matches are merged.
+func markEm(code string, res []*regexp.Regexp, style emStyle) string {
+	em := make([]bool, len(code))
+	for _, re := range res {
+		for _, m := range re.FindAllStringIndex(code, -1) {
+			for i := m[0]; i < m[1]; i++ {
+				em[i] = true
+			}
+		}
+	}
+	var b strings.Builder
+	for i := range len(code) {
+		if em[i] && (i == 0 || !em[i-1]) {
+			b.WriteString(emStyleStart(style))
+		}
+		b.WriteByte(code[i])
+		if em[i] && (i == len(code)-1 || !em[i+1]) {
+			b.WriteString(emEnd)
+		}
+	}
+	return b.String()
+}
+
+// trimLeft removes the first n bytes of l.
+func (l codeLine) trimLeft(n int) codeLine {
+	n = min(n, len(l.text))
+	return codeLine{l.text[n:], l.em[n:]}
+}
+
+// mapIdents replaces each identifier id in l with f(id).
+// A replacement is emphasized if the first byte of the original was.
+func (l codeLine) mapIdents(f func(string) string) codeLine {
+	var (
+		text strings.Builder
+		em   []emStyle
+		last int
+	)
+	for _, m := range identRe.FindAllStringIndex(l.text, -1) {
+		text.WriteString(l.text[last:m[0]])
+		em = append(em, l.em[last:m[0]]...)
+		r := f(l.text[m[0]:m[1]])
+		text.WriteString(r)
+		for range len(r) {
+			em = append(em, l.em[m[0]])
+		}
+		last = m[1]
+	}
+	text.WriteString(l.text[last:])
+	em = append(em, l.em[last:]...)
+	return codeLine{text.String(), em}
+}
+
+// codeOptions control how renderCode displays code.
+type codeOptions struct {
+	lineNumbers   bool
+	alignComments bool
+	noEscape      bool              // write the code's text as HTML
+	renames       map[string]string // see renderIdent
+	comment       string            // prefix of line comments; "//" if empty
+	docLinks      map[string]string // link references to names in these packages, by name
+	chanOps       bool              // style channel operations
+	mutexOps      bool              // style mutex operations and guarded fields
+	emElement     string            // HTML element for emphasis; if empty, a span
+	emClass       string            // class of emElement; "em" if emElement is empty
+	critical      bool              // shade critical sections
+	highlight     []lineRange       // emphasize these lines, by number
+}
+
+// codeOptionsFor returns the codeOptions for a code section
+// with the given attributes.
+func codeOptionsFor(o *options, attrs []codeAttr) codeOptions {
+	opts := codeOptions{
+		// Code to be run is edited, so numbers would get in the way.
+		lineNumbers:   !slices.Contains(attrs, attrNoNumbers) && !slices.Contains(attrs, attrPlay),
+		alignComments: slices.Contains(attrs, attrAlign),
+		noEscape:      slices.Contains(attrs, attrNoEscape),
+		// Links would get in the way of editing, and escaped text can't
+		// be searched for names.
+		chanOps:   o.chanOps && !slices.Contains(attrs, attrPlay) && !slices.Contains(attrs, attrNoEscape),
+		mutexOps:  o.mutexOps && !slices.Contains(attrs, attrPlay) && !slices.Contains(attrs, attrNoEscape),
+		critical:  slices.Contains(attrs, attrCritical),
+		emElement: o.emElement,
+		emClass:   o.emClass,
+	}
+	if !slices.Contains(attrs, attrPlay) && !slices.Contains(attrs, attrNoEscape) {
+		opts.docLinks = o.docLinks
+	}
+	return opts
+}
+
+// codeHTML returns the HTML of sec, a code section of slide.
+func codeHTML(o *options, sec section, slide *Slide) string {
+	if sec.rendered != "" {
+		return sec.rendered
+	}
+	opts := codeOptionsFor(o, sec.attrs)
+	opts.highlight = sec.highlight
+	opts.renames = slide.renames
+	opts.comment = slide.comment
+	return renderCode(sec.content, opts)
+}
+
+func renderCode(s string, opts codeOptions) string {
+	lines, callouts, steps, gors := codeLines(s)
+	// Indent by 3 spaces per level, or as the code does if it uses fewer.
+	unit := indentUnit(lines, opts.commentPrefix())
+	for i, line := range lines {
+		line = line.mapIdents(func(id string) string { return renderIdent(id, opts.renames) })
+		indent := len(line.text) - len(strings.TrimLeft(line.text, " "))
+		lines[i] = line.trimLeft(indent / unit * max(unit-3, 0))
+	}
+	if opts.alignComments {
+		alignComments(lines, opts.commentPrefix())
+	}
+
+	var syntax [][]string
+	if opts.commentPrefix() == "//" && !opts.noEscape {
+		syntax = goSyntaxTags(lines)
+	}
+	var guarded, critical []bool
+	if opts.mutexOps {
+		guarded = mutexHat(lines, opts.commentPrefix())
+	}
+	if opts.critical {
+		critical = criticalLines(lines, opts.commentPrefix())
+	}
+
+	var result strings.Builder
+	nonBlankLineNum := 0
+	codeLineNum := 0 // numbered even without line numbers, for highlight
+	for i, line := range lines {
+		if i > 0 {
+			result.WriteByte('\n')
+		}
+		var h string
+		if t := strings.TrimLeft(line.text, " "); t == ellipsisMark {
+			h = line.text[:len(line.text)-len(t)] + "<span class='ellipsis'>\u22ee</span>"
+		} else {
+			// Number lines that have code before any comment.
+			if len(codePart(line.text, opts.commentPrefix())) > 0 {
+				codeLineNum++
+				if opts.lineNumbers {
+					nonBlankLineNum++
+				}
+				if slices.ContainsFunc(opts.highlight, func(r lineRange) bool { return r.contains(codeLineNum) }) {
+					line.em = slices.Repeat([]emStyle{emPlain}, len(line.text))
+				}
+			}
+			lineNum := 0
+			if opts.lineNumbers {
+				lineNum = nonBlankLineNum
+			}
+			var tags []string
+			if syntax != nil {
+				tags = syntax[i]
+			}
+			h = renderCodeLine(line, lineNum, guarded != nil && guarded[i], tags, opts)
+		}
+		if i < len(callouts) && callouts[i] != "" {
+			h += "<span class='callout'>" + html.EscapeString(callouts[i]) + "</span>"
+		}
+		if strings.TrimSpace(line.text) != "" && slices.ContainsFunc(gors, func(g string) bool { return g != "" }) {
+			h = goroutineGutter(gors[i], gors) + h
+		}
+		if critical != nil && critical[i] {
+			if h == "" {
+				h = " " // so the shading has no gap
+			}
+			h = "<span class='critical'>" + h + "</span>"
+		}
+		if i < len(steps) && steps[i] > 0 && h != "" {
+			h = fmt.Sprintf("<span class='step' data-step='%d'>%s</span>", steps[i], h)
+		}
+		result.WriteString(h)
+	}
+	return result.String()
+}
+
+// A lineRange is the lines of a highlight directive, from first through
+// last, counting from 1.
+type lineRange struct{ first, last int }
+
+func (r lineRange) contains(n int) bool { return r.first <= n && n <= r.last }
+
+// parseLineRanges parses the line numbers and ranges of a highlight
+// directive, like "3,5-7" or "3 5-7".
+func parseLineRanges(spec string) ([]lineRange, error) {
+	var rs []lineRange
+	for f := range strings.FieldsFuncSeq(spec, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+		a, b, isRange := strings.Cut(f, "-")
+		first, err1 := strconv.Atoi(a)
+		last, err2 := first, error(nil)
+		if isRange {
+			last, err2 = strconv.Atoi(b)
+		}
+		if err1 != nil || err2 != nil || first < 1 || last < first {
+			return nil, fmt.Errorf("highlight: bad line range %q: want N or N-M", f)
+		}
+		rs = append(rs, lineRange{first, last})
+	}
+	if len(rs) == 0 {
+		return nil, errors.New("highlight: no lines")
+	}
+	return rs, nil
+}
+
+// goroutineGutter returns the gutter beside a line of code that runs on
+// goroutine g, colored by the order in which the goroutines of the code
+// block, gors, first appear. Lines that aren't marked get an empty gutter,
+// so the code stays lined up.
+func goroutineGutter(g string, gors []string) string {
+	if g == "" {
+		return "<span class='goroutine'></span>"
+	}
+	var seen []string
+	for _, x := range gors {
+		if x != "" && !slices.Contains(seen, x) {
+			seen = append(seen, x)
+		}
+	}
+	return fmt.Sprintf("<span class='goroutine g%d'>%s</span>", slices.Index(seen, g)%4+1, html.EscapeString(g))
+}
+
+func (o codeOptions) commentPrefix() string {
+	if o.comment == "" {
+		return "//"
+	}
+	return o.comment
+}
+
+// codeLines splits s, the content of a code section, into lines, with
+// tabs expanded and the indentation common to its lines removed. It also
+// returns the callout, step and goroutine of each line.
+func codeLines(s string) (lines []codeLine, callouts []string, steps []int, gors []string) {
+	s = strings.ReplaceAll(s, "\t", "    ")
+	s, callouts = splitCallouts(s)
+	s, steps = splitSteps(s)
+	s, gors = splitGoroutines(s)
+	lines = parseEm(s)
+
+	// Find minimum indentation across all non-empty lines
+	minIndent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line.text) == "" {
+			continue
+		}
+		indent := len(line.text) - len(strings.TrimLeft(line.text, " "))
+		if minIndent < 0 || indent < minIndent {
+			minIndent = indent
+		}
+	}
+	// Remove common indentation
+	if minIndent > 0 {
+		for i, line := range lines {
+			if len(line.text) >= minIndent {
+				lines[i] = line.trimLeft(minIndent)
+			}
+		}
+	}
+	return lines, callouts, steps, gors
+}
+
+// indentUnit returns the number of spaces in a level of indentation of
+// lines, from codeLines, whose comments begin with prefix. Go code is
+// indented with tabs, which are 4 spaces (see checkCodeIndent); other
+// languages are indented as they like, so the unit is the largest that
+// divides the indentation of every line.
+func indentUnit(lines []codeLine, prefix string) int {
+	if prefix == "//" {
+		return 4
+	}
+	unit := 0
+	for _, line := range lines {
+		if strings.TrimSpace(line.text) == "" {
+			continue
+		}
+		indent := len(line.text) - len(strings.TrimLeft(line.text, " "))
+		for indent > 0 {
+			unit, indent = indent, unit%indent
+		}
+	}
+	return cmp.Or(unit, 4)
+}
+
+// checkCodeIndent checks that the lines of content, the content of a code
+// section of Go, are indented by levels of 4 spaces, as they are when the
+// code is indented with tabs.
+func checkCodeIndent(content string) error {
+	lines, _, _, _ := codeLines(content)
+	for _, line := range lines {
+		trimmed := strings.TrimLeft(line.text, " ")
+		if indent := len(line.text) - len(trimmed); indent%4 != 0 && trimmed != "" {
+			return fmt.Errorf("code line %q is indented by %d spaces, not a multiple of 4; indent Go code with tabs", trimmed, indent)
+		}
+	}
+	return nil
+}
+
+// codePart returns the part of line before any comment that begins
+// with prefix.
+func codePart(line, prefix string) string {
+	if idx := strings.Index(line, prefix); idx >= 0 {
+		return line[:idx]
+	}
+	return line
+}
+
+// alignComments pads the code before each trailing comment so that
+// all the trailing comments start in the same column.
+func alignComments(lines []codeLine, prefix string) {
+	col := 0
+	for _, l := range lines {
+		code := codePart(l.text, prefix)
+		if code != l.text && strings.TrimSpace(code) != "" {
+			col = max(col, utf8.RuneCountInString(strings.TrimRight(code, " ")))
+		}
+	}
+	for i, l := range lines {
+		code := codePart(l.text, prefix)
+		if code == l.text || strings.TrimSpace(code) == "" {
+			continue
+		}
+		trimmed := strings.TrimRight(code, " ")
+		pad := col + 1 - utf8.RuneCountInString(trimmed)
+		em := l.em[len(trimmed)-1]
+		lines[i] = codeLine{
+			text: trimmed + strings.Repeat(" ", pad) + l.text[len(code):],
+			em:   slices.Concat(l.em[:len(trimmed)], slices.Repeat([]emStyle{em}, pad), l.em[len(code):]),
+		}
+	}
+}
+
+// emOpenTag returns the start tag used for emphasis in code in style.
+// All forms of the em directive render the same way; a named style adds
+// the class "em-STYLE", as "em.red" adds "em-red".
+func (o codeOptions) emOpenTag(style emStyle) string {
+	elem, class := o.emElement, o.emClass
+	if elem == "" {
+		elem, class = "span", "em"
+	}
+	if style != emPlain {
+		class = strings.TrimSpace(class + " em-" + string(style))
+	}
+	if class == "" {
+		return "<" + elem + ">"
+	}
+	return fmt.Sprintf("<%s class=%q>", elem, class)
+}
+
+// renderCodeLine returns the HTML for line, with line number num if it isn't
+// zero. If tags isn't nil, it has the syntax tag of each byte of the line,
+// from goSyntaxTags; otherwise comments and definitions are found by their
+// look.
+func renderCodeLine(line codeLine, num int, guarded bool, tags []string, opts codeOptions) string {
+	var b strings.Builder
+	// Non-blank lines begin with a line number.
+	if len(codePart(line.text, opts.commentPrefix())) > 0 && num > 0 {
+		fmt.Fprintf(&b, "<span class='codenum'>%d</span>", num)
+	}
+
+	// Assign each byte of the line a start tag at each of several levels,
+	// outermost first. The empty string means no tag.
+	text := line.text
+	n := len(text)
+	kinds := make([]string, n) // kinds of token, like definitions and comments
+	spans := make([]string, n) // code spans in comments
+	ems := make([]string, n)
+	var commentStart int
+	if tags != nil {
+		copy(kinds, tags)
+		commentStart = slices.Index(kinds, "<comment>")
+		if commentStart < 0 {
+			commentStart = n
+		}
+	} else {
+		commentStart = len(codePart(text, opts.commentPrefix()))
+		for i := commentStart; i < n; i++ {
+			kinds[i] = "<comment>"
+		}
+		start, end := defnRange(text[:commentStart])
+		for i := start; i < end; i++ {
+			kinds[i] = "<defn>"
+		}
+	}
+	for i := range n {
+		if line.em[i] != "" {
+			ems[i] = opts.emOpenTag(line.em[i])
+		}
+	}
+	ops := make([]string, n) // channel and mutex operations
+	if opts.chanOps {
+		for _, r := range chanOpRanges(text[:commentStart]) {
+			for i := r[0]; i < r[1]; i++ {
+				ops[i] = "<span class='chanop'>"
+			}
+		}
+	}
+	if opts.mutexOps {
+		if guarded {
+			code := strings.TrimRight(text[:commentStart], " ")
+			for i := len(code) - len(strings.TrimLeft(code, " ")); i < len(code); i++ {
+				ops[i] = "<span class='guarded'>"
+			}
+		}
+		for _, r := range mutexOpRanges(text[:commentStart]) {
+			for i := r[0]; i < r[1]; i++ {
+				ops[i] = "<span class='mutexop'>"
+			}
+		}
+	}
+	links := make([]string, n) // documentation links
+	if len(opts.docLinks) > 0 {
+		ranges, urls := docRefs(text, opts.docLinks)
+		for j, r := range ranges {
+			for i := r[0]; i < r[1]; i++ {
+				links[i] = fmt.Sprintf("<a class='doc' href=%q>", urls[j])
+			}
+		}
+	}
+	// A pair of backticks in a comment marks a code span, as in markdown.
+	// Drop the backticks themselves.
+	keep := make([]bool, n)
+	for i := range keep {
+		keep[i] = true
+	}
+	for i := commentStart; i < n; i++ {
+		if text[i] != '`' || kinds[i] != "<comment>" {
+			continue
+		}
+		j := strings.IndexByte(text[i+1:], '`')
+		if j < 0 {
+			break
+		}
+		j += i + 1
+		if kinds[j] != "<comment>" {
+			continue
+		}
+		keep[i], keep[j] = false, false
+		for k := i + 1; k < j; k++ {
+			spans[k] = "<code>"
+		}
+		i = j
+	}
+	kept := string(filter([]byte(text), keep))
+	levels := [][]string{kinds, spans, ems, ops, links}
+	for d := range levels {
+		levels[d] = filter(levels[d], keep)
+	}
+
+	esc := html.EscapeString
+	if opts.noEscape {
+		esc = func(s string) string { return s }
+	}
+	writeNested(&b, kept, levels, esc, 0, len(kept))
+	return b.String()
+}
+
+// filter returns the elements of s for which keep is true.
+func filter[T any](s []T, keep []bool) []T {
+	var res []T
+	for i, x := range s {
+		if keep[i] {
+			res = append(res, x)
+		}
+	}
+	return res
+}
+
+// writeNested writes text[lo:hi] to b as HTML, using esc to escape the text.
+// levels[d][i] is the start tag that byte i should be inside at nesting
+// depth d, or "" for none. Runs of bytes with the same tag share an element,
+// so the output is well-formed however the levels overlap.
+func writeNested(b *strings.Builder, text string, levels [][]string, esc func(string) string, lo, hi int) {
+	if len(levels) == 0 {
+		b.WriteString(esc(text[lo:hi]))
+		return
+	}
+	tags := levels[0]
+	for i := lo; i < hi; {
+		j := i
+		for j < hi && tags[j] == tags[i] {
+			j++
+		}
+		if tags[i] != "" {
+			b.WriteString(tags[i])
+		}
+		writeNested(b, text, levels[1:], esc, i, j)
+		if tags[i] != "" {
+			b.WriteString(endTag(tags[i]))
+		}
+		i = j
+	}
+}
+
+// endTag returns the end tag matching the start tag t.
+func endTag(t string) string {
+	name := strings.TrimPrefix(t, "<")
+	if i := strings.IndexAny(name, " >"); i >= 0 {
+		name = name[:i]
+	}
+	return "</" + name + ">"
+}
+
+// defnRange returns the byte range of the name defined by code, if it is the
+// start of a type or function declaration. Otherwise it returns (0, 0).
+func defnRange(code string) (start, end int) {
+	trimmed := strings.TrimLeft(code, " ")
+	off := len(code) - len(trimmed)
+
+	// Check for type definition: "type NAME"
+	if rest, ok := strings.CutPrefix(trimmed, "type "); ok {
+		parts := strings.Fields(rest)
+		if len(parts) > 0 {
+			start := off + len("type ") + strings.Index(rest, parts[0])
+			return start, start + len(parts[0])
+		}
+	}
+
+	// Check for func/method definition: "func NAME(" or "func (receiver) NAME("
+	if rest, ok := strings.CutPrefix(trimmed, "func "); ok {
+		off += len("func ")
+		if strings.HasPrefix(rest, "(") {
+			// Method: skip the receiver.
+			idx := strings.Index(rest, ") ")
+			if idx < 0 {
+				return 0, 0
+			}
+			off += idx + 2
+			rest = rest[idx+2:]
+		}
+		if parenIdx := strings.Index(rest, "("); parenIdx > 0 {
+			return off, off + parenIdx
+		}
+	}
+	return 0, 0
+}
+
+func renderMarkdown(o *options, s string) string {
+	s, classes := tableClasses(s)
+	var p markdown.Parser
+	p.Table = true
+	doc := p.Parse(s)
+	h := linkDocRefs(markdown.ToHTML(doc), o.docLinks)
+	n := 0
+	return tableTagRe.ReplaceAllStringFunc(h, func(tag string) string {
+		n++
+		if c := classes[n]; c != "" {
+			return fmt.Sprintf("<table class=%q>", c)
+		}
+		return tag
+	})
+}
+
+var (
+	// tableClassRe matches a line giving classes for the markdown table
+	// above it, like "{.interleave .small}".
+	tableClassRe = regexp.MustCompile(`^\{\s*(\.[\w-]+\s*)+\}$`)
+	tableTagRe   = regexp.MustCompile(`<table>`)
+)
+
+// tableClasses removes the class lines that follow markdown tables in s.
+// It returns the rest of s, and the classes of each table, numbered from 1
+// in order.
+func tableClasses(s string) (string, map[int]string) {
+	var (
+		b       strings.Builder
+		classes map[int]string
+		n       int  // tables so far
+		inTable bool // previous line was a table row
+	)
+	for line := range strings.Lines(s) {
+		t := strings.TrimSpace(line)
+		if inTable && tableClassRe.MatchString(t) {
+			if classes == nil {
+				classes = map[int]string{}
+			}
+			var cs []string
+			for f := range strings.FieldsSeq(strings.Trim(t, "{}")) {
+				cs = append(cs, strings.TrimPrefix(f, "."))
+			}
+			classes[n] = strings.Join(cs, " ")
+			inTable = false
+			continue
+		}
+		row := strings.HasPrefix(t, "|")
+		if row && !inTable {
+			n++
+		}
+		inTable = row
+		b.WriteString(line)
+	}
+	return b.String(), classes
+}
+
+func stripPara(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "<p>")
+	return strings.TrimSuffix(s, "</p>")
+}
+
+const top = `<!DOCTYPE html>
+<html>
+  <head>
+    <title>%s</title>
+    <meta charset='utf-8'>
+    <link rel='icon' type='image/svg+xml' href='static/favicon.svg'>
+    <script>
+      var notesEnabled = %t;
+      var fontURL = %q;
+      var themeURL = %q;
+    </script>
+    <script src='static/slides.js'></script>
+  </head>
+
+  <body style='display: none'>
+    <section class='slides'>
+`
+
+// scrollTop begins a deck built with -scroll. It doesn't load slides.js,
+// so there is no slide navigation; scroll.css lays the slides out one after
+// another.
+const scrollTop = `<!DOCTYPE html>
+<html>
+  <head>
+    <title>%s</title>
+    <meta charset='utf-8'>
+    <meta name='viewport' content='width=device-width,initial-scale=1'>
+    <link rel='icon' type='image/svg+xml' href='static/favicon.svg'>
+    <link rel='stylesheet' href='static/styles.css'>
+    <link rel='stylesheet' href='static/scroll.css'>
+    %s
+  </head>
+
+  <body class='scroll'>
+    <section class='slides'>
+`
+
+const defaultFontURL = "//fonts.googleapis.com/css?family=Open+Sans:regular,semibold,italic,italicsemibold|Droid+Sans+Mono"
+
+const bottom = `
+    <div id="help">
+      Use the left and right arrow keys, click the left and right
+      edges of the page, or swipe to navigate between slides.<br>
+      On a phone, pinch code to zoom it.<br>
+      Press 'D' to draw on a slide, 'M' to highlight, and 'C' to clear.<br>
+      Press 'L' for a laser pointer and 'S' for a spotlight.<br>
+      Press 'B' to bookmark a slide and 'G' to go to a bookmark.<br>
+      Press 'O' to skip optional slides.<br>
+      Press 'A' to reveal the next answer or hint.<br>
+      (Press 'H' or navigate to hide this message.)
+    </div>
+    <script type="application/javascript" src='static/play.js'></script>
+    <script src='static/draw.js'></script>
+    <script src='static/pointer.js'></script>
+    <script src='static/bookmarks.js'></script>
+    <script src='static/optional.js'></script>
+    <script src='static/answers.js'></script>
+    <script src='static/steps.js'></script>
+    <script src='static/quiz.js'></script>
+    <script src='static/stepper.js'></script>
+    <script src='static/fit.js'></script>`
+
+// playScripts runs code marked with the play attribute. The deck's server
+// must handle /compile; "code2slides serve -play" runs it in the sandbox.
+const playScripts = `    <script src='static/jquery.js'></script>
+    <script src='static/playground.js'></script>
+    <script>initPlayground(new HTTPTransport());</script>`
+
+const mermaidCDN = `	<script type="module">
+	   import mermaid from 'https://cdn.jsdelivr.net/npm/mermaid@11/dist/mermaid.esm.min.mjs';
+	   mermaid.initialize({ startOnLoad: true });
+	</script>`
+
+// mermaidOffline loads the copy of mermaid fetched by vendorAssets.
+const mermaidOffline = `	<script src='static/vendor/mermaid.min.js'></script>
+	<script>
+	   mermaid.initialize({ startOnLoad: true });
+	</script>`
+
+const end = `  </body>
+</html>`
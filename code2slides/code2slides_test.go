@@ -0,0 +1,4310 @@
+package code2slides
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"testing/synctest"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// checkGolden compares got with the contents of testdata/name.golden.
+// With -update, it writes got to the file instead.
+func checkGolden(t *testing.T, name, got string) {
+	t.Helper()
+	file := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(file, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("%s: got:\n%s\nwant:\n%s", file, got, want)
+	}
+}
+
+// testOptions returns the options of a build with the zero Config,
+// whose deck code stops when t ends.
+func testOptions(t *testing.T) *options {
+	t.Helper()
+	o, err := Config{}.options(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+func sectionsEqual(a, b []section) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestScanFileErrors(t *testing.T) {
+	o := testOptions(t)
+	tests := []struct {
+		file    string
+		wantErr string
+	}{
+		{"testdata/unmatched_endcode.go", "!code without matching code"},
+		{"testdata/unmatched_endnote.go", "!note without matching note"},
+		{"testdata/code_inside_note.go", "code inside note"},
+		{"testdata/note_inside_code.go", "note inside code"},
+		{"testdata/unclosed_code.go", "unclosed code section"},
+		{"testdata/unclosed_note.go", "unclosed note section"},
+		{"testdata/unclosed_question.go", "unclosed answer section"},
+		{"testdata/unmatched_endquestion.go", "!question without matching question"},
+		{"testdata/question_without_answer.go", "!question without answer"},
+		{"testdata/code_small_smaller.go", "cannot use both 'small' and 'smaller'"},
+		{"testdata/code_invalid_option.go", "invalid code option \"unknown\""},
+		{"testdata/line_inside_code.go", "line inside code"},
+		{"testdata/rename_collision.go", "rename_collision.go:4: rename collision: nc1 and nc both display as nc"},
+		{"testdata/rename_conflict.go", "nc1 renamed to both nc and count"},
+		{"testdata/slide_inside_code.go", "slide inside code"},
+		{"testdata/block_code.go", "code cannot be in a /* comment"},
+		{"testdata/cols_nextcol.go", "cols_nextcol.go:6: nextcol without matching cols"},
+		{"testdata/cols_unclosed.go", "cols_unclosed.go:8: heading inside cols"},
+		{"testdata/cols_nested.go", "cols inside cols"},
+		{"testdata/highlight_bad.go", `highlight_bad.go:5: highlight: bad line range "5-3"`},
+		{"testdata/cols_widths.go", "cols_widths.go:9: cols has 3 widths but 2 columns"},
+		{"testdata/omit_unclosed.go", "omit_unclosed.go:9: omit without matching !omit"},
+		{"testdata/em_no_previous.go", "em_no_previous.go:5: em pattern without a preceding code line"},
+		{"testdata/callout_no_previous.go", "callout_no_previous.go:5: callout without a preceding code line"},
+		{"testdata/compare_code.go", "compare_code.go:11: compare needs two outputs, and nothing else"},
+		{"testdata/caption_no_image.go", "caption_no_image.go:7: caption without a preceding image"},
+		{"testdata/html_unclosed.go", "html_unclosed.go:4: html: <div> is not closed on its slide"},
+		{"testdata/html_mismatch.go", "html_mismatch.go:5: html: </div> closes <p>"},
+		{"testdata/if_unclosed.go", "if without !if"},
+		{"testdata/hint_without_question.go", "hint_without_question.go:6: hint without question"},
+		{"testdata/if_unmatched.go", "if_unmatched.go:6: !if without if"},
+		{"testdata/same_as_drift.go", "same_as_drift.go:15: same-as: code block 1 differs from the previous slide:\n\t- \t\tc++"},
+		{"testdata/goroutine_unmatched.go", "goroutine_unmatched.go:6: !goroutine without matching goroutine"},
+		{"testdata/diagram_unknown.go", "diagram_unknown.go:4: diagram: unknown language \"plantuml\""},
+		{"testdata/code_diff_first.go", "code_diff_first.go:4: code diff: previous slide has no code block 1"},
+		{"testdata/quiz_no_correct.go", "quiz_no_correct.go:4: quiz has no correct choice"},
+		{"testdata/title_info_outside.go", "title_info_outside.go:4: author outside a title slide"},
+		{"testdata/stepper_bad.go", "stepper_bad.go:4: stepper: can't run \"c += 1\""},
+		{"testdata/code_indent.go", `code_indent.go:5: code line "return 1" is indented by 2 spaces, not a multiple of 4`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			_, err := scanFile(o, tt.file)
+			if err == nil {
+				t.Fatalf("expected error containing %q, got nil", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %q", tt.wantErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestScanFile(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/valid.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(slides) != 1 {
+		t.Fatalf("got %d slides, want 1", len(slides))
+	}
+	slide := slides[0]
+
+	if slide.heading != "Test Heading" {
+		t.Errorf("heading = %q, want %q", slide.heading, "Test Heading")
+	}
+
+	wantSections := []section{
+		{kind: sectionNote, content: "First note.\n"},
+		{kind: sectionCode, content: "func foo() {}"},
+		{kind: sectionNote, content: "Second note.\n\nThird note after blank comment.\n\nFourth note after blank line.\n"},
+		{kind: sectionCode, content: "func bar() {}"},
+		{kind: sectionQuestion, content: "What is the answer?\n"},
+		{kind: sectionAnswer, content: "The answer is 42.\n"},
+		{kind: sectionNote, content: "Use `fmt.Println` to print.\n"},
+	}
+
+	if !sectionsEqual(slide.sections, wantSections) {
+		t.Errorf("got:\n%v\nwant:\n%v", slide.sections, wantSections)
+	}
+}
+
+func TestSlideSeparator(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/slide_separator.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, s := range slides {
+		got = append(got, s.heading+": "+s.sections[0].content)
+	}
+	want := []string{"Counting: Start here.\n", "Counting: Still counting.\n", "Done: Finished.\n"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestOptional(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/optional.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"<article>",
+		"<article class='optional' data-tags='advanced race'>",
+		"<article class='optional'>",
+	}
+	for i, s := range slides {
+		var buf strings.Builder
+		writeSlideHTML(o, &indentWriter{w: &buf}, s, i+1, false)
+		if !strings.Contains(buf.String(), want[i]) {
+			t.Errorf("slide %d: missing %q in:\n%s", i+1, want[i], buf.String())
+		}
+	}
+}
+
+func TestColumns(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/cols_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range [][]string{
+		{`<div class="flex"><div>`, "left", "</div>", "<div> <!-- next col -->", "right", "</div></div> <!-- flex -->"},
+		{`<div class="flex"><div style='flex: 60 1 0'>`, "left", "</div>", "<div style='flex: 40 1 0'> <!-- next col -->", "right", "</div></div> <!-- flex -->"},
+	} {
+		var buf strings.Builder
+		writeSlideHTML(o, &indentWriter{w: &buf}, slides[i], i+1, true)
+		got := buf.String()
+		rest := got
+		for _, w := range want {
+			j := strings.Index(rest, w)
+			if j < 0 {
+				t.Fatalf("slide %d: missing %q, or out of order, in:\n%s", i+1, w, got)
+			}
+			rest = rest[j+len(w):]
+		}
+	}
+}
+
+func TestOutput(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/output_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []section{{kind: sectionOutput, options: []string{"-race"}, content: `WARNING: DATA RACE
+Read at 0x00c000012345 by goroutine 7:
+  main.count()
+      m.go:26 +0x2c
+got <-done
+`}}
+	if !sectionsEqual(slides[0].sections, want) {
+		t.Errorf("got:\n%v\nwant:\n%v", slides[0].sections, want)
+	}
+
+	var buf strings.Builder
+	writeSlideHTML(o, &indentWriter{w: &buf}, slides[0], 1, true)
+	if !strings.Contains(buf.String(), "got &lt;-done") {
+		t.Errorf("output not escaped:\n%s", buf.String())
+	}
+}
+
+func TestBlockComment(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/block_comment.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []section{
+		{kind: sectionNote, content: "A note that doesn't need\n// prefixes.\n"},
+		{kind: sectionOutput, content: "panic: oops\n    main.main()\n"},
+		{kind: sectionText, content: "Some *text*.\n"},
+	}
+	if !sectionsEqual(slides[0].sections, want) {
+		t.Errorf("got:\n%v\nwant:\n%v", slides[0].sections, want)
+	}
+}
+
+func TestElide(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/elide_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(slides) != 1 {
+		t.Fatalf("got %d slides, want 1", len(slides))
+	}
+	slide := slides[0]
+	if len(slide.sections) != 1 {
+		t.Fatalf("got %d sections, want 1", len(slide.sections))
+	}
+	sec := slide.sections[0]
+	if sec.kind != sectionCode {
+		t.Fatalf("got section kind %v, want code", sec.kind)
+	}
+	want := "func example() {\n\tx := 1\n\t" + ellipsisMark + "\n\tfmt.Println(x)\n}"
+	if sec.content != want {
+		t.Errorf("got:\n%q\nwant:\n%q", sec.content, want)
+	}
+	// It renders the same as "// ...".
+	if got := renderCode(sec.content, codeOptions{}); !strings.Contains(got, "<span class='ellipsis'>\u22ee</span>") || strings.Contains(got, "...") {
+		t.Errorf("elided lines render as:\n%s", got)
+	}
+}
+
+func TestOmit(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/omit_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "func example() error {\n\tf, err := os.Open(name)\n\tdefer f.Close()\n\treturn nil\n}"
+	if got := slides[0].sections[0].content; got != want {
+		t.Errorf("got:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestInlineEmMulti(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/inline_em_multi.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(slides) != 1 {
+		t.Fatalf("got %d slides, want 1", len(slides))
+	}
+	slide := slides[0]
+	if len(slide.sections) != 1 {
+		t.Fatalf("got %d sections, want 1", len(slide.sections))
+	}
+	sec := slide.sections[0]
+	if sec.kind != sectionCode {
+		t.Fatalf("got section kind %v, want code", sec.kind)
+	}
+	// Both foo and bar should be wrapped with em markers
+	want := "x, y := \x00em\x00foo\x00/em\x00(), \x00em\x00bar\x00/em\x00()"
+	if sec.content != want {
+		t.Errorf("got:\n%q\nwant:\n%q", sec.content, want)
+	}
+}
+
+func TestCodeInAnswer(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/code_in_answer.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(slides) != 1 {
+		t.Fatalf("got %d slides, want 1", len(slides))
+	}
+	slide := slides[0]
+	// Should have: question, answer (before code), code (inAnswer), answer (after code)
+	wantSections := []section{
+		{kind: sectionQuestion, content: "How do you print hello?\n"},
+		{kind: sectionAnswer, content: "Use fmt.Println:\n"},
+		{kind: sectionCode, content: "fmt.Println(\"hello\")", inAnswer: true},
+		{kind: sectionAnswer, content: "That's it!\n"},
+	}
+	if !sectionsEqual(slide.sections, wantSections) {
+		t.Errorf("got:\n%v\nwant:\n%v", slide.sections, wantSections)
+	}
+}
+
+func TestCodeInAnswerHTML(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/code_in_answer.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	slide := slides[0]
+
+	var buf strings.Builder
+	w := &indentWriter{w: &buf}
+	writeSlideHTML(o, w, slide, 1, false)
+	html := buf.String()
+
+	// The code should appear between <details> and </details>
+	detailsStart := strings.Index(html, "<details data-kind='question'>")
+	detailsEnd := strings.Index(html, "</details>")
+	codeStart := strings.Index(html, "<div class='code' data-kind='code'>")
+
+	if detailsStart == -1 || detailsEnd == -1 || codeStart == -1 {
+		t.Fatalf("missing expected HTML elements in:\n%s", html)
+	}
+	if !(detailsStart < codeStart && codeStart < detailsEnd) {
+		t.Errorf("code block not inside details block:\ndetails starts at %d, code at %d, details ends at %d\n%s",
+			detailsStart, codeStart, detailsEnd, html)
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	o := testOptions(t)
+	got := renderMarkdown(o, "Use `fmt.Println` to print.\n")
+	want := "<p>Use <code>fmt.Println</code> to print.</p>\n"
+	if got != want {
+		t.Errorf("renderMarkdown() = %q, want %q", got, want)
+	}
+}
+
+func TestSplitFirstWord(t *testing.T) {
+	tests := []struct {
+		input    string
+		wantWord string
+		wantRest string
+		wantOK   bool
+	}{
+		{"// code", "code", "", true},
+		{"// heading Title", "heading", "Title", true},
+		{"/* text", "text", "", true},
+		{"// html <div>foo</div>", "html", "<div>foo</div>", true},
+		{"//code", "code", "", true},
+		{"//  spaced   rest", "spaced", "rest", true},
+		{"not a comment", "", "", false},
+		{"/ not a comment", "", "", false},
+	}
+	for _, tt := range tests {
+		word, rest, ok := splitFirstWord(tt.input)
+		if word != tt.wantWord || rest != tt.wantRest || ok != tt.wantOK {
+			t.Errorf("splitFirstWord(%q) = (%q, %q, %v), want (%q, %q, %v)",
+				tt.input, word, rest, ok, tt.wantWord, tt.wantRest, tt.wantOK)
+		}
+	}
+}
+
+func TestDivClass(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/div_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(slides) != 1 {
+		t.Fatalf("got %d slides, want 1", len(slides))
+	}
+
+	wantSections := []section{
+		{kind: sectionHTML, content: `<div class="flex">`},
+		{kind: sectionCode, content: "x := 1"},
+		{kind: sectionHTML, content: "</div> <!-- flex -->"},
+	}
+
+	if !sectionsEqual(slides[0].sections, wantSections) {
+		t.Errorf("got:\n%v\nwant:\n%v", slides[0].sections, wantSections)
+	}
+}
+
+func TestDivClassMismatch(t *testing.T) {
+	o := testOptions(t)
+	_, err := scanFile(o, "testdata/div_mismatch.go")
+	if err == nil {
+		t.Fatal("expected error for mismatched div class")
+	}
+	if !strings.Contains(err.Error(), "mismatched div class") {
+		t.Errorf("error = %q, want error containing 'mismatched div class'", err)
+	}
+}
+
+func TestCodeBad(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/code_bad.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(slides) != 1 {
+		t.Fatalf("got %d slides, want 1", len(slides))
+	}
+
+	wantSections := []section{
+		{kind: sectionCode, attrs: []codeAttr{attrBad}, content: "x := 1 // wrong"},
+	}
+
+	if !sectionsEqual(slides[0].sections, wantSections) {
+		t.Errorf("got:\n%v\nwant:\n%v", slides[0].sections, wantSections)
+	}
+}
+
+func TestInlineEm(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/inline_em.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(slides) != 1 {
+		t.Fatalf("got %d slides, want 1", len(slides))
+	}
+
+	wantSections := []section{
+		{kind: sectionCode, content: "x := \x00em\x00foo\x00/em\x00()\ny := bar()"},
+	}
+
+	if !sectionsEqual(slides[0].sections, wantSections) {
+		t.Errorf("got:\n%v\nwant:\n%v", slides[0].sections, wantSections)
+	}
+
+	// Verify rendered HTML
+	got := renderCode(slides[0].sections[0].content, codeOptions{lineNumbers: true})
+	if !strings.Contains(got, "<span class=\"em\">foo</span>") {
+		t.Errorf("rendered code does not contain <span class=\"em\">foo</span>: %s", got)
+	}
+	if strings.Contains(got, "// em") {
+		t.Errorf("rendered code still contains // em: %s", got)
+	}
+}
+
+func TestInlineEmWholeLine(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/inline_em_whole_line.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(slides) != 1 {
+		t.Fatalf("got %d slides, want 1", len(slides))
+	}
+
+	wantSections := []section{
+		{kind: sectionCode, content: "\x00em\x00x := foo()\x00/em\x00\ny := bar()"},
+	}
+
+	if !sectionsEqual(slides[0].sections, wantSections) {
+		t.Errorf("got:\n%v\nwant:\n%v", slides[0].sections, wantSections)
+	}
+
+	// Verify rendered HTML
+	got := renderCode(slides[0].sections[0].content, codeOptions{lineNumbers: true})
+	if !strings.Contains(got, "<span class=\"em\">x := foo()</span>") {
+		t.Errorf("rendered code does not contain whole line em: %s", got)
+	}
+	if strings.Contains(got, "// em") {
+		t.Errorf("rendered code still contains // em: %s", got)
+	}
+}
+
+func TestInlineEmPreviousLine(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/inline_em_previous.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "wg.Go(func() { \x00em\x00close(c)\x00/em\x00 })\ny := bar()"
+	if got := slides[0].sections[0].content; got != want {
+		t.Errorf("got:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestInclude(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/include_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, sec := range slides[0].sections {
+		got = append(got, sec.content)
+	}
+	want := []string{
+		"type Counter struct {\n\tmu sync.Mutex\n\tn  int\n}",
+		"\tc.mu.Lock()\n\tc.n++\n\tc.mu.Unlock()",
+		"import \"sync\"",
+		"func (c *Counter) Inc() {\n\tc.mu.Lock()\n\tc.n++\n\tc.mu.Unlock()\n}",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got:\n%q\nwant:\n%q", got, want)
+	}
+
+	for _, test := range []struct {
+		addr, err string
+	}{
+		{"missing", `no region "missing"`},
+		{"30,40", "lines 30,40 out of range: file has 19 lines"},
+	} {
+		content, err := os.ReadFile("testdata/include/canon.go")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := includePart(content, test.addr, "//"); err == nil || err.Error() != test.err {
+			t.Errorf("%s: got %v, want %q", test.addr, err, test.err)
+		}
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	a := strings.Fields("a b c d")
+	b := strings.Fields("a x c d e")
+	var got []string
+	for _, l := range diffLines(a, b) {
+		got = append(got, fmt.Sprintf("%c%s", " -+"[l.op], l.text))
+	}
+	want := []string{" a", "-b", "+x", " c", " d", "+e"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSolution(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/solution_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	render := func() string {
+		var buf strings.Builder
+		writeSlideHTML(o, &indentWriter{w: &buf}, slides[0], 1, true)
+		return buf.String()
+	}
+	if got := render(); strings.Contains(got, "diff") {
+		t.Errorf("solution shown without -notes:\n%s", got)
+	}
+	o.includeNotes = true
+	got := render()
+	for _, want := range []string{
+		"<summary>ex.go: exercise and solution</summary>",
+		"<tr><td class='num'>4</td><td class='del'>    // TODO</td><td class='num'>4</td><td class='ins'>    return 42</td></tr>",
+		"<tr><td class='num'>5</td><td class='del'>    return 0</td><td class='num'></td><td></td></tr>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+}
+
+func TestSubheading(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/subheading.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []section{
+		{kind: sectionHTML, content: "<h2 class='subheading'>Sending</h2>"},
+		{kind: sectionText, content: "`c <- v` blocks.\n"},
+		{kind: sectionHTML, content: "<h2 class='subheading'>Receiving</h2>"},
+	}
+	if !sectionsEqual(slides[0].sections, want) {
+		t.Errorf("got:\n%v\nwant:\n%v", slides[0].sections, want)
+	}
+}
+
+func TestCommentPrefix(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/comment/worker.py")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(slides) != 1 || slides[0].heading != "Threads in Python" {
+		t.Fatalf("got %d slides, first heading %q", len(slides), slides[0].heading)
+	}
+	secs := slides[0].sections
+	if len(secs) != 3 {
+		t.Fatalf("got %d sections, want 3", len(secs))
+	}
+	wantCode := "def main():\n" +
+		"    t = threading." + emStart + "Thread" + emEnd + "(target=work)\n" +
+		"    t.start()\n" +
+		"    " + ellipsisMark + "\n" +
+		"    t.join()  # wait for it"
+	if got := secs[1].content; got != wantCode {
+		t.Errorf("got code\n%q\nwant\n%q", got, wantCode)
+	}
+	got := renderCode(secs[2].content, codeOptions{lineNumbers: true, comment: slides[0].comment})
+	for _, want := range []string{
+		"n = total // 2  <comment># half</comment>",
+		"def helper():",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("rendered code missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "region") {
+		t.Errorf("region markers in rendered code:\n%s", got)
+	}
+
+	// Code indented by 2 spaces keeps its indentation.
+	slides, err = scanFile(o, "testdata/comment/two_spaces.py")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = renderCode(slides[0].sections[0].content, codeOptions{comment: slides[0].comment})
+	if want := "def f():\n  if True:\n    return 1"; got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestIf(t *testing.T) {
+	o := testOptions(t)
+	for _, test := range []struct {
+		tags   []string
+		slides int
+		text   string
+		code   string
+	}{
+		{
+			tags:   nil,
+			slides: 1,
+			text:   "Call `Add` before starting each goroutine.\n",
+			code: "func run() {\n\tvar wg sync.WaitGroup\n\twg.Add(1)\n\tgo func() {\n" +
+				"\t\tdefer wg.Done()\n\t\twork()\n\t}()\n\twg.Wait()\n}",
+		},
+		{
+			tags:   []string{"advanced"},
+			slides: 2,
+			text:   "Use `sync.WaitGroup.Go`.\n",
+			code:   "func run() {\n\tvar wg sync.WaitGroup\n\twg.Go(work)\n\twg.Wait()\n}",
+		},
+		{
+			tags:   []string{"race", "advanced"},
+			slides: 2,
+			text:   "Use `sync.WaitGroup.Go`.\n",
+			code:   "func run() {\n\tvar wg sync.WaitGroup\n\twg.Go(work)\n\twg.Go(work)\n\twg.Wait()\n}",
+		},
+	} {
+		o.buildTags = test.tags
+		slides, err := scanFile(o, "testdata/if_test.go")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(slides) != test.slides {
+			t.Errorf("%v: got %d slides, want %d", test.tags, len(slides), test.slides)
+		}
+		want := []section{
+			{kind: sectionText, content: test.text},
+			{kind: sectionCode, content: test.code},
+		}
+		if !sectionsEqual(slides[0].sections, want) {
+			t.Errorf("%v: got:\n%v\nwant:\n%v", test.tags, slides[0].sections, want)
+		}
+	}
+}
+
+func TestHint(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/hint_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []section{
+		{kind: sectionQuestion, content: "Why does the program deadlock?\n"},
+		{kind: sectionHint, content: "Who receives from `c`?\n"},
+		{kind: sectionHint, content: "Nobody.\n"},
+		{kind: sectionAnswer, content: "The send blocks forever.\n"},
+	}
+	if !sectionsEqual(slides[0].sections, want) {
+		t.Fatalf("got:\n%v\nwant:\n%v", slides[0].sections, want)
+	}
+
+	var buf bytes.Buffer
+	writeSlideHTML(o, &indentWriter{w: &buf}, slides[0], 1, true)
+	got := buf.String()
+	// Each hint is inside the one before, and the answer is inside the last.
+	wantOrder := []string{
+		"<summary>", "Why does the program deadlock?", "</summary>",
+		"<summary>Hint 1</summary>", "Who receives",
+		"<summary>Hint 2</summary>", "Nobody.",
+		"<summary>Answer</summary>", "The send blocks forever.",
+		"</details>", "</details>", "</details>", "</details>", "</article>",
+	}
+	rest := got
+	for _, w := range wantOrder {
+		i := strings.Index(rest, w)
+		if i < 0 {
+			t.Fatalf("missing %q after earlier parts in\n%s", w, got)
+		}
+		rest = rest[i+len(w):]
+	}
+	if n := strings.Count(got, "<details"); n != 4 {
+		t.Errorf("got %d details elements, want 4", n)
+	}
+}
+
+func TestStep(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/step_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := renderCode(slides[0].sections[0].content, codeOptions{})
+	want := `<keyword>type</keyword> <defn>counter</defn> <keyword>struct</keyword> {
+   mu sync.Mutex
+   n  int
+}
+
+<span class='step' data-step='1'><keyword>func</keyword> (c *counter) <defn>inc</defn>() {</span>
+<span class='step' data-step='1'>   c.mu.Lock()</span>
+<span class='step' data-step='2'>   c.n++</span>
+<span class='step' data-step='2'>   c.mu.Unlock()</span>
+<span class='step' data-step='2'>}</span>
+`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCodeDiff(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/code_diff.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := renderCode(slides[1].sections[0].content, codeOptions{})
+	want := `<keyword>func</keyword> <defn>count</defn>(n int) int {
+   <keyword><span class="em">var</span></keyword><span class="em"> wg sync.WaitGroup</span>
+   c := <number>0</number>
+   <keyword>for</keyword> <keyword>range</keyword> n {
+      <span class="em">wg.Go(</span><keyword><span class="em">func</span></keyword><span class="em">() {</span>
+         c++
+      <span class="em">})</span>
+   }
+   <span class="em">wg.Wait()</span>
+   <keyword>return</keyword> c
+}
+`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestEllipsis(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/ellipsis.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := renderCode(slides[0].sections[0].content, codeOptions{lineNumbers: true})
+	want := `<span class='codenum'>1</span><keyword>func</keyword> (c *Cache) <defn>Get</defn>(key string) (string, bool) {
+<span class='codenum'>2</span>   c.mu.Lock()
+<span class='codenum'>3</span>   <keyword>defer</keyword> c.mu.Unlock()
+   <span class='ellipsis'>⋮</span>
+<span class='codenum'>4</span>   <keyword>return</keyword> v, ok
+<span class='codenum'>5</span>}
+`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCritical(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/critical.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sec := slides[0].sections[0]
+	got := renderCode(sec.content, codeOptionsFor(o, sec.attrs))
+	want := `<span class='codenum'>1</span><keyword>func</keyword> (c *Cache) <defn>Get</defn>(key string) string {
+<span class='critical'><span class='codenum'>2</span>   c.mu.Lock()</span>
+<span class='critical'><span class='codenum'>3</span>   v, ok := c.m[key]</span>
+<span class='critical'><span class='codenum'>4</span>   c.mu.Unlock()</span>
+<span class='codenum'>5</span>   <keyword>if</keyword> !ok {
+<span class='codenum'>6</span>      v = compute(key)
+<span class='critical'><span class='codenum'>7</span>      c.mu.Lock()</span>
+<span class='critical'><span class='codenum'>8</span>      <keyword>defer</keyword> c.mu.Unlock()</span>
+<span class='critical'><span class='codenum'>9</span>      c.m[key] = v</span>
+
+<span class='codenum'>10</span>   }
+<span class='codenum'>11</span>   <keyword>return</keyword> v
+<span class='codenum'>12</span>}
+`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestGoroutineGutters(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/goroutine.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := renderCode(slides[0].sections[0].content, codeOptions{})
+	want := `<span class='goroutine g1'>G1</span><keyword>func</keyword> <defn>printTree</defn>(t *Tree) {
+<span class='goroutine g1'>G1</span>   <keyword>var</keyword> wg sync.WaitGroup
+<span class='goroutine g1'>G1</span>   <keyword>for</keyword> _, c := <keyword>range</keyword> t.children {
+<span class='goroutine g1'>G1</span>      wg.Go(<keyword>func</keyword>() {
+<span class='goroutine g2'>G2</span>         printTree(c)
+<span class='goroutine g1'>G1</span>      })
+<span class='goroutine g1'>G1</span>   }
+<span class='goroutine g1'>G1</span>   wg.Wait()
+<span class='goroutine g1'>G1</span>}
+`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestDiagram(t *testing.T) {
+	o := testOptions(t)
+	o.outputCache = t.TempDir()
+	// Use a cached rendering, so the test doesn't need Graphviz.
+	src := "digraph {\n    gen -> sq -> print\n}\n"
+	svg := "<svg xmlns='http://www.w3.org/2000/svg'><text>gen</text></svg>"
+	cached := filepath.Join(o.outputCache, fmt.Sprintf("%x.svg", sha256.Sum256([]byte("dot\n"+src))))
+	if err := os.WriteFile(cached, []byte(svg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	slides, err := scanFile(o, "testdata/diagram.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := section{kind: sectionDiagram, options: []string{"dot"}, content: svg}
+	if got := slides[0].sections[0]; !sectionsEqual([]section{got}, []section{want}) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	var buf strings.Builder
+	writeSlideHTML(o, &indentWriter{w: &buf}, slides[0], 1, true)
+	if want := "<div class='diagram' data-kind='diagram'>"; !strings.Contains(buf.String(), want) {
+		t.Errorf("missing %q in:\n%s", want, buf.String())
+	}
+
+	if got := inlineSVG("<?xml version='1.0'?>\n<!DOCTYPE svg>\n<!-- made by dot -->\n<svg></svg>\n"); got != "<svg></svg>" {
+		t.Errorf("inlineSVG: got %q", got)
+	}
+}
+
+func TestSameAs(t *testing.T) {
+	o := testOptions(t)
+	if _, err := scanFile(o, "testdata/same_as.go"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPresenterNotes(t *testing.T) {
+	o := testOptions(t)
+	o.presenterNotes = true
+	files := []string{"testdata/valid.go", "testdata/footnote_test.go"}
+	var buf bytes.Buffer
+	slides, err := writeDeck(o, &buf, "", "T", []part{{files: files}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"var notesEnabled = true;",
+		`var titleNotes = ["\u003cp\u003eFirst note.\u003c/p\u003e\n",`,
+		`var sections = [{"Notes":null}];`,
+		"<script src='static/notes.js'></script>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("deck does not contain %q", want)
+		}
+	}
+	// Notes are for the presenter, not the slides.
+	if strings.Contains(got, "<p>First note.</p>") {
+		t.Error("notes on the slides")
+	}
+
+	buf.Reset()
+	if err := writeNotesDoc(o, &buf, "T", slides); err != nil {
+		t.Fatal(err)
+	}
+	got = buf.String()
+	for _, want := range []string{
+		"<section id='1'>\n<h2><span class='num'>1</span>Test Heading</h2>\n<p>First note.</p>",
+		"<p>Use <code>fmt.Println</code> to print.</p>",
+		"<section id='2' class='empty'>\n<h2><span class='num'>2</span>Footnotes</h2>\n</section>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("notes document does not contain %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestFootnote(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/footnote_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []section{
+		{kind: sectionFootnote, content: "The real `sync.WaitGroup` uses atomics.\n"},
+		{kind: sectionText, content: "A `WaitGroup` counts goroutines.\n"},
+		{kind: sectionFootnote, content: "It also has a `Go` method,\nsince Go 1.25.\n"},
+		{kind: sectionText, content: "Call `Wait` to wait for them.\n"},
+	}
+	if !sectionsEqual(slides[0].sections, want) {
+		t.Fatalf("got:\n%v\nwant:\n%v", slides[0].sections, want)
+	}
+
+	var buf bytes.Buffer
+	writeSlideHTML(o, &indentWriter{w: &buf}, slides[0], 1, true)
+	got := buf.String()
+	// The footnotes follow the rest of the slide, in order.
+	wantOrder := []string{
+		"counts goroutines", "to wait for them",
+		"<div class='footnotes'>", "uses atomics", "since Go 1.25", "</div>",
+		"<span class='pagenumber'>",
+	}
+	rest := got
+	for _, w := range wantOrder {
+		i := strings.Index(rest, w)
+		if i < 0 {
+			t.Fatalf("missing %q after earlier parts in\n%s", w, got)
+		}
+		rest = rest[i+len(w):]
+	}
+}
+
+func TestAnswerSummary(t *testing.T) {
+	o := testOptions(t)
+	o.answerSummary = "Show me"
+	slides, err := scanFile(o, "testdata/question_no_text.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	writeSlideHTML(o, &indentWriter{w: &buf}, slides[0], 1, true)
+	got := buf.String()
+	if !strings.Contains(got, "<summary>\nShow me") {
+		t.Errorf("no summary text in\n%s", got)
+	}
+	if strings.Count(got, "<details") != strings.Count(got, "</details>") {
+		t.Errorf("unbalanced details in\n%s", got)
+	}
+}
+
+func TestTableClasses(t *testing.T) {
+	o := testOptions(t)
+	for _, test := range []struct {
+		in   string
+		want []string // substrings of the output, in order
+		not  string   // must not appear
+	}{
+		{
+			in:   "| G1 | G2 |\n| -- | -- |\n| c++ | |\n{.interleave}\n",
+			want: []string{`<table class="interleave">`, "<td>c++</td>"},
+			not:  "{.interleave}",
+		},
+		{
+			in:   "| a |\n| - |\n| 1 |\n\ntext\n\n| b |\n| - |\n| 2 |\n{ .x .y }\n",
+			want: []string{"<table>", "<td>1</td>", `<table class="x y">`, "<td>2</td>"},
+			not:  ".x",
+		},
+		{
+			// A class line that doesn't follow a table is left alone.
+			in:   "text\n{.interleave}\n",
+			want: []string{"{.interleave}"},
+			not:  "<table",
+		},
+	} {
+		got := renderMarkdown(o, test.in)
+		rest := got
+		for _, w := range test.want {
+			i := strings.Index(rest, w)
+			if i < 0 {
+				t.Errorf("%q: missing %q in\n%s", test.in, w, got)
+				break
+			}
+			rest = rest[i+len(w):]
+		}
+		if strings.Contains(got, test.not) {
+			t.Errorf("%q: unexpected %q in\n%s", test.in, test.not, got)
+		}
+	}
+}
+
+func TestImage(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/image_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(slides) != 1 {
+		t.Fatalf("got %d slides, want 1", len(slides))
+	}
+
+	wantSections := []section{
+		{kind: sectionHTML, content: `<img src="testdata/diagram.png" alt="diagram.png" />`},
+		{kind: sectionHTML, content: `<img src="testdata/photo.jpg" alt="photo.jpg" />`},
+	}
+
+	if !sectionsEqual(slides[0].sections, wantSections) {
+		t.Errorf("got:\n%v\nwant:\n%v", slides[0].sections, wantSections)
+	}
+}
+
+func TestImageMissingFilename(t *testing.T) {
+	o := testOptions(t)
+	_, err := scanFile(o, "testdata/image_missing.go")
+	if err == nil {
+		t.Fatal("expected error for missing image filename")
+	}
+	if !strings.Contains(err.Error(), "missing image filename") {
+		t.Errorf("error = %q, want error containing 'missing image filename'", err)
+	}
+}
+
+func TestLink(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/link_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(slides) != 1 {
+		t.Fatalf("got %d slides, want 1", len(slides))
+	}
+
+	wantSections := []section{
+		{kind: sectionHTML, content: `<a href="testdata/doc.html">See the documentation</a>`},
+		{kind: sectionHTML, content: `<a href="testdata/other/file.go">View source code</a>`},
+	}
+
+	if !sectionsEqual(slides[0].sections, wantSections) {
+		t.Errorf("got:\n%v\nwant:\n%v", slides[0].sections, wantSections)
+	}
+}
+
+func TestLinkMissingFilename(t *testing.T) {
+	o := testOptions(t)
+	_, err := scanFile(o, "testdata/link_missing_file.go")
+	if err == nil {
+		t.Fatal("expected error for missing link filename")
+	}
+	if !strings.Contains(err.Error(), "missing link filename") {
+		t.Errorf("error = %q, want error containing 'missing link filename'", err)
+	}
+}
+
+func TestLinkMissingText(t *testing.T) {
+	o := testOptions(t)
+	_, err := scanFile(o, "testdata/link_missing_text.go")
+	if err == nil {
+		t.Fatal("expected error for missing link text")
+	}
+	if !strings.Contains(err.Error(), "missing link text") {
+		t.Errorf("error = %q, want error containing 'missing link text'", err)
+	}
+}
+
+func TestRenderCode(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{
+			input: "x := 1 // comment\n",
+			want:  "<span class='codenum'>1</span>x := <number>1</number> <comment>// comment</comment>\n",
+		},
+		{
+			input: "type Foo struct {}\n",
+			want:  "<span class='codenum'>1</span><keyword>type</keyword> <defn>Foo</defn> <keyword>struct</keyword> {}\n",
+		},
+		{
+			input: "func bar() {}\n",
+			want:  "<span class='codenum'>1</span><keyword>func</keyword> <defn>bar</defn>() {}\n",
+		},
+		{
+			input: "func (*Foo) moo() {}\n",
+			want:  "<span class='codenum'>1</span><keyword>func</keyword> (*Foo) <defn>moo</defn>() {}\n",
+		},
+		{
+			// Inline em markers (as produced by scanFile)
+			input: "x := \x00em\x00foo\x00/em\x00()\n",
+			want:  "<span class='codenum'>1</span>x := <span class=\"em\">foo</span>()\n",
+		},
+		{
+			input: "func (f Foo) moo() {}\n",
+			want:  "<span class='codenum'>1</span><keyword>func</keyword> (f Foo) <defn>moo</defn>() {}\n",
+		},
+		{
+			// Underscore suffix stripping
+			input: "x := foo_3x(bar_v2)\n",
+			want:  "<span class='codenum'>1</span>x := foo(bar)\n",
+		},
+		{
+			// Leading underscore preserved
+			input: "_private := 1\n",
+			want:  "<span class='codenum'>1</span>_private := <number>1</number>\n",
+		},
+		{
+			// Underscore suffix on func def
+			input: "func doThing_2() {}\n",
+			want:  "<span class='codenum'>1</span><keyword>func</keyword> <defn>doThing</defn>() {}\n",
+		},
+	}
+	for _, tt := range tests {
+		got := renderCode(tt.input, codeOptions{lineNumbers: true})
+		if got != tt.want {
+			t.Errorf("renderCode(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestDocLinks(t *testing.T) {
+	o, err := Config{DocLinks: []string{"sync", "sync/atomic"}}.options(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := renderCode("var wg sync.WaitGroup // not a time.Timer\nvar n atomic.Int64\n", codeOptions{docLinks: o.docLinks})
+	want := "<keyword>var</keyword> wg <a class='doc' href=\"https://pkg.go.dev/sync#WaitGroup\">sync.WaitGroup</a> <comment>// not a time.Timer</comment>\n" +
+		"<keyword>var</keyword> n <a class='doc' href=\"https://pkg.go.dev/sync/atomic#Int64\">atomic.Int64</a>\n"
+	if got != want {
+		t.Errorf("code:\ngot  %q\nwant %q", got, want)
+	}
+
+	for _, test := range []struct {
+		in, want string
+	}{
+		{
+			"Call `sync.WaitGroup.Add` first.",
+			"<p>Call <code><a class='doc' href=\"https://pkg.go.dev/sync#WaitGroup.Add\">sync.WaitGroup.Add</a></code> first.</p>\n",
+		},
+		{
+			// Already a link.
+			"See [`sync.Mutex`](https://go.dev/ref/mem).",
+			"<p>See <a href=\"https://go.dev/ref/mem\"><code>sync.Mutex</code></a>.</p>\n",
+		},
+		{
+			// Not a package from the flag, and not a package.
+			"Not `time.After` or `mu.Lock`.",
+			"<p>Not <code>time.After</code> or <code>mu.Lock</code>.</p>\n",
+		},
+	} {
+		if got := renderMarkdown(o, test.in); got != test.want {
+			t.Errorf("renderMarkdown(%q):\ngot  %q\nwant %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestScanFileValidOptions(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/code_valid_options.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(slides) != 1 {
+		t.Fatalf("got %d slides, want 1", len(slides))
+	}
+	slide := slides[0]
+
+	wantSections := []section{
+		{
+			kind:    sectionCode,
+			attrs:   []codeAttr{attrSmall, attrWeak},
+			content: "func foo() {}",
+		},
+		{
+			kind:    sectionCode,
+			attrs:   []codeAttr{attrSmaller, attrBad},
+			content: "func bar() {}",
+		},
+	}
+
+	if !sectionsEqual(slide.sections, wantSections) {
+		t.Errorf("got:\n%v\nwant:\n%v", slide.sections, wantSections)
+	}
+}
+
+func TestScanFileLine(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/line_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(slides) != 1 {
+		t.Fatalf("got %d slides, want 1", len(slides))
+	}
+	slide := slides[0]
+
+	wantSections := []section{
+		{
+			kind:    sectionLine,
+			content: "Hello\n",
+		},
+		{
+			kind:    sectionLine,
+			content: "World **bold**\n",
+		},
+	}
+
+	if !sectionsEqual(slide.sections, wantSections) {
+		t.Errorf("got:\n%v\nwant:\n%v", slide.sections, wantSections)
+	}
+}
+
+func TestFileLineHTML(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/line_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	slide := slides[0]
+
+	var buf strings.Builder
+	w := &indentWriter{w: &buf}
+	writeSlideHTML(o, w, slide, 1, false)
+	html := buf.String()
+
+	want1 := "Hello<br/>"
+	want2 := "World <strong>bold</strong><br/>"
+	if !strings.Contains(html, want1) {
+		t.Errorf("expected html to contain %q, got:\n%s", want1, html)
+	}
+	if !strings.Contains(html, want2) {
+		t.Errorf("expected html to contain %q, got:\n%s", want2, html)
+	}
+}
+
+func TestNoLineNumbersHTML(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/code_nonumbers.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(slides) != 1 {
+		t.Fatalf("got %d slides, want 1", len(slides))
+	}
+	slide := slides[0]
+
+	var buf strings.Builder
+	w := &indentWriter{w: &buf}
+	writeSlideHTML(o, w, slide, 1, false)
+	html := buf.String()
+
+	// The HTML should contain the code, but NOT the codenum spans.
+	if !strings.Contains(html, "<keyword>func</keyword> <defn>foo</defn>()") {
+		t.Errorf("expected html to contain %q, got:\n%s", "<keyword>func</keyword> <defn>foo</defn>()", html)
+	}
+
+	if strings.Contains(html, "codenum") {
+		t.Errorf("expected html to NOT contain %q, got:\n%s", "codenum", html)
+	}
+}
+
+func TestNetworkRefs(t *testing.T) {
+	in := `<script src='static/slides.js'></script>
+<script src="https://cdn.example.com/x.js"></script>
+var fontURL = "//fonts.googleapis.com/css?family=Open+Sans";
+<p>See <a href="https://go.dev/ref/mem">the memory model</a>.</p>
+<img src="testdata/diagram.png" alt="diagram.png" />
+`
+	got, err := networkRefs(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"2: https://cdn.example.com/x.js",
+		"3: //fonts.googleapis.com/css?family=Open+Sans",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestOfflineDeck(t *testing.T) {
+	dir := t.TempDir()
+	// Pretend the assets were already vendored, so the test needs no network.
+	for name := range vendoredAssets {
+		path := filepath.Join(dir, "static", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out := filepath.Join(dir, "deck.slides")
+
+	cfg := Config{Files: []string{"testdata/valid.go"}}
+	if err := BuildFile(t.Context(), cfg, out); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckOffline(out, ""); err == nil {
+		t.Error("default deck passed offline check, want error")
+	}
+
+	cfg.Offline = true
+	if err := BuildFile(t.Context(), cfg, out); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckOffline(out, ""); err != nil {
+		t.Error(err)
+	}
+
+	// The check includes the scripts the deck loads, except in comments
+	// and where marked.
+	js := "fetch('https://example.com/a');\n// see 'https://example.com/b'\nget('https://example.com/c'); // offline-ok\n"
+	if err := os.WriteFile(filepath.Join(dir, "static", "slides.js"), []byte(js), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := CheckOffline(out, "")
+	want := filepath.Join(dir, "static", "slides.js") + ":1: https://example.com/a"
+	if err == nil || !strings.HasSuffix(err.Error(), "\n"+want) {
+		t.Errorf("got %v, want error ending with %q", err, want)
+	}
+}
+
+// Words that are slide directives are only comments inside code.
+func TestDirectiveWordsInCode(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/directive_words.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(slides) != 2 {
+		t.Fatalf("got %d slides, want 2", len(slides))
+	}
+	for _, s := range slides {
+		if s.isTitle || len(s.titleInfo) > 0 || len(s.renames) > 0 || len(s.todos) > 0 ||
+			s.planned != 0 || s.exercise || s.sameAs != nil || s.hold != "" ||
+			s.label != "" || s.optional || len(s.tags) > 0 {
+			t.Errorf("slide %q changed: %+v", s.heading, s)
+		}
+	}
+	code := slides[0].sections[0].content
+	for _, want := range []string{
+		"// time out after a second",
+		"// exercise the slow path",
+		"// same-as above",
+		"// title case the name",
+		"// author of the change",
+		"// date of the last change",
+		"// event loop",
+		"// heading north",
+		"// rename the file",
+		"// hold the lock",
+		"// label the result",
+		"// label sum",
+		"// optional retry on error",
+	} {
+		if !strings.Contains(code, "\t"+want+"\n") {
+			t.Errorf("code is missing %q:\n%s", want, code)
+		}
+	}
+}
+
+func TestTodos(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/todo_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := findTodos(slides)
+	want := []string{
+		"testdata/todo_test.go:5: todo check this output on 1.26",
+		"testdata/todo_test.go:14: answer: TODO",
+		"testdata/todo_test.go:20: text: TODO: the second isn't.",
+		"testdata/todo_test.go:22: text: TODO inline",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// The todo directive doesn't produce a section.
+	for _, sec := range slides[0].sections {
+		if strings.Contains(sec.content, "check this output") {
+			t.Errorf("todo rendered in %s section", sec.kind)
+		}
+	}
+	// But a todo comment in code is code.
+	if sec := slides[0].sections[0]; sec.kind != sectionCode || sec.content != "x := 1\n// todo: remove x" {
+		t.Errorf("got %s section %q, want code with the todo comment", sec.kind, sec.content)
+	}
+
+	cfg := Config{Files: []string{"testdata/todo_test.go"}, ForbidTodo: true}
+	err = BuildFile(t.Context(), cfg, filepath.Join(t.TempDir(), "out"))
+	if err == nil || !strings.Contains(err.Error(), "deck has TODOs") {
+		t.Errorf("got %v, want error about TODOs", err)
+	}
+}
+
+func TestLintAnswers(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/answer_lint.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := lintSlides(slides, 10)
+	want := []string{
+		"testdata/answer_lint.go:11: empty answer",
+		`testdata/answer_lint.go:16: placeholder answer "TODO"`,
+		`testdata/answer_lint.go:22: answer "Yes." is shorter than 10 characters`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestEmGolden(t *testing.T) {
+	o := testOptions(t)
+	for _, name := range []string{"em_block", "inline_em_whole_line", "inline_em"} {
+		t.Run(name, func(t *testing.T) {
+			slides, err := scanFile(o, "testdata/"+name+".go")
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, name, renderCode(slides[0].sections[0].content, codeOptions{lineNumbers: true}))
+		})
+	}
+}
+
+func TestEmElement(t *testing.T) {
+	for _, tt := range []struct {
+		elem, class string
+		want        string
+	}{
+		{"span", "em", `x := <span class="em">foo</span>()`},
+		{"b", "", "x := <b>foo</b>()"},
+		{"mark", "hot", `x := <mark class="hot">foo</mark>()`},
+	} {
+		got := renderCode("x := \x00em\x00foo\x00/em\x00()", codeOptions{emElement: tt.elem, emClass: tt.class})
+		if got != tt.want {
+			t.Errorf("%s.%s: got %q, want %q", tt.elem, tt.class, got, tt.want)
+		}
+	}
+}
+
+func TestMarkEm(t *testing.T) {
+	// The second pattern must not match inside the markers added by the first.
+	res := []*regexp.Regexp{regexp.MustCompile("foo"), regexp.MustCompile("em"), regexp.MustCompile("o+")}
+	got := markEm("foo(em)", res, emPlain)
+	want := "\x00em\x00foo\x00/em\x00(\x00em\x00em\x00/em\x00)"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRenderEmSpans(t *testing.T) {
+	for _, tt := range []struct {
+		in, want string
+	}{
+		{
+			// Emphasis around a comment.
+			"\x00em\x00x := 1 // set x\x00/em\x00",
+			`<span class="em">x := </span><number><span class="em">1</span></number><span class="em"> </span><comment><span class="em">// set x</span></comment>`,
+		},
+		{
+			// Emphasis ending inside a comment.
+			"x := \x00em\x001 // set\x00/em\x00 x",
+			`x := <number><span class="em">1</span></number><span class="em"> </span><comment><span class="em">// set</span> x</comment>`,
+		},
+		{
+			// Nested emphasis.
+			"\x00em\x00a \x00em\x00b\x00/em\x00 c\x00/em\x00 d",
+			`<span class="em">a b c</span> d`,
+		},
+		{
+			// Several regions on one line.
+			"\x00em\x00a\x00/em\x00 + \x00em\x00b\x00/em\x00",
+			`<span class="em">a</span> + <span class="em">b</span>`,
+		},
+		{
+			// Definitions are still found.
+			"\x00em\x00func foo_2() {}\x00/em\x00",
+			`<keyword><span class="em">func</span></keyword><span class="em"> </span><defn><span class="em">foo</span></defn><span class="em">() {}</span>`,
+		},
+		{
+			// Block emphasis spanning lines is closed on each line.
+			"\x00em\x00a\nb\x00/em\x00\nc",
+			"<span class=\"em\">a</span>\n<span class=\"em\">b</span>\nc",
+		},
+	} {
+		got := renderCode(tt.in, codeOptions{})
+		if got != tt.want {
+			t.Errorf("renderCode(%q)\ngot  %s\nwant %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRenderCommentCodeSpans(t *testing.T) {
+	for _, tt := range []struct {
+		in, want string
+	}{
+		{
+			"v, ok := <-c // `ok` distinguishes closed from zero value",
+			"v, ok := &lt;-c <comment>// <code>ok</code> distinguishes closed from zero value</comment>",
+		},
+		{
+			// Backticks in code are left alone, as is an unmatched one in a comment.
+			"s := `a` // it`s",
+			"s := <string>`a`</string> <comment>// it`s</comment>",
+		},
+		{
+			"x // `a` and `b<c`",
+			"x <comment>// <code>a</code> and <code>b&lt;c</code></comment>",
+		},
+		{
+			"x // \x00em\x00see `wg.Wait`\x00/em\x00",
+			`x <comment>// <span class="em">see </span><code><span class="em">wg.Wait</span></code></comment>`,
+		},
+	} {
+		got := renderCode(tt.in, codeOptions{})
+		if got != tt.want {
+			t.Errorf("renderCode(%q)\ngot  %s\nwant %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCodeAttrs(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/code_attrs.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf strings.Builder
+	writeSlideHTML(o, &indentWriter{w: &buf}, slides[0], 1, true)
+	got := buf.String()
+	for _, want := range []string{
+		"<div class='code playground' data-kind='code'><pre contenteditable='true' spellcheck='false'>\n<keyword>package</keyword> main",
+		"<div class='code noescape weak' data-kind='code'><pre>\n<span class='codenum'>1</span>x := <b>1</b> <comment>// a < b</comment>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+	if !hasPlay(slides) {
+		t.Error("hasPlay = false, want true")
+	}
+}
+
+func TestAlignComments(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/code_align.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sec := slides[0].sections[0]
+	if !slices.Equal(sec.attrs, []codeAttr{attrAlign, attrNoNumbers}) {
+		t.Fatalf("attrs = %q, want [align nonumbers]", sec.attrs)
+	}
+	got := renderCode(sec.content, codeOptionsFor(o, sec.attrs))
+	// Columns are computed after tab expansion, indent compression
+	// and suffix stripping.
+	want := `c := make(<keyword>chan</keyword> int, <number>2</number>) <comment>// buffer of 2</comment>
+c &lt;- <number>1</number>                 <comment>// doesn&#39;t block</comment>
+c &lt;- <number>2</number>                 <comment>// doesn&#39;t block</comment>
+<comment>// just a comment</comment>
+<keyword>func</keyword>() {
+   c &lt;- <number>3</number>              <comment>// blocks</comment>
+}()`
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRename(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/rename_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(slides) != 2 {
+		t.Fatalf("got %d slides, want 2", len(slides))
+	}
+	for i, want := range []string{
+		"<keyword>func</keyword> <defn>printTree</defn>(nc int) {\n   printTree(nc - <number>1</number>)\n}",
+		"x := nc + foo",
+	} {
+		var buf strings.Builder
+		writeSlideHTML(o, &indentWriter{w: &buf}, slides[i], i+1, false)
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("slide %d: want %q in\n%s", i+1, want, buf.String())
+		}
+	}
+}
+
+func TestLintSignatures(t *testing.T) {
+	o := testOptions(t)
+	var slides []*Slide
+	for _, f := range []string{"testdata/signatures1.go", "testdata/signatures2.go"} {
+		ss, err := scanFile(o, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		slides = append(slides, ss...)
+	}
+	got := lintSignatures(slides)
+	want := []string{
+		"testdata/signatures2.go:13: *WaitGroup.Add(int64) differs from *WaitGroup.Add(int) at testdata/signatures1.go:4",
+		"testdata/signatures2.go:13: *WaitGroup.Done() error differs from *WaitGroup.Done() at testdata/signatures1.go:4",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// scanAndRun scans filename and runs its code, as building a deck does.
+func scanAndRun(o *options, filename string) ([]*Slide, error) {
+	slides, err := scanFile(o, filename)
+	if err != nil {
+		return nil, err
+	}
+	return slides, runCode(o, filename, slides)
+}
+
+func TestTestFail(t *testing.T) {
+	o := testOptions(t)
+	if testing.Short() {
+		t.Skip("runs go test")
+	}
+	slides, err := scanAndRun(o, "testdata/failing/failing.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []section{{kind: sectionOutput, content: `--- FAIL: TestFails
+    failing_test.go:6: count = 49, want 50
+FAIL
+exit status 1
+FAIL	github.com/jba/concurrency-workshop/code2slides/testdata/failing
+`}}
+	if !sectionsEqual(slides[0].sections, want) {
+		t.Errorf("got:\n%v\nwant:\n%v", slides[0].sections, want)
+	}
+
+	// Scanning alone runs nothing.
+	slides, err = scanFile(o, "testdata/failing/passing.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = runCode(o, "testdata/failing/passing.go", slides)
+	if err == nil || !strings.Contains(err.Error(), "TestPasses passed, but should fail") {
+		t.Errorf("got %v, want error about passing test", err)
+	}
+}
+
+func TestTestFailPinned(t *testing.T) {
+	o := testOptions(t)
+	if testing.Short() {
+		t.Skip("runs go test")
+	}
+	slides, err := scanAndRun(o, "testdata/failing/pinned.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sec := slides[0].sections[0]
+	if want := []string{"GOMAXPROCS=3 -v"}; !slices.Equal(sec.options, want) {
+		t.Errorf("got options %q, want %q", sec.options, want)
+	}
+	if !strings.Contains(sec.content, "=== RUN   TestReportsProcs") || !strings.Contains(sec.content, "GOMAXPROCS=3\n") {
+		t.Errorf("test did not run with -v and GOMAXPROCS=3:\n%s", sec.content)
+	}
+	if got := conditions([]string{"GOTOOLCHAIN=go1.24.0", "-race"}); got != "go1.24.0 -race" {
+		t.Errorf("conditions: got %q", got)
+	}
+}
+
+func TestCheckDeterminism(t *testing.T) {
+	o := testOptions(t)
+	if testing.Short() {
+		t.Skip("runs go test")
+	}
+	o.determinismRuns = 3
+
+	_, err := scanAndRun(o, "testdata/failing/varies.go")
+	if err == nil || !strings.Contains(err.Error(), "output of TestVaries varies between runs") {
+		t.Errorf("got %v, want error about varying output", err)
+	}
+	if _, err := scanAndRun(o, "testdata/failing/failing.go"); err != nil {
+		t.Error(err)
+	}
+
+	slides, err := scanAndRun(o, "testdata/failing/varies_marked.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf strings.Builder
+	writeSlideHTML(o, &indentWriter{w: &buf}, slides[0], 1, true)
+	if want := "<div class='conditions'>-v<span class='badge'>output varies</span></div>"; !strings.Contains(buf.String(), want) {
+		t.Errorf("missing %q in:\n%s", want, buf.String())
+	}
+}
+
+func TestTruncateLines(t *testing.T) {
+	got := truncateLines("a\nb\nc\nd\n", 2)
+	want := "a\nb\n... (2 more lines)\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := truncateLines("a\nb\n", 2); got != "a\nb\n" {
+		t.Errorf("got %q, want unchanged", got)
+	}
+}
+
+func TestContainerSandboxArgs(t *testing.T) {
+	root, err := filepath.Abs("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sb := containerSandbox{image: "golang", runtime: "runsc", modCache: "/home/u/go/pkg/mod"}
+	got, err := sb.dockerArgs(execRequest{dir: "testdata/failing", args: []string{"test", "-race"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"run", "--rm", "--network=none", "--runtime=runsc",
+		"--tmpfs=/tmp", "--env=GOCACHE=/tmp/gocache", "--env=GOTOOLCHAIN=local",
+		"--env=GOFLAGS=-mod=readonly", "--env=GOPROXY=off", "--env=GOMODCACHE=/home/u/go/pkg/mod",
+		"--volume=/home/u/go/pkg/mod:/home/u/go/pkg/mod:ro",
+		"--volume=" + root + ":" + root + ":ro",
+		"--workdir=" + filepath.Join(root, "code2slides/testdata/failing"),
+		"golang", "go", "test", "-race",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestContainerSandboxToolchain(t *testing.T) {
+	modCache := t.TempDir()
+	dl := filepath.Join(modCache, "cache", "download", "golang.org", "toolchain", "@v")
+	if err := os.MkdirAll(dl, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dl, "v0.0.1-go1.24.0.linux-"+runtime.GOARCH+".zip"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sb := containerSandbox{image: "golang:1.25.1", modCache: modCache}
+	req := execRequest{dir: "testdata/failing", args: []string{"test"}, env: []string{"GOTOOLCHAIN=go1.24.0"}}
+	args, err := sb.dockerArgs(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The directive's setting replaces the default.
+	var toolchains []string
+	for _, a := range args {
+		if v, ok := strings.CutPrefix(a, "--env=GOTOOLCHAIN="); ok {
+			toolchains = append(toolchains, v)
+		}
+	}
+	if want := []string{"go1.24.0"}; !slices.Equal(toolchains, want) {
+		t.Errorf("got GOTOOLCHAIN %q, want %q", toolchains, want)
+	}
+
+	// The image has its own toolchain.
+	req.env = []string{"GOTOOLCHAIN=go1.25.1"}
+	if _, err := sb.dockerArgs(req); err != nil {
+		t.Errorf("image's toolchain: %v", err)
+	}
+
+	// Others can't be downloaded.
+	req.env = []string{"GOTOOLCHAIN=go1.23.0"}
+	if _, err := sb.dockerArgs(req); err == nil || !strings.Contains(err.Error(), "GOTOOLCHAIN=go1.23.0") {
+		t.Errorf("toolchain not in cache: got %v, want error naming it", err)
+	}
+}
+
+func TestNewExecutor(t *testing.T) {
+	e, err := newExecutor(Config{Sandbox: "gvisor"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sb, ok := e.sandbox.(containerSandbox); !ok || sb.runtime != "runsc" {
+		t.Errorf("got %#v, want gVisor container sandbox", e.sandbox)
+	}
+	if _, err := newExecutor(Config{Sandbox: "chroot"}); err == nil {
+		t.Error("unknown sandbox: got nil error")
+	}
+}
+
+// A sandboxFunc is a sandbox that calls itself.
+type sandboxFunc func(ctx context.Context, req execRequest) (int, error)
+
+func (f sandboxFunc) run(ctx context.Context, req execRequest) (int, error) { return f(ctx, req) }
+
+func TestExecutorRun(t *testing.T) {
+	e := &executor{timeout: time.Minute, maxOutput: 10}
+
+	t.Run("output", func(t *testing.T) {
+		e.sandbox = sandboxFunc(func(ctx context.Context, req execRequest) (int, error) {
+			for ctx.Err() == nil {
+				fmt.Fprint(req.stdout, "spam ")
+				fmt.Fprint(req.stderr, "eggs ")
+			}
+			return -1, nil
+		})
+		var buf bytes.Buffer
+		_, note, err := e.run(t.Context(), execRequest{stdout: &buf, stderr: &buf})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := buf.String(), "spam eggs "; got != want {
+			t.Errorf("got output %q, want %q", got, want)
+		}
+		if want := "... (stopped after 10 bytes of output)\n"; note != want {
+			t.Errorf("got note %q, want %q", note, want)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		synctest.Test(t, func(t *testing.T) {
+			e.sandbox = sandboxFunc(func(ctx context.Context, req execRequest) (int, error) {
+				fmt.Fprint(req.stdout, "started")
+				<-ctx.Done()
+				return -1, nil
+			})
+			var buf bytes.Buffer
+			start := time.Now()
+			_, note, err := e.run(t.Context(), execRequest{stdout: &buf})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := time.Since(start); d != e.timeout {
+				t.Errorf("ran for %s, want %s", d, e.timeout)
+			}
+			if buf.String() != "started" {
+				t.Errorf("lost partial output: got %q", buf.String())
+			}
+			if want := "... (timed out after 1m0s)\n"; note != want {
+				t.Errorf("got note %q, want %q", note, want)
+			}
+		})
+	})
+}
+
+func TestServePlay(t *testing.T) {
+	e := &executor{timeout: time.Minute, maxOutput: 1 << 20}
+	ds := &deckServer{opts: &options{exec: e}}
+	post := func(body string) playResult {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/compile", strings.NewReader(url.Values{"body": {body}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		ds.servePlay(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %d: %s", w.Code, w.Body)
+		}
+		var res playResult
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	t.Run("run", func(t *testing.T) {
+		e.sandbox = sandboxFunc(func(ctx context.Context, req execRequest) (int, error) {
+			if !slices.Equal(req.args, []string{"run", "."}) {
+				t.Errorf("got args %q", req.args)
+			}
+			prog, err := os.ReadFile(filepath.Join(req.dir, "prog.go"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			fmt.Fprint(req.stdout, "hello, ")
+			fmt.Fprint(req.stdout, string(prog))
+			fmt.Fprint(req.stderr, "exit status 3\n")
+			return 3, nil
+		})
+		got := post("world")
+		want := playResult{
+			Events: []playEvent{
+				{Message: "hello, world", Kind: "stdout"},
+				{Message: "exit status 3\n", Kind: "stderr"},
+			},
+			Status: 3,
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("build error", func(t *testing.T) {
+		e.sandbox = sandboxFunc(func(ctx context.Context, req execRequest) (int, error) {
+			fmt.Fprintf(req.stderr, "# play\n%s/prog.go:1:1: expected 'package'\n", req.dir)
+			return 1, nil
+		})
+		got := post("oops")
+		if want := "# play\nprog.go:1:1: expected 'package'\n"; got.Errors != want || got.Events != nil {
+			t.Errorf("got %+v, want Errors %q and no events", got, want)
+		}
+	})
+}
+
+func TestFindFlakes(t *testing.T) {
+	e := &executor{timeout: time.Minute, maxOutput: 1 << 20}
+	// TestTimeout fails once when GOMAXPROCS is 1.
+	e.sandbox = sandboxFunc(func(ctx context.Context, req execRequest) (int, error) {
+		ev := func(action, test string) {
+			fmt.Fprintf(req.stdout, `{"Action":%q,"Package":"example.com/ch","Test":%q}`+"\n", action, test)
+		}
+		fmt.Fprintln(req.stdout, "# a line that isn't JSON")
+		ev("pass", "TestA")
+		ev("pass", "TestA")
+		ev("pass", "TestTimeout")
+		if slices.Contains(req.env, "GOMAXPROCS=1") {
+			ev("fail", "TestTimeout")
+			return 1, nil
+		}
+		ev("pass", "TestTimeout")
+		return 0, nil
+	})
+	results, err := findFlakes(t.Context(), e, ".", []string{"test", "-json"}, []int{1, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf strings.Builder
+	if !reportFlakes(&buf, results, false) {
+		t.Error("reportFlakes reported no failures")
+	}
+	want := `PACKAGE         TEST         GOMAXPROCS  FAILED
+example.com/ch  TestTimeout  1           1/2 (50%)
+1 of 2 tests failed at least once
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	e.sandbox = sandboxFunc(func(ctx context.Context, req execRequest) (int, error) {
+		fmt.Fprintln(req.stderr, "syntax error")
+		return 1, nil
+	})
+	if _, err := findFlakes(t.Context(), e, ".", []string{"test", "-json"}, []int{1}); err == nil || !strings.Contains(err.Error(), "syntax error") {
+		t.Errorf("build failure: got %v", err)
+	}
+}
+
+func TestReadManifest(t *testing.T) {
+	parts, err := readManifest("testdata/manifest.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []part{
+		{files: []string{"testdata/valid.go"}},
+		{name: "Basics", files: []string{"testdata/code_bad.go", "testdata/div_test.go"}},
+		{name: "Questions", files: []string{"testdata/code_in_answer.go"}},
+	}
+	if len(parts) != len(want) {
+		t.Fatalf("got %d parts, want %d", len(parts), len(want))
+	}
+	for i := range want {
+		if parts[i].name != want[i].name || !slices.Equal(parts[i].files, want[i].files) {
+			t.Errorf("part %d = %+v, want %+v", i, parts[i], want[i])
+		}
+	}
+}
+
+func TestNewModule(t *testing.T) {
+	o := testOptions(t)
+	dir := filepath.Join(t.TempDir(), "channels2")
+	files, err := newModule(dir, "channels2", "More Channels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != len(newModuleFiles) {
+		t.Errorf("got %d files, want %d", len(files), len(newModuleFiles))
+	}
+	parts, err := readManifest(filepath.Join(dir, "manifest.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "00-intro.go"), filepath.Join(dir, "10-channels2.go")}
+	if got := parts[0].files; !slices.Equal(got, want) {
+		t.Fatalf("manifest files = %v, want %v", got, want)
+	}
+	slides, err := scanFile(o, want[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slides[0].isTitle || slides[0].heading != "More Channels" {
+		t.Errorf("first slide: title %t, heading %q", slides[0].isTitle, slides[0].heading)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "channels2_test.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "package channels2\n") {
+		t.Errorf("test file has the wrong package:\n%s", data)
+	}
+
+	if _, err := newModule(dir, "channels2", "Again"); err == nil {
+		t.Error("existing module: got nil error")
+	}
+	if _, err := newModule(filepath.Join(t.TempDir(), "Bad-Name"), "Bad-Name", ""); err == nil {
+		t.Error("bad name: got nil error")
+	}
+}
+
+func TestNewSlide(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Two numbering schemes, and a manifest that names files.
+	for _, f := range []string{"1-intro.go", "2-add.go", "8-wait.go", "10-errgroup.go", "70-actor.go"} {
+		write(f, "package waitgroup\n")
+	}
+	write("manifest.txt", "1-intro.go\npart More\n[1-9]0-*.go\n8-wait.go\n")
+
+	file, err := newSlide(dir, "wg", "WaitGroups")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := filepath.Base(file), "80-wg.go"; got != want {
+		t.Errorf("new file %s, want %s", got, want)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "package waitgroup\n") || !strings.Contains(string(data), "// heading WaitGroups\n") {
+		t.Errorf("new file:\n%s", data)
+	}
+
+	rename := func(from, to string) error { return os.Rename(from, to) }
+	if err := renumberSlides(dir, rename); err != nil {
+		t.Fatal(err)
+	}
+	files, _, err := numberedFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range files {
+		got = append(got, f.file)
+	}
+	want := []string{"00-intro.go", "10-add.go", "20-wait.go", "30-errgroup.go", "40-actor.go", "50-wg.go"}
+	if !slices.Equal(got, want) {
+		t.Errorf("renumbered:\ngot  %v\nwant %v", got, want)
+	}
+	// The manifest's patterns are kept, and the files it names are renamed.
+	data, err = os.ReadFile(filepath.Join(dir, "manifest.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), "00-intro.go\npart More\n[1-9]0-*.go\n20-wait.go\n"; got != want {
+		t.Errorf("manifest:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestDups(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.CopyFS(dir, os.DirFS("testdata/dups")); err != nil {
+		t.Fatal(err)
+	}
+	canon, old := filepath.Join(dir, "canon"), filepath.Join(dir, "old")
+	matches, err := findDups(canon, []string{old}, 0.8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	reportDups(&buf, matches)
+	want := old + "/slide1.go: same as " + canon + "/10-mutex.go\n" +
+		old + "/slide2.go: 92% like " + canon + "/20-counter.go\n" +
+		old + "/slide3.go: no match\n"
+	if got := buf.String(); got != want {
+		t.Errorf("report:\ngot\n%s\nwant\n%s", got, want)
+	}
+
+	var removed []string
+	remove := func(f string) error {
+		removed = append(removed, f)
+		return os.Remove(f)
+	}
+	buf.Reset()
+	if err := mergeDups(&buf, canon, matches, os.Rename, remove); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join(old, "slide1.go")}; !slices.Equal(removed, want) {
+		t.Errorf("removed %v, want %v", removed, want)
+	}
+	data, err := os.ReadFile(filepath.Join(canon, "30-slide3.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "package mutexes\n") {
+		t.Errorf("moved file:\n%s", data)
+	}
+	if got, want := buf.String(), "merge by hand:\n\t"+old+"/slide2.go into "+canon+"/20-counter.go\n"; got != want {
+		t.Errorf("merge output:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestWriteWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	if err := writeWorkspace(dir, "testdata/grader/exercises", "1.26.2"); err != nil {
+		t.Fatal(err)
+	}
+	read := func(name string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	if got, want := read("go.work"), "go 1.26\n\nuse (\n\t./adder\n\t./scratch\n)\n"; got != want {
+		t.Errorf("go.work = %q, want %q", got, want)
+	}
+	if got, want := read("adder/go.mod"), "module workshop/adder\n\ngo 1.26\n"; got != want {
+		t.Errorf("go.mod = %q, want %q", got, want)
+	}
+	if got := read("adder/adder.go"); !strings.Contains(got, "// TODO") {
+		t.Errorf("adder.go is not the exercise:\n%s", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "adder", "solution")); err == nil {
+		t.Error("solution copied to workspace")
+	}
+	if testing.Short() {
+		return
+	}
+	// Workspaces allow only -mod=readonly and -mod=vendor.
+	cmd := exec.Command("go", "build", "-mod=readonly", "workshop/adder")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("building workspace: %v\n%s", err, out)
+	}
+}
+
+func TestWriteGrader(t *testing.T) {
+	dir := t.TempDir()
+	if err := writeGrader(dir, "testdata/grader/exercises", "1.26.2"); err != nil {
+		t.Fatal(err)
+	}
+	read := func(name string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	if got := read("Dockerfile"); !strings.Contains(got, "FROM golang:1.26.2\n") {
+		t.Errorf("Dockerfile not pinned to 1.26.2:\n%s", got)
+	}
+	if got, want := read("tests/adder/go.mod"), "module workshop/adder\n\ngo 1.26\n"; got != want {
+		t.Errorf("go.mod = %q, want %q", got, want)
+	}
+	read("tests/adder/adder_test.go")
+	// Only tests are vendored, and exercises without tests are left out.
+	for _, name := range []string{"tests/adder/adder.go", "tests/scratch"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			t.Errorf("%s exists", name)
+		}
+	}
+
+	if err := writeGrader(t.TempDir(), "testdata/grader/exercises", "devel"); err == nil {
+		t.Error("bad version: got nil error")
+	}
+
+	if testing.Short() {
+		return
+	}
+	// Grade with the local sandbox and toolchain.
+	e := &executor{sandbox: localSandbox{}, timeout: 10 * time.Minute, maxOutput: 1 << 20}
+	run := func(submission string) (int, string) {
+		t.Helper()
+		var buf strings.Builder
+		failed, err := grade(t.Context(), e, &buf, dir, submission)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return failed, buf.String()
+	}
+	// The unfinished exercise fails.
+	failed, out := run("testdata/grader/exercises")
+	if failed != 1 || !strings.HasPrefix(out, "FAIL adder\n") || !strings.Contains(out, "Add(1, 2) = 0") {
+		t.Errorf("exercise: %d failed, output:\n%s", failed, out)
+	}
+	// The solution passes.
+	sub := t.TempDir()
+	if err := os.CopyFS(filepath.Join(sub, "adder"), os.DirFS("testdata/grader/exercises/adder/solution")); err != nil {
+		t.Fatal(err)
+	}
+	if failed, out := run(sub); failed != 0 || out != "PASS adder\n" {
+		t.Errorf("solution: %d failed, output:\n%s", failed, out)
+	}
+}
+
+func TestSharedLibrary(t *testing.T) {
+	parts, err := readManifest("testdata/shared/mutexes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The second pattern for race.go adds nothing, so part More is empty.
+	if len(parts) != 2 || len(parts[1].files) != 0 {
+		t.Fatalf("got %+v, want the shared file only in the first part", parts)
+	}
+	if want := []string{"testdata/valid.go", "testdata/shared/lib/race.go"}; !slices.Equal(parts[0].files, want) {
+		t.Errorf("files = %q, want %q", parts[0].files, want)
+	}
+	if want := []string{"testdata/shared/lib/race.go"}; !slices.Equal(parts[0].shared, want) {
+		t.Errorf("shared = %q, want %q", parts[0].shared, want)
+	}
+
+	users, err := sharedUsers([]string{"testdata/shared/mutexes.txt", "testdata/shared/channels.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf strings.Builder
+	reportShared(&buf, users)
+	want := `testdata/shared/lib/race.go: testdata/shared/mutexes.txt testdata/shared/channels.txt
+testdata/shared/lib/unused.go: unused
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestNumbering(t *testing.T) {
+	o := testOptions(t)
+	parts := []part{
+		{name: "Mutexes", files: []string{"testdata/numbering/first.go"}},
+		{name: "Channels", files: []string{"testdata/numbering/second.go"}},
+	}
+	for _, test := range []struct {
+		byPart    bool
+		headings  []string
+		questions []int
+	}{
+		{false, []string{"Exercise 1: Bank Account", "Races", "Exercise 2: Hedging"}, []int{1, 2, 3}},
+		{true, []string{"Exercise 1: Bank Account", "Races", "Exercise 1: Hedging"}, []int{1, 2, 1}},
+	} {
+		o.numberByPart = test.byPart
+		var buf bytes.Buffer
+		slides, err := writeDeck(o, &buf, "", "T", parts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var headings []string
+		var questions []int
+		for _, s := range slides {
+			if s.isDivider || s == slides[0] {
+				continue // dividers and contents
+			}
+			headings = append(headings, s.heading)
+			for _, sec := range s.sections {
+				if sec.kind == sectionQuestion {
+					questions = append(questions, sec.num)
+				}
+			}
+		}
+		if !slices.Equal(headings, test.headings) {
+			t.Errorf("by part %t: headings %q, want %q", test.byPart, headings, test.headings)
+		}
+		if !slices.Equal(questions, test.questions) {
+			t.Errorf("by part %t: questions %v, want %v", test.byPart, questions, test.questions)
+		}
+		if !strings.Contains(buf.String(), "<span class='question-number'>Question 2.</span>") {
+			t.Errorf("by part %t: no label for question 2", test.byPart)
+		}
+	}
+}
+
+func TestLabels(t *testing.T) {
+	o := testOptions(t)
+	var buf bytes.Buffer
+	_, err := writeDeck(o, &buf, "", "T", []part{{files: []string{"testdata/label/first.go", "testdata/label/second.go"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<a href="#1">WaitGroup</a>`,
+		`<a href="#3">errgroup</a>`,
+		`<a href="#1">slide 1</a>`,
+		`<a href="#1">WaitGroup chapter</a>`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("missing %s", want)
+		}
+	}
+
+	_, err = writeDeck(o, io.Discard, "", "T", []part{{files: []string{"testdata/label_dangling.go"}}})
+	if err == nil || !strings.Contains(err.Error(), `label_dangling.go:4: no slide labeled "missing"`) {
+		t.Errorf("got %v, want dangling reference error", err)
+	}
+	_, err = writeDeck(o, io.Discard, "", "T", []part{{files: []string{"testdata/label/second.go", "testdata/label/second.go"}}})
+	if err == nil || !strings.Contains(err.Error(), `label "errgroup" used twice`) {
+		t.Errorf("got %v, want duplicate label error", err)
+	}
+}
+
+func TestAnalytics(t *testing.T) {
+	o := testOptions(t)
+	o.analyticsURL = "https://example.com/events?deck=</script>"
+	var buf bytes.Buffer
+	_, err := writeDeck(o, &buf, "", "T", []part{{files: []string{"testdata/numbering/first.go", "testdata/label/first.go", "testdata/label/second.go"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"<article data-slide='first.go:1' data-exercise='1'>",
+		"<article data-slide='errgroup'>",
+		"<article data-slide='first.go:2'>",
+		"<article data-slide='waitgroup'>",
+		"<details data-kind='question'>",
+		"<div class='text' data-kind='text'>",
+		`<script>var analyticsURL = "https://example.com/events?deck=\u003c/script\u003e";</script>`,
+		"<script src='static/analytics.js'></script>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("deck does not contain %q", want)
+		}
+	}
+}
+
+func TestGrep(t *testing.T) {
+	o := testOptions(t)
+	files, err := slideFiles([]string{"testdata/numbering", "testdata/inline_em.go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		pat, kind string
+		want      string
+	}{
+		{
+			"(?i)race", "",
+			"testdata/numbering/first.go: Races [heading]\n" +
+				"testdata/numbering/first.go:10: Races [question]: Does the race detector find every race?\n" +
+				"testdata/numbering/first.go:11: Races [answer]: Only races that happen while it watches.\n",
+		},
+		// Emphasis doesn't get in the way.
+		{`foo\(\)`, "code", "testdata/inline_em.go:4: Inline Em Test [code]: x := foo()\n"},
+		{"Hedging", "heading", "testdata/numbering/second.go: Hedging [heading]\n"},
+		{"canceled", "question", ""},
+		{"canceled", "answer", "testdata/numbering/second.go:6: Hedging [answer]: It should be canceled, so it stops using resources.\n"},
+	} {
+		var buf bytes.Buffer
+		n, err := grepSlides(o, &buf, regexp.MustCompile(test.pat), test.kind, files)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("%q -kind %q:\ngot\n%s\nwant\n%s", test.pat, test.kind, got, test.want)
+		}
+		if want := strings.Count(test.want, "\n"); n != want {
+			t.Errorf("%q -kind %q: got %d matches, want %d", test.pat, test.kind, n, want)
+		}
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	read := func(name string) []byte {
+		t.Helper()
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	add, goCalls := read("testdata/rewrite/add.go"), read("testdata/rewrite/go.go")
+
+	var buf bytes.Buffer
+	got, err := rewriteSource(&buf, "add.go", add, wgGoEdits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(goCalls) {
+		t.Errorf("wg-go:\ngot\n%s\nwant\n%s", got, goCalls)
+	}
+	wantLog := "add.go:15: skipped: comment in the way of wg.Go\nadd.go: 2 rewritten\n"
+	if buf.String() != wantLog {
+		t.Errorf("wg-go log:\ngot\n%s\nwant\n%s", buf.String(), wantLog)
+	}
+
+	// The skipped code in go.go is as it was, so wg-add gets back add.go.
+	got, err = rewriteSource(io.Discard, "go.go", goCalls, wgAddEdits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(add) {
+		t.Errorf("wg-add:\ngot\n%s\nwant\n%s", got, add)
+	}
+}
+
+func TestImports(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "unused.go")
+	data, err := os.ReadFile("testdata/imports/unused.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err = checkImports([]string{file, "testdata/imports/unused.go.fixed", "testdata/code_bad.go"})
+	if err == nil {
+		t.Fatal("got nil, want error")
+	}
+	for _, want := range []string{`unused.go:6: "strings" imported and not used`, `unused.go:8: "time"`, `unused.go:12: "os"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "fixed") {
+		t.Errorf("error %q reports imports that are used", err)
+	}
+
+	if err := fixImports(io.Discard, []string{file}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("testdata/imports/unused.go.fixed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	for path, want := range map[string]string{
+		"sync":                "sync",
+		"math/rand/v2":        "rand",
+		"gopkg.in/yaml.v3":    "yaml",
+		"github.com/x/go-foo": "foo",
+	} {
+		if got := importName(path); got != want {
+			t.Errorf("importName(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestBuildParts(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "deck.slides")
+	if err := BuildFile(t.Context(), Config{Manifest: "testdata/manifest.txt"}, out); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	for _, want := range []string{
+		// valid.go is slide 1, then contents.
+		"<h1>Contents</h1>",
+		"<li><a href='#3'>Part 1: Basics</a><ul><li><a href='#4'>code_bad.go</a></li><li><a href='#5'>div_test.go</a></li></ul></li>",
+		"<li><a href='#6'>Part 2: Questions</a><ul><li><a href='#7'>Code in Answer</a></li></ul></li>",
+		"<article class='title-slide divider'>\n  <div class='title-text'>Part 1: Basics</div>\n  <span class='pagenumber'>1</span>",
+		"<span class='pagenumber'>1.2</span>",
+		"<span class='pagenumber'>2.1 and last</span>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %q", want)
+		}
+	}
+}
+
+func TestScroll(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "deck.html")
+	if err := BuildFile(t.Context(), Config{Files: []string{"testdata/valid.go"}, Scroll: true}, out); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	if !strings.Contains(got, "<body class='scroll'>") || !strings.Contains(got, "static/scroll.css") {
+		t.Error("scroll deck missing scroll styling")
+	}
+	for _, bad := range []string{"slides.js", "id=\"help\""} {
+		if strings.Contains(got, bad) {
+			t.Errorf("scroll deck contains %q", bad)
+		}
+	}
+}
+
+func TestFooter(t *testing.T) {
+	o := testOptions(t)
+	o.footer = "Licensed under [CC BY 4.0](https://creativecommons.org/licenses/by/4.0/)."
+	slides, err := scanFile(o, "testdata/valid.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf strings.Builder
+	writeSlideHTML(o, &indentWriter{w: &buf}, slides[0], 1, true)
+	want := `<div class='footer'>Licensed under <a href="https://creativecommons.org/licenses/by/4.0/">CC BY 4.0</a>.</div>`
+	if got := buf.String(); !strings.Contains(got, want) {
+		t.Errorf("missing %q in:\n%s", want, got)
+	}
+}
+
+func TestFeed(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "s.go")
+	cfg := Config{Files: []string{src}, Title: "Deck", Feed: filepath.Join(dir, "feed.json")}
+	defer func() { now = time.Now }()
+
+	readFeed := func() jsonFeed {
+		t.Helper()
+		data, err := os.ReadFile(cfg.Feed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var f jsonFeed
+		if err := json.Unmarshal(data, &f); err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+
+	day1 := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	now = func() time.Time { return day1 }
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(src, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := BuildFile(t.Context(), cfg, filepath.Join(dir, "deck.html")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("// heading A\n// text\n// a\n// !text\n// heading B\n// text b\n")
+	f := readFeed()
+	if len(f.Items) != 2 || f.Items[0].ContentText != `Added slide 1, "A".` {
+		t.Fatalf("day 1 items: %+v", f.Items)
+	}
+
+	day2 := day1.Add(24 * time.Hour)
+	now = func() time.Time { return day2 }
+	write("// heading A\n// text\n// a, revised\n// !text\n// heading B\n// text b\n// heading C\n")
+	f = readFeed()
+	var got []string
+	for _, it := range f.Items {
+		got = append(got, fmt.Sprintf("%s %s %s", it.DateModified.Format(time.DateOnly), it.URL, it.ContentText))
+	}
+	want := []string{
+		`2026-10-02 deck.html#1 Updated slide 1, "A".`,
+		`2026-10-02 deck.html#3 Added slide 3, "C".`,
+		`2026-10-01 deck.html#2 Added slide 2, "B".`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	cfg.FeedSince = time.Hour
+	write("// heading A\n// text\n// a, revised\n// !text\n// heading B\n// text b\n// heading C\n")
+	if f := readFeed(); len(f.Items) != 2 {
+		t.Errorf("with -feed-since, got %d items, want 2", len(f.Items))
+	}
+}
+
+func TestChangesSince(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "s.go")
+	out := filepath.Join(dir, "deck.html")
+	cfg := Config{Files: []string{src}, Title: "Deck", Feed: filepath.Join(dir, "feed.json")}
+	build := func(content string) string {
+		t.Helper()
+		if err := os.WriteFile(src, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := BuildFile(t.Context(), cfg, out); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	cfg.Version = "v1"
+	build("// title Workshop\n// heading A\n// text a\n// heading B\n// text b\n")
+	cfg.Version = ""
+	cfg.ChangesSince = "v1"
+	got := build("// title Workshop\n// heading A\n// text a, revised\n// heading C\n// text c\n")
+	want := "<h1>What changed since v1</h1>\n" +
+		"  <ul class='changes'><li><a href='#3'>A</a> (changed)</li><li><a href='#4'>C</a> (new)</li><li>B (removed)</li></ul>"
+	if !strings.Contains(got, want) {
+		t.Errorf("missing %q in:\n%s", want, got)
+	}
+	if !strings.Contains(got, "<!-- slide 2 -->\n<article>\n  <h1>What changed") {
+		t.Error("changes are not the second slide")
+	}
+
+	cfg.ChangesSince = "v0"
+	if err := BuildFile(t.Context(), cfg, out); err == nil || !strings.Contains(err.Error(), `no snapshot of version "v0"`) {
+		t.Errorf("unknown version: got %v", err)
+	}
+}
+
+func TestServeAuth(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { fmt.Fprint(w, "ok") })
+	h := authConfig{user: "u", password: "p", token: "tok"}.wrap(ok)
+
+	get := func(target string, setup func(*http.Request)) *http.Response {
+		t.Helper()
+		r := httptest.NewRequest("GET", target, nil)
+		if setup != nil {
+			setup(r)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	for _, test := range []struct {
+		name   string
+		target string
+		setup  func(*http.Request)
+		want   int
+	}{
+		{"none", "/", nil, http.StatusUnauthorized},
+		{"basic", "/", func(r *http.Request) { r.SetBasicAuth("u", "p") }, http.StatusOK},
+		{"basic wrong", "/", func(r *http.Request) { r.SetBasicAuth("u", "x") }, http.StatusUnauthorized},
+		{"query", "/?token=tok", nil, http.StatusOK},
+		{"query wrong", "/?token=x", nil, http.StatusUnauthorized},
+		{"bearer", "/", func(r *http.Request) { r.Header.Set("Authorization", "Bearer tok") }, http.StatusOK},
+		{"cookie", "/static/x", func(r *http.Request) { r.AddCookie(&http.Cookie{Name: tokenCookie, Value: "tok"}) }, http.StatusOK},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := get(test.target, test.setup).StatusCode; got != test.want {
+				t.Errorf("got %d, want %d", got, test.want)
+			}
+		})
+	}
+
+	res := get("/?token=tok", nil)
+	if cs := res.Cookies(); len(cs) != 1 || cs[0].Name != tokenCookie || cs[0].Value != "tok" {
+		t.Errorf("token in query: got cookies %v", cs)
+	}
+	res = get("/", nil)
+	if res.Header.Get("WWW-Authenticate") == "" {
+		t.Error("no WWW-Authenticate header on 401")
+	}
+}
+
+func TestDeckServerRebuild(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "s.go")
+	write := func(content string, mtime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(src, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(src, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	get := func(h http.Handler) string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %d: %s", w.Code, w.Body)
+		}
+		return w.Body.String()
+	}
+
+	ds := &deckServer{title: "T", parts: []part{{files: []string{src}}}, opts: testOptions(t)}
+	write("// heading First\n", time.Now().Add(-time.Hour))
+	if got := get(ds); !strings.Contains(got, "First") {
+		t.Fatal("deck does not contain first heading")
+	}
+	write("// heading Second\n", time.Now().Add(time.Hour))
+	if got := get(ds); !strings.Contains(got, "Second") {
+		t.Error("deck was not rebuilt after change")
+	}
+}
+
+func TestHoldRelease(t *testing.T) {
+	ds := &deckServer{title: "T", parts: []part{{files: []string{"testdata/hold.go"}}}, opts: testOptions(t)}
+	ds.live.presenterKey = "k"
+	mux := http.NewServeMux()
+	mux.Handle("/{$}", ds)
+	mux.HandleFunc("POST /release/{name}", ds.serveRelease)
+	do := func(method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w
+	}
+
+	if got := do("GET", "/").Body.String(); strings.Contains(got, "Solution") {
+		t.Error("held slide is in the deck before release")
+	}
+	if w := do("POST", "/release/ex1"); w.Code != http.StatusForbidden {
+		t.Errorf("release without key: got %d, want 403", w.Code)
+	}
+	if w := do("POST", "/release/ex1?key=k&after=-1s"); w.Code != http.StatusBadRequest {
+		t.Errorf("negative delay: got %d, want 400", w.Code)
+	}
+	if w := do("POST", "/release/ex1?key=k"); w.Code != http.StatusNoContent {
+		t.Fatalf("release: got %d, want 204", w.Code)
+	}
+	if got := do("GET", "/").Body.String(); !strings.Contains(got, "<article class='held' data-slide='hold.go:2'>\n  <h1>Solution</h1>") {
+		t.Error("held slide is not in the deck after release")
+	}
+}
+
+func TestPoll(t *testing.T) {
+	ds := &deckServer{title: "T", parts: []part{{files: []string{"testdata/poll.go"}}}, opts: testOptions(t)}
+	mux := http.NewServeMux()
+	mux.Handle("/{$}", ds)
+	mux.HandleFunc("POST /poll/{slide}", ds.servePollVote)
+	mux.HandleFunc("GET /polls", ds.servePolls)
+	do := func(method, target string, form url.Values) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	got := do("GET", "/", nil).Body.String()
+	if want := "<div class='poll' data-poll='1'>\n    <button data-choice='A'>A</button>"; !strings.Contains(got, want) {
+		t.Errorf("missing %q in:\n%s", want, got)
+	}
+	events := ds.live.connect("watcher", "1")
+	defer ds.live.disconnect("watcher", events)
+
+	for _, test := range []struct {
+		slide, viewer, choice string
+		want                  int
+	}{
+		{"1", "v1", "A", http.StatusNoContent},
+		{"1", "v2", "A", http.StatusNoContent},
+		{"1", "v2", "B", http.StatusNoContent}, // v2 changes their vote
+		{"1", "v3", "C", http.StatusBadRequest},
+		{"1", "", "A", http.StatusBadRequest},
+		{"2", "v1", "A", http.StatusNotFound},
+	} {
+		form := url.Values{"viewer": {test.viewer}, "choice": {test.choice}}
+		if w := do("POST", "/poll/"+test.slide, form); w.Code != test.want {
+			t.Errorf("%+v: got %d, want %d", test, w.Code, test.want)
+		}
+	}
+	var first string
+	select {
+	case first = <-events:
+	default:
+	}
+	if got, want := first, "event: poll\ndata: {\"counts\":{\"A\":1},\"poll\":\"1\"}\n\n"; got != want {
+		t.Errorf("first event: got %q, want %q", got, want)
+	}
+	if got, want := do("GET", "/polls", nil).Body.String(), `{"1":{"A":1,"B":1}}`+"\n"; got != want {
+		t.Errorf("polls: got %q, want %q", got, want)
+	}
+	var buf strings.Builder
+	ds.writeMetrics(&buf)
+	if want := `code2slides_poll_voters{slide="1"} 2`; !strings.Contains(buf.String(), want) {
+		t.Errorf("metrics missing %q", want)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	ds := &deckServer{title: "T", parts: []part{{files: []string{"testdata/code_diff.go"}}}, opts: testOptions(t)}
+	ds.live.hasSlide = ds.isSlide
+	mux := http.NewServeMux()
+	mux.Handle("/{$}", ds)
+	mux.HandleFunc("GET /live", ds.live.serveEvents)
+	mux.HandleFunc("POST /live/slide", ds.live.serveSlide)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if !strings.Contains(string(body), liveScript) {
+		t.Error("served deck does not load live.js")
+	}
+
+	// Connect two viewers, and wait until the server has registered them.
+	for _, id := range []string{"a", "b"} {
+		res, err := http.Get(srv.URL + "/live?id=" + id + "&slide=1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		if _, err := res.Body.Read(make([]byte, 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	res, err = http.Post(srv.URL+"/live/slide?id=b&slide=2", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	// Viewers can't make up slides.
+	for _, slide := range []string{"3", "02", `1"} 9`} {
+		res, err = http.Post(srv.URL+"/live/slide?id=a&slide="+url.QueryEscape(slide), "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusBadRequest {
+			t.Errorf("slide %q: got status %d, want %d", slide, res.StatusCode, http.StatusBadRequest)
+		}
+	}
+
+	var buf strings.Builder
+	ds.writeMetrics(&buf)
+	for _, want := range []string{
+		"code2slides_viewers 2\n",
+		`code2slides_slide_viewers{slide="1"} 1` + "\n",
+		`code2slides_slide_viewers{slide="2"} 1` + "\n",
+		"code2slides_rebuild_duration_seconds_count 1\n",
+		"code2slides_rebuild_errors_total 0\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("metrics missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestLabelValue(t *testing.T) {
+	if got, want := labelValue("a\\b\"c\nd"), `a\\b\"c\nd`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestLiveTest(t *testing.T) {
+	o := testOptions(t)
+	var got execRequest
+	o.exec.sandbox = sandboxFunc(func(ctx context.Context, req execRequest) (int, error) {
+		got = req
+		fmt.Fprint(req.stdout, `{"Action":"output","Output":"--- FAIL: TestFails\n"}`+"\n")
+		fmt.Fprint(req.stdout, `{"Action":"fail"}`)
+		return 1, nil
+	})
+	ds := &deckServer{title: "T", parts: []part{{files: []string{"testdata/failing/livetest.go"}}}, opts: o}
+
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ds.serveLiveTest(w, httptest.NewRequest("GET", "/livetest?"+query, nil))
+		return w
+	}
+	if w := get("dir=/etc&args=-run+TestFails+-v"); w.Code != http.StatusForbidden {
+		t.Errorf("unknown livetest: got status %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	w := get("dir=testdata/failing&args=-run+TestFails+-v")
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", w.Code, w.Body)
+	}
+	if want := []string{"test", "-json", "-run", "TestFails", "-v"}; !slices.Equal(got.args, want) || got.dir != "testdata/failing" {
+		t.Errorf("ran go %q in %s, want go %q in testdata/failing", got.args, got.dir, want)
+	}
+	want := `data: {"Action":"output","Output":"--- FAIL: TestFails\n"}
+
+data: {"Action":"fail"}
+
+event: done
+data: {"code":1,"note":""}
+
+`
+	if w.Body.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", w.Body, want)
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "annotations.json")
+	ds := &deckServer{title: "T", parts: []part{{files: []string{"testdata/valid.go"}}}, opts: testOptions(t)}
+	ds.live.presenterKey = "k"
+	ds.annotations.file = file
+	if err := ds.annotations.load(); err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /annotations", ds.annotations.serveAll)
+	mux.HandleFunc("PUT /annotations/{slide}", ds.serveAnnotation)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+	stroke := `[{"tool":"pen","points":[[1,2],[3,4]]}]`
+	if w := do("PUT", "/annotations/1?key=k", stroke); w.Code != http.StatusNoContent {
+		t.Fatalf("PUT: got status %d: %s", w.Code, w.Body)
+	}
+	for _, key := range []string{"", "?key=x"} {
+		if w := do("PUT", "/annotations/1"+key, stroke); w.Code != http.StatusForbidden {
+			t.Errorf("PUT with key %q: got status %d, want %d", key, w.Code, http.StatusForbidden)
+		}
+	}
+	if w := do("PUT", "/annotations/1?key=k", "not JSON"); w.Code != http.StatusBadRequest {
+		t.Errorf("PUT invalid JSON: got status %d", w.Code)
+	}
+	for _, slide := range []string{"x", "0", "-1", "+01", "01", "99999999"} {
+		if w := do("PUT", "/annotations/"+slide+"?key=k", stroke); w.Code != http.StatusBadRequest {
+			t.Errorf("PUT slide %q: got status %d, want %d", slide, w.Code, http.StatusBadRequest)
+		}
+	}
+	want := `{"1":` + stroke + `}`
+	if got := do("GET", "/annotations", "").Body.String(); got != want {
+		t.Errorf("GET: got %s, want %s", got, want)
+	}
+
+	// The drawings survive a restart.
+	as2 := &annotationStore{file: file}
+	if err := as2.load(); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(as2.slides["1"]); got != stroke {
+		t.Errorf("after reload: got %s, want %s", got, stroke)
+	}
+}
+
+func TestPointerSharing(t *testing.T) {
+	hub := &liveHub{presenterKey: "k"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /live", hub.serveEvents)
+	mux.HandleFunc("POST /live/pointer", hub.servePointer)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/live?id=a&slide=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	r := bufio.NewReader(res.Body)
+	if _, err := r.ReadString('\n'); err != nil { // ": connected"
+		t.Fatal(err)
+	}
+
+	post := func(key, body string) int {
+		res, err := http.Post(srv.URL+"/live/pointer?key="+key, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+	if code := post("wrong", `{}`); code != http.StatusForbidden {
+		t.Errorf("wrong key: got status %d", code)
+	}
+	if code := post("k", `{"slide": 2, "x": 0.5, "y": 0.25, "mode": "laser"}`); code != http.StatusNoContent {
+		t.Fatalf("got status %d", code)
+	}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line == "event: pointer\n" {
+			break
+		}
+	}
+	line, _ := r.ReadString('\n')
+	if want := `data: {"slide":2,"x":0.5,"y":0.25,"mode":"laser"}` + "\n"; line != want {
+		t.Errorf("got %q, want %q", line, want)
+	}
+}
+
+// A pipeListener is an in-memory [net.Listener], so servers can run in a
+// synctest bubble.
+type pipeListener struct {
+	conns  chan net.Conn
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newPipeListener() *pipeListener {
+	return &pipeListener{conns: make(chan net.Conn), closed: make(chan struct{})}
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr { return pipeAddr{} }
+
+func (l *pipeListener) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	c1, c2 := net.Pipe()
+	select {
+	case l.conns <- c1:
+		return c2, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "pipe" }
+
+// startServer runs srv with runServer in the current synctest bubble.
+// It returns a client for the server, a function that cancels the
+// server's context, and a channel that receives runServer's result.
+func startServer(t *testing.T, srv *http.Server, drain time.Duration) (*http.Client, context.CancelFunc, <-chan error) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	ln := newPipeListener()
+	ctx, cancel := context.WithCancel(t.Context())
+	errc := make(chan error, 1)
+	go func() { errc <- runServer(ctx, srv, ln, "", "", drain) }()
+	client := &http.Client{Transport: &http.Transport{DialContext: ln.dial}}
+	t.Cleanup(client.CloseIdleConnections)
+	return client, cancel, errc
+}
+
+func TestShutdownEndsEventStreams(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		hub := &liveHub{hasSlide: func(s string) bool { return s == "1" }}
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /live", hub.serveEvents)
+		srv := &http.Server{Handler: mux}
+		srv.RegisterOnShutdown(hub.shutdown)
+		client, cancel, errc := startServer(t, srv, time.Minute)
+
+		res, err := client.Get("http://deck/live?id=a&slide=1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		if _, err := res.Body.Read(make([]byte, 1)); err != nil {
+			t.Fatal(err)
+		}
+		if got := hub.slideCounts()["1"]; got != 1 {
+			t.Fatalf("got %d viewers, want 1", got)
+		}
+
+		start := time.Now()
+		cancel()
+		rest, _ := io.ReadAll(res.Body)
+		if !strings.Contains(string(rest), "event: shutdown") {
+			t.Errorf("stream ended without shutdown event: %q", rest)
+		}
+		if err := <-errc; err != nil {
+			t.Errorf("runServer: %v", err)
+		}
+		if d := time.Since(start); d >= time.Minute {
+			t.Errorf("shutdown took %s; it should not wait for the drain timeout", d)
+		}
+		if n := len(hub.slideCounts()); n != 0 {
+			t.Errorf("%d viewers remain after shutdown", n)
+		}
+	})
+}
+
+func TestShutdownDrainTimeout(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		const drain = 5 * time.Second
+		started := make(chan struct{})
+		release := make(chan struct{})
+		srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release // ignores the request's context
+		})}
+		client, cancel, errc := startServer(t, srv, drain)
+
+		go client.Get("http://deck/")
+		<-started
+		start := time.Now()
+		cancel()
+		<-errc
+		if d := time.Since(start); d != drain {
+			t.Errorf("shutdown took %s, want %s", d, drain)
+		}
+		close(release)
+		synctest.Wait()
+	})
+}
+
+func TestChanOps(t *testing.T) {
+	o := testOptions(t)
+	o.chanOps = true
+	src := "func f(in <-chan int, out chan<- int) {\n\tdone := make(chan struct{})\n\tout <- <-in // \"<-\" in a comment\n\tclose(done)\n\tx.close()\n}\n"
+	got := renderCode(src, codeOptionsFor(o, nil))
+	want := `<span class='codenum'>1</span><keyword>func</keyword> <defn>f</defn>(in &lt;-<keyword>chan</keyword> int, out <keyword>chan</keyword>&lt;- int) {
+<span class='codenum'>2</span>   done := <span class='chanop'>make(</span><keyword><span class='chanop'>chan</span></keyword> <keyword>struct</keyword>{})
+<span class='codenum'>3</span>   out <span class='chanop'>&lt;-</span> <span class='chanop'>&lt;-</span>in <comment>// &#34;&lt;-&#34; in a comment</comment>
+<span class='codenum'>4</span>   <span class='chanop'>close</span>(done)
+<span class='codenum'>5</span>   x.close()
+<span class='codenum'>6</span>}
+`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestMutexOps(t *testing.T) {
+	src := "type Account struct {\n\tname string\n\n\tmu      sync.Mutex\n\tbalance int // in cents\n}\n\nfunc (a *Account) Deposit(n int) {\n\ta.mu.Lock()\n\tdefer a.mu.Unlock()\n\ta.balance += n\n}\n"
+	got := renderCode(src, codeOptions{mutexOps: true})
+	want := `<keyword>type</keyword> <defn>Account</defn> <keyword>struct</keyword> {
+   name string
+
+   mu      sync.Mutex
+   <span class='guarded'>balance int</span> <comment>// in cents</comment>
+}
+
+<keyword>func</keyword> (a *Account) <defn>Deposit</defn>(n int) {
+   a.mu.<span class='mutexop'>Lock()</span>
+   <keyword>defer</keyword> a.mu.<span class='mutexop'>Unlock()</span>
+   a.balance += n
+}
+`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestOutputAuto(t *testing.T) {
+	o := testOptions(t)
+	if testing.Short() {
+		t.Skip("runs go")
+	}
+	o.outputCache = t.TempDir()
+	// Scanning alone neither runs the code nor caches its output.
+	if _, err := scanFile(o, "testdata/auto/hello.go"); err != nil {
+		t.Fatal(err)
+	}
+	if ents, _ := os.ReadDir(o.outputCache); len(ents) > 0 {
+		t.Errorf("scanning cached %d outputs", len(ents))
+	}
+	slides, err := scanAndRun(o, "testdata/auto/hello.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := slides[0].sections[1].content, "hello, world\n"; got != want {
+		t.Errorf("go run: got %q, want %q", got, want)
+	}
+	sec := slides[1].sections[0]
+	if !strings.Contains(sec.content, "=== RUN   TestHello\nhello, world\n--- PASS: TestHello") || strings.Contains(sec.content, "ok ") {
+		t.Errorf("go test: got %q", sec.content)
+	}
+	if want := []string{"-v"}; !slices.Equal(sec.options, want) {
+		t.Errorf("got options %q, want %q", sec.options, want)
+	}
+
+	// With -no-exec, the cached output is still there.
+	o.noExec = true
+	slides2, err := scanAndRun(o, "testdata/auto/hello.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sectionsEqual(slides2[1].sections, slides[1].sections) {
+		t.Errorf("cached: got %v, want %v", slides2[1].sections, slides[1].sections)
+	}
+	o.outputCache = t.TempDir()
+	slides2, err = scanAndRun(o, "testdata/auto/hello.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := slides2[0].sections[1].content; !strings.Contains(got, "-no-exec") {
+		t.Errorf("uncached with -no-exec: got %q", got)
+	}
+}
+
+func TestTraceEdits(t *testing.T) {
+	src := []byte("package p\n\nfunc f() {\n\tout <- <-in\n\tv, ok := <-in\n\tclose(out)\n\tselect {\n\tcase x := <-in:\n\t\tout <- x\n\t}\n}\n")
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(applyEdits(src, traceEdits(fset, f, src)))
+	want := `package p
+
+func f() {
+	code2slidesSend(out , code2slidesRecv(in, "in"), "out")
+	v, ok := code2slidesRecv2(in, "in")
+	code2slidesClose(out, "out")
+	select {
+	case x := <-in:
+		code2slidesSend(out , x, "out")
+	}
+}
+`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSequence(t *testing.T) {
+	o := testOptions(t)
+	if testing.Short() {
+		t.Skip("runs go")
+	}
+	o.outputCache = t.TempDir()
+	slides, err := scanFile(o, "testdata/sequence/pingpong.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := slides[0].sections[0].content; got != "" {
+		t.Errorf("scanning drew the diagram:\n%s", got)
+	}
+	slides, err = scanAndRun(o, "testdata/sequence/pingpong.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svg := slides[0].sections[0].content
+	for _, want := range []string{"G1", "G2", "G3", "close(ch)", "ch: 0", "ch: 1", "done: true"} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("missing %q in:\n%s", want, svg)
+		}
+	}
+	if got := strings.Count(svg, "class='message'"); got != 3 {
+		t.Errorf("got %d messages, want 3", got)
+	}
+	if got := strings.Count(svg, "class='closed'"); got != 1 {
+		t.Errorf("got %d receives of closed channels, want 1", got)
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/timeline.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []sectionKind
+	for _, sec := range slides[0].sections {
+		kinds = append(kinds, sec.kind)
+	}
+	wantKinds := []sectionKind{sectionQuestion, sectionTimeline, sectionAnswer}
+	if !slices.Equal(kinds, wantKinds) {
+		t.Fatalf("got sections %v, want %v", kinds, wantKinds)
+	}
+	got := timelineSteps(slides[0].sections[1].content)
+	want := []string{
+		"`time.After` case executes\n",
+		"`select` finishes\n",
+		"goroutine tries to send to `c`\nand blocks forever\n",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestQuiz(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/quiz.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prompt, choices, err := parseQuiz(slides[0].sections[0].content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "What does this program print?"; prompt != want {
+		t.Errorf("got prompt %q, want %q", prompt, want)
+	}
+	want := []quizChoice{
+		{"`0`\n", false},
+		{"`1`\n", true},
+		{"Nothing: it\ndeadlocks\n", false},
+	}
+	if !slices.Equal(choices, want) {
+		t.Errorf("got choices %+v, want %+v", choices, want)
+	}
+}
+
+func TestClasses(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/classes.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, sec := range slides[0].sections {
+		got = append(got, fmt.Sprintf("%s %q", sec.kind, sec.classes))
+	}
+	want := []string{
+		`text ["small" "right"]`,
+		`text ["note"]`,
+		`code ["wide"]`,
+		`question ["tight"]`,
+		`answer ["small"]`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if got, want := slides[0].sections[1].content, "A one-line text.\n"; got != want {
+		t.Errorf("one-line text: got %q, want %q", got, want)
+	}
+	if got, want := slides[0].sections[2].attrs, []codeAttr{attrBad}; !slices.Equal(got, want) {
+		t.Errorf("code attrs: got %v, want %v", got, want)
+	}
+	var buf bytes.Buffer
+	writeSlideHTML(o, &indentWriter{w: &buf}, slides[0], 1, true)
+	for _, want := range []string{
+		`<div class='text small right' data-kind='text'>`,
+		`<div class='code bad wide' data-kind='code'>`,
+		`<details class='tight' data-kind='question'>`,
+		`<div class='answer small' data-kind='answer'>`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("HTML does not contain %q", want)
+		}
+	}
+}
+
+func TestStepper(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/stepper.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sec := slides[0].sections[0]
+	il, err := interleave(sec.options, sec.content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(il.Steps), 7; got != want {
+		t.Fatalf("got %d states, want %d", got, want)
+	}
+	last := il.Steps[len(il.Steps)-1]
+	if got, want := last.Shared["c"], 1; got != want {
+		t.Errorf("c = %d at the end, want %d (an increment is lost)", got, want)
+	}
+	if got, want := last.Registers, []map[string]int{{"R0": 1}, {"R0": 1}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("registers at the end: got %v, want %v", got, want)
+	}
+	if got, want := il.Steps[3], (stepperState{G: 0, Stmt: 1, Shared: map[string]int{"c": 0}, Registers: []map[string]int{{"R0": 1}, {"R0": 0}}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("after G1 R0++: got %+v, want %+v", got, want)
+	}
+}
+
+func TestTitleInfo(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/title_info.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(slides) != 3 {
+		t.Fatalf("got %d slides, want 3", len(slides))
+	}
+	if d := slides[1]; !d.isTitle || !d.isDivider || d.heading != "Part 2: Channels" {
+		t.Errorf("divider: got isTitle=%t isDivider=%t heading=%q", d.isTitle, d.isDivider, d.heading)
+	}
+	var buf bytes.Buffer
+	writeSlideHTML(o, &indentWriter{w: &buf}, slides[0], 1, false)
+	want := `  <div class='title-info'>
+    <div class='author'>Ann Gopher</div>
+    <div class='event'><a href="https://gophercon.eu">GopherCon</a></div>
+    <div class='date'>May 2026</div>
+  </div>
+`
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("got\n%s\nwant it to contain\n%s", buf.String(), want)
+	}
+}
+
+func TestTOC(t *testing.T) {
+	o := testOptions(t)
+	build := func(file string) string {
+		var buf bytes.Buffer
+		if _, err := writeDeck(o, &buf, "deck.slides", "T", []part{{files: []string{file}}}); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+	toc := "<ul class='toc'><li><a href='#3'>Goroutines</a></li><li><a href='#5'>Channels</a></li></ul>"
+	got := build("testdata/toc.go")
+	if want := "<h1>Agenda</h1>\n  " + toc + "\n  <div class='text' data-kind='text'>\n<p>We'll cover:</p>"; !strings.Contains(got, want) {
+		t.Errorf("output does not contain %q", want)
+	}
+
+	o.addTOC = true
+	got = build("testdata/title_info.go")
+	want := "<!-- slide 2 -->\n<article>\n  <h1>Contents</h1>\n  <ul class='toc'><li><a href='#3'>Part 2: Channels</a><ul><li><a href='#4'>Channels</a></li></ul></li></ul>"
+	if !strings.Contains(got, want) {
+		t.Errorf("-toc: output does not contain %q\n%s", want, got)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	dir := t.TempDir()
+	deck := filepath.Join(dir, "deck.slides")
+	if err := os.MkdirAll(filepath.Join(dir, "static"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "static", "slides.js"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	page := `<html><body>
+<script src='static/slides.js'></script>
+<img src="slides/missing.png"> <a href="elsewhere.html">link</a>
+<script src='https://example.com/x.js'></script>
+</body></html>
+`
+	if err := os.WriteFile(deck, []byte(page), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := missingAssets(deck, page), []string{"3: missing slides/missing.png"}; !slices.Equal(got, want) {
+		t.Errorf("missingAssets: got %q, want %q", got, want)
+	}
+
+	// A stand-in for Chrome prints the page as the dry run script leaves it.
+	chrome := filepath.Join(dir, "chrome")
+	script := "#!/bin/sh\necho '<html><body><pre id=\"dry-run-result\">{\"slides\":2,\"presses\":3,\"errors\":[\"file:///static/steps.js:12: boom &amp; bust\"]}</pre></body></html>'\n"
+	if err := os.WriteFile(chrome, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CHROME", chrome)
+	err := dryRun(deck, "")
+	want := "dry run of " + deck + " failed:\n3: missing slides/missing.png\nfile:///static/steps.js:12: boom & bust"
+	if err == nil || err.Error() != want {
+		t.Errorf("got %v, want %q", err, want)
+	}
+	if entries, _ := filepath.Glob(filepath.Join(dir, ".dry-run-*")); len(entries) > 0 {
+		t.Errorf("dry run left %q", entries)
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	parts, prof, err := readProfile("testdata/profiles.txt", "short")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []part{
+		{files: []string{"testdata/valid.go"}},
+		{name: "Basics", files: []string{"testdata/code_bad.go"}},
+	}
+	if len(parts) != len(want) {
+		t.Fatalf("got %d parts, want %d", len(parts), len(want))
+	}
+	for i := range want {
+		if parts[i].name != want[i].name || !slices.Equal(parts[i].files, want[i].files) {
+			t.Errorf("part %d = %+v, want %+v", i, parts[i], want[i])
+		}
+	}
+	wantFlags := []string{"-o", "short.slides", "-tags", "short,intro", "-title", "Concurrency in 45 minutes", "-theme", "static/short.css"}
+	if !slices.Equal(prof.flags, wantFlags) {
+		t.Errorf("flags = %q, want %q", prof.flags, wantFlags)
+	}
+
+	// The command line overrides the profile.
+	fs := flag.NewFlagSet("build", flag.ContinueOnError)
+	out := fs.String("o", "output.slides", "")
+	title := fs.String("title", "Title", "")
+	notes := fs.Bool("notes", false, "")
+	args := []string{"-o", "day.slides"}
+	fs.Parse(args)
+	if err := ApplyProfile(fs, args, "testdata/profiles.txt", "full"); err != nil {
+		t.Fatal(err)
+	}
+	parts, err = Config{Manifest: "testdata/profiles.txt", Profile: "full"}.parts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 3 || *out != "day.slides" || *title != "A whole day" || !*notes {
+		t.Errorf("got %d parts, -o %q, -title %q, -notes %t; want 3, day.slides, A whole day, true", len(parts), *out, *title, *notes)
+	}
+
+	// Manifests without profiles read as before.
+	if _, _, err := readProfile("testdata/manifest.txt", "short"); err == nil || !strings.Contains(err.Error(), `no profile "short"`) {
+		t.Errorf("readProfile of a manifest without profiles: got %v", err)
+	}
+	dir := t.TempDir()
+	for _, test := range []struct{ manifest, err string }{
+		{"valid.go\nprofile p\nskip nope.go\n", `no files in the deck match skip`},
+		{"valid.go\nprofile p\nvalid.go\n", `want flags, theme or skip`},
+		{"valid.go\nprofile p\nprofile p\n", `duplicate profile "p"`},
+		{"valid.go\nprofile p\nflags -title \"unterminated\n", `bad string in flags`},
+	} {
+		m := filepath.Join(dir, "m.txt")
+		if err := os.WriteFile(m, []byte(test.manifest), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "valid.go"), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := readProfile(m, "p"); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: got %v, want error containing %q", test.manifest, err, test.err)
+		}
+	}
+}
+
+func TestWriteAttendeeRepo(t *testing.T) {
+	o := testOptions(t)
+	dir := filepath.Join(t.TempDir(), "repo")
+	deck, err := readAttendeeDeck("testdata/attendee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeAttendeeRepo(o, dir, "Cohort 1", "testdata/grader/exercises", "../static", "1.26.2", []attendeeDeck{deck}); err != nil {
+		t.Fatal(err)
+	}
+	read := func(name string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "exercises", "adder", "solution")); err == nil {
+		t.Error("solution copied to the repo")
+	}
+	read("exercises/go.work")
+	read("handouts/static/scroll.css")
+	read("handouts/testdata/attendee/gopher.png")
+	handout := read("handouts/attendee.html")
+	if strings.Contains(handout, "Instructors only") {
+		t.Error("handout has the instructor's note")
+	}
+	if !strings.Contains(handout, "Why?") {
+		t.Error("handout is missing the question")
+	}
+	if got := read("examples/attendee/hello-world/main.go"); !strings.Contains(got, "greeting := \"hello\"") {
+		t.Errorf("example isn't the displayed code:\n%s", got)
+	}
+	read("examples/attendee/hello-world-2/main.go")
+	readme := read("README.md")
+	for _, s := range []string{"# Cohort 1", "[Attendee Deck](handouts/attendee.html)", "go run ./attendee/hello-world"} {
+		if !strings.Contains(readme, s) {
+			t.Errorf("README is missing %q:\n%s", s, readme)
+		}
+	}
+	if testing.Short() {
+		return
+	}
+	cmd := exec.Command("go", "build", "./...")
+	cmd.Dir = filepath.Join(dir, "examples")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("building examples: %v\n%s", err, out)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/highlight.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sec := slides[0].sections[0]
+	if want := []lineRange{{2, 2}, {4, 5}}; !slices.Equal(sec.highlight, want) {
+		t.Errorf("highlight = %v, want %v", sec.highlight, want)
+	}
+	opts := codeOptionsFor(o, sec.attrs)
+	opts.highlight = sec.highlight
+	got := strings.Split(renderCode(sec.content, opts), "\n")
+	for i, want := range []bool{false, true, false, false, true, true, false, false} {
+		if em := strings.Contains(got[i], "class=\"em\""); em != want {
+			t.Errorf("line %d emphasized: %t, want %t:\n%s", i+1, em, want, got[i])
+		}
+	}
+}
+
+func TestStaleRefs(t *testing.T) {
+	o := testOptions(t)
+	changes, err := readStdChanges("testdata/fresh/goroot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := changes["math/rand.Rand.Seed"]; len(got) != 1 || !got[0].deprecated || got[0].version != "1.20" {
+		t.Errorf("math/rand.Rand.Seed: got %+v, want a deprecation in 1.20", got)
+	}
+	if got := changes["syscall.Bogus"]; got != nil {
+		t.Errorf("platform-specific deprecation read as %+v", got)
+	}
+	probs, err := staleRefs(o, []string{"testdata/fresh/slides/fresh.go"}, changes, "1.27")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"testdata/fresh/slides/fresh.go:11: Timers: time.NewTimer: Go 1.23 changed the channels created by package time to be unbuffered (synchronous).",
+		"testdata/fresh/slides/fresh.go:13: Timers: rand.Seed: deprecated in Go 1.20",
+		"testdata/fresh/slides/fresh.go:18: Timeouts: time.After: Go 1.23 also changed time.After, in this made-up history.",
+	}
+	if !slices.Equal(probs, want) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(probs, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestHeadings(t *testing.T) {
+	o := testOptions(t)
+	o.headingFallback = "name"
+	slides, err := scanFile(o, "testdata/headings/10-worker-pool.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := slides[0].heading, "Worker pool"; got != want {
+		t.Errorf("fallback heading = %q, want %q", got, want)
+	}
+
+	o.headingOverrides, err = readHeadings("testdata/headings/headings.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	slides, err = scanFile(o, "testdata/headings/10-worker-pool.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := slides[0].heading, "The worker pool"; got != want {
+		t.Errorf("heading = %q, want %q", got, want)
+	}
+	slides, err = scanFile(o, "testdata/headings/20-shutdown.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, s := range slides {
+		got = append(got, s.heading)
+	}
+	if want := []string{"Shutting down", "Draining", "Waiting for workers"}; !slices.Equal(got, want) {
+		t.Errorf("headings = %q, want %q", got, want)
+	}
+	if sec := slides[0].sections[0]; sec.kind != sectionSubtitle || sec.content != "Draining the queue, it's done\n" {
+		t.Errorf("first section = %v %q, want the subtitle", sec.kind, sec.content)
+	}
+
+	dir := t.TempDir()
+	for _, test := range []struct{ yaml, err string }{
+		{"a.go: A\na.go: B\n", "2: duplicate entry for a.go"},
+		{"  heading: A\n", "1: indented line"},
+		{"a.go:\n  title: A\n", `2: want heading, subtitle or slides, not "title"`},
+		{"a.go:\n  slides: A\n", "2: slides is a list"},
+		{"a.go: \"A\n", "1: bad quoted string"},
+	} {
+		f := filepath.Join(dir, "headings.yaml")
+		if err := os.WriteFile(f, []byte(test.yaml), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := readHeadings(f); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: got %v, want error containing %q", test.yaml, err, test.err)
+		}
+	}
+}
+
+func TestSplitOverflow(t *testing.T) {
+	o := testOptions(t)
+	o.maxCodeLines = 7
+
+	slides, err := scanFile(o, "testdata/overflow.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := slides[0]
+	conts := splitOverflow(o, first)
+	var headings []string
+	for _, s := range conts {
+		headings = append(headings, s.heading)
+	}
+	if want := []string{"Long code (cont.)", "Long code (cont.)"}; !slices.Equal(headings, want) {
+		t.Fatalf("continuations = %q, want %q", headings, want)
+	}
+	// Each part ends at a blank line, and the numbers continue.
+	for i, test := range []struct {
+		slide     *Slide
+		firstLine string
+		lines     int
+	}{
+		{first, "1", 5},
+		{conts[0], "6", 5},
+		{conts[1], "11", 4},
+	} {
+		code := test.slide.sections[0].rendered
+		if got := strings.Count(code, "\n") + 1; got != test.lines {
+			t.Errorf("part %d has %d lines, want %d:\n%s", i, got, test.lines, code)
+		}
+		if want := "<span class='codenum'>" + test.firstLine + "</span>"; !strings.HasPrefix(code, want) {
+			t.Errorf("part %d begins %.40q, want %q", i, code, want)
+		}
+	}
+	if len(first.sections) != 1 || len(conts[0].sections) != 1 {
+		t.Errorf("first slides have %d and %d sections, want only the code", len(first.sections), len(conts[0].sections))
+	}
+	if last := conts[1].sections; len(last) != 2 || last[1].kind != sectionText {
+		t.Errorf("last continuation doesn't end with the text after the code")
+	}
+
+	// Short code isn't split, and -overflow applies to code without an
+	// attribute of its own.
+	if conts := splitOverflow(o, slides[1]); conts != nil {
+		t.Errorf("short code split into %d slides", len(conts)+1)
+	}
+	o.overflowMode = attrScroll
+	if got := overflowAttr(o, nil); got != attrScroll {
+		t.Errorf("overflowAttr(nil) = %q, want scroll", got)
+	}
+	if got := overflowAttr(o, slides[1].sections[0].attrs); got != attrFit {
+		t.Errorf("overflowAttr(fit) = %q, want fit", got)
+	}
+	if _, err := parseCodeAttrs([]string{"fit", "split"}); err == nil {
+		t.Error("fit split: got no error")
+	}
+}
+
+func TestTiming(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/timing.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []time.Duration
+	for _, s := range slides {
+		got = append(got, s.planned)
+	}
+	if want := []time.Duration{3 * time.Minute, 90 * time.Second, 0}; !slices.Equal(got, want) {
+		t.Errorf("planned = %v, want %v", got, want)
+	}
+
+	var buf bytes.Buffer
+	parts := []part{{name: "Pools", files: []string{"testdata/timing.go"}}}
+	if err := reportTiming(o, &buf, parts); err != nil {
+		t.Fatal(err)
+	}
+	want := "4m30s    testdata/timing.go (no time: Questions)\n" +
+		"4m30s    part Pools\n" +
+		"4m30s    total, 1m30s of it optional\n"
+	if got := buf.String(); got != want {
+		t.Errorf("report:\n%s\nwant:\n%s", got, want)
+	}
+
+	buf.Reset()
+	if err := writeTimingScript(&buf, slides); err != nil {
+		t.Fatal(err)
+	}
+	if want := `var plannedTimes = [{"Time":"3m","Before":"0s"},{"Time":"1m30s","Before":"3m"},null];`; !strings.Contains(buf.String(), want) {
+		t.Errorf("script:\n%s\nwant it to contain\n%s", buf.String(), want)
+	}
+
+	for _, test := range []struct{ arg, want string }{
+		{"2h0m0s", "2h"},
+		{"1h5m", "1h5m"},
+		{"45s", "45s"},
+	} {
+		d, err := parsePlannedTime(test.arg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := formatPlanned(d); got != test.want {
+			t.Errorf("formatPlanned(%s) = %q, want %q", test.arg, got, test.want)
+		}
+	}
+	if _, err := parsePlannedTime("3"); err == nil {
+		t.Error(`parsePlannedTime("3"): got no error`)
+	}
+}
+
+func TestRemoteManifest(t *testing.T) {
+	o := testOptions(t)
+	// A module proxy of one module, in files.
+	proxy := t.TempDir()
+	vdir := filepath.Join(proxy, "example.com", "talk", "@v")
+	if err := os.MkdirAll(vdir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	gomod := "module example.com/talk\n\ngo 1.22\n"
+	var zbuf bytes.Buffer
+	zw := zip.NewWriter(&zbuf)
+	for name, content := range map[string]string{
+		"go.mod":           gomod,
+		"examples/pool.go": "package examples\n\n// heading A pool\n// text\n// Workers.\n// !text\n",
+	} {
+		f, err := zw.Create("example.com/talk@v1.0.0/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(f, content)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"list":        "v1.0.0\n",
+		"v1.0.0.info": `{"Version":"v1.0.0"}`,
+		"v1.0.0.mod":  gomod,
+		"v1.0.0.zip":  zbuf.String(),
+	} {
+		if err := os.WriteFile(filepath.Join(vdir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	modCache := t.TempDir()
+	t.Setenv("GOPROXY", "file://"+filepath.ToSlash(proxy))
+	t.Setenv("GOSUMDB", "off")
+	t.Setenv("GOMODCACHE", modCache)
+	t.Setenv("GOFLAGS", "-modcacherw") // so the cache can be removed
+	defer func(m map[string]string) { moduleDirs = m }(moduleDirs)
+	moduleDirs = map[string]string{}
+
+	manifest := filepath.Join(t.TempDir(), "manifest.txt")
+	if err := os.WriteFile(manifest, []byte("example.com/talk@v1.0.0//examples/*.go\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	parts, err := readManifest(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(modCache, "example.com", "talk@v1.0.0", "examples", "pool.go")
+	if len(parts) != 1 || !slices.Equal(parts[0].files, []string{want}) {
+		t.Fatalf("parts = %v, want one part of %s", parts, want)
+	}
+	slides, err := scanFile(o, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slides[0].heading != "A pool" {
+		t.Errorf("heading = %q, want %q", slides[0].heading, "A pool")
+	}
+
+	if err := os.WriteFile(manifest, []byte("example.com/talk@v9.9.9//examples/*.go\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readManifest(manifest); err == nil || !strings.Contains(err.Error(), "downloading example.com/talk@v9.9.9") {
+		t.Errorf("missing version: got %v, want a download error", err)
+	}
+	if _, _, _, ok := splitRemote("slides//intro.go"); ok {
+		t.Error("splitRemote treats a local path as a module")
+	}
+}
+
+func TestSince(t *testing.T) {
+	o := testOptions(t)
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("no git")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=T", "-c", "user.email=t@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	slide := func(heading, text string) string {
+		return "// heading " + heading + "\n// text\n// " + text + "\n// !text\n\n"
+	}
+	write("00-title.go", "package p\n\n// title Workshop\n")
+	write("10-pools.go", "package p\n\n"+slide("Pools", "Workers.")+slide("Sizing", "Use GOMAXPROCS."))
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-q", "-m", "2025")
+	git("tag", "v2025")
+	write("10-pools.go", "package p\n\n"+slide("Pools", "Workers.")+slide("Sizing", "Measure first.")+slide("Errgroup", "SetLimit."))
+	write("20-iterators.go", "package p\n\n"+slide("Iterators", "Range over functions."))
+
+	o.sinceRef = "v2025"
+	files := []string{filepath.Join(dir, "00-title.go"), filepath.Join(dir, "10-pools.go"), filepath.Join(dir, "20-iterators.go")}
+	slides, err := writeDeck(o, io.Discard, "", "T", []part{{files: files}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, s := range slides {
+		got = append(got, s.heading)
+	}
+	want := []string{"Workshop", "What's new since v2025", "Sizing", "Errgroup", "Iterators"}
+	if !slices.Equal(got, want) {
+		t.Errorf("headings = %q, want %q", got, want)
+	}
+
+	o.sinceRef = "v1999"
+	if _, err := writeDeck(o, io.Discard, "", "T", []part{{files: files}}); err == nil || !strings.Contains(err.Error(), "not a commit") {
+		t.Errorf("unknown ref: got %v, want an error", err)
+	}
+}
+
+func TestEmStyles(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/em_styles.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "mu.\x00em.red\x00Lock\x00/em\x00()\n" +
+		"\x00em.del\x00count++\x00/em\x00\n" +
+		"\x00em.add\x00\x00em\x00count.Add(1)\x00/em\x00\x00/em\x00\n" +
+		"\x00em.red\x00return\x00/em\x00"
+	if got := slides[0].sections[0].content; got != want {
+		t.Errorf("content:\n%q\nwant:\n%q", got, want)
+	}
+	got := renderCode(slides[0].sections[0].content, codeOptions{})
+	for _, want := range []string{
+		`mu.<span class="em em-red">Lock</span>()`,
+		`<span class="em em-del">count++</span>`,
+		// Plain emphasis inside a style is plain.
+		`<span class="em">count.Add(</span><number><span class="em">1</span></number><span class="em">)</span>`,
+		`<span class="em em-red">return</span>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("rendered code does not contain %s:\n%s", want, got)
+		}
+	}
+	if got, want := stripEm(want), "mu.Lock()\ncount++\ncount.Add(1)\nreturn"; got != want {
+		t.Errorf("stripEm = %q, want %q", got, want)
+	}
+
+	// Styles are lower case, so "// em.Red x" isn't a directive.
+	if rest, style := cutInlineEmStyle(".Red x"); rest != ".Red x" || style != emPlain {
+		t.Errorf("cutInlineEmStyle accepted style %q", style)
+	}
+}
+
+func TestFormatSlides(t *testing.T) {
+	o := testOptions(t)
+	in, err := os.ReadFile("testdata/fmt/in.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("testdata/fmt/want.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := formatSlides(o, "in.go", in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	// Formatting is idempotent.
+	again, err := formatSlides(o, "want.go", want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(want) {
+		t.Errorf("formatting want.go changed it:\n%s", again)
+	}
+
+	// A blank line in a raw string outside sections is code.
+	raw := []byte("package p\n\nvar s = `a\n\n\nb`\n")
+	if _, err := formatSlides(o, "raw.go", raw); err == nil {
+		t.Error("raw string: got no error")
+	}
+
+	// Every attribute has a place in the canonical order.
+	for w, a := range codeAttrs {
+		if !slices.Contains(codeAttrOrder, a) {
+			t.Errorf("attribute %q is missing from codeAttrOrder", w)
+		}
+	}
+}
+
+func TestBuild(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "deck.html")
+	if err := BuildFile(t.Context(), Config{Files: []string{"testdata/valid.go"}, Title: "Deck"}, out); err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deck, err := Build(t.Context(), Config{Files: []string{"testdata/valid.go"}, Title: "Deck", Notes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deck, err = Build(t.Context(), Config{Files: []string{"testdata/valid.go"}, Title: "Deck"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := deck.WriteHTML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != string(want) {
+		t.Errorf("Build's HTML differs from the command's:\n%s", buf.String())
+	}
+	if deck.NumSlides() == 0 {
+		t.Error("no slides")
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	if _, err := Build(ctx, Config{Files: []string{"testdata/valid.go"}}); err != context.Canceled {
+		t.Errorf("canceled: got %v, want context.Canceled", err)
+	}
+}
+
+func TestCallouts(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/callouts.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sec := slides[0].sections[0]
+	want := "func (g *Gauge) Inc() {\n" +
+		"\tif g.count.Load() < g.max {" + calloutMark + "TOCTOU race here\n" +
+		"\t\tg.count.Add(1)\n" +
+		"\t}" + calloutMark + "<checked & changed>\n" +
+		"}"
+	if sec.content != want {
+		t.Errorf("content:\n%q\nwant:\n%q", sec.content, want)
+	}
+	got := renderCode(sec.content, codeOptions{})
+	for _, want := range []string{
+		`<span class='callout'>TOCTOU race here</span>`,
+		`<span class='callout'>&lt;checked &amp; changed&gt;</span>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("rendered code does not contain %s:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "callout\x00") {
+		t.Errorf("rendered code contains a callout mark:\n%s", got)
+	}
+	if got := codeDifference(want, stripCallouts(want)); got != "" {
+		t.Errorf("callouts changed the code: %q", got)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/compare.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secs := slides[0].sections
+	if len(secs) != 1 || secs[0].kind != sectionCompare || len(secs[0].outputs) != 2 {
+		t.Fatalf("got sections %v, want one compare with two outputs", secs)
+	}
+	var buf bytes.Buffer
+	writeCompareHTML(&indentWriter{w: &buf}, secs[0])
+	got := buf.String()
+	for _, want := range []string{
+		"<div class='conditions'>-race</div>",
+		"<span class='changed'>WARNING: DATA RACE</span>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("HTML does not contain %s:\n%s", want, got)
+		}
+	}
+	if strings.Count(got, "class='changed'") != 1 {
+		t.Errorf("want one changed line:\n%s", got)
+	}
+}
+
+func TestImageAltCaption(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/image_alt.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []section{
+		{kind: sectionHTML, content: `<figure><img src="testdata/diagram.png" alt="Two goroutines sharing a counter" />` +
+			`<figcaption>The <em>race</em> on <code>count</code></figcaption></figure>`},
+		{kind: sectionHTML, content: `<img src="testdata/photo.jpg" alt="photo.jpg" />`},
+	}
+	if !sectionsEqual(slides[0].sections, want) {
+		t.Errorf("got:\n%v\nwant:\n%v", slides[0].sections, want)
+	}
+
+	o.requireAlt = true
+	_, err = scanFile(o, "testdata/image_alt.go")
+	if err == nil || !strings.Contains(err.Error(), "image_alt.go:6: image needs alt text") {
+		t.Errorf("-require-alt: got %v, want error for photo.jpg", err)
+	}
+}
+
+func TestLintHTMLClasses(t *testing.T) {
+	o := testOptions(t)
+	slides, err := scanFile(o, "testdata/html_classes.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := lintHTMLClasses(o, slides, "../static")
+	want := []string{
+		`testdata/html_classes.go:4: html: class "nosuch" is not in the stylesheets`,
+		`testdata/html_classes.go:6: html: class "missing" is not in the stylesheets`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if got := lintHTMLClasses(o, slides, t.TempDir()); got != nil {
+		t.Errorf("without styles.css: got %q, want nothing", got)
+	}
+}
+
+func TestTransform(t *testing.T) {
+	o := testOptions(t)
+	dir := t.TempDir()
+	script := func(name, body string) string {
+		t.Helper()
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte("#!/bin/sh\n"+body+"\n"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+
+	// Add a note to the start of every slide.
+	o.transforms = []string{script("note", `sed 's/"sections":\[/&{"kind":"note","content":"Compliance."},/g'`)}
+	slides, err := scanFile(o, "testdata/valid.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := slices.Clone(slides[0].sections)
+	got, err := transformSlides(o, "testdata/valid.go", slides)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = append([]section{{kind: sectionNote, content: "Compliance."}}, want...)
+	if !sectionsEqual(got[0].sections, want) {
+		t.Errorf("got:\n%v\nwant:\n%v", got[0].sections, want)
+	}
+
+	for _, test := range []struct {
+		body, wantErr string
+	}{
+		{`echo '{"slides":[]}'`, "wrote 0 slides for"},
+		{`sed 's/"kind":"code"/"kind":"nosuch"/'`, `unknown section kind "nosuch"`},
+		{"exit 3", "exit status 3"},
+	} {
+		o.transforms = []string{script("bad", test.body)}
+		slides, err := scanFile(o, "testdata/valid.go")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := transformSlides(o, "testdata/valid.go", slides); err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("%s: got %v, want error containing %q", test.body, err, test.wantErr)
+		}
+	}
+}
+
+func TestGoSyntax(t *testing.T) {
+	for _, tt := range []struct {
+		in, want string
+	}{
+		{
+			// A "//" in a string doesn't begin a comment.
+			`u := "http://go.dev" // home`,
+			`u := <string>&#34;http://go.dev&#34;</string> <comment>// home</comment>`,
+		},
+		{
+			// Nor does "type" in a comment begin a definition.
+			"// type T is unused",
+			"<comment>// type T is unused</comment>",
+		},
+		{
+			"func (c *Cache[K, V]) Get(k K) (V, bool)",
+			"<keyword>func</keyword> (c *Cache[K, V]) <defn>Get</defn>(k K) (V, bool)",
+		},
+		{
+			// A function literal defines nothing.
+			"go func(n int) { ch <- 'x' }(0x1f)",
+			"<keyword>go</keyword> <keyword>func</keyword>(n int) { ch &lt;- <string>&#39;x&#39;</string> }(<number>0x1f</number>)",
+		},
+		{
+			// Strings and comments can span lines.
+			"s := `a\n// b`\n/* c\nfunc d() */",
+			"s := <string>`a</string>\n<string>// b`</string>\n<comment>/* c</comment>\n<comment>func d() */</comment>",
+		},
+	} {
+		got := renderCode(tt.in, codeOptions{})
+		if got != tt.want {
+			t.Errorf("renderCode(%q)\ngot  %s\nwant %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestServeReferencedFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"deck.go":     "// heading Files\n// image pic.png A picture\n// link notes.txt The notes\n",
+		"pic.png":     "png",
+		"notes.txt":   "notes",
+		"secret.txt":  "secret",
+		".git/config": "[core]\n",
+	} {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+	ds := &deckServer{title: "T", parts: []part{{files: []string{"deck.go"}}}, opts: testOptions(t)}
+	mux := http.NewServeMux()
+	mux.Handle("/{$}", ds)
+	mux.HandleFunc("/", ds.serveFile)
+	for _, test := range []struct {
+		path string
+		want int
+	}{
+		{"/pic.png", http.StatusOK},
+		{"/notes.txt", http.StatusOK},
+		{"/deck.go", http.StatusNotFound},
+		{"/.git/config", http.StatusNotFound},
+		{"/secret.txt", http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+		if w.Code != test.want {
+			t.Errorf("GET %s: got %d, want %d", test.path, w.Code, test.want)
+		}
+	}
+}
+
+func TestBuildConcurrent(t *testing.T) {
+	configs := []Config{
+		{Files: []string{"testdata/valid.go"}, Title: "Notes", Notes: true},
+		{Files: []string{"testdata/valid.go"}, Title: "Themed", Theme: "dark.css", ExecParallel: 1},
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, 20)
+	for i := range errs {
+		wg.Go(func() {
+			cfg := configs[i%len(configs)]
+			deck, err := Build(t.Context(), cfg)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			var buf strings.Builder
+			deck.WriteHTML(&buf)
+			got := buf.String()
+			hasNotes := strings.Contains(got, "First note.")
+			hasTheme := strings.Contains(got, "dark.css")
+			if !strings.Contains(got, "<title>"+cfg.Title+"</title>") || hasNotes != cfg.Notes || hasTheme != (cfg.Theme != "") {
+				errs[i] = fmt.Errorf("%s: notes %t, theme %t", cfg.Title, hasNotes, hasTheme)
+			}
+		})
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package code2slides
+
+import (
+	"fmt"
+	"html"
+	"slices"
+	"strings"
+)
+
+// A compare section shows two outputs side by side, like the output of a
+// program with and without -race, with the lines that differ between them
+// marked. The outputs are the sections between compare and !compare:
+// output blocks, output auto or testfail.
+
+// compareContent returns the content of a compare section of outputs.
+func compareContent(outputs []section) string {
+	return outputs[0].content + "\n" + outputs[1].content
+}
+
+// outputConditions returns the HTML for the conditions that sec, an output
+// section, was produced under, or "" if it has none.
+func outputConditions(sec section) string {
+	if len(sec.options) == 0 {
+		return ""
+	}
+	opts := slices.DeleteFunc(slices.Clone(sec.options), func(o string) bool { return o == "nondeterministic" })
+	conds := "<div class='conditions'>" + html.EscapeString(strings.Join(opts, " "))
+	if len(opts) < len(sec.options) {
+		conds += "<span class='badge'>output varies</span>"
+	}
+	return conds + "</div>"
+}
+
+// writeCompareHTML writes sec, a compare section.
+func writeCompareHTML(w *indentWriter, sec section) {
+	before, after := sec.outputs[0], sec.outputs[1]
+	a := strings.Split(before.content, "\n")
+	b := strings.Split(after.content, "\n")
+	// Whether each line of a and b is missing from the other.
+	aChanged := make([]bool, len(a))
+	bChanged := make([]bool, len(b))
+	i, j := 0, 0
+	for _, d := range diffLines(a, b) {
+		switch d.op {
+		case diffSame:
+			i++
+			j++
+		case diffDelete:
+			aChanged[i] = true
+			i++
+		case diffInsert:
+			bChanged[j] = true
+			j++
+		}
+	}
+	w.open("<div class='compare' data-kind='compare'>")
+	for k, out := range []section{before, after} {
+		lines, changed := a, aChanged
+		if k == 1 {
+			lines, changed = b, bChanged
+		}
+		w.open("<div" + out.classAttr("output") + ">" + outputConditions(out) + "<pre>")
+		for n, line := range lines {
+			if n > 0 {
+				fmt.Fprintln(w)
+			}
+			if changed[n] {
+				fmt.Fprintf(w, "<span class='changed'>%s</span>", html.EscapeString(line))
+			} else {
+				fmt.Fprint(w, html.EscapeString(line))
+			}
+		}
+		fmt.Fprintln(w, "</pre>") // indenting adds a blank line
+		w.close("</div>")
+	}
+	w.close("</div>")
+}
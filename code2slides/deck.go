@@ -0,0 +1,418 @@
+package code2slides
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// A Config says what deck Build builds and how. Its fields are the
+// options of the code2slides command of the same names; the zero value of
+// each means the command's default.
+type Config struct {
+	Files    []string // the slide files, in order; ignored if Manifest is set
+	Manifest string   // a file naming the deck's files and parts (-manifest)
+	Profile  string   // build the variant of the Manifest deck this profile describes (-profile); see ApplyProfile
+	Title    string   // the HTML page title (-title)
+
+	Notes            bool          // include notes and answers (-notes)
+	Presenter        bool          // show notes in a presenter window (-presenter)
+	NotesFile        string        // also write the notes of each slide to this file (-notes-file)
+	Release          []string      // include the slides held under these names (-release)
+	Tags             []string      // keep the lines in if directives for these tags (-tags)
+	TOC              bool          // add a table of contents (-toc)
+	NumberByPart     bool          // number exercises and questions in each part (-number-by-part)
+	Scroll           bool          // render the slides as one scrolling page (-scroll)
+	Offline          bool          // vendor external assets so the deck needs no network (-offline)
+	Analytics        string        // report slide viewing times and revealed answers to this URL (-analytics)
+	Footer           string        // markdown for the foot of every slide (-footer)
+	Theme            string        // URL of a stylesheet loaded after styles.css (-theme)
+	Transforms       []string      // commands that rewrite each file's slides (-transform)
+	Headings         string        // a headings file (-headings)
+	HeadingFallback  string        // "file" or "name" (-heading-fallback)
+	Comment          string        // the prefix of line comments in every file (-comment)
+	Overflow         string        // fit, scroll or split long code (-overflow)
+	SplitLines       int           // split code after this many lines (-split-lines)
+	AnswerSummary    string        // text that reveals an answer (-answer-summary)
+	DocLinks         []string      // packages to link to their documentation (-doc-links)
+	ChanOps          bool          // style channel operations (-chan-ops)
+	MutexOps         bool          // style mutex operations (-mutex-ops)
+	EmElement        string        // HTML element for emphasized code (-em-element); if empty, a span
+	EmClass          string        // class of EmElement (-em-class); "em" if EmElement is empty
+	RequireAlt       bool          // fail if an image has no alt text (-require-alt)
+	ForbidTodo       bool          // fail if the deck has TODOs (-forbid-todo)
+	TranscriptLines  int           // maximum lines of testfail output (-transcript-lines)
+	CheckDeterminism int           // run each testfail this many times, failing if its output varies (-check-determinism)
+	Since            string        // build only the slides new or changed since this git ref (-since)
+	Feed             string        // update a JSON feed of changed slides in this file (-feed)
+	FeedSince        time.Duration // only list changes made within this duration (-feed-since)
+	Version          string        // save a snapshot of the deck under this name (-version)
+	ChangesSince     string        // add a slide listing what changed since this version (-changes-since)
+	Static           string        // the deck's static/ directory, for Lint and DryRun (-static)
+	MinAnswer        int           // the minimum length of an answer, for Lint; 10 if zero (-min-answer)
+	Debug            bool          // print the slides as they are scanned (-debug)
+
+	Sandbox      string        // local, docker or gvisor (-sandbox)
+	SandboxImage string        // image for the container sandboxes (-sandbox-image)
+	ExecTimeout  time.Duration // stop deck code that runs longer (-exec-timeout)
+	ExecOutput   int           // stop deck code that writes more bytes (-exec-output)
+	ExecParallel int           // run at most this many pieces of deck code at once (-exec-parallel)
+	NoExec       bool          // use only cached output for output auto (-no-exec)
+	OutputCache  string        // cache the output of output auto here (-output-cache)
+}
+
+// A Deck is a built slide deck.
+type Deck struct {
+	html   []byte
+	slides []*Slide
+}
+
+// NumSlides returns the number of slides in the deck.
+func (d *Deck) NumSlides() int { return len(d.slides) }
+
+// WriteHTML writes the deck's HTML page to w. The page loads its scripts
+// and stylesheets from static/, relative to where it is served.
+func (d *Deck) WriteHTML(w io.Writer) error {
+	_, err := w.Write(d.html)
+	return err
+}
+
+// Build builds the deck that cfg describes, as the code2slides command
+// does, for programs that make decks as part of their own work. The
+// commands that testfail and output directives run are stopped when ctx
+// is done.
+func Build(ctx context.Context, cfg Config) (*Deck, error) {
+	o, err := cfg.options(ctx)
+	if err != nil {
+		return nil, err
+	}
+	parts, err := cfg.parts()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkImports(partFiles(parts)); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	slides, err := writeDeck(o, &buf, "", cmp.Or(cfg.Title, "Title"), parts)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return &Deck{html: buf.Bytes(), slides: slides}, nil
+}
+
+// BuildFile builds the deck that cfg describes into outputFile, as the
+// code2slides command does. With cfg.Offline, it also writes the assets
+// the deck needs to the static directory next to outputFile, and with
+// cfg.NotesFile, it writes the notes of each slide there.
+func BuildFile(ctx context.Context, cfg Config, outputFile string) error {
+	o, err := cfg.options(ctx)
+	if err != nil {
+		return err
+	}
+	parts, err := cfg.parts()
+	if err != nil {
+		return err
+	}
+	return buildFile(o, outputFile, cmp.Or(cfg.Title, "Title"), parts)
+}
+
+// Todos writes the TODOs in the deck that cfg describes to w.
+func Todos(ctx context.Context, w io.Writer, cfg Config) error {
+	o, err := cfg.options(ctx)
+	if err != nil {
+		return err
+	}
+	files, err := cfg.files()
+	if err != nil {
+		return err
+	}
+	return reportTodos(o, w, files)
+}
+
+// Timing writes the planned time of each file, part and the deck that
+// cfg describes to w.
+func Timing(ctx context.Context, w io.Writer, cfg Config) error {
+	o, err := cfg.options(ctx)
+	if err != nil {
+		return err
+	}
+	parts, err := cfg.parts()
+	if err != nil {
+		return err
+	}
+	return reportTiming(o, w, parts)
+}
+
+// FixImports removes the unused imports of the Go files of the deck that
+// cfg describes, and lists them on w.
+func FixImports(w io.Writer, cfg Config) error {
+	files, err := cfg.files()
+	if err != nil {
+		return err
+	}
+	return fixImports(w, files)
+}
+
+// Fresh writes to w the deck's references to standard APIs that are
+// deprecated or changed since its go.mod's Go version, and reports whether
+// there were none.
+func Fresh(ctx context.Context, w io.Writer, cfg Config) (bool, error) {
+	o, err := cfg.options(ctx)
+	if err != nil {
+		return false, err
+	}
+	files, err := cfg.files()
+	if err != nil {
+		return false, err
+	}
+	return freshFiles(o, w, files)
+}
+
+// Lint writes the problems of the deck that cfg describes to w, one per
+// line, and reports whether there were none.
+func Lint(ctx context.Context, w io.Writer, cfg Config) (bool, error) {
+	o, err := cfg.options(ctx)
+	if err != nil {
+		return false, err
+	}
+	files, err := cfg.files()
+	if err != nil {
+		return false, err
+	}
+	return lintFiles(o, w, files)
+}
+
+// CheckOffline reports an error if the deck in deckFile, or the scripts
+// and stylesheets it loads from staticDir, would make network requests.
+// If staticDir is empty, it is the static directory next to the deck.
+func CheckOffline(deckFile, staticDir string) error {
+	return verifyOffline(deckFile, staticDir)
+}
+
+// DryRun walks the slides, steps and answers of the deck in deckFile in a
+// headless browser, reporting an error for missing files or JavaScript
+// errors. If staticDir is empty, the deck's static files are the ones
+// next to it.
+func DryRun(deckFile, staticDir string) error {
+	return dryRun(deckFile, staticDir)
+}
+
+// buildFile writes a deck made from parts to outputFile.
+func buildFile(o *options, outputFile, title string, parts []part) error {
+	if o.offline {
+		if err := vendorAssets(filepath.Join(filepath.Dir(outputFile), "static")); err != nil {
+			return err
+		}
+	}
+	if err := checkImports(partFiles(parts)); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	slides, err := writeDeck(o, &buf, filepath.Base(outputFile), title, parts)
+	if err != nil {
+		return err
+	}
+	if o.notesFile != "" {
+		var nb bytes.Buffer
+		if err := writeNotesDoc(o, &nb, title, slides); err != nil {
+			return err
+		}
+		if err := os.WriteFile(o.notesFile, nb.Bytes(), 0o644); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(outputFile, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("error writing output file: %w", err)
+	}
+	return nil
+}
+
+// parts returns the parts of the deck that cfg describes.
+func (cfg Config) parts() ([]part, error) {
+	switch {
+	case cfg.Profile != "":
+		if cfg.Manifest == "" {
+			return nil, fmt.Errorf("Profile requires Manifest")
+		}
+		parts, _, err := readProfile(cfg.Manifest, cfg.Profile)
+		return parts, err
+	case cfg.Manifest != "":
+		return readManifest(cfg.Manifest)
+	}
+	return []part{{files: cfg.Files}}, nil
+}
+
+// files returns the slide files of the deck that cfg describes.
+func (cfg Config) files() ([]string, error) {
+	parts, err := cfg.parts()
+	if err != nil {
+		return nil, err
+	}
+	return partFiles(parts), nil
+}
+
+// options are the options of a build, made from a Config. The code that
+// scans and renders a deck is passed them.
+type options struct {
+	ctx            context.Context // stops the commands that deck code runs, for testfail and output directives
+	includeNotes   bool
+	presenterNotes bool   // show notes in a presenter window
+	notesFile      string // also write the notes of each slide here
+	debug          bool
+	offline        bool
+	scroll         bool
+	forbidTodo     bool
+	requireAlt     bool     // fail if an image has no alt text
+	emElement      string   // HTML element for emphasized code
+	emClass        string   // class of emElement; may be empty
+	footer         string   // markdown for the license or attribution on every slide
+	comment        string   // prefix of line comments in every file, overriding commentPrefixes
+	buildTags      []string // for the if directive
+	numberByPart   bool     // number exercises and questions separately in each part
+	addTOC         bool     // add a table of contents after the title slide
+	themeURL       string   // a stylesheet loaded after styles.css
+	analyticsURL   string   // where the deck reports how it is used
+	chanOps        bool     // style channel operations in code
+	mutexOps       bool     // style mutex operations and the fields they guard
+
+	// answerSummary is the text that reveals an answer when there is no
+	// question text to click on, as after hints.
+	answerSummary string
+
+	// docLinks maps the names of the packages whose exported names link
+	// to their documentation to their import paths.
+	docLinks map[string]string
+
+	// headingOverrides are the entries of the headings file, by the
+	// absolute paths of their slide files.
+	headingOverrides map[string]*fileHeadings
+
+	// headingFallback is how a file's first slide is headed when the file
+	// has no heading before it: "file" for the file's name, or "name" for a
+	// heading made from it, like "Worker pool" for "20-worker-pool.go".
+	headingFallback string
+
+	// overflowMode is the attribute of code sections without fit, scroll
+	// or split; if empty, their code is cut off. maxCodeLines is the most
+	// lines of code at the default size that a slide holds before split
+	// code continues on the next.
+	overflowMode codeAttr
+	maxCodeLines int
+
+	transforms []string // the commands of the transform programs
+
+	// transcriptLines is the maximum number of lines of test output shown
+	// by a testfail directive. determinismRuns is the number of times to
+	// run each testfail test to check that its output doesn't vary; values
+	// less than 2 disable the check.
+	transcriptLines int
+	determinismRuns int
+
+	sinceRef     string        // if non-empty, build only the slides changed since this git ref
+	feedFile     string        // if non-empty, write a change feed here
+	feedSince    time.Duration // only list changes this recent; 0 means all
+	deckVersion  string        // if non-empty, save a snapshot of the deck under this name
+	changesSince string        // if non-empty, add a slide of changes since this version
+
+	static       string // the deck's static/ directory, if not the one next to it
+	minAnswerLen int    // the length below which lint considers an answer a placeholder
+
+	noExec      bool             // use only cached output for output auto
+	outputCache string           // directory of cached output; none if empty
+	releases    *releaseSchedule // when held slides may be shown
+	exec        *executor        // runs deck code
+}
+
+// options returns the options that cfg describes, for a build whose deck
+// code is stopped when ctx is done.
+func (cfg Config) options(ctx context.Context) (*options, error) {
+	o := &options{
+		ctx:             ctx,
+		includeNotes:    cfg.Notes,
+		presenterNotes:  cfg.Presenter,
+		notesFile:       cfg.NotesFile,
+		debug:           cfg.Debug,
+		offline:         cfg.Offline,
+		scroll:          cfg.Scroll,
+		forbidTodo:      cfg.ForbidTodo,
+		requireAlt:      cfg.RequireAlt,
+		emElement:       "span",
+		emClass:         "em",
+		footer:          cfg.Footer,
+		comment:         cfg.Comment,
+		buildTags:       slices.Clone(cfg.Tags),
+		numberByPart:    cfg.NumberByPart,
+		addTOC:          cfg.TOC,
+		themeURL:        cfg.Theme,
+		analyticsURL:    cfg.Analytics,
+		answerSummary:   cmp.Or(cfg.AnswerSummary, "Answer"),
+		docLinks:        map[string]string{},
+		chanOps:         cfg.ChanOps,
+		mutexOps:        cfg.MutexOps,
+		headingFallback: "file",
+		maxCodeLines:    cmp.Or(cfg.SplitLines, 20),
+		transforms:      slices.Clone(cfg.Transforms),
+		transcriptLines: cmp.Or(cfg.TranscriptLines, 30),
+		determinismRuns: cfg.CheckDeterminism,
+		sinceRef:        cfg.Since,
+		feedFile:        cfg.Feed,
+		feedSince:       cfg.FeedSince,
+		deckVersion:     cfg.Version,
+		changesSince:    cfg.ChangesSince,
+		static:          cfg.Static,
+		minAnswerLen:    cmp.Or(cfg.MinAnswer, 10),
+		noExec:          cfg.NoExec,
+		outputCache:     cmp.Or(cfg.OutputCache, defaultOutputCache()),
+		releases:        &releaseSchedule{},
+	}
+	if cfg.EmElement != "" {
+		o.emElement, o.emClass = cfg.EmElement, cfg.EmClass
+	}
+	if cfg.Headings != "" {
+		hs, err := readHeadings(cfg.Headings)
+		if err != nil {
+			return nil, err
+		}
+		o.headingOverrides = hs
+	}
+	if cfg.HeadingFallback != "" {
+		if cfg.HeadingFallback != "file" && cfg.HeadingFallback != "name" {
+			return nil, fmt.Errorf("HeadingFallback: want file or name, not %q", cfg.HeadingFallback)
+		}
+		o.headingFallback = cfg.HeadingFallback
+	}
+	if cfg.Overflow != "" {
+		switch a := codeAttr(cfg.Overflow); a {
+		case attrFit, attrScroll, attrSplit:
+			o.overflowMode = a
+		default:
+			return nil, fmt.Errorf("Overflow: want fit, scroll or split, not %q", cfg.Overflow)
+		}
+	}
+	for _, p := range cfg.DocLinks {
+		o.docLinks[path.Base(p)] = p
+	}
+	for _, name := range cfg.Release {
+		o.releases.set(name, time.Time{})
+	}
+	if (o.deckVersion != "" || o.changesSince != "") && o.feedFile == "" {
+		return nil, fmt.Errorf("Version and ChangesSince require Feed")
+	}
+	var err error
+	o.exec, err = newExecutor(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
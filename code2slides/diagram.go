@@ -0,0 +1,92 @@
+package code2slides
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Diagram sections hold the source of a diagram, which the build renders
+// to SVG with the diagram language's program and puts in the slide. The
+// SVG is cached in the -output-cache directory, like the output of output auto, so only
+// new or changed diagrams need the program.
+
+// diagramCommands holds, for each diagram language, the command that
+// renders a diagram to SVG. "IN" and "OUT" stand for the files that hold
+// the source and the SVG.
+var diagramCommands = map[string][]string{
+	"mermaid": {"mmdc", "--quiet", "-i", "IN", "-o", "OUT"},
+	"dot":     {"dot", "-Tsvg", "-o", "OUT", "IN"},
+	"d2":      {"d2", "IN", "OUT"},
+}
+
+// renderDiagram returns the SVG for src, a diagram in lang, for inclusion
+// in HTML.
+func renderDiagram(o *options, lang, src string) (string, error) {
+	args, ok := diagramCommands[lang]
+	if !ok {
+		return "", fmt.Errorf("diagram: unknown language %q: want mermaid, dot or d2", lang)
+	}
+	var cacheFile string
+	if o.outputCache != "" {
+		cacheFile = filepath.Join(o.outputCache, fmt.Sprintf("%x.svg", sha256.Sum256([]byte(lang+"\n"+src))))
+		if svg, err := os.ReadFile(cacheFile); err == nil {
+			return string(svg), nil
+		}
+	}
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return "", fmt.Errorf("diagram: rendering %s needs %s, which isn't installed", lang, args[0])
+	}
+	dir, err := os.MkdirTemp("", "code2slides-diagram")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	in, out := filepath.Join(dir, "diagram."+lang), filepath.Join(dir, "diagram.svg")
+	if err := os.WriteFile(in, []byte(src), 0o644); err != nil {
+		return "", err
+	}
+	args = append([]string(nil), args...)
+	for i, a := range args {
+		switch a {
+		case "IN":
+			args[i] = in
+		case "OUT":
+			args[i] = out
+		}
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("diagram: %s: %v\n%s", args[0], err, stderr.Bytes())
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		return "", err
+	}
+	svg := inlineSVG(string(data))
+	if cacheFile != "" {
+		if err := os.MkdirAll(o.outputCache, 0o755); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(cacheFile, []byte(svg), 0o644); err != nil {
+			return "", err
+		}
+	}
+	return svg, nil
+}
+
+// svgPrologRe matches the XML declaration, doctype and comments that may
+// precede the svg element of a file.
+var svgPrologRe = regexp.MustCompile(`^(?:\s*(?:<\?[^>]*\?>|<!DOCTYPE[^>]*>|<!--.*?-->))*\s*`)
+
+// inlineSVG returns the SVG file svg without what can't appear inside HTML.
+func inlineSVG(svg string) string {
+	return strings.TrimSpace(svgPrologRe.ReplaceAllString(svg, ""))
+}
@@ -0,0 +1,165 @@
+package code2slides
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A diffOp says what happened to a line going from one text to another.
+type diffOp int
+
+const (
+	diffSame diffOp = iota
+	diffDelete
+	diffInsert
+)
+
+// A diffLine is a line of a diff.
+type diffLine struct {
+	op   diffOp
+	text string
+}
+
+// diffLines returns a shortest edit from a to b, as the lines that are the
+// same in both interleaved with the lines deleted from a and inserted from b.
+// Deletions come before insertions where the order doesn't matter.
+func diffLines(a, b []string) []diffLine {
+	// lcs[i][j] is the length of the longest common subsequence
+	// of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var d []diffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			d = append(d, diffLine{diffSame, a[i]})
+			i++
+			j++
+		case j == len(b) || i < len(a) && lcs[i+1][j] >= lcs[i][j+1]:
+			d = append(d, diffLine{diffDelete, a[i]})
+			i++
+		default:
+			d = append(d, diffLine{diffInsert, b[j]})
+			j++
+		}
+	}
+	return d
+}
+
+// sideBySideHTML returns a table showing the diff from a to b in two
+// columns, with line numbers. Runs of deleted lines are shown beside
+// the lines that replaced them.
+func sideBySideHTML(a, b []string) string {
+	var sb strings.Builder
+	sb.WriteString("<table class='diff'>\n")
+	cell := func(num int, text, class string) {
+		if num == 0 {
+			sb.WriteString("<td class='num'></td><td></td>")
+			return
+		}
+		text = html.EscapeString(strings.ReplaceAll(text, "\t", "    "))
+		fmt.Fprintf(&sb, "<td class='num'>%d</td><td class='%s'>%s</td>", num, class, text)
+	}
+	d := diffLines(a, b)
+	anum, bnum := 0, 0
+	for k := 0; k < len(d); {
+		if d[k].op == diffSame {
+			anum++
+			bnum++
+			sb.WriteString("<tr>")
+			cell(anum, d[k].text, "same")
+			cell(bnum, d[k].text, "same")
+			sb.WriteString("</tr>\n")
+			k++
+			continue
+		}
+		var dels, ins []string
+		for ; k < len(d) && d[k].op == diffDelete; k++ {
+			dels = append(dels, d[k].text)
+		}
+		for ; k < len(d) && d[k].op == diffInsert; k++ {
+			ins = append(ins, d[k].text)
+		}
+		for r := range max(len(dels), len(ins)) {
+			sb.WriteString("<tr>")
+			if r < len(dels) {
+				anum++
+				cell(anum, dels[r], "del")
+			} else {
+				cell(0, "", "")
+			}
+			if r < len(ins) {
+				bnum++
+				cell(bnum, ins[r], "ins")
+			} else {
+				cell(0, "", "")
+			}
+			sb.WriteString("</tr>\n")
+		}
+	}
+	sb.WriteString("</table>")
+	return sb.String()
+}
+
+// solutionDiffHTML returns side-by-side diffs between each Go file in an
+// exercise directory and the file of the same name in its solution
+// subdirectory, for files that differ. Each diff is in a details element
+// so it can be opened during the debrief.
+func solutionDiffHTML(dir string) (string, error) {
+	solFiles, err := filepath.Glob(filepath.Join(dir, "solution", "*.go"))
+	if err != nil {
+		return "", err
+	}
+	if len(solFiles) == 0 {
+		return "", fmt.Errorf("no solution files in %s", filepath.Join(dir, "solution"))
+	}
+	var sb strings.Builder
+	for _, sf := range solFiles {
+		name := filepath.Base(sf)
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		sol, err := os.ReadFile(sf)
+		if err != nil {
+			return "", err
+		}
+		// An exercise may have no starting file, to be written from scratch.
+		skel, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		if string(skel) == string(sol) {
+			continue
+		}
+		fmt.Fprintf(&sb, "<details class='solution'><summary>%s: exercise and solution</summary>\n", html.EscapeString(name))
+		sb.WriteString(sideBySideHTML(splitLines(string(skel)), splitLines(string(sol))))
+		sb.WriteString("\n</details>\n")
+	}
+	return sb.String(), nil
+}
+
+// splitLines splits s into lines, without a final empty line
+// for a trailing newline.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,212 @@
+package code2slides
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// A dry run checks a deck before it is presented: that the files it refers
+// to exist, and, if Chrome is installed, that pressing the keys of a talk,
+// from the first slide to the last and back, through every step and answer,
+// raises no JavaScript errors. It runs after the build with -dry-run.
+
+// chromeNames are the names Chrome may be installed under. The CHROME
+// environment variable overrides them.
+var chromeNames = []string{"chromium", "chromium-browser", "google-chrome", "google-chrome-stable", "chrome"}
+
+// assetRe matches the src and href attributes of a deck.
+var assetRe = regexp.MustCompile(`\b(?:src|href)=["']([^"']+)["']`)
+
+// dryRun checks the deck in deckFile, whose static/ files are in staticDir,
+// if not the directory next to it.
+func dryRun(deckFile, staticDir string) error {
+	data, err := os.ReadFile(deckFile)
+	if err != nil {
+		return err
+	}
+	deck := string(data)
+	if staticDir != "" {
+		abs, err := filepath.Abs(staticDir)
+		if err != nil {
+			return err
+		}
+		deck = staticRefRe.ReplaceAllString(deck, "${1}file://"+filepath.ToSlash(abs)+"/")
+	}
+	errs := missingAssets(deckFile, deck)
+	chrome := os.Getenv("CHROME")
+	if chrome == "" {
+		for _, name := range chromeNames {
+			if path, err := exec.LookPath(name); err == nil {
+				chrome = path
+				break
+			}
+		}
+	}
+	if chrome == "" {
+		fmt.Fprintln(os.Stderr, "dry run: Chrome isn't installed, so JavaScript isn't checked")
+	} else {
+		jsErrs, err := walkDeck(chrome, deckFile, deck)
+		if err != nil {
+			return fmt.Errorf("dry run: %w", err)
+		}
+		errs = append(errs, jsErrs...)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("dry run of %s failed:\n%s", deckFile, strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// staticRefRe matches the beginning of a reference to a static file.
+var staticRefRe = regexp.MustCompile(`(['"])static/`)
+
+// missingAssets returns "LINE: missing FILE" for each file that deck, the
+// contents of deckFile, refers to but that doesn't exist. Links to other
+// pages and network references aren't checked, except for file URLs.
+func missingAssets(deckFile, deck string) []string {
+	var missing []string
+	dir := filepath.Dir(deckFile)
+	for i, line := range strings.Split(deck, "\n") {
+		line = linkRe.ReplaceAllString(line, "")
+		for _, m := range assetRe.FindAllStringSubmatch(line, -1) {
+			ref := html.UnescapeString(m[1])
+			path, isFile := strings.CutPrefix(ref, "file://")
+			if !isFile && (strings.HasPrefix(ref, "#") || strings.HasPrefix(ref, "//") || strings.Contains(ref, ":")) {
+				continue // fragment, or a URL with a scheme
+			}
+			path, _, _ = strings.Cut(path, "?")
+			path, _, _ = strings.Cut(path, "#")
+			path = filepath.FromSlash(path)
+			if !isFile {
+				path = filepath.Join(dir, path)
+			}
+			if _, err := os.Stat(path); err != nil {
+				missing = append(missing, fmt.Sprintf("%d: missing %s", i+1, ref))
+			}
+		}
+	}
+	return missing
+}
+
+// dryRunResultRe matches the result that dryRunScript adds to the page.
+var dryRunResultRe = regexp.MustCompile(`(?s)<pre id="dry-run-result">(.*?)</pre>`)
+
+// walkDeck loads a copy of deck, the contents of deckFile, with
+// dryRunScript into a headless chrome, and returns the errors it reports.
+func walkDeck(chrome, deckFile, deck string) ([]string, error) {
+	i := strings.LastIndex(deck, "</body>")
+	if i < 0 {
+		return nil, errors.New("deck has no </body>")
+	}
+	// The copy is next to the deck, so that its relative references work.
+	f, err := os.CreateTemp(filepath.Dir(deckFile), ".dry-run-*.html")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(deck[:i] + dryRunScript + deck[i:])
+	if err := errors.Join(err, f.Close()); err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(f.Name())
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, chrome, "--headless", "--disable-gpu", "--no-sandbox",
+		"--allow-file-access-from-files", "--virtual-time-budget=60000", "--dump-dom",
+		"file://"+filepath.ToSlash(abs))
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", chrome, err)
+	}
+	return dryRunErrors(string(out))
+}
+
+// dryRunErrors returns the errors in the result that dryRunScript left in
+// dom, the page after it ran.
+func dryRunErrors(dom string) ([]string, error) {
+	m := dryRunResultRe.FindStringSubmatch(dom)
+	if m == nil {
+		return nil, errors.New("the deck didn't finish loading")
+	}
+	var res struct {
+		Slides  int      `json:"slides"`
+		Presses int      `json:"presses"`
+		Errors  []string `json:"errors"`
+	}
+	if err := json.Unmarshal([]byte(html.UnescapeString(m[1])), &res); err != nil {
+		return nil, err
+	}
+	return res.Errors, nil
+}
+
+// dryRunScript walks a deck with the keyboard. It presses the right arrow
+// until nothing changes, pressing 'A' on each slide to open each answer
+// and hint and then close them all, and then presses the left arrow back to
+// the start. It reports what went wrong in a pre element for dryRunErrors.
+const dryRunScript = `<script>
+(function() {
+  var errors = [];
+  // Capturing also catches files that fail to load.
+  window.addEventListener('error', function(e) {
+    if (e.target && e.target !== window) {
+      errors.push('failed to load ' + (e.target.src || e.target.href));
+    } else {
+      errors.push((e.filename || '') + ':' + (e.lineno || 0) + ': ' + e.message);
+    }
+  }, true);
+  window.addEventListener('unhandledrejection', function(e) {
+    errors.push('unhandled rejection: ' + e.reason);
+  });
+  function press(code) {
+    var e = new KeyboardEvent('keydown', {bubbles: true, cancelable: true});
+    Object.defineProperty(e, 'keyCode', {get: function() { return code; }});
+    document.body.dispatchEvent(e);
+  }
+  function state() {
+    return curSlide + ':' + document.querySelectorAll('.shown').length;
+  }
+  function walk(code) {
+    var presses = 0;
+    for (var s = state(); presses < 10000; presses++) {
+      var el = getSlideEl(curSlide);
+      var n = el ? el.querySelectorAll('details').length : 0;
+      for (var i = 0; i <= n && n > 0; i++) press(65); // 'A'
+      press(code);
+      var t = state();
+      if (t === s) break;
+      s = t;
+    }
+    return presses;
+  }
+  window.addEventListener('load', function() {
+    setTimeout(function() {
+      var res = {slides: 0, presses: 0, errors: errors};
+      try {
+        if (typeof slideEls !== 'undefined') {
+          res.slides = slideEls.length;
+          res.presses = walk(39) + walk(37);
+        }
+      } catch (e) {
+        errors.push(String(e));
+      }
+      var pre = document.createElement('pre');
+      pre.id = 'dry-run-result';
+      pre.textContent = JSON.stringify(res);
+      document.body.appendChild(pre);
+    }, 500);
+  });
+})();
+</script>
+`
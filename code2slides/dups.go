@@ -0,0 +1,206 @@
+package code2slides
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// When slides are copied into a new tree and both copies are edited, the
+// trees drift apart. The dups subcommand finds the slide files in other
+// trees that duplicate or nearly duplicate files in a canonical one, and
+// can merge the trees so only the canonical sequence remains.
+
+// dupsCommand reports, and with -merge reconciles, duplicate slide files.
+func dupsCommand(args []string) error {
+	fs := flag.NewFlagSet("dups", flag.ExitOnError)
+	threshold := fs.Float64("threshold", 0.8, "report files whose lines are at least this `fraction` alike")
+	merge := fs.Bool("merge", false, "git rm duplicates and git mv unmatched files into the canonical tree")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: code2slides dups [flags] <canonical dir> <dir>...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() < 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	matches, err := findDups(fs.Arg(0), fs.Args()[1:], *threshold)
+	if err != nil {
+		return err
+	}
+	reportDups(os.Stdout, matches)
+	if !*merge {
+		return nil
+	}
+	return mergeDups(os.Stdout, fs.Arg(0), matches, gitMove, gitRemove)
+}
+
+// A dupMatch is the file of the canonical tree that most resembles
+// a file of another tree.
+type dupMatch struct {
+	file      string  // in another tree
+	canonical string  // empty if no canonical file is alike enough
+	alike     float64 // the fraction of lines that are alike, from 0 to 1
+}
+
+// findDups returns, for each slide file in the dirs, its best match among
+// the slide files in the canonical dir, if they are at least threshold
+// alike.
+func findDups(canonical string, dirs []string, threshold float64) ([]dupMatch, error) {
+	canonFiles, err := slideSources(canonical)
+	if err != nil {
+		return nil, err
+	}
+	canonLines := map[string][]string{}
+	for _, f := range canonFiles {
+		if canonLines[f], err = normalizedLines(f); err != nil {
+			return nil, err
+		}
+	}
+	var matches []dupMatch
+	for _, dir := range dirs {
+		files, err := slideSources(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			lines, err := normalizedLines(f)
+			if err != nil {
+				return nil, err
+			}
+			m := dupMatch{file: f}
+			for _, cf := range canonFiles {
+				if a := alikeness(lines, canonLines[cf]); a >= threshold && a > m.alike {
+					m.canonical, m.alike = cf, a
+				}
+			}
+			matches = append(matches, m)
+		}
+	}
+	return matches, nil
+}
+
+// slideSources returns the Go files in dir, other than tests.
+func slideSources(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	var srcs []string
+	for _, f := range files {
+		if !strings.HasSuffix(f, "_test.go") {
+			srcs = append(srcs, f)
+		}
+	}
+	return srcs, nil
+}
+
+// normalizedLines returns the lines of a slide file that matter when
+// comparing it to another: without surrounding space, blank lines,
+// separator lines or the package clause, which differs between trees.
+func normalizedLines(file string) ([]string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for line := range strings.Lines(string(data)) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.Trim(line, "/") == "" || strings.HasPrefix(line, "package ") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// alikeness returns the fraction of the lines of a and b that are the same
+// in both, in order.
+func alikeness(a, b []string) float64 {
+	if len(a)+len(b) == 0 {
+		return 1
+	}
+	same := 0
+	for _, d := range diffLines(a, b) {
+		if d.op == diffSame {
+			same++
+		}
+	}
+	return float64(2*same) / float64(len(a)+len(b))
+}
+
+func reportDups(w io.Writer, matches []dupMatch) {
+	for _, m := range matches {
+		switch {
+		case m.canonical == "":
+			fmt.Fprintf(w, "%s: no match\n", m.file)
+		case m.alike == 1:
+			fmt.Fprintf(w, "%s: same as %s\n", m.file, m.canonical)
+		default:
+			fmt.Fprintf(w, "%s: %.0f%% like %s\n", m.file, 100*m.alike, m.canonical)
+		}
+	}
+}
+
+// mergeDups reconciles the other trees with the canonical one. It removes
+// files that are the same as a canonical file, and moves files that match
+// none to the end of the canonical sequence, in the canonical package.
+// Files that are only alike must be merged by hand; it lists them on w.
+func mergeDups(w io.Writer, canonical string, matches []dupMatch, move func(from, to string) error, remove func(string) error) error {
+	pkg := packageName(canonical)
+	var byHand []dupMatch
+	for _, m := range matches {
+		switch {
+		case m.canonical == "":
+			rest := filepath.Base(m.file)
+			if sm := numberedRe.FindStringSubmatch(rest); sm != nil {
+				rest = sm[2] + ".go"
+			}
+			to, err := nextSlideFile(canonical, rest)
+			if err != nil {
+				return err
+			}
+			if err := move(m.file, to); err != nil {
+				return err
+			}
+			if err := setPackage(to, pkg); err != nil {
+				return err
+			}
+			if err := addToManifest(filepath.Join(canonical, "manifest.txt"), to); err != nil {
+				return err
+			}
+		case m.alike == 1:
+			if err := remove(m.file); err != nil {
+				return err
+			}
+		default:
+			byHand = append(byHand, m)
+		}
+	}
+	if len(byHand) > 0 {
+		fmt.Fprintln(w, "merge by hand:")
+		for _, m := range byHand {
+			fmt.Fprintf(w, "\t%s into %s\n", m.file, m.canonical)
+		}
+	}
+	return nil
+}
+
+// setPackage changes the package clause of a Go file to declare pkg.
+func setPackage(file, pkg string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	loc := packageRe.FindSubmatchIndex(data)
+	if loc == nil {
+		return fmt.Errorf("%s: no package clause", file)
+	}
+	data = slices.Concat(data[:loc[2]], []byte(pkg), data[loc[3]:])
+	return os.WriteFile(file, data, 0o644)
+}
@@ -0,0 +1,329 @@
+package code2slides
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// A codeRun is code that a directive runs for the content of its section.
+// Scanning a deck only records it; building the deck runs it (see
+// runCode), so commands that only scan a deck, like -lint, grep or -todos,
+// run nothing.
+type codeRun struct {
+	directive string   // the directive, like "testfail"
+	dir       string   // the directory of the package to run
+	args      []string // the directive's arguments, less "nondeterministic"
+	nondet    bool     // the output varies from run to run
+	line      int      // the line of the directive
+}
+
+func (r *codeRun) equal(other *codeRun) bool {
+	if r == nil || other == nil {
+		return r == other
+	}
+	return r.directive == other.directive && r.dir == other.dir &&
+		slices.Equal(r.args, other.args) && r.nondet == other.nondet
+}
+
+// output runs r and returns what it produces for its section.
+func (r *codeRun) output(o *options) (string, error) {
+	switch r.directive {
+	case "testfail":
+		name, args := r.args[0], r.args[1:]
+		out, err := failingTestOutput(o, r.dir, name, args)
+		if err != nil {
+			return "", err
+		}
+		if o.determinismRuns > 1 && !r.nondet {
+			if err := checkDeterministic(o, r.dir, name, args, out, o.determinismRuns); err != nil {
+				return "", err
+			}
+		}
+		return out, nil
+	case "output auto":
+		return autoOutput(o, r.dir, r.args)
+	case "sequence":
+		return sequenceDiagram(o, r.dir, r.args)
+	}
+	return "", fmt.Errorf("unknown directive %q", r.directive)
+}
+
+// runCode runs the code of the sections of slides, from filename, that
+// have it, and makes their content its output.
+func runCode(o *options, filename string, slides []*Slide) error {
+	run := func(sec *section) error {
+		if sec.run == nil {
+			return nil
+		}
+		out, err := sec.run.output(o)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", filename, sec.run.line, err)
+		}
+		sec.content = out
+		sec.run = nil
+		return nil
+	}
+	for _, s := range slides {
+		for i := range s.sections {
+			sec := &s.sections[i]
+			if err := run(sec); err != nil {
+				return err
+			}
+			if sec.kind == sectionCompare {
+				for j := range sec.outputs {
+					if err := run(&sec.outputs[j]); err != nil {
+						return err
+					}
+				}
+				sec.content = compareContent(sec.outputs)
+			}
+		}
+	}
+	return nil
+}
+
+// failingTestOutput runs the test named name in the package in dir.
+// args are extra flags to go test and environment settings, like
+// GOMAXPROCS=2 or GOTOOLCHAIN=go1.24.0. It returns the test's output,
+// scrubbed and truncated for display. A test stopped by one of the -exec
+// limits counts as failing; its partial output is returned with a note.
+func failingTestOutput(o *options, dir, name string, args []string) (string, error) {
+	flags, env := splitEnv(args)
+	var buf bytes.Buffer
+	code, note, err := o.exec.run(o.ctx, execRequest{
+		dir:    dir,
+		args:   append([]string{"test", "-count=1", "-run", "^" + name + "$"}, flags...),
+		env:    env,
+		stdout: &buf,
+		stderr: &buf,
+	})
+	if err != nil {
+		return "", fmt.Errorf("testfail: %w", err)
+	}
+	if code == 0 && note == "" {
+		return "", fmt.Errorf("testfail: %s passed, but should fail", name)
+	}
+	out := buf.Bytes()
+	if bytes.Contains(out, []byte("no tests to run")) {
+		return "", fmt.Errorf("testfail: no test named %s", name)
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	text := truncateLines(scrubOutput(string(out), absDir), o.transcriptLines)
+	if note != "" && text != "" && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return text + note, nil
+}
+
+// testNameRe matches the name of a test or example.
+var testNameRe = regexp.MustCompile(`^(Test|Example)\w*$`)
+
+// autoOutput returns the output of the package in dir for an "output auto"
+// directive. If args begins with the name of a test, it is the output of
+// "go test" running that test, which must pass; otherwise, it is what
+// "go run ." writes to stdout. The rest of args are flags and environment
+// settings, as for failingTestOutput. Output is cached in o.outputCache,
+// keyed by args and the files in dir, and with -no-exec only cached output
+// is used.
+func autoOutput(o *options, dir string, args []string) (string, error) {
+	key, err := outputKey(dir, args)
+	if err != nil {
+		return "", err
+	}
+	var cacheFile string
+	if o.outputCache != "" {
+		cacheFile = filepath.Join(o.outputCache, key)
+		if out, err := os.ReadFile(cacheFile); err == nil {
+			return string(out), nil
+		}
+	}
+	if o.noExec {
+		return "(no output: built with -no-exec, and none is cached)\n", nil
+	}
+
+	var name string // of the test to run
+	if len(args) > 0 && testNameRe.MatchString(args[0]) {
+		name, args = args[0], args[1:]
+	}
+	flags, env := splitEnv(args)
+	goArgs := append(append([]string{"run"}, flags...), ".")
+	if name != "" {
+		goArgs = append([]string{"test", "-count=1", "-run", "^" + name + "$"}, flags...)
+	}
+	var stdout, stderr bytes.Buffer
+	code, note, err := o.exec.run(o.ctx, execRequest{
+		dir:    dir,
+		args:   goArgs,
+		env:    env,
+		stdout: &stdout,
+		stderr: &stderr,
+	})
+	if err != nil {
+		return "", fmt.Errorf("output auto: %w", err)
+	}
+	if code != 0 && note == "" {
+		return "", fmt.Errorf("output auto: go %s failed:\n%s%s", strings.Join(goArgs, " "), stdout.Bytes(), stderr.Bytes())
+	}
+	out := stdout.String()
+	if name != "" {
+		if strings.Contains(out, "no tests to run") {
+			return "", fmt.Errorf("output auto: no test named %s", name)
+		}
+		out = trimTestSummary(out)
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	text := truncateLines(scrubOutput(out, absDir), o.transcriptLines)
+	if note != "" {
+		// Partial output isn't cached, so the next build tries again.
+		if text != "" && !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		return text + note, nil
+	}
+	if cacheFile != "" {
+		if err := os.MkdirAll(o.outputCache, 0o755); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(cacheFile, []byte(text), 0o644); err != nil {
+			return "", err
+		}
+	}
+	return text, nil
+}
+
+// trimTestSummary removes the lines that go test adds after the output
+// of passing tests.
+func trimTestSummary(out string) string {
+	lines := strings.SplitAfter(out, "\n")
+	for len(lines) > 0 {
+		last := strings.TrimSpace(lines[len(lines)-1])
+		if last != "" && last != "PASS" && !strings.HasPrefix(last, "ok ") {
+			break
+		}
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "")
+}
+
+// outputKey returns the key for the cached output of the package in dir,
+// run with args: a hash of args and of the names and contents of the
+// files in dir.
+func outputKey(dir string, args []string) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "%q\n", args)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s %d\n", e.Name(), len(data))
+		h.Write(data)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// checkDeterministic runs the test named name n-1 more times and reports
+// an error if its output differs from want, the output of the first run.
+func checkDeterministic(o *options, dir, name string, args []string, want string, n int) error {
+	for i := 2; i <= n; i++ {
+		got, err := failingTestOutput(o, dir, name, args)
+		if err != nil {
+			return err
+		}
+		if got != want {
+			wl, gl := firstDiff(want, got)
+			return fmt.Errorf("testfail: output of %s varies between runs; fix it or mark it nondeterministic\n\trun 1: %s\n\trun %d: %s", name, wl, i, gl)
+		}
+	}
+	return nil
+}
+
+// firstDiff returns the first line at which a and b differ.
+func firstDiff(a, b string) (string, string) {
+	al, bl := strings.Split(a, "\n"), strings.Split(b, "\n")
+	for i := range min(len(al), len(bl)) {
+		if al[i] != bl[i] {
+			return al[i], bl[i]
+		}
+	}
+	if len(al) < len(bl) {
+		return "(end of output)", bl[len(al)]
+	}
+	return al[len(bl)], "(end of output)"
+}
+
+var envRe = regexp.MustCompile(`^[A-Z][A-Z0-9_]*=`)
+
+// splitEnv separates environment settings, like GOGC=off, from flags.
+func splitEnv(args []string) (flags, env []string) {
+	for _, a := range args {
+		if envRe.MatchString(a) {
+			env = append(env, a)
+		} else {
+			flags = append(flags, a)
+		}
+	}
+	return flags, env
+}
+
+// conditions describes the flags and environment in args for display with
+// the output they produced. A GOTOOLCHAIN setting is shown as just the
+// toolchain version.
+func conditions(args []string) string {
+	var cs []string
+	for _, a := range args {
+		if v, ok := strings.CutPrefix(a, "GOTOOLCHAIN="); ok {
+			a = v
+		}
+		cs = append(cs, a)
+	}
+	return strings.Join(cs, " ")
+}
+
+var (
+	durationRe = regexp.MustCompile(`\s*\(?\b\d+\.\d+s\)?`)
+	addressRe  = regexp.MustCompile(`\b0x[0-9a-f]{6,}\b`)
+)
+
+// scrubOutput removes details of go test output that vary from run to run
+// or machine to machine: absolute paths, timings and pointer values.
+func scrubOutput(out, dir string) string {
+	out = strings.ReplaceAll(out, dir+string(filepath.Separator), "")
+	out = strings.ReplaceAll(out, runtime.GOROOT(), "$GOROOT")
+	out = durationRe.ReplaceAllString(out, "")
+	out = addressRe.ReplaceAllString(out, "0x...")
+	return out
+}
+
+// truncateLines returns s with at most n lines, with a marker
+// replacing any that were dropped.
+func truncateLines(s string, n int) string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) <= n {
+		return s
+	}
+	return strings.Join(lines[:n], "") + fmt.Sprintf("... (%d more lines)\n", len(lines)-n)
+}
@@ -0,0 +1,221 @@
+package code2slides
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io/fs"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// The change feed is a JSON Feed (https://jsonfeed.org) with an item for
+// each slide that was added or updated. So that we can tell what changed,
+// the feed also records a hash of every slide in the deck.
+//
+// Building with -version saves a snapshot of those hashes under the
+// version's name, and -changes-since compares the deck to a snapshot to
+// make a slide listing what changed, for giving the workshop again to
+// people who saw an earlier version.
+
+type jsonFeed struct {
+	Version  string                       `json:"version"`
+	Title    string                       `json:"title"`
+	Items    []feedItem                   `json:"items"`
+	Slides   map[string]slideState        `json:"_code2slides"`
+	Versions map[string]map[string]string `json:"_code2slides_versions,omitempty"` // version to slide ID to hash
+}
+
+type feedItem struct {
+	ID           string    `json:"id"`
+	URL          string    `json:"url"`
+	Title        string    `json:"title"`
+	ContentText  string    `json:"content_text"`
+	DateModified time.Time `json:"date_modified"`
+}
+
+type slideState struct {
+	Hash    string    `json:"hash"`
+	Added   time.Time `json:"added"`
+	Changed time.Time `json:"changed"`
+}
+
+// now is the current time; tests replace it.
+var now = time.Now
+
+// writeFeed updates the change feed in feedFile for the deck at deckURL,
+// made of slides.
+func writeFeed(o *options, feedFile, title, deckURL string, slides []*Slide) error {
+	old, err := readFeed(feedFile)
+	if err != nil {
+		return err
+	}
+
+	t := now().UTC().Truncate(time.Second)
+	feed := jsonFeed{
+		Version:  "https://jsonfeed.org/version/1.1",
+		Title:    title + " changes",
+		Slides:   map[string]slideState{},
+		Versions: old.Versions,
+	}
+	ids := slideIDs(slides)
+	for i, s := range slides {
+		id := ids[i]
+		st, ok := old.Slides[id]
+		h := slideHash(s)
+		switch {
+		case !ok:
+			st = slideState{Hash: h, Added: t, Changed: t}
+		case st.Hash != h:
+			st.Hash = h
+			st.Changed = t
+		}
+		feed.Slides[id] = st
+		if o.feedSince > 0 && t.Sub(st.Changed) > o.feedSince {
+			continue
+		}
+		what := "Updated"
+		if st.Added.Equal(st.Changed) {
+			what = "Added"
+		}
+		feed.Items = append(feed.Items, feedItem{
+			ID:           id,
+			URL:          fmt.Sprintf("%s#%d", deckURL, i+1),
+			Title:        s.heading,
+			ContentText:  fmt.Sprintf("%s slide %d, %q.", what, i+1, s.heading),
+			DateModified: st.Changed,
+		})
+	}
+	slices.SortStableFunc(feed.Items, func(a, b feedItem) int {
+		return b.DateModified.Compare(a.DateModified)
+	})
+
+	if o.deckVersion != "" {
+		snap := map[string]string{}
+		for id, st := range feed.Slides {
+			snap[id] = st.Hash
+		}
+		if feed.Versions == nil {
+			feed.Versions = map[string]map[string]string{}
+		}
+		feed.Versions[o.deckVersion] = snap
+	}
+
+	data, err := json.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(feedFile, append(data, '\n'), 0o644)
+}
+
+// readFeed reads the change feed in feedFile.
+// If the file doesn't exist, it returns an empty feed.
+func readFeed(feedFile string) (jsonFeed, error) {
+	var feed jsonFeed
+	data, err := os.ReadFile(feedFile)
+	if err == nil {
+		if err := json.Unmarshal(data, &feed); err != nil {
+			return feed, fmt.Errorf("%s: %w", feedFile, err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return feed, err
+	}
+	return feed, nil
+}
+
+// slideIDs returns IDs for slides that stay the same as the deck changes
+// around them: a slide's file and heading, numbered if they repeat.
+func slideIDs(slides []*Slide) []string {
+	var ids []string
+	seen := map[string]int{}
+	for _, s := range slides {
+		id := s.filename + "#" + s.heading
+		seen[id]++
+		if n := seen[id]; n > 1 {
+			id = fmt.Sprintf("%s(%d)", id, n)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// versionSnapshot returns the slide hashes that the feed saved for version.
+func versionSnapshot(feedFile, version string) (map[string]string, error) {
+	feed, err := readFeed(feedFile)
+	if err != nil {
+		return nil, err
+	}
+	snap, ok := feed.Versions[version]
+	if !ok {
+		return nil, fmt.Errorf("%s: no snapshot of version %q", feedFile, version)
+	}
+	return snap, nil
+}
+
+// changesHTML lists the slides of a deck that were added or changed since
+// the snapshot was taken, and the headings of those that were removed.
+// Generated slides, which have no file, are omitted.
+func changesHTML(slides []*Slide, snap map[string]string) string {
+	var b strings.Builder
+	b.WriteString("<ul class='changes'>")
+	ids := slideIDs(slides)
+	current := map[string]bool{}
+	n := 0
+	for i, s := range slides {
+		current[ids[i]] = true
+		if s.filename == "" {
+			continue
+		}
+		what := "new"
+		if h, ok := snap[ids[i]]; ok {
+			if h == slideHash(s) {
+				continue
+			}
+			what = "changed"
+		}
+		fmt.Fprintf(&b, "<li><a href='#%d'>%s</a> (%s)</li>", i+1, html.EscapeString(s.heading), what)
+		n++
+	}
+	for _, id := range slices.Sorted(maps.Keys(snap)) {
+		file, heading, _ := strings.Cut(id, "#")
+		if current[id] || file == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "<li>%s (removed)</li>", html.EscapeString(heading))
+		n++
+	}
+	if n == 0 {
+		b.WriteString("<li>Nothing.</li>")
+	}
+	b.WriteString("</ul>")
+	return b.String()
+}
+
+// slideHash returns a hash of the content of s.
+func slideHash(s *Slide) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%t %q\n", s.isTitle, s.heading)
+	for _, t := range s.titleInfo {
+		fmt.Fprintf(h, "%s %q\n", t.kind, t.text)
+	}
+	for _, sec := range s.sections {
+		var opts any = sec.options
+		if sec.kind == sectionCode {
+			// Attributes hash as the options they once were.
+			opts = sec.attrs
+		}
+		fmt.Fprintf(h, "%s %q %t %q\n", sec.kind, opts, sec.inAnswer, sec.content)
+		// Code not yet run hashes as what it runs.
+		for _, s := range append([]section{sec}, sec.outputs...) {
+			if s.run != nil {
+				fmt.Fprintf(h, "run %s %q\n", s.run.directive, s.run.args)
+			}
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)[:8])
+}
@@ -0,0 +1,189 @@
+package code2slides
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// flakyCommand implements "code2slides flaky", which runs the tests of
+// packages repeatedly under different conditions and reports tests that
+// sometimes fail.
+func flakyCommand(args []string) error {
+	fs := flag.NewFlagSet("flaky", flag.ExitOnError)
+	count := fs.Int("count", 20, "run each test `n` times for each GOMAXPROCS value")
+	race := fs.Bool("race", true, "run tests with the race detector")
+	procs := fs.String("procs", "1,2,8", "comma-separated GOMAXPROCS `values` to test with")
+	run := fs.String("run", "", "run only tests matching `regexp`")
+	verbose := fs.Bool("v", false, "report every test, not just flaky ones")
+	// Repeated runs take longer and write more than building a deck.
+	cfg := Config{ExecTimeout: 30 * time.Minute, ExecOutput: 256 << 20}
+	cfg.SandboxFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: code2slides flaky [flags] <package>...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	e, err := newExecutor(cfg)
+	if err != nil {
+		return err
+	}
+	var procValues []int
+	for p := range strings.SplitSeq(*procs, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil || n < 1 {
+			return fmt.Errorf("-procs: bad value %q", p)
+		}
+		procValues = append(procValues, n)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	goArgs := []string{"test", "-json", "-count=" + strconv.Itoa(*count)}
+	if *race {
+		goArgs = append(goArgs, "-race")
+	}
+	if *run != "" {
+		goArgs = append(goArgs, "-run", *run)
+	}
+	goArgs = append(goArgs, fs.Args()...)
+	results, err := findFlakes(ctx, e, ".", goArgs, procValues)
+	if err != nil {
+		return err
+	}
+	if reportFlakes(os.Stdout, results, *verbose) {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// A flakeKey identifies a test run with a particular GOMAXPROCS.
+type flakeKey struct {
+	pkg, test string
+	procs     int
+}
+
+// flakeCount records how often a test passed and failed.
+type flakeCount struct {
+	pass, fail int
+}
+
+// findFlakes runs "go goArgs..." in dir with e once for each GOMAXPROCS
+// value, and counts the passes and failures of each test.
+// goArgs must include -json.
+func findFlakes(ctx context.Context, e *executor, dir string, goArgs []string, procs []int) (map[flakeKey]*flakeCount, error) {
+	results := map[flakeKey]*flakeCount{}
+	for _, p := range procs {
+		var stdout, stderr bytes.Buffer
+		code, note, err := e.run(ctx, execRequest{
+			dir:    dir,
+			args:   goArgs,
+			env:    []string{"GOMAXPROCS=" + strconv.Itoa(p)},
+			stdout: &stdout,
+			stderr: &stderr,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if note != "" {
+			return nil, fmt.Errorf("with GOMAXPROCS=%d: %s", p, strings.Trim(note, ".() \n"))
+		}
+		fails, err := countTestEvents(&stdout, p, results)
+		if err != nil {
+			return nil, err
+		}
+		if code != 0 && fails == 0 {
+			// go test failed for some other reason, like a build error.
+			return nil, fmt.Errorf("with GOMAXPROCS=%d, go test failed:\n%s", p, stderr.Bytes())
+		}
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no tests ran")
+	}
+	return results, nil
+}
+
+// countTestEvents reads test2json events from r and adds the passes
+// and failures of each test to results. It returns the number of failures.
+func countTestEvents(r io.Reader, procs int, results map[flakeKey]*flakeCount) (fails int, err error) {
+	scan := bufio.NewScanner(r)
+	scan.Buffer(nil, 1<<20)
+	for scan.Scan() {
+		var ev struct {
+			Action  string
+			Package string
+			Test    string
+		}
+		if err := json.Unmarshal(scan.Bytes(), &ev); err != nil {
+			// go test writes some lines, like build errors, as plain text.
+			continue
+		}
+		if ev.Test == "" || (ev.Action != "pass" && ev.Action != "fail") {
+			continue
+		}
+		k := flakeKey{ev.Package, ev.Test, procs}
+		c := results[k]
+		if c == nil {
+			c = &flakeCount{}
+			results[k] = c
+		}
+		if ev.Action == "pass" {
+			c.pass++
+		} else {
+			c.fail++
+			fails++
+		}
+	}
+	return fails, scan.Err()
+}
+
+// reportFlakes writes a table of the tests in results that sometimes
+// failed, or all tests if verbose is true. It reports whether any test
+// failed.
+func reportFlakes(w io.Writer, results map[flakeKey]*flakeCount, verbose bool) (failed bool) {
+	keys := slices.SortedFunc(maps.Keys(results), func(a, b flakeKey) int {
+		return cmp.Or(cmp.Compare(a.pkg, b.pkg), cmp.Compare(a.test, b.test), cmp.Compare(a.procs, b.procs))
+	})
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	flaky := map[string]bool{}
+	rows := 0
+	for _, k := range keys {
+		c := results[k]
+		if c.fail > 0 {
+			failed = true
+			flaky[k.pkg+"."+k.test] = true
+		}
+		if c.fail > 0 || verbose {
+			if rows == 0 {
+				fmt.Fprintln(tw, "PACKAGE\tTEST\tGOMAXPROCS\tFAILED")
+			}
+			rows++
+			runs := c.pass + c.fail
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%d/%d (%.0f%%)\n", k.pkg, k.test, k.procs, c.fail, runs, 100*float64(c.fail)/float64(runs))
+		}
+	}
+	tw.Flush()
+	tests := map[string]bool{}
+	for k := range results {
+		tests[k.pkg+"."+k.test] = true
+	}
+	fmt.Fprintf(w, "%d of %d tests failed at least once\n", len(flaky), len(tests))
+	return failed
+}
@@ -0,0 +1,295 @@
+package code2slides
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// The fmt subcommand formats the directive comments of slide sources, as
+// gofmt formats Go, so that files written by many hands over many years
+// read alike. It
+//
+//   - writes each directive as "// NAME ARGS", with one space after the
+//     comment prefix and between the words of the directive's options,
+//   - writes the attributes of code directives in a canonical order and
+//     with their canonical names, and the tags of optional sorted,
+//   - leaves at most one blank line between the lines outside sections,
+//     and puts one before each directive that begins a slide, and
+//   - leaves the contents of sections, and of code, alone.
+//
+// The formatting of a Go file must not change its tokens; if it would,
+// because a blank line outside sections is in a raw string, say, the file
+// is reported and left alone.
+
+// slideStarts holds the directives that begin a slide.
+var slideStarts = map[string]bool{
+	"title":   true,
+	"divider": true,
+	"heading": true,
+	"slide":   true,
+	"toc":     true,
+}
+
+// fmtDirectives holds the directives, other than those that open and
+// close sections, that fmt formats, and whether their arguments are
+// options, whose spacing it normalizes, rather than text.
+var fmtDirectives = map[string]bool{
+	"title": false, "divider": false, "heading": false, "slide": false, "toc": false,
+	"author": false, "date": false, "event": false,
+	"subheading": false, "heading2": false, "line": false, "todo": false,
+	"image": false, "img": false, "caption": false, "include": true, "link": false, "html": false,
+	"label": true, "same-as": true, "hold": true, "exercise": true, "time": true,
+	"optional": true, "rename": true, "solution": true, "poll": true,
+	"cols": true, "nextcol": true, "!cols": true, "compare": true, "!compare": true,
+	"testfail": true, "livetest": true, "sequence": true,
+}
+
+// codeAttrOrder is the canonical order of code attributes.
+var codeAttrOrder = []codeAttr{
+	attrBad, attrWeak, attrSmall, attrSmaller, attrLarge, attrNoNumbers, attrAlign,
+	attrPlay, attrNoEscape, attrDiff, attrCritical, attrFit, attrScroll, attrSplit,
+}
+
+// fmtCommand formats slide sources.
+func fmtCommand(args []string) error {
+	fs := flag.NewFlagSet("fmt", flag.ExitOnError)
+	list := fs.Bool("l", false, "list the files whose formatting differs, instead of printing them")
+	write := fs.Bool("w", false, "write the formatted sources to the files, instead of printing them")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: code2slides fmt [-l] [-w] <file or dir>...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	files, err := slideFiles(fs.Args())
+	if err != nil {
+		return err
+	}
+	o, err := Config{}.options(context.Background())
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		out, err := formatSlides(o, file, src)
+		if err != nil {
+			return err
+		}
+		changed := !bytes.Equal(src, out)
+		if *list && changed {
+			fmt.Println(file)
+		}
+		if *write && changed {
+			if err := os.WriteFile(file, out, 0o644); err != nil {
+				return err
+			}
+		}
+		if !*list && !*write {
+			os.Stdout.Write(out)
+		}
+	}
+	return nil
+}
+
+// formatSlides returns src, the contents of the slide file filename, with
+// its directives formatted.
+func formatSlides(o *options, filename string, src []byte) ([]byte, error) {
+	prefix := commentPrefix(o, filename)
+	var (
+		out      []string
+		open     []string // the sections open, innermost last
+		inBlock  bool     // in a section opened with "/*"
+		blanks   bool     // blank lines outside sections since the last line
+		prevCode bool     // the last line outside sections was code or a directive
+	)
+	lines := strings.Split(strings.TrimRight(string(src), "\n"), "\n")
+	for _, orig := range lines {
+		if inBlock {
+			out = append(out, orig)
+			inBlock = !strings.Contains(orig, "*/")
+			continue
+		}
+		line := slashComments(orig, prefix)
+		trimmed := strings.TrimSpace(line)
+		top := ""
+		if len(open) > 0 {
+			top = open[len(open)-1]
+		}
+		if top == "" && trimmed == "" {
+			blanks = true
+			continue
+		}
+		first, rest, isComment := splitFirstWord(line)
+		if strings.HasPrefix(trimmed, "/*") {
+			// The block forms of sections are left as they are.
+			if blanks && len(out) > 0 {
+				out = append(out, "")
+			}
+			blanks, prevCode = false, true
+			out = append(out, orig)
+			inBlock = !strings.Contains(trimmed[2:], "*/")
+			continue
+		}
+		if top != "" {
+			prevCode = true
+			out = append(out, formatInSection(&open, orig, first, rest, isComment, prefix))
+			continue
+		}
+
+		// Outside sections.
+		_, known := fmtDirectives[first]
+		directive := isComment && (known || opensSection(first, rest))
+		if len(out) > 0 && (blanks || directive && slideStarts[first] && prevCode) {
+			out = append(out, "")
+		}
+		blanks = false
+		switch {
+		case !isComment:
+			out = append(out, orig)
+			prevCode = true
+		case directive:
+			out = append(out, formatDirective(orig, prefix, first, rest))
+			prevCode = true
+			if opensSection(first, rest) {
+				open = append(open, first)
+			}
+		default:
+			out = append(out, orig)
+			// A plain comment may introduce the slide after it, but the
+			// end of an if or a div is like the end of a section.
+			prevCode = strings.HasPrefix(first, "!") || strings.HasPrefix(first, "div.")
+		}
+	}
+	if len(open) > 0 || inBlock {
+		// A build would report the unclosed section; leave the file as it is.
+		return src, nil
+	}
+	result := []byte(strings.Join(out, "\n") + "\n")
+	if filepath.Ext(filename) == ".go" && !sameGoTokens(src, result) {
+		return nil, fmt.Errorf("%s: formatting would change the code; check the blank lines outside sections", filename)
+	}
+	return result, nil
+}
+
+// formatInSection formats orig, a line in the innermost section of open,
+// and updates open if the line closes a section or opens one within it.
+func formatInSection(open *[]string, orig, first, rest string, isComment bool, prefix string) string {
+	top := (*open)[len(*open)-1]
+	if !isComment {
+		return orig
+	}
+	if first == "!"+top {
+		*open = (*open)[:len(*open)-1]
+		return formatDirective(orig, prefix, first, rest)
+	}
+	switch {
+	case top == "code":
+		// Code and its directives, like em, are left as they are.
+		return orig
+	case top == "question" && (first == "hint" || first == "answer"):
+		return formatDirective(orig, prefix, first, rest)
+	case top == "question" && (first == "code" || first == "timeline") && opensSection(first, rest):
+		*open = append(*open, first)
+		return formatDirective(orig, prefix, first, rest)
+	}
+	return orig
+}
+
+// opensSection reports whether the directive first, with the words rest
+// after it, opens a section that a directive closes.
+func opensSection(first, rest string) bool {
+	_, after := cutClasses(rest)
+	switch first {
+	case "code", "question":
+		return true
+	case "text", "footnote":
+		return after == "" // not the inline form
+	case "output":
+		w, _, _ := strings.Cut(after, " ")
+		return w != "auto"
+	}
+	_, ok := simpleOpens[first]
+	return ok
+}
+
+// formatDirective formats orig, a directive line whose first word, with
+// "//" for the comment prefix, is first, followed by rest.
+func formatDirective(orig, prefix, first, rest string) string {
+	indent := orig[:len(orig)-len(strings.TrimLeft(orig, " \t"))]
+	classes, after := []string(nil), rest
+	if classDirectives[first] {
+		classes, after = cutClasses(rest)
+	}
+	words := strings.Fields(after)
+	switch {
+	case first == "code":
+		words = canonicalAttrs(words)
+	case first == "optional":
+		slices.Sort(words)
+		words = slices.Compact(words)
+	case !fmtDirectives[first] && !opensSection(first, rest) && !strings.HasPrefix(first, "!"):
+		// The arguments are text, like a heading, so only the space
+		// around them changes.
+		words = []string{strings.TrimSpace(after)}
+	}
+	parts := []string{prefix, first}
+	for _, c := range classes {
+		parts = append(parts, "."+c)
+	}
+	for _, w := range words {
+		if w != "" {
+			parts = append(parts, w)
+		}
+	}
+	return indent + strings.Join(parts, " ")
+}
+
+// canonicalAttrs returns the code attributes words, if they are valid, in
+// their canonical order and with their canonical names, without
+// duplicates. Invalid attributes are returned as they are, for the build
+// to report.
+func canonicalAttrs(words []string) []string {
+	if _, err := parseCodeAttrs(words); err != nil {
+		return words
+	}
+	var res []string
+	for _, a := range codeAttrOrder {
+		if slices.ContainsFunc(words, func(w string) bool { return codeAttrs[w] == a }) {
+			res = append(res, string(a))
+		}
+	}
+	return res
+}
+
+// sameGoTokens reports whether the Go sources a and b have the same
+// tokens, other than comments.
+func sameGoTokens(a, b []byte) bool {
+	toks := func(src []byte) []string {
+		var s scanner.Scanner
+		fset := token.NewFileSet()
+		s.Init(fset.AddFile("", -1, len(src)), src, nil, 0)
+		var ts []string
+		for {
+			_, tok, lit := s.Scan()
+			if tok == token.EOF {
+				return ts
+			}
+			ts = append(ts, tok.String()+" "+lit)
+		}
+	}
+	return slices.Equal(toks(a), toks(b))
+}
@@ -0,0 +1,373 @@
+package code2slides
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A freshness check looks for what a slide says about the standard library
+// that the Go release building the deck may have made stale: uses in code,
+// and mentions in text like `time.After`, of APIs that are deprecated, or
+// whose behavior changed after the release in the go line of the slides'
+// go.mod. Both come from the toolchain's own metadata: deprecations from
+// $GOROOT/api, and behavior changes from the GODEBUG history in
+// $GOROOT/doc/godebug.md, which links each change to the APIs it affects.
+
+// A stdChange is a deprecation of, or change in behavior of, an API of the
+// standard library.
+type stdChange struct {
+	version    string // the Go release that made the change, like "1.23"
+	deprecated bool
+	note       string // for behavior changes, the first sentence of their description
+}
+
+func (c stdChange) String() string {
+	if c.deprecated {
+		return "deprecated in Go " + c.version
+	}
+	return c.note
+}
+
+// freshFiles writes to w the stale references in files, and reports
+// whether there were none.
+func freshFiles(o *options, w io.Writer, files []string) (bool, error) {
+	goroot, err := exec.Command("go", "env", "GOROOT").Output()
+	if err != nil {
+		return false, fmt.Errorf("finding GOROOT: %w", err)
+	}
+	changes, err := readStdChanges(strings.TrimSpace(string(goroot)))
+	if err != nil {
+		return false, err
+	}
+	toolchain, err := goLang(strings.TrimPrefix(runtime.Version(), "go"))
+	if err != nil {
+		return false, err
+	}
+	probs, err := staleRefs(o, files, changes, toolchain)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range probs {
+		fmt.Fprintln(w, p)
+	}
+	return len(probs) == 0, nil
+}
+
+// apiDeprecatedRe matches a deprecation in a file of $GOROOT/api, like
+//
+//	pkg math/rand, func Seed //deprecated #56319
+//	pkg reflect, method (Value) Pointer() uintptr //deprecated #60001
+//
+// Lines for particular platforms have a parenthesized list after the path,
+// and don't match.
+var apiDeprecatedRe = regexp.MustCompile(`^pkg ([^ ,]+), (?:(?:func|type|var|const) (\w+)|method \(\*?(\w+)\) (\w+)).*//deprecated`)
+
+// godebugLinkRe matches a link to the documentation of an API in
+// godebug.md, like "[`Timer.Stop`](/pkg/time/#Timer.Stop)".
+var godebugLinkRe = regexp.MustCompile(`\]\(/pkg/([\w/.]+?)/?#([\w.]+)\)`)
+
+// mdLinkRe matches a markdown link, to replace with its text.
+var mdLinkRe = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+
+// readStdChanges returns the changes in the API metadata of the Go
+// installation in goroot, by the names of the APIs they affect, like
+// "time.After" or "sync.WaitGroup.Go", with the package's import path.
+func readStdChanges(goroot string) (map[string][]stdChange, error) {
+	changes := map[string][]stdChange{}
+	apis, err := filepath.Glob(filepath.Join(goroot, "api", "go1.*.txt"))
+	if err != nil {
+		return nil, err
+	}
+	for _, api := range apis {
+		version := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(api), "go"), ".txt")
+		data, err := os.ReadFile(api)
+		if err != nil {
+			return nil, err
+		}
+		for line := range strings.Lines(string(data)) {
+			m := apiDeprecatedRe.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			name := m[1] + "." + m[2]
+			if m[2] == "" {
+				name = m[1] + "." + m[3] + "." + m[4]
+			}
+			changes[name] = append(changes[name], stdChange{version: version, deprecated: true})
+		}
+	}
+
+	f, err := os.Open(filepath.Join(goroot, "doc", "godebug.md"))
+	if os.IsNotExist(err) {
+		return changes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	version := ""
+	var para []string
+	endPara := func() {
+		text := strings.Join(para, " ")
+		para = nil
+		if version == "" || !strings.HasPrefix(text, "Go "+version+" ") {
+			return
+		}
+		note := strings.ReplaceAll(mdLinkRe.ReplaceAllString(text, "$1"), "`", "")
+		if i := strings.Index(note, ". "); i >= 0 {
+			note = note[:i+1]
+		}
+		var seen []string
+		for _, m := range godebugLinkRe.FindAllStringSubmatch(text, -1) {
+			name := m[1] + "." + m[2]
+			if !slices.Contains(seen, name) {
+				seen = append(seen, name)
+				changes[name] = append(changes[name], stdChange{version: version, note: note})
+			}
+		}
+	}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if v, ok := strings.CutPrefix(line, "### Go "); ok {
+			endPara()
+			version = v
+			continue
+		}
+		if line == "" {
+			endPara()
+			continue
+		}
+		para = append(para, line)
+	}
+	endPara()
+	return changes, scanner.Err()
+}
+
+// staleRefs returns "FILE:LINE: HEADING: NAME: CHANGE" for each use or
+// mention in the slides of files of an API in changes that was deprecated
+// by the Go release toolchain, or that changed after the go line of the
+// slides' go.mod, up to toolchain.
+func staleRefs(o *options, files []string, changes map[string][]stdChange, toolchain string) ([]string, error) {
+	type ref struct {
+		file string
+		line int
+		name string // with the package's import path
+	}
+	var refs []ref
+	slidesOf := map[string][]*Slide{}
+	byDir := map[string][]string{}
+	for _, file := range files {
+		slides, err := scanFile(o, file)
+		if err != nil {
+			return nil, err
+		}
+		slidesOf[file] = slides
+		if filepath.Ext(file) == ".go" {
+			dir := filepath.Dir(file)
+			byDir[dir] = append(byDir[dir], file)
+		}
+	}
+
+	// Uses in code.
+	for _, dir := range slices.Sorted(maps.Keys(byDir)) {
+		uses, err := stdUses(byDir[dir])
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range uses {
+			refs = append(refs, ref{u.Filename, u.Line, u.name})
+		}
+	}
+
+	// Mentions in text, by package name.
+	paths := map[string][]string{} // by package name
+	for name := range changes {
+		pkgPath, _ := splitStdName(name)
+		base := path.Base(pkgPath)
+		if !slices.Contains(paths[base], pkgPath) {
+			paths[base] = append(paths[base], pkgPath)
+		}
+	}
+	for _, file := range files {
+		for _, s := range slidesOf[file] {
+			for _, sec := range s.sections {
+				if sec.kind == sectionCode {
+					continue
+				}
+				for _, m := range docRefRe.FindAllStringSubmatch(sec.content, -1) {
+					for _, p := range paths[m[1]] {
+						refs = append(refs, ref{file, sec.line, p + "." + m[2]})
+					}
+				}
+			}
+		}
+	}
+
+	var probs []string
+	seen := map[ref]bool{}
+	slices.SortStableFunc(refs, func(a, b ref) int {
+		return cmp.Or(cmp.Compare(slices.Index(files, a.file), slices.Index(files, b.file)), cmp.Compare(a.line, b.line), cmp.Compare(a.name, b.name))
+	})
+	modVersions := map[string]string{}
+	for _, r := range refs {
+		if seen[r] {
+			continue
+		}
+		seen[r] = true
+		dir := filepath.Dir(r.file)
+		since, ok := modVersions[dir]
+		if !ok {
+			since = moduleGoVersion(dir)
+			modVersions[dir] = since
+		}
+		for _, c := range changes[r.name] {
+			if !versionAfter(toolchain, c.version) && toolchain != c.version {
+				continue // from a later release than the one building the deck
+			}
+			if !c.deprecated && since != "" && !versionAfter(c.version, since) {
+				continue // the slides were written for the changed behavior
+			}
+			pkgPath, name := splitStdName(r.name)
+			probs = append(probs, fmt.Sprintf("%s:%d: %s: %s.%s: %s", r.file, r.line, headingAt(slidesOf[r.file], r.line), path.Base(pkgPath), name, c))
+		}
+	}
+	return probs, nil
+}
+
+// splitStdName splits the name of an API, like "math/rand.Rand.Seed", into
+// the import path of its package and the name in it.
+func splitStdName(name string) (pkgPath, rest string) {
+	slash := strings.LastIndexByte(name, '/') + 1
+	dot := strings.IndexByte(name[slash:], '.')
+	return name[:slash+dot], name[slash+dot+1:]
+}
+
+// A stdUse is a use of an API of the standard library in code.
+type stdUse struct {
+	token.Position
+	name string // like "time.After" or "sync.WaitGroup.Go", with the import path
+}
+
+// stdUses type-checks files, which make up a package, and returns their
+// uses of the exported APIs of the standard library. Type errors, like
+// those from imports that can't be found, are ignored, since only
+// standard packages matter.
+func stdUses(files []string) ([]stdUse, error) {
+	fset := token.NewFileSet()
+	var astFiles []*ast.File
+	for _, f := range files {
+		af, err := parser.ParseFile(fset, f, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		astFiles = append(astFiles, af)
+	}
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error:    func(error) {},
+	}
+	info := &types.Info{Uses: map[*ast.Ident]types.Object{}}
+	conf.Check("", fset, astFiles, info)
+	var uses []stdUse
+	for id, obj := range info.Uses {
+		if obj.Pkg() == nil || !obj.Exported() || !isStdPath(obj.Pkg().Path()) {
+			continue
+		}
+		name := obj.Name()
+		if fn, ok := obj.(*types.Func); ok {
+			if recv := fn.Signature().Recv(); recv != nil {
+				t := recv.Type()
+				if p, ok := t.(*types.Pointer); ok {
+					t = p.Elem()
+				}
+				named, ok := t.(*types.Named)
+				if !ok {
+					continue // an interface method
+				}
+				name = named.Obj().Name() + "." + name
+			}
+		} else if v, ok := obj.(*types.Var); ok && v.IsField() {
+			continue
+		}
+		uses = append(uses, stdUse{fset.Position(id.Pos()), obj.Pkg().Path() + "." + name})
+	}
+	return uses, nil
+}
+
+// isStdPath reports whether the import path is that of a standard package,
+// which has no dot in its first element.
+func isStdPath(p string) bool {
+	first, _, _ := strings.Cut(p, "/")
+	return !strings.Contains(first, ".")
+}
+
+// headingAt returns the heading of the slide of slides, from one file, with
+// the last section that begins at or before line.
+func headingAt(slides []*Slide, line int) string {
+	heading, best := "", 0
+	for _, s := range slides {
+		for _, sec := range s.sections {
+			if sec.line <= line && sec.line >= best {
+				heading, best = s.heading, sec.line
+			}
+		}
+	}
+	if heading == "" && len(slides) > 0 {
+		heading = slides[0].heading
+	}
+	return heading
+}
+
+// moduleGoVersion returns the language version of the go line of the
+// go.mod of the module containing dir, or "" if there is none.
+func moduleGoVersion(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+		if err == nil {
+			for line := range strings.Lines(string(data)) {
+				if v, ok := strings.CutPrefix(strings.TrimSpace(line), "go "); ok {
+					lang, _ := goLang(strings.TrimSpace(v))
+					return lang
+				}
+			}
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// versionAfter reports whether the Go language version a, like "1.23", is
+// later than b.
+func versionAfter(a, b string) bool {
+	minor := func(v string) int {
+		n, _ := strconv.Atoi(strings.TrimPrefix(v, "1."))
+		return n
+	}
+	return minor(a) > minor(b)
+}
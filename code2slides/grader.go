@@ -0,0 +1,212 @@
+package code2slides
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// A grader lets training partners grade students' exercises on their own
+// machines. It holds the tests of each exercise, and the build context of
+// an image with a pinned Go toolchain. "code2slides grade" runs each
+// exercise's tests, with the race detector, against the student's copy of
+// the exercise, in the sandbox: with -sandbox docker and the grader image,
+// it needs no network.
+
+// graderCommand writes the build context of a grader image, and builds
+// the image if -image is set.
+func graderCommand(args []string) error {
+	fs := flag.NewFlagSet("grader", flag.ExitOnError)
+	out := fs.String("o", "grader", "write the image's build context to `dir`")
+	image := fs.String("image", "", "build the image with docker and tag it `name`")
+	goVersion := fs.String("go", strings.TrimPrefix(runtime.Version(), "go"), "pin the image to Go `version`")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: code2slides grader [flags] <exercises dir>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if err := writeGrader(*out, fs.Arg(0), *goVersion); err != nil {
+		return err
+	}
+	if *image == "" {
+		fmt.Printf("wrote %s; build it with \"docker build -t NAME %[1]s\"\n", *out)
+		return nil
+	}
+	cmd := exec.Command("docker", "build", "-t", *image, *out)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// writeGrader writes to dir the build context of a grader image for the
+// exercises in exercisesDir, using Go goVersion. The tests of an exercise
+// are the tests of its solution, which pass against it. They are in a
+// module laid out as in the students' workspace.
+func writeGrader(dir, exercisesDir, goVersion string) error {
+	lang, err := goLang(goVersion)
+	if err != nil {
+		return err
+	}
+	exs, err := exercises(exercisesDir)
+	if err != nil {
+		return err
+	}
+	// Don't leave the tests of exercises that no longer exist.
+	testsDir := filepath.Join(dir, "tests")
+	if err := os.RemoveAll(testsDir); err != nil {
+		return err
+	}
+	n := 0
+	for _, ex := range exs {
+		tests, err := filepath.Glob(filepath.Join(exercisesDir, ex, "solution", "*_test.go"))
+		if err != nil {
+			return err
+		}
+		if len(tests) == 0 {
+			continue
+		}
+		if err := copyExercise(filepath.Join(testsDir, ex), ex, lang, tests); err != nil {
+			return err
+		}
+		n++
+	}
+	if n == 0 {
+		return fmt.Errorf("no exercise in %s has a solution with tests", exercisesDir)
+	}
+	dockerfile := fmt.Sprintf(graderDockerfile, goVersion)
+	return os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte(dockerfile), 0o644)
+}
+
+const graderDockerfile = `# The toolchain for grading exercises. Grade those in the current directory
+# with
+#
+#   code2slides grade -sandbox docker -sandbox-image IMAGE GRADER .
+#
+# where GRADER is the directory of this file.
+FROM golang:%s
+ENV GOTOOLCHAIN=local GOPROXY=off GOWORK=off GOFLAGS=-mod=readonly CGO_ENABLED=1
+# Build the standard library for the race detector now, so grading
+# doesn't have to.
+RUN go build -race std
+`
+
+// gradeCommand grades a student's exercises with the tests written by
+// graderCommand.
+func gradeCommand(args []string) error {
+	fs := flag.NewFlagSet("grade", flag.ExitOnError)
+	// Tests run with the race detector, for every exercise.
+	cfg := Config{ExecTimeout: 10 * time.Minute}
+	cfg.SandboxFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: code2slides grade [flags] <grader dir> <submission dir>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	e, err := newExecutor(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	failed, err := grade(ctx, e, os.Stdout, fs.Arg(0), fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d exercises failed", failed)
+	}
+	return nil
+}
+
+// grade runs the tests of each exercise in graderDir against the
+// student's code for it in submission, with the race detector, with e. For each exercise, it copies the student's code, but not their
+// tests, next to the exercise's tests. It writes PASS or FAIL for each
+// exercise to w, with the output of failing tests, and returns the number
+// that failed.
+func grade(ctx context.Context, e *executor, w io.Writer, graderDir, submission string) (failed int, err error) {
+	testsDir := filepath.Join(graderDir, "tests")
+	entries, err := os.ReadDir(testsDir)
+	if err != nil {
+		return 0, err
+	}
+	for _, ent := range entries {
+		if !ent.IsDir() {
+			continue
+		}
+		ex := ent.Name()
+		ok, out, err := gradeExercise(ctx, e, filepath.Join(testsDir, ex), filepath.Join(submission, ex))
+		if err != nil {
+			return failed, fmt.Errorf("grading %s: %w", ex, err)
+		}
+		if ok {
+			fmt.Fprintf(w, "PASS %s\n", ex)
+			continue
+		}
+		failed++
+		fmt.Fprintf(w, "FAIL %s\n", ex)
+		for line := range strings.Lines(out) {
+			fmt.Fprintf(w, "    %s", line)
+		}
+	}
+	return failed, nil
+}
+
+// gradeExercise runs the tests in testsDir against the code in codeDir,
+// and returns whether they passed and their output.
+func gradeExercise(ctx context.Context, e *executor, testsDir, codeDir string) (bool, string, error) {
+	work, err := os.MkdirTemp("", "code2slides-grade-")
+	if err != nil {
+		return false, "", err
+	}
+	defer os.RemoveAll(work)
+	if err := os.CopyFS(work, os.DirFS(testsDir)); err != nil {
+		return false, "", err
+	}
+	files, err := filepath.Glob(filepath.Join(codeDir, "*.go"))
+	if err != nil {
+		return false, "", err
+	}
+	for _, f := range files {
+		if strings.HasSuffix(f, "_test.go") {
+			continue
+		}
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return false, "", err
+		}
+		if err := os.WriteFile(filepath.Join(work, filepath.Base(f)), data, 0o644); err != nil {
+			return false, "", err
+		}
+	}
+	var out bytes.Buffer
+	code, note, err := e.run(ctx, execRequest{
+		dir:    work,
+		args:   []string{"test", "-race", "-count=1", "."},
+		stdout: &out,
+		stderr: &out,
+	})
+	if err != nil {
+		return false, "", err
+	}
+	out.WriteString(note)
+	// Name files as the student knows them.
+	output := strings.ReplaceAll(out.String(), work+string(filepath.Separator), "")
+	return code == 0 && note == "", output, nil
+}
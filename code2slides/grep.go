@@ -0,0 +1,135 @@
+package code2slides
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// grepCommand searches the headings and sections of slides.
+func grepCommand(args []string) error {
+	fset := flag.NewFlagSet("grep", flag.ExitOnError)
+	kind := fset.String("kind", "", "search only sections of `kind`, like code or note, or headings")
+	ignoreCase := fset.Bool("i", false, "ignore case")
+	manifest := fset.String("manifest", "", "search the deck's files listed in `file`")
+	fset.Usage = func() {
+		fmt.Fprintln(fset.Output(), "usage: code2slides grep [flags] <pattern> [<file or dir>...]")
+		fset.PrintDefaults()
+	}
+	fset.Parse(args)
+	if fset.NArg() < 1 || fset.NArg() == 1 && *manifest == "" {
+		fset.Usage()
+		os.Exit(2)
+	}
+	pat := fset.Arg(0)
+	if *ignoreCase {
+		pat = "(?i)" + pat
+	}
+	re, err := regexp.Compile(pat)
+	if err != nil {
+		return err
+	}
+	var files []string
+	if *manifest != "" {
+		parts, err := readManifest(*manifest)
+		if err != nil {
+			return err
+		}
+		for _, p := range parts {
+			files = append(files, p.files...)
+		}
+	}
+	more, err := slideFiles(fset.Args()[1:])
+	if err != nil {
+		return err
+	}
+	files = append(files, more...)
+	o, err := Config{}.options(context.Background())
+	if err != nil {
+		return err
+	}
+	n, err := grepSlides(o, os.Stdout, re, *kind, files)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// slideFiles returns the files named by args, replacing each directory
+// with the Go files in its tree, other than tests.
+func slideFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() && d.Name() == "testdata" {
+				return filepath.SkipDir
+			}
+			if !d.IsDir() && strings.HasSuffix(path, ".go") && !strings.HasSuffix(path, "_test.go") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// grepSlides writes to w the headings and lines of sections in files
+// that match re, and returns the number of matches. If kind isn't empty,
+// it searches only sections of that kind, or only headings if kind is
+// "heading". Each match is written as
+//
+//	FILE:LINE: HEADING [KIND]: TEXT
+//
+// where LINE is the line where the section begins.
+func grepSlides(o *options, w io.Writer, re *regexp.Regexp, kind string, files []string) (int, error) {
+	n := 0
+	for _, file := range files {
+		unMark := strings.NewReplacer(stepMark+"\n", "", ellipsisMark, commentPrefix(o, file)+" ...")
+		slides, err := scanFile(o, file)
+		if err != nil {
+			return n, err
+		}
+		for _, s := range slides {
+			if (kind == "" || kind == "heading") && re.MatchString(s.heading) {
+				fmt.Fprintf(w, "%s: %s [heading]\n", file, s.heading)
+				n++
+			}
+			for _, sec := range s.sections {
+				if kind != "" && kind != sec.kind.String() {
+					continue
+				}
+				for line := range strings.Lines(unMark.Replace(stripCallouts(stripEm(sec.content)))) {
+					line = strings.TrimSpace(line)
+					if !isMarkLine(line) && re.MatchString(line) {
+						fmt.Fprintf(w, "%s:%d: %s [%s]: %s\n", file, sec.line, s.heading, sec.kind, line)
+						n++
+					}
+				}
+			}
+		}
+	}
+	return n, nil
+}
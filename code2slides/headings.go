@@ -0,0 +1,231 @@
+package code2slides
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Slides generated from code that can't be edited, like a third-party
+// codebase, can't have heading directives. A headings file, named with
+// -headings, gives them headings and subtitles from outside, in a small
+// subset of YAML:
+//
+//	# Comments and blank lines are ignored.
+//	10-intro.go: Introduction
+//	20-worker.go:
+//	  heading: The worker pool
+//	  subtitle: from the scheduler package
+//	  slides:
+//	    - Starting workers
+//	    - Stopping them
+//
+// Each key is a slide file, relative to the headings file, or a file of a
+// module named as in a manifest, like "golang.org/x/sync@v0.10.0//errgroup/errgroup.go".
+// A plain value is the heading of the file's first slide, as is the
+// heading key. The subtitle key adds a subtitle to the first slide, and
+// slides lists the headings of the file's slides in order; "~" keeps a
+// slide's own heading. Headings in the file override those in the sources.
+// Values can be quoted with double or single quotes, as in YAML.
+
+// A fileHeadings is the entry for one slide file in a headings file.
+type fileHeadings struct {
+	heading  string
+	subtitle string
+	slides   []string // "" keeps the heading
+}
+
+// fallbackHeading returns the heading for the first slide of filename, if
+// it has no heading of its own.
+func fallbackHeading(o *options, filename string) string {
+	base := filepath.Base(filename)
+	if o.headingFallback != "name" {
+		return base
+	}
+	name := strings.TrimSuffix(base, filepath.Ext(base))
+	name = strings.TrimLeft(name, "0123456789")
+	name = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' {
+			return ' '
+		}
+		return r
+	}, name))
+	if name == "" {
+		return base
+	}
+	r, n := utf8.DecodeRuneInString(name)
+	return string(unicode.ToUpper(r)) + name[n:]
+}
+
+// headingsKeyRe matches a key of a headings file, and what follows it.
+var headingsKeyRe = regexp.MustCompile(`^(\S(?:.*\S)?):(?:\s+(.*))?$`)
+
+// readHeadings reads a headings file.
+func readHeadings(filename string) (_ map[string]*fileHeadings, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dir := filepath.Dir(filename)
+	hs := map[string]*fileHeadings{}
+	var (
+		cur      *fileHeadings // the file's entry
+		inSlides bool          // in its list of slides
+		lineNum  int
+	)
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("%s:%d: %v", filename, lineNum, err)
+		}
+	}()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lineNum++
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		indented := line != raw && (raw[0] == ' ' || raw[0] == '\t')
+		if !indented {
+			m := headingsKeyRe.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("want FILE: HEADING, not %q", line)
+			}
+			file := filepath.Join(dir, m[1])
+			if modPath, version, pat, ok := splitRemote(m[1]); ok {
+				modDir, err := moduleDir(modPath, version)
+				if err != nil {
+					return nil, err
+				}
+				file = filepath.Join(modDir, pat)
+			}
+			path, err := filepath.Abs(file)
+			if err != nil {
+				return nil, err
+			}
+			if hs[path] != nil {
+				return nil, fmt.Errorf("duplicate entry for %s", m[1])
+			}
+			cur, inSlides = &fileHeadings{}, false
+			hs[path] = cur
+			if m[2] != "" {
+				if cur.heading, err = yamlScalar(m[2]); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		if cur == nil {
+			return nil, fmt.Errorf("indented line %q before a file", line)
+		}
+		if item, ok := strings.CutPrefix(line, "-"); ok && inSlides && (item == "" || item[0] == ' ') {
+			h, err := yamlScalar(strings.TrimSpace(item))
+			if err != nil {
+				return nil, err
+			}
+			if h == "~" {
+				h = ""
+			}
+			cur.slides = append(cur.slides, h)
+			continue
+		}
+		m := headingsKeyRe.FindStringSubmatch(line)
+		if m == nil {
+			return nil, fmt.Errorf("want heading, subtitle or slides, not %q", line)
+		}
+		val, err := yamlScalar(m[2])
+		if err != nil {
+			return nil, err
+		}
+		inSlides = false
+		switch m[1] {
+		case "heading":
+			cur.heading = val
+		case "subtitle":
+			cur.subtitle = val
+		case "slides":
+			if val != "" {
+				return nil, fmt.Errorf("slides is a list of headings, one per line beginning with -")
+			}
+			inSlides = true
+		default:
+			return nil, fmt.Errorf("want heading, subtitle or slides, not %q", m[1])
+		}
+	}
+	return hs, scanner.Err()
+}
+
+// yamlScalar returns the value of s, a YAML scalar that may be quoted and
+// may be followed by a comment.
+func yamlScalar(s string) (string, error) {
+	var val, rest string
+	switch {
+	case strings.HasPrefix(s, `"`):
+		q, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			return "", fmt.Errorf("bad quoted string %s", s)
+		}
+		val, _ = strconv.Unquote(q)
+		rest = s[len(q):]
+	case strings.HasPrefix(s, "'"):
+		// A quote is doubled to escape it.
+		i := 1
+		for {
+			j := strings.IndexByte(s[i:], '\'')
+			if j < 0 {
+				return "", fmt.Errorf("bad quoted string %s", s)
+			}
+			i += j + 1
+			if !strings.HasPrefix(s[i:], "'") {
+				break
+			}
+			i++
+		}
+		val = strings.ReplaceAll(s[1:i-1], "''", "'")
+		rest = s[i:]
+	default:
+		val, _, _ = strings.Cut(s, " #")
+		return strings.TrimSpace(val), nil
+	}
+	if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", fmt.Errorf("unexpected %q after quoted string", rest)
+	}
+	return val, nil
+}
+
+// applyHeadings applies the entry of the headings file, if any, for
+// filename to its slides.
+func applyHeadings(o *options, filename string, slides []*Slide) error {
+	path, err := filepath.Abs(filename)
+	if err != nil {
+		return err
+	}
+	h := o.headingOverrides[path]
+	if h == nil || len(slides) == 0 {
+		return nil
+	}
+	if len(h.slides) > len(slides) {
+		return fmt.Errorf("headings file lists %d slides, but the file has %d", len(h.slides), len(slides))
+	}
+	if h.heading != "" {
+		slides[0].heading = h.heading
+	}
+	for i, heading := range h.slides {
+		if heading != "" {
+			slides[i].heading = heading
+		}
+	}
+	if h.subtitle != "" {
+		sub := section{kind: sectionSubtitle, content: h.subtitle + "\n", line: 1}
+		slides[0].sections = append([]section{sub}, slides[0].sections...)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package code2slides
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// The HTML of a slide, from html directives and the like, goes into the
+// deck as written, so a stray unclosed <div> would swallow every slide
+// after it. Each slide's HTML must have balanced tags, and -lint checks
+// that the classes it uses are defined by the deck's stylesheets.
+
+// tagRe matches an HTML start or end tag, or a comment.
+var tagRe = regexp.MustCompile(`<!--[\s\S]*?-->|<(/?)([A-Za-z][A-Za-z0-9-]*)((?:\s[^<>]*)?)>`)
+
+// voidElements are the HTML elements that have no end tag.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"source": true, "track": true, "wbr": true,
+}
+
+// checkSlideHTML checks that the tags of the HTML sections of s balance.
+// If they don't, it returns the line of the section at fault and an error.
+func checkSlideHTML(s *Slide) (int, error) {
+	type openTag struct {
+		name string
+		line int
+	}
+	var open []openTag
+	for _, sec := range s.sections {
+		if sec.kind != sectionHTML {
+			continue
+		}
+		for _, m := range tagRe.FindAllStringSubmatch(sec.content, -1) {
+			name := strings.ToLower(m[2])
+			switch {
+			case name == "" || voidElements[name]:
+				// A comment or an element without an end tag.
+			case m[1] == "":
+				if !strings.HasSuffix(m[3], "/") {
+					open = append(open, openTag{name, sec.line})
+				}
+			case len(open) == 0:
+				return sec.line, fmt.Errorf("html: </%s> without matching <%s>", name, name)
+			case open[len(open)-1].name != name:
+				return sec.line, fmt.Errorf("html: </%s> closes <%s>", name, open[len(open)-1].name)
+			default:
+				open = open[:len(open)-1]
+			}
+		}
+	}
+	if len(open) > 0 {
+		t := open[len(open)-1]
+		return t.line, fmt.Errorf("html: <%s> is not closed on its slide", t.name)
+	}
+	return 0, nil
+}
+
+var (
+	// classAttrRe matches a class attribute of an HTML tag.
+	classAttrRe = regexp.MustCompile(`\bclass\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	// cssClassRe matches a class in a CSS selector.
+	cssClassRe = regexp.MustCompile(`\.([A-Za-z_][\w-]*)`)
+	// htmlCommentRe matches an HTML comment.
+	htmlCommentRe = regexp.MustCompile(`<!--[\s\S]*?-->`)
+	// cssCommentRe matches a CSS comment.
+	cssCommentRe = regexp.MustCompile(`/\*[\s\S]*?\*/`)
+)
+
+// stylesheetClasses returns the classes in the selectors of styles.css in
+// staticDir, and of the -theme stylesheet if it is a file, or nil if
+// styles.css can't be read.
+func stylesheetClasses(o *options, staticDir string) map[string]bool {
+	css, err := os.ReadFile(filepath.Join(staticDir, "styles.css"))
+	if err != nil {
+		return nil
+	}
+	if o.themeURL != "" && !strings.Contains(o.themeURL, "://") {
+		// The theme is relative to the deck, and so is static/.
+		if theme, err := os.ReadFile(filepath.Join(filepath.Dir(staticDir), o.themeURL)); err == nil {
+			css = append(css, theme...)
+		}
+	}
+	classes := map[string]bool{}
+	for _, m := range cssClassRe.FindAllStringSubmatch(cssCommentRe.ReplaceAllString(string(css), ""), -1) {
+		classes[m[1]] = true
+	}
+	return classes
+}
+
+// lintHTMLClasses returns "FILE:LINE: MESSAGE" for each class used by the
+// HTML sections of slides that the stylesheets in staticDir, or "static"
+// if it is empty, don't define. It returns nothing if they can't be read.
+func lintHTMLClasses(o *options, slides []*Slide, staticDir string) []string {
+	known := stylesheetClasses(o, cmp.Or(staticDir, "static"))
+	if known == nil {
+		return nil
+	}
+	var probs []string
+	for _, s := range slides {
+		for _, sec := range s.sections {
+			if sec.kind != sectionHTML {
+				continue
+			}
+			var unknown []string
+			for _, m := range classAttrRe.FindAllStringSubmatch(htmlCommentRe.ReplaceAllString(sec.content, ""), -1) {
+				for c := range strings.FieldsSeq(m[1] + m[2] + m[3]) {
+					if !known[c] && !slices.Contains(unknown, c) {
+						unknown = append(unknown, c)
+					}
+				}
+			}
+			for _, c := range unknown {
+				probs = append(probs, fmt.Sprintf("%s:%d: html: class %q is not in the stylesheets", s.filename, sec.line, c))
+			}
+		}
+	}
+	return probs
+}
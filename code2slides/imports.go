@@ -0,0 +1,195 @@
+package code2slides
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Slide sources are edited for what the slides show, and an edit that
+// removes the last use of a package leaves an import that breaks the
+// build of the slides' package. The build checks for such imports, and
+// -fix-imports removes them.
+
+// An unusedImport is an import that its file doesn't use.
+type unusedImport struct {
+	file string
+	line int
+	path string
+	spec *ast.ImportSpec
+}
+
+func (u unusedImport) String() string {
+	return fmt.Sprintf("%s:%d: %q imported and not used", u.file, u.line, u.path)
+}
+
+// checkImports returns an error listing the unused imports of the Go
+// files among files.
+func checkImports(files []string) error {
+	var unused []string
+	for _, file := range files {
+		_, _, us, err := unusedImports(file)
+		if err != nil {
+			return err
+		}
+		for _, u := range us {
+			unused = append(unused, u.String())
+		}
+	}
+	if len(unused) > 0 {
+		return fmt.Errorf("unused imports (remove them with -fix-imports):\n%s", strings.Join(unused, "\n"))
+	}
+	return nil
+}
+
+// fixImports removes the unused imports of the Go files among files,
+// and lists them on w.
+func fixImports(w io.Writer, files []string) error {
+	for _, file := range files {
+		fset, f, unused, err := unusedImports(file)
+		if err != nil {
+			return err
+		}
+		if len(unused) == 0 {
+			continue
+		}
+		for _, u := range unused {
+			fmt.Fprintf(w, "%s: removed\n", u)
+		}
+		src, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		src = removeImports(fset, f, src, unused)
+		if err := os.WriteFile(file, src, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unusedImports parses file and returns its unused imports. It ignores
+// files that aren't Go, or don't parse, because those can't be built
+// anyway.
+func unusedImports(file string) (*token.FileSet, *ast.File, []unusedImport, error) {
+	if !strings.HasSuffix(file, ".go") {
+		return nil, nil, nil, nil
+	}
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, src, parser.ParseComments)
+	if err != nil {
+		return nil, nil, nil, nil
+	}
+	used := map[string]bool{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok {
+				used[id.Name] = true
+			}
+		}
+		return true
+	})
+	var unused []unusedImport
+	for _, spec := range f.Imports {
+		p, err := strconv.Unquote(spec.Path.Value)
+		if err != nil || p == "C" {
+			continue
+		}
+		name := importName(p)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		if name == "_" || name == "." || used[name] {
+			continue
+		}
+		unused = append(unused, unusedImport{file, fset.Position(spec.Pos()).Line, p, spec})
+	}
+	return fset, f, unused, nil
+}
+
+// majorVersionRe matches the major version element of an import path.
+var majorVersionRe = regexp.MustCompile(`^v[0-9]+$`)
+
+// importName returns the name that a package with import path p is
+// usually declared with: its last element, without a major version or
+// a "go-" prefix.
+func importName(p string) string {
+	dir, name := path.Split(p)
+	if majorVersionRe.MatchString(name) && dir != "" {
+		name = path.Base(dir)
+	}
+	name = strings.TrimPrefix(name, "go-")
+	if i := strings.IndexAny(name, ".-"); i > 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// removeImports returns src, the source of f, without the unused imports.
+// It removes the lines of each import, with its comments, or of the whole
+// declaration and the blank line after it if none of its imports are used.
+// It edits the text rather than printing f, because printing would reformat
+// comments like the separator lines between slides.
+func removeImports(fset *token.FileSet, f *ast.File, src []byte, unused []unusedImport) []byte {
+	isUnused := func(s ast.Spec) bool {
+		return slices.ContainsFunc(unused, func(u unusedImport) bool { return u.spec == s })
+	}
+	var cuts [][2]int // byte ranges of whole lines
+	lines := func(start, end token.Pos) [2]int {
+		lo := bytes.LastIndexByte(src[:fset.Position(start).Offset], '\n') + 1
+		hi := len(src)
+		if i := bytes.IndexByte(src[fset.Position(end).Offset:], '\n'); i >= 0 {
+			hi = fset.Position(end).Offset + i + 1
+		}
+		return [2]int{lo, hi}
+	}
+	for _, d := range f.Decls {
+		gd, ok := d.(*ast.GenDecl)
+		if !ok || gd.Tok != token.IMPORT {
+			continue
+		}
+		if !slices.ContainsFunc(gd.Specs, isUnused) {
+			continue
+		}
+		if !slices.ContainsFunc(gd.Specs, func(s ast.Spec) bool { return !isUnused(s) }) {
+			c := lines(gd.Pos(), gd.End())
+			if c[1] < len(src) && src[c[1]] == '\n' {
+				c[1]++
+			}
+			cuts = append(cuts, c)
+			continue
+		}
+		for _, s := range gd.Specs {
+			if !isUnused(s) {
+				continue
+			}
+			is := s.(*ast.ImportSpec)
+			start, end := is.Pos(), is.End()
+			if is.Doc != nil {
+				start = is.Doc.Pos()
+			}
+			if is.Comment != nil {
+				end = is.Comment.End()
+			}
+			cuts = append(cuts, lines(start, end))
+		}
+	}
+	slices.SortFunc(cuts, func(a, b [2]int) int { return b[0] - a[0] })
+	for _, c := range cuts {
+		src = slices.Delete(src, c[0], c[1])
+	}
+	return src
+}
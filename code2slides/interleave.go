@@ -0,0 +1,216 @@
+package code2slides
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A stepper section runs a tiny program across goroutines, one statement
+// at a time in the order it lists them, and shows the program and its
+// state at each step:
+//
+//	// stepper c=0
+//	// G1 R0 = c
+//	// G2 R0 = c
+//	// G1 R0++
+//	// G2 R0++
+//	// G1 c = R0
+//	// G2 c = R0
+//	// !stepper
+//
+// Each line names a goroutine and the statement it executes next. The
+// statements are as simple as machine instructions: "X = Y", "X = Y + Z",
+// "X = Y - Z", "X++" and "X--", where Y and Z are variables or integers.
+// Registers, named R0, R1 and so on, belong to their goroutine; other
+// variables are shared. Variables start at zero, or at the values given
+// after the directive.
+
+// registerRe matches the name of a goroutine-local register.
+var registerRe = regexp.MustCompile(`^R\d+$`)
+
+var (
+	assignRe     = regexp.MustCompile(`^(\w+)\s*=\s*(\w+)(?:\s*([-+])\s*(\w+))?$`)
+	incDecRe     = regexp.MustCompile(`^(\w+)(\+\+|--)$`)
+	stepperVarRe = regexp.MustCompile(`^[A-Za-z_]\w*$`)
+)
+
+// An interleaving is a run of a stepper program.
+type interleaving struct {
+	Goroutines []stepperGoroutine `json:"goroutines"`
+	Shared     []string           `json:"shared"` // shared variables, in order of appearance
+	Steps      []stepperState     `json:"steps"`  // the state before the first step, and after each
+}
+
+// A stepperGoroutine is one goroutine of a stepper program.
+type stepperGoroutine struct {
+	Name      string   `json:"name"`
+	Stmts     []string `json:"stmts"`     // in program order
+	Registers []string `json:"registers"` // in order of appearance
+}
+
+// A stepperState is the state of a stepper program between steps.
+type stepperState struct {
+	G         int              `json:"g"`    // goroutine of the last step, or -1
+	Stmt      int              `json:"stmt"` // its statement's index in Stmts
+	Shared    map[string]int   `json:"shared"`
+	Registers []map[string]int `json:"registers"` // by goroutine
+}
+
+// interleave runs the stepper program in content, with the initial values
+// of shared variables in options, and returns the run.
+func interleave(options []string, content string) (*interleaving, error) {
+	var il interleaving
+	shared := map[string]int{}
+	var regs []map[string]int
+	gIndex := map[string]int{}
+	addShared := func(v string) {
+		if _, ok := shared[v]; !ok {
+			shared[v] = 0
+			il.Shared = append(il.Shared, v)
+		}
+	}
+	for _, opt := range options {
+		name, val, ok := strings.Cut(opt, "=")
+		n, err := strconv.Atoi(val)
+		if !ok || err != nil || !stepperVarRe.MatchString(name) || registerRe.MatchString(name) {
+			return nil, fmt.Errorf("stepper: bad initial value %q: want VAR=INT", opt)
+		}
+		addShared(name)
+		shared[name] = n
+	}
+	snapshot := func(g, stmt int) {
+		st := stepperState{G: g, Stmt: stmt, Shared: map[string]int{}}
+		for k, v := range shared {
+			st.Shared[k] = v
+		}
+		for _, r := range regs {
+			m := map[string]int{}
+			for k, v := range r {
+				m[k] = v
+			}
+			st.Registers = append(st.Registers, m)
+		}
+		il.Steps = append(il.Steps, st)
+	}
+	// The initial state can't be taken until the goroutines are known.
+	type step struct{ g, stmt int }
+	var steps []step
+	for line := range strings.Lines(content) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		name, stmt, _ := strings.Cut(line, " ")
+		stmt = strings.TrimSpace(stmt)
+		if stmt == "" {
+			return nil, fmt.Errorf("stepper: %q: want GOROUTINE STATEMENT", line)
+		}
+		g, ok := gIndex[name]
+		if !ok {
+			g = len(il.Goroutines)
+			gIndex[name] = g
+			il.Goroutines = append(il.Goroutines, stepperGoroutine{Name: name})
+			regs = append(regs, map[string]int{})
+		}
+		vars, err := stmtVars(stmt)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range vars {
+			if !registerRe.MatchString(v) {
+				addShared(v)
+			} else if _, ok := regs[g][v]; !ok {
+				regs[g][v] = 0
+				il.Goroutines[g].Registers = append(il.Goroutines[g].Registers, v)
+			}
+		}
+		gr := &il.Goroutines[g]
+		gr.Stmts = append(gr.Stmts, stmt)
+		steps = append(steps, step{g, len(gr.Stmts) - 1})
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("stepper: no statements")
+	}
+	snapshot(-1, -1)
+	for _, s := range steps {
+		vars := regs[s.g]
+		get := func(x string) int {
+			if n, err := strconv.Atoi(x); err == nil {
+				return n
+			}
+			if registerRe.MatchString(x) {
+				return vars[x]
+			}
+			return shared[x]
+		}
+		set := func(x string, n int) {
+			if registerRe.MatchString(x) {
+				vars[x] = n
+			} else {
+				shared[x] = n
+			}
+		}
+		stmt := il.Goroutines[s.g].Stmts[s.stmt]
+		if m := incDecRe.FindStringSubmatch(stmt); m != nil {
+			if m[2] == "++" {
+				set(m[1], get(m[1])+1)
+			} else {
+				set(m[1], get(m[1])-1)
+			}
+		} else {
+			m := assignRe.FindStringSubmatch(stmt)
+			n := get(m[2])
+			switch m[3] {
+			case "+":
+				n += get(m[4])
+			case "-":
+				n -= get(m[4])
+			}
+			set(m[1], n)
+		}
+		snapshot(s.g, s.stmt)
+	}
+	return &il, nil
+}
+
+// stmtVars returns the variables of stmt, a statement of a stepper
+// program, or an error if it isn't one.
+func stmtVars(stmt string) ([]string, error) {
+	var operands []string
+	if m := incDecRe.FindStringSubmatch(stmt); m != nil {
+		operands = []string{m[1]}
+	} else if m := assignRe.FindStringSubmatch(stmt); m != nil {
+		operands = []string{m[1], m[2], m[4]}
+	} else {
+		return nil, fmt.Errorf(`stepper: can't run %q: want "X = Y", "X = Y + Z", "X = Y - Z", "X++" or "X--"`, stmt)
+	}
+	var vars []string
+	for i, o := range operands {
+		if o == "" {
+			continue
+		}
+		if _, err := strconv.Atoi(o); err == nil {
+			if i == 0 {
+				return nil, fmt.Errorf("stepper: can't assign to %s in %q", o, stmt)
+			}
+			continue
+		}
+		if !stepperVarRe.MatchString(o) {
+			return nil, fmt.Errorf("stepper: bad operand %q in %q", o, stmt)
+		}
+		vars = append(vars, o)
+	}
+	return vars, nil
+}
+
+// dataJSON returns the run as JSON, for stepper.js.
+func (il *interleaving) dataJSON() string {
+	data, err := json.Marshal(il)
+	if err != nil {
+		panic(err) // can't happen for these types
+	}
+	return string(data)
+}
@@ -0,0 +1,209 @@
+package code2slides
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"strings"
+)
+
+// lintFiles scans files and writes any problems to w, one per line.
+// It reports whether the files are free of problems.
+func lintFiles(o *options, w io.Writer, files []string) (bool, error) {
+	var slides []*Slide
+	for _, filename := range files {
+		ss, err := scanFile(o, filename)
+		if err != nil {
+			return false, fmt.Errorf("error processing %s: %w", filename, err)
+		}
+		slides = append(slides, ss...)
+	}
+	probs := lintSlides(slides, o.minAnswerLen)
+	probs = append(probs, lintHTMLClasses(o, slides, o.static)...)
+	for _, p := range probs {
+		fmt.Fprintln(w, p)
+	}
+	return len(probs) == 0, nil
+}
+
+// lintSlides returns "FILE:LINE: MESSAGE" for each problem in slides,
+// which should make up a whole deck.
+func lintSlides(slides []*Slide, minAnswerLen int) []string {
+	var probs []string
+	for _, s := range slides {
+		for _, p := range lintAnswers(s, minAnswerLen) {
+			probs = append(probs, fmt.Sprintf("%s:%s", s.filename, p))
+		}
+	}
+	probs = append(probs, lintSignatures(slides)...)
+	return probs
+}
+
+// lintAnswers checks that every answer on the slide says something.
+// An answer consists of all the answer and code sections that follow a
+// question and its hints. Answers containing code are exempt from the length check.
+func lintAnswers(s *Slide, minAnswerLen int) []string {
+	var probs []string
+	secs := s.sections
+	for i := 0; i < len(secs); i++ {
+		if secs[i].kind != sectionQuestion {
+			continue
+		}
+		line := secs[i].line
+		for i+1 < len(secs) && secs[i+1].kind == sectionHint {
+			i++
+		}
+		var answer strings.Builder
+		hasCode := false
+		for i+1 < len(secs) && (secs[i+1].kind == sectionAnswer || secs[i+1].inAnswer) {
+			i++
+			answer.WriteString(secs[i].content)
+			hasCode = hasCode || secs[i].kind == sectionCode
+		}
+		text := strings.TrimSpace(answer.String())
+		switch {
+		case text == "":
+			probs = append(probs, fmt.Sprintf("%d: empty answer", line))
+		case isPlaceholder(text):
+			probs = append(probs, fmt.Sprintf("%d: placeholder answer %q", line, text))
+		case !hasCode && len(text) < minAnswerLen:
+			probs = append(probs, fmt.Sprintf("%d: answer %q is shorter than %d characters", line, text, minAnswerLen))
+		}
+	}
+	return probs
+}
+
+// isPlaceholder reports whether s is a stand-in for a real answer,
+// like "TODO" or "TBD".
+func isPlaceholder(s string) bool {
+	s = strings.Trim(strings.ToUpper(s), " .:!?-")
+	switch s {
+	case "TODO", "TBD", "XXX", "FIXME", "...":
+		return true
+	}
+	return strings.HasPrefix(s, "TODO")
+}
+
+// A funcDecl is a function declaration as it is displayed on a slide.
+type funcDecl struct {
+	pos    string // FILE:LINE of the code section
+	name   string // "F" or "T.M"
+	sig    string // parameter and result types
+	linked bool   // declared the same as an earlier one, by rename or same-as
+}
+
+// lintSignatures reports functions and methods that are displayed with
+// different signatures on different slides. It compares names after
+// renaming and suffix stripping, since that is what the audience sees.
+// Parameter names don't matter, only their types.
+//
+// Decks often show a function changing from slide to slide, as foo_1,
+// foo_2 and so on, so a declaration is checked only if something says it
+// is the same as earlier ones: a rename of its name or receiver type,
+// or a same-as directive on its slide.
+func lintSignatures(slides []*Slide) []string {
+	var probs []string
+	first := map[string]funcDecl{}
+	for _, s := range slides {
+		for _, sec := range s.sections {
+			if sec.kind != sectionCode {
+				continue
+			}
+			for _, d := range displayedFuncs(sec.content, s.renames) {
+				d.pos = fmt.Sprintf("%s:%d", s.filename, sec.line)
+				f, ok := first[d.name]
+				if !ok {
+					first[d.name] = d
+					continue
+				}
+				if (d.linked || s.sameAs != nil) && f.sig != d.sig {
+					probs = append(probs, fmt.Sprintf("%s: %s%s differs from %s%s at %s", d.pos, d.name, d.sig, f.name, f.sig, f.pos))
+				}
+			}
+		}
+	}
+	return probs
+}
+
+// displayedFuncs returns the functions declared in code, as displayed.
+// It only understands declarations whose signature fits on one line.
+func displayedFuncs(code string, renames map[string]string) []funcDecl {
+	var ds []funcDecl
+	for _, cl := range parseEm(code) {
+		line := strings.TrimSpace(codePart(cl.text, "//"))
+		if !strings.HasPrefix(line, "func ") {
+			continue
+		}
+		fd := parseFuncLine(line)
+		if fd == nil {
+			continue
+		}
+		linked := false
+		ast.Inspect(fd, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok {
+				_, r := renames[id.Name]
+				linked = linked || r
+			}
+			// Look only at the name and receiver.
+			return n != fd.Type && n != fd.Body
+		})
+		// Compare what the audience sees.
+		ast.Inspect(fd, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok {
+				id.Name = renderIdent(id.Name, renames)
+			}
+			return true
+		})
+		name := fd.Name.Name
+		if fd.Recv != nil && len(fd.Recv.List) == 1 {
+			name = types.ExprString(fd.Recv.List[0].Type) + "." + name
+		}
+		ds = append(ds, funcDecl{name: name, sig: signature(fd.Type), linked: linked})
+	}
+	return ds
+}
+
+// parseFuncLine parses a line beginning a function declaration.
+// It returns nil if the line can't be parsed.
+func parseFuncLine(line string) *ast.FuncDecl {
+	// Try the line by itself, then as the start of a longer body.
+	for _, src := range []string{line, line + "\n}"} {
+		f, err := parser.ParseFile(token.NewFileSet(), "", "package p\n"+src, parser.SkipObjectResolution)
+		if err != nil || len(f.Decls) != 1 {
+			continue
+		}
+		if fd, ok := f.Decls[0].(*ast.FuncDecl); ok {
+			return fd
+		}
+	}
+	return nil
+}
+
+// signature returns the parameter and result types of ft, like "(int) error".
+func signature(ft *ast.FuncType) string {
+	fieldTypes := func(fl *ast.FieldList) []string {
+		var ts []string
+		if fl == nil {
+			return nil
+		}
+		for _, f := range fl.List {
+			t := types.ExprString(f.Type)
+			for range max(len(f.Names), 1) {
+				ts = append(ts, t)
+			}
+		}
+		return ts
+	}
+	sig := "(" + strings.Join(fieldTypes(ft.Params), ", ") + ")"
+	switch rs := fieldTypes(ft.Results); len(rs) {
+	case 0:
+	case 1:
+		sig += " " + rs[0]
+	default:
+		sig += " (" + strings.Join(rs, ", ") + ")"
+	}
+	return sig
+}
@@ -0,0 +1,192 @@
+package code2slides
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// A liveHub keeps track of the browsers viewing a served deck.
+//
+// Each browser picks a random ID and holds open an event stream at
+// /live?id=ID&slide=N. When it moves to another slide, it posts to
+// /live/slide?id=ID&slide=N.
+//
+// The presenter's browser, which knows presenterKey, may post the
+// position of its pointer to /live/pointer, and the hub sends it to
+// every viewer.
+type liveHub struct {
+	presenterKey string            // if empty, pointer sharing is disabled
+	hasSlide     func(string) bool // reports whether a slide number is in the deck; if nil, none is
+
+	mu      sync.Mutex
+	viewers map[string]*viewer       // by ID
+	streams map[chan string]struct{} // events for each open stream
+	done    chan struct{}            // closed on shutdown
+}
+
+// maxPointerSize limits the size of a pointer position.
+const maxPointerSize = 1024
+
+type viewer struct {
+	slide string // the slide number the viewer is on
+	conns int    // open event streams; a viewer may reconnect before its old stream closes
+}
+
+// serveEvents serves the event stream for one viewer. The stream stays
+// open until the viewer goes away.
+func (h *liveHub) serveEvents(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "missing id", http.StatusBadRequest)
+		return
+	}
+	// A viewer on a slide the deck no longer has is still connected,
+	// but on no slide.
+	slide := r.URL.Query().Get("slide")
+	if !h.validSlide(slide) {
+		slide = ""
+	}
+	events := h.connect(id, slide)
+	defer h.disconnect(id, events)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprint(w, ": connected\n\n")
+	rc := http.NewResponseController(w)
+	rc.Flush()
+	done := h.doneChan()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-done:
+			// Tell the browser not to reconnect.
+			fmt.Fprint(w, "event: shutdown\ndata:\n\n")
+			return
+		case ev := <-events:
+			fmt.Fprint(w, ev)
+			rc.Flush()
+		}
+	}
+}
+
+// servePointer sends the presenter's pointer position, a small JSON
+// object, to all viewers.
+func (h *liveHub) servePointer(w http.ResponseWriter, r *http.Request) {
+	if h.presenterKey == "" || !secretEqual(r.URL.Query().Get("key"), h.presenterKey) {
+		http.Error(w, "not the presenter", http.StatusForbidden)
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPointerSize))
+	if err != nil || !json.Valid(data) {
+		http.Error(w, "bad pointer position", http.StatusBadRequest)
+		return
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.broadcast(fmt.Sprintf("event: pointer\ndata: %s\n\n", buf.Bytes()))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// broadcast sends an event to every open stream. Viewers that are too
+// slow to keep up miss events.
+func (h *liveHub) broadcast(ev string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.streams {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// shutdown ends all event streams, so the server can drain its connections.
+func (h *liveHub) shutdown() {
+	done := h.doneChan()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	select {
+	case <-done:
+	default:
+		close(done)
+	}
+}
+
+func (h *liveHub) doneChan() chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.done == nil {
+		h.done = make(chan struct{})
+	}
+	return h.done
+}
+
+// serveSlide records the slide a viewer has moved to.
+func (h *liveHub) serveSlide(w http.ResponseWriter, r *http.Request) {
+	slide := r.URL.Query().Get("slide")
+	if !h.validSlide(slide) {
+		http.Error(w, "bad slide number", http.StatusBadRequest)
+		return
+	}
+	h.mu.Lock()
+	if v := h.viewers[r.URL.Query().Get("id")]; v != nil {
+		v.slide = slide
+	}
+	h.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *liveHub) validSlide(slide string) bool {
+	return h.hasSlide != nil && h.hasSlide(slide)
+}
+
+func (h *liveHub) connect(id, slide string) chan string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.viewers == nil {
+		h.viewers = map[string]*viewer{}
+		h.streams = map[chan string]struct{}{}
+	}
+	events := make(chan string, 16)
+	h.streams[events] = struct{}{}
+	v := h.viewers[id]
+	if v == nil {
+		v = &viewer{}
+		h.viewers[id] = v
+	}
+	v.slide = slide
+	v.conns++
+	return events
+}
+
+func (h *liveHub) disconnect(id string, events chan string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.streams, events)
+	if v := h.viewers[id]; v != nil {
+		v.conns--
+		if v.conns == 0 {
+			delete(h.viewers, id)
+		}
+	}
+}
+
+// slideCounts returns the number of viewers on each slide. Those on no
+// slide are counted under "".
+func (h *liveHub) slideCounts() map[string]int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	counts := map[string]int{}
+	for _, v := range h.viewers {
+		counts[v.slide]++
+	}
+	return counts
+}
@@ -0,0 +1,90 @@
+package code2slides
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// A liveTest is a run of go test requested by a livetest directive.
+type liveTest struct {
+	dir  string // directory of the source file
+	args string // space-separated flags and environment settings
+}
+
+// serveLiveTest runs go test for a livetest pane, and streams the test2json
+// events it writes to the browser as server-sent events. When the test
+// finishes, it sends a "done" event with the exit code.
+//
+// Only tests named by the deck's livetest directives may be run.
+func (ds *deckServer) serveLiveTest(w http.ResponseWriter, r *http.Request) {
+	lt := liveTest{r.URL.Query().Get("dir"), r.URL.Query().Get("args")}
+	if _, err := ds.deck(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ds.mu.Lock()
+	ok := ds.liveTests[lt]
+	ds.mu.Unlock()
+	if !ok {
+		http.Error(w, "no such livetest in the deck", http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	rc := http.NewResponseController(w)
+	stdout := &eventWriter{w: w, rc: rc}
+	stderr := &eventWriter{w: w, rc: rc}
+	flags, env := splitEnv(strings.Fields(lt.args))
+	code, note, err := ds.opts.exec.run(r.Context(), execRequest{
+		dir:    lt.dir,
+		args:   append([]string{"test", "-json"}, flags...),
+		env:    env,
+		stdout: stdout,
+		stderr: stderr,
+	})
+	stdout.flush()
+	stderr.flush()
+	if err != nil {
+		note = err.Error()
+	}
+	done, _ := json.Marshal(map[string]any{"code": code, "note": strings.TrimSpace(note)})
+	fmt.Fprintf(w, "event: done\ndata: %s\n\n", done)
+	rc.Flush()
+}
+
+// An eventWriter writes each line written to it as a server-sent event.
+// Its Write method is not safe for concurrent use; the executor serializes
+// writes to stdout and stderr.
+type eventWriter struct {
+	w       io.Writer
+	rc      *http.ResponseController
+	partial []byte // incomplete last line
+}
+
+func (ew *eventWriter) Write(p []byte) (int, error) {
+	ew.partial = append(ew.partial, p...)
+	for {
+		line, rest, ok := bytes.Cut(ew.partial, []byte("\n"))
+		if !ok {
+			break
+		}
+		if _, err := fmt.Fprintf(ew.w, "data: %s\n\n", line); err != nil {
+			return 0, err
+		}
+		ew.partial = rest
+	}
+	ew.rc.Flush()
+	return len(p), nil
+}
+
+// flush sends any incomplete final line.
+func (ew *eventWriter) flush() {
+	if len(ew.partial) > 0 {
+		ew.Write([]byte("\n"))
+	}
+}
@@ -0,0 +1,361 @@
+package code2slides
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A part is a named group of files in a deck.
+type part struct {
+	name   string // empty for files that aren't in a part
+	files  []string
+	shared []string // the files that come from the shared library
+}
+
+// readManifest reads a deck manifest. Each line of a manifest is one of
+//
+//	# comment
+//	part NAME
+//	library DIR
+//	shared PATTERN
+//	PATTERN
+//	MODULE@VERSION//PATTERN
+//
+// A part line begins a new part of the deck, which gets a divider slide
+// and its own slide numbering. A library line names a directory of slides
+// shared by several decks, and shared lines include files from it.
+// Other lines are file name patterns, in the syntax of filepath.Match and
+// relative to the manifest's directory, as is DIR. A pattern after a
+// module and version is relative to that version of the module, which is
+// downloaded through the module proxy.
+// Blank lines are ignored.
+//
+// A file that is matched more than once is included only the first time,
+// so a shared slide appears once in a deck however many patterns name it.
+//
+// A manifest may end with profiles, variants of the deck that are built
+// with -profile NAME. Each begins with a profile line and has lines of
+//
+//	profile NAME
+//	flags FLAG...
+//	theme FILE
+//	skip PATTERN
+//
+// A flags line gives build flags, like -tags or -o, which are applied
+// before those on the command line. Values with spaces can be written as
+// Go strings: -title "Concurrency in 45 minutes". A theme line is short for
+// "flags -theme FILE". A skip line leaves out the deck's files that match
+// PATTERN, relative to the manifest's directory.
+func readManifest(filename string) ([]part, error) {
+	parts, _, err := parseManifest(filename)
+	return parts, err
+}
+
+// A profile is a named variant of a deck, from a manifest.
+type profile struct {
+	name  string
+	flags []string // build flags, which the command line overrides
+	skip  []string // patterns of files to leave out, joined to the manifest's directory
+}
+
+// readProfile reads a deck manifest and returns the parts of the deck
+// built with the named profile, and the profile.
+func readProfile(filename, name string) ([]part, *profile, error) {
+	parts, profiles, err := parseManifest(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	i := slices.IndexFunc(profiles, func(p *profile) bool { return p.name == name })
+	if i < 0 {
+		return nil, nil, fmt.Errorf("%s: no profile %q", filename, name)
+	}
+	prof := profiles[i]
+	for _, pat := range prof.skip {
+		matched := false
+		for i := range parts {
+			parts[i].files = slices.DeleteFunc(parts[i].files, func(f string) bool {
+				ok, _ := filepath.Match(pat, f)
+				matched = matched || ok
+				return ok
+			})
+			parts[i].shared = slices.DeleteFunc(parts[i].shared, func(f string) bool {
+				ok, _ := filepath.Match(pat, f)
+				return ok
+			})
+		}
+		if !matched {
+			return nil, nil, fmt.Errorf("%s: profile %s: no files in the deck match skip %q", filename, name, pat)
+		}
+	}
+	parts = slices.DeleteFunc(parts, func(p part) bool { return len(p.files) == 0 })
+	return parts, prof, nil
+}
+
+// parseManifest reads a deck manifest and returns its parts and profiles.
+func parseManifest(filename string) (_ []part, _ []*profile, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	dir := filepath.Dir(filename)
+	library := ""
+	seen := map[string]bool{}
+	parts := []part{{}}
+	var profiles []*profile
+	var prof *profile // the profile being read
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("%s:%d: %v", filename, lineNum, err)
+		}
+	}()
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if name, ok := strings.CutPrefix(line, "profile "); ok {
+			name = strings.TrimSpace(name)
+			if slices.ContainsFunc(profiles, func(p *profile) bool { return p.name == name }) {
+				return nil, nil, fmt.Errorf("duplicate profile %q", name)
+			}
+			prof = &profile{name: name}
+			profiles = append(profiles, prof)
+			continue
+		}
+		if prof != nil {
+			if err := prof.parseLine(dir, line); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		if name, ok := strings.CutPrefix(line, "part "); ok {
+			parts = append(parts, part{name: strings.TrimSpace(name)})
+			continue
+		}
+		if lib, ok := strings.CutPrefix(line, "library "); ok {
+			library = filepath.Join(dir, strings.TrimSpace(lib))
+			continue
+		}
+		pattern := filepath.Join(dir, line)
+		shared := false
+		if modPath, version, pat, ok := splitRemote(line); ok {
+			modDir, err := moduleDir(modPath, version)
+			if err != nil {
+				return nil, nil, err
+			}
+			pattern = filepath.Join(modDir, pat)
+		} else if pat, ok := strings.CutPrefix(line, "shared "); ok {
+			if library == "" {
+				return nil, nil, errors.New("shared without library")
+			}
+			pattern = filepath.Join(library, strings.TrimSpace(pat))
+			shared = true
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(matches) == 0 {
+			return nil, nil, fmt.Errorf("no files match %q", line)
+		}
+		p := &parts[len(parts)-1]
+		for _, m := range matches {
+			if seen[m] {
+				continue
+			}
+			seen[m] = true
+			p.files = append(p.files, m)
+			if shared {
+				p.shared = append(p.shared, m)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	if len(parts[0].files) == 0 {
+		parts = parts[1:]
+	}
+	return parts, profiles, nil
+}
+
+// parseLine parses a line of the profile in the manifest in dir.
+func (p *profile) parseLine(dir, line string) error {
+	keyword, rest, _ := strings.Cut(line, " ")
+	rest = strings.TrimSpace(rest)
+	switch keyword {
+	case "flags":
+		args, err := profileArgs(rest)
+		if err != nil {
+			return err
+		}
+		p.flags = append(p.flags, args...)
+	case "theme":
+		p.flags = append(p.flags, "-theme", rest)
+	case "skip":
+		pat := filepath.Join(dir, rest)
+		if _, err := filepath.Match(pat, ""); err != nil {
+			return err
+		}
+		p.skip = append(p.skip, pat)
+	default:
+		return fmt.Errorf("profile %s: want flags, theme or skip, not %q", p.name, line)
+	}
+	return nil
+}
+
+// profileArgs splits the flags of a profile at spaces, except inside Go
+// strings.
+func profileArgs(s string) ([]string, error) {
+	var args []string
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		var arg string
+		if s[0] == '"' || s[0] == '`' {
+			q, err := strconv.QuotedPrefix(s)
+			if err != nil {
+				return nil, fmt.Errorf("bad string in flags: %s", s)
+			}
+			s = s[len(q):]
+			arg, _ = strconv.Unquote(q)
+		} else {
+			arg, s, _ = strings.Cut(s, " ")
+			// -title="A B"
+			if name, val, ok := strings.Cut(arg, "="); ok && val != "" && (val[0] == '"' || val[0] == '`') {
+				q, err := strconv.QuotedPrefix(val + " " + s)
+				if err != nil {
+					return nil, fmt.Errorf("bad string in flags: %s", val+" "+s)
+				}
+				s = (val + " " + s)[len(q):]
+				val, _ = strconv.Unquote(q)
+				arg = name + "=" + val
+			}
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// partFiles returns all the files in parts, in order.
+func partFiles(parts []part) []string {
+	var files []string
+	for _, p := range parts {
+		files = append(files, p.files...)
+	}
+	return files
+}
+
+// sharedCommand implements "code2slides shared", which reports which decks
+// use each slide file of their shared libraries.
+func sharedCommand(args []string) error {
+	fs := flag.NewFlagSet("shared", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: code2slides shared <manifest>...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	users, err := sharedUsers(fs.Args())
+	if err != nil {
+		return err
+	}
+	reportShared(os.Stdout, users)
+	return nil
+}
+
+// sharedUsers reads the manifests and returns, for each file in their
+// shared libraries, the manifests of the decks that use it.
+// Library files with no users map to an empty list.
+func sharedUsers(manifests []string) (map[string][]string, error) {
+	users := map[string][]string{}
+	for _, m := range manifests {
+		parts, err := readManifest(m)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range parts {
+			for _, f := range p.shared {
+				users[f] = append(users[f], m)
+			}
+		}
+		libs, err := manifestLibraries(m)
+		if err != nil {
+			return nil, err
+		}
+		for _, lib := range libs {
+			files, err := filepath.Glob(filepath.Join(lib, "*.go"))
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range files {
+				if _, ok := users[f]; !ok {
+					users[f] = nil
+				}
+			}
+		}
+	}
+	return users, nil
+}
+
+// manifestLibraries returns the library directories named by a manifest.
+func manifestLibraries(filename string) ([]string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var libs []string
+	for line := range strings.Lines(string(data)) {
+		if lib, ok := strings.CutPrefix(strings.TrimSpace(line), "library "); ok {
+			libs = append(libs, filepath.Join(filepath.Dir(filename), strings.TrimSpace(lib)))
+		}
+	}
+	return libs, nil
+}
+
+// reportShared writes a line for each shared file listing the decks that
+// use it, in order of file name.
+func reportShared(w io.Writer, users map[string][]string) {
+	for _, f := range slices.Sorted(maps.Keys(users)) {
+		decks := users[f]
+		if len(decks) == 0 {
+			fmt.Fprintf(w, "%s: unused\n", f)
+			continue
+		}
+		fmt.Fprintf(w, "%s: %s\n", f, strings.Join(decks, " "))
+	}
+}
+
+// ApplyProfile sets in fs the flags of the named profile of the deck in
+// manifest. The flags in args, which fs has already parsed, are parsed
+// again after the profile's, so that the command line overrides the
+// profile. The profile's files are those that a Config with the same
+// Manifest and Profile builds.
+func ApplyProfile(fs *flag.FlagSet, args []string, manifest, name string) error {
+	_, prof, err := readProfile(manifest, name)
+	if err != nil {
+		return err
+	}
+	if err := fs.Parse(prof.flags); err != nil {
+		return fmt.Errorf("%s: profile %s: %v", manifest, name, err)
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("%s: profile %s: %q is not a flag", manifest, name, fs.Arg(0))
+	}
+	return fs.Parse(args)
+}
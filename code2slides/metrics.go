@@ -0,0 +1,81 @@
+package code2slides
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// serveMetrics serves metrics about the deck and its viewers in the
+// Prometheus text format.
+func (ds *deckServer) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	ds.writeMetrics(w)
+}
+
+func (ds *deckServer) writeMetrics(w io.Writer) {
+	counts := ds.live.slideCounts()
+	viewers := 0
+	current, most := "", 0
+	slides := slices.SortedFunc(maps.Keys(counts), compareSlides)
+	for _, s := range slides {
+		viewers += counts[s]
+		if s != "" && counts[s] > most {
+			current, most = s, counts[s]
+		}
+	}
+
+	metric(w, "viewers", "gauge", "Number of connected viewers.")
+	fmt.Fprintf(w, "code2slides_viewers %d\n", viewers)
+	metric(w, "slide_viewers", "gauge", "Number of connected viewers on each slide.")
+	for _, s := range slides {
+		if s != "" {
+			fmt.Fprintf(w, "code2slides_slide_viewers{slide=\"%s\"} %d\n", labelValue(s), counts[s])
+		}
+	}
+	if n, err := strconv.Atoi(current); err == nil {
+		metric(w, "current_slide", "gauge", "The slide the most viewers are on.")
+		fmt.Fprintf(w, "code2slides_current_slide %d\n", n)
+	}
+
+	voters := ds.polls.voters()
+	metric(w, "poll_voters", "gauge", "Number of viewers who voted in each poll.")
+	for _, p := range slices.SortedFunc(maps.Keys(voters), compareSlides) {
+		fmt.Fprintf(w, "code2slides_poll_voters{slide=\"%s\"} %d\n", labelValue(p), voters[p])
+	}
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	metric(w, "rebuild_duration_seconds", "summary", "Time taken to rebuild the deck.")
+	fmt.Fprintf(w, "code2slides_rebuild_duration_seconds_sum %g\n", ds.rebuildTime.Seconds())
+	fmt.Fprintf(w, "code2slides_rebuild_duration_seconds_count %d\n", ds.rebuilds)
+	metric(w, "rebuild_errors_total", "counter", "Number of failed rebuilds.")
+	fmt.Fprintf(w, "code2slides_rebuild_errors_total %d\n", ds.rebuildErrors)
+}
+
+func metric(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP code2slides_%s %s\n# TYPE code2slides_%s %s\n", name, help, name, typ)
+}
+
+// labelValue escapes s for use as a label value in the Prometheus text
+// format, which is not quite Go syntax.
+func labelValue(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// compareSlides orders slide numbers numerically.
+func compareSlides(a, b string) int {
+	an, aerr := strconv.Atoi(a)
+	bn, berr := strconv.Atoi(b)
+	if aerr == nil && berr == nil {
+		return cmp.Compare(an, bn)
+	}
+	return cmp.Compare(a, b)
+}
@@ -0,0 +1,125 @@
+package code2slides
+
+import (
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// newModuleCommand starts the slides for a new deck, laid out like the
+// existing ones.
+func newModuleCommand(args []string) error {
+	fset := flag.NewFlagSet("new-module", flag.ExitOnError)
+	dir := fset.String("dir", "slides", "create the module in `dir`")
+	title := fset.String("title", "", "the deck's `title` (default NAME)")
+	fset.Usage = func() {
+		fmt.Fprintln(fset.Output(), "usage: code2slides new-module [flags] <name>")
+		fset.PrintDefaults()
+	}
+	fset.Parse(args)
+	if fset.NArg() != 1 {
+		fset.Usage()
+		os.Exit(2)
+	}
+	name := fset.Arg(0)
+	files, err := newModule(filepath.Join(*dir, name), name, cmp.Or(*title, name))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		fmt.Println(f)
+	}
+	return nil
+}
+
+// moduleNameRe matches the name of a slides module, which is also the
+// name of its package.
+var moduleNameRe = regexp.MustCompile(`^[a-z][a-z0-9]*$`)
+
+// newModule creates the directory dir for a module of slides with the
+// given name and deck title, and returns the files it wrote. The module
+// has:
+//
+//   - a doc.go, with the package comment;
+//   - slide files numbered by tens, so new ones can go between them,
+//     starting with the title slide in 00-intro.go;
+//   - a test file for the code on the slides, which uses testhelp;
+//   - a manifest, manifest.txt, that builds the numbered files in order.
+func newModule(dir, name, title string) ([]string, error) {
+	if !moduleNameRe.MatchString(name) {
+		return nil, fmt.Errorf("bad module name %q: use lower-case letters and digits", name)
+	}
+	if _, err := os.Stat(dir); err == nil {
+		return nil, fmt.Errorf("%s already exists", dir)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, f := range newModuleFiles {
+		fname := filepath.Join(dir, strings.ReplaceAll(f.name, "NAME", name))
+		content := strings.NewReplacer("DIR", filepath.ToSlash(dir), "NAME", name, "TITLE", title).Replace(f.content)
+		if err := os.WriteFile(fname, []byte(content), 0o644); err != nil {
+			return nil, err
+		}
+		files = append(files, fname)
+	}
+	return files, nil
+}
+
+// newModuleFiles are the files of a new module. NAME in their names and
+// contents is replaced by the module's name. DIR in their contents is
+// replaced by the module's directory, and TITLE by the deck's title.
+var newModuleFiles = []struct {
+	name, content string
+}{
+	{"doc.go", `// Package NAME holds the slides of the TITLE deck, and the code on them.
+//
+// Build the deck with
+//
+//	code2slides -manifest DIR/manifest.txt -o NAME.slides
+package NAME
+`},
+	{"00-intro.go", `package NAME
+
+// title TITLE
+// subtitle
+// GopherCon Europe 2026
+// !subtitle
+
+////////////////////////////////////
+// heading Outline
+// todo list the sections of the deck
+`},
+	{"10-NAME.go", `package NAME
+
+////////////////////////////////////
+// heading TITLE
+// todo write the first section
+`},
+	{"NAME_test.go", `package NAME
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/jba/concurrency-workshop/internal/testhelp"
+)
+
+// Test the code on the slides here. Check what it prints with testhelp.
+func TestSlides(t *testing.T) {
+	testhelp.WantStdout(t, "hello", func() { fmt.Println("hello") })
+}
+`},
+	{"manifest.txt", `# The TITLE deck. See the -manifest flag of code2slides.
+[0-9]*.go
+`},
+}
@@ -0,0 +1,238 @@
+package code2slides
+
+import (
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// newSlideCommand adds a numbered slide file to a module, or renumbers
+// the module's slide files.
+func newSlideCommand(args []string) error {
+	fset := flag.NewFlagSet("new-slide", flag.ExitOnError)
+	renumber := fset.Bool("renumber", false, "first renumber the slide files by tens from 00, with git mv")
+	heading := fset.String("heading", "", "the new slide's `heading` (default NAME)")
+	fset.Usage = func() {
+		fmt.Fprintln(fset.Output(), "usage: code2slides new-slide [flags] <module dir> [<name>]")
+		fset.PrintDefaults()
+	}
+	fset.Parse(args)
+	if fset.NArg() < 1 || fset.NArg() > 2 || fset.NArg() == 1 && !*renumber {
+		fset.Usage()
+		os.Exit(2)
+	}
+	dir := fset.Arg(0)
+	if *renumber {
+		if err := renumberSlides(dir, gitMove); err != nil {
+			return err
+		}
+	}
+	if fset.NArg() == 1 {
+		return nil
+	}
+	name := fset.Arg(1)
+	file, err := newSlide(dir, name, cmp.Or(*heading, name))
+	if err != nil {
+		return err
+	}
+	fmt.Println(file)
+	return nil
+}
+
+// numberedRe matches the name of a numbered slide file, like 20-waitgroup.go.
+var numberedRe = regexp.MustCompile(`^(\d+)-(.+)\.go$`)
+
+// A numberedFile is a slide file whose name begins with a number, which
+// orders the files in a deck.
+type numberedFile struct {
+	num  int
+	rest string // the name after the number and hyphen, like "waitgroup.go"
+	file string // the file's name, if it exists
+}
+
+func (f numberedFile) name(width int) string {
+	return fmt.Sprintf("%0*d-%s", width, f.num, f.rest)
+}
+
+// numberedFiles returns the numbered slide files in dir, in order,
+// and the width of the widest number.
+func numberedFiles(dir string) ([]numberedFile, int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, 0, err
+	}
+	var files []numberedFile
+	width := 2
+	for _, e := range entries {
+		m := numberedRe.FindStringSubmatch(e.Name())
+		if m == nil || e.IsDir() || strings.HasSuffix(e.Name(), "_test.go") {
+			continue
+		}
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return nil, 0, err
+		}
+		files = append(files, numberedFile{n, m[2] + ".go", e.Name()})
+		width = max(width, len(m[1]))
+	}
+	slices.SortFunc(files, func(a, b numberedFile) int {
+		return cmp.Or(cmp.Compare(a.num, b.num), cmp.Compare(a.file, b.file))
+	})
+	return files, width, nil
+}
+
+// newSlide creates a slide file in dir with the given heading, numbered
+// after the last slide file, and adds it to the module's manifest if
+// that doesn't already include it. It returns the new file's name.
+func newSlide(dir, name, heading string) (string, error) {
+	file, err := nextSlideFile(dir, name+".go")
+	if err != nil {
+		return "", err
+	}
+	content := fmt.Sprintf(newSlideTemplate, packageName(dir), heading)
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		return "", err
+	}
+	return file, addToManifest(filepath.Join(dir, "manifest.txt"), file)
+}
+
+// nextSlideFile returns the path of a slide file in dir named rest,
+// numbered ten past the last slide file.
+func nextSlideFile(dir, rest string) (string, error) {
+	files, width, err := numberedFiles(dir)
+	if err != nil {
+		return "", err
+	}
+	next := 0
+	if len(files) > 0 {
+		next = (files[len(files)-1].num/10 + 1) * 10
+	}
+	if len(strconv.Itoa(next)) > width {
+		return "", fmt.Errorf("%s: slide number %d would sort before the others; use -renumber", dir, next)
+	}
+	return filepath.Join(dir, numberedFile{num: next, rest: rest}.name(width)), nil
+}
+
+const newSlideTemplate = `package %s
+
+////////////////////////////////////
+// heading %s
+// todo write this slide
+`
+
+// packageName returns the name of the Go package in dir, or the
+// directory's name if it has no Go files.
+func packageName(dir string) string {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		if m := packageRe.FindSubmatch(data); m != nil {
+			return string(m[1])
+		}
+	}
+	return filepath.Base(dir)
+}
+
+var packageRe = regexp.MustCompile(`(?m)^package (\w+)`)
+
+// addToManifest adds file to the manifest, unless the manifest doesn't
+// exist or already includes the file.
+func addToManifest(manifest, file string) error {
+	parts, err := readManifest(manifest)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, p := range parts {
+		if slices.Contains(p.files, file) {
+			return nil
+		}
+	}
+	f, err := os.OpenFile(manifest, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(f, filepath.Base(file))
+	return errors.Join(err, f.Close())
+}
+
+// renumberSlides renumbers the slide files in dir by tens from 00, keeping
+// their order, so new slides fit between them. It renames them with move,
+// and updates the lines of the module's manifest that name them.
+func renumberSlides(dir string, move func(from, to string) error) error {
+	files, _, err := numberedFiles(dir)
+	if err != nil {
+		return err
+	}
+	width := max(2, len(strconv.Itoa(10*(len(files)-1))))
+	renames := map[string]string{}
+	for i, f := range files {
+		if to := (numberedFile{num: 10 * i, rest: f.rest}).name(width); to != f.file {
+			renames[f.file] = to
+		}
+	}
+	// Move the files aside first, so that a new name can't collide with
+	// an old one.
+	for from := range renames {
+		if err := move(filepath.Join(dir, from), filepath.Join(dir, "renumber-"+from)); err != nil {
+			return err
+		}
+	}
+	for from, to := range renames {
+		if err := move(filepath.Join(dir, "renumber-"+from), filepath.Join(dir, to)); err != nil {
+			return err
+		}
+	}
+	return renameInManifest(filepath.Join(dir, "manifest.txt"), renames)
+}
+
+// renameInManifest replaces the lines of the manifest that are keys of
+// renames with their values. It does nothing if the manifest doesn't exist.
+func renameInManifest(manifest string, renames map[string]string) error {
+	data, err := os.ReadFile(manifest)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(string(data), "\n")
+	for i, l := range lines {
+		if to, ok := renames[strings.TrimSpace(l)]; ok {
+			lines[i] = to
+		}
+	}
+	return os.WriteFile(manifest, []byte(strings.Join(lines, "\n")), 0o644)
+}
+
+// gitRemove removes a file with git rm.
+func gitRemove(file string) error {
+	out, err := exec.Command("git", "rm", "-q", file).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git rm %s: %v: %s", file, err, out)
+	}
+	return nil
+}
+
+// gitMove renames a file with git mv, so its history follows it.
+func gitMove(from, to string) error {
+	out, err := exec.Command("git", "mv", from, to).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git mv %s %s: %v: %s", from, to, err, out)
+	}
+	return nil
+}
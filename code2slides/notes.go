@@ -0,0 +1,96 @@
+package code2slides
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+)
+
+// Speaker notes, from the note directive, are left out of decks unless
+// -notes puts them on the slides for everyone to see. For the presenter
+// alone, -presenter lets the deck open a window, with 'N', that shows the
+// current slide and its notes, and -notes-file writes the notes of every
+// slide to a document of their own.
+
+// slideNotes returns the notes of a slide, rendered as HTML.
+func slideNotes(o *options, s *Slide) []string {
+	var notes []string
+	for _, sec := range s.sections {
+		if sec.kind == sectionNote {
+			notes = append(notes, renderMarkdown(o, sec.content))
+		}
+	}
+	return notes
+}
+
+// writePresenterScripts writes the scripts that show the notes of slides
+// in the presenter's window. notes.js expects the notes of the first slide
+// in titleNotes and those of the others in sections.
+func writePresenterScripts(o *options, w io.Writer, slides []*Slide, haveJQuery bool) error {
+	type notesSection struct {
+		Notes []string
+	}
+	var title []string
+	var sections []notesSection
+	for i, s := range slides {
+		if i == 0 {
+			title = slideNotes(o, s)
+		} else {
+			sections = append(sections, notesSection{slideNotes(o, s)})
+		}
+	}
+	tj, err := json.Marshal(title)
+	if err != nil {
+		return err
+	}
+	sj, err := json.Marshal(sections)
+	if err != nil {
+		return err
+	}
+	if !haveJQuery {
+		// notes.js keeps resized output in step with jQuery.
+		fmt.Fprintln(w, "    <script src='static/jquery.js'></script>")
+	}
+	fmt.Fprintf(w, "    <script>\n      var titleNotes = %s;\n      var sections = %s;\n    </script>\n", tj, sj)
+	_, err = fmt.Fprintln(w, "    <script src='static/notes.js'></script>")
+	return err
+}
+
+// writeNotesDoc writes a document with the heading and notes of each
+// slide, for the presenter to read alongside the deck. As in the deck,
+// "#N" goes to the Nth slide.
+func writeNotesDoc(o *options, w io.Writer, title string, slides []*Slide) error {
+	fmt.Fprintf(w, notesDocTop, html.EscapeString(title), html.EscapeString(title))
+	for i, s := range slides {
+		notes := slideNotes(o, s)
+		class := ""
+		if len(notes) == 0 {
+			class = " class='empty'"
+		}
+		fmt.Fprintf(w, "<section id='%d'%s>\n<h2><span class='num'>%[1]d</span>%[3]s</h2>\n", i+1, class, html.EscapeString(s.heading))
+		for _, n := range notes {
+			fmt.Fprint(w, n)
+		}
+		fmt.Fprintln(w, "</section>")
+	}
+	_, err := fmt.Fprintln(w, "</body>\n</html>")
+	return err
+}
+
+const notesDocTop = `<!DOCTYPE html>
+<html>
+<head>
+<title>Notes: %s</title>
+<meta charset='utf-8'>
+<meta name='viewport' content='width=device-width,initial-scale=1'>
+<style>
+  body { font-family: 'Open Sans', Arial, sans-serif; max-width: 50em; margin: 2em auto; padding: 0 1em; }
+  h2 { font-size: 1.1em; border-top: 1px solid #ccc; padding-top: 0.5em; }
+  h2 span.num { color: #8c8c8c; margin-right: 0.5em; }
+  section.empty h2 { color: #8c8c8c; font-weight: normal; }
+</style>
+</head>
+<body>
+<h1>%s</h1>
+`
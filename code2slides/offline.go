@@ -0,0 +1,152 @@
+package code2slides
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// vendoredAssets maps files under the static directory to the URLs
+// they are fetched from when building with -offline.
+var vendoredAssets = map[string]string{
+	"vendor/mermaid.min.js": "https://cdn.jsdelivr.net/npm/mermaid@11/dist/mermaid.min.js",
+}
+
+// vendorAssets downloads each of vendoredAssets into staticDir, unless it
+// is already there. Run it once while you still have a network connection.
+func vendorAssets(staticDir string) error {
+	for name, url := range vendoredAssets {
+		path := filepath.Join(staticDir, name)
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+		if err := download(url, path); err != nil {
+			return fmt.Errorf("vendoring %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func download(url, path string) (err error) {
+	res, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, res.Status)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, f.Close()) }()
+	_, err = io.Copy(f, res.Body)
+	return err
+}
+
+// networkRefRe matches quoted absolute or protocol-relative URLs, as they
+// appear in src and href attributes, script imports and CSS.
+var networkRefRe = regexp.MustCompile(`['"(](https?:)?//[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}[^'")\s]*`)
+
+// linkRe matches the start of a hyperlink. Following a link is the
+// reader's choice, so links don't count as network requests.
+var linkRe = regexp.MustCompile(`<a\s+href=["'][^"']*["']`)
+
+// verifyOffline reports an error listing every place in the deck, or in
+// the scripts and stylesheets it loads from the static directory, that
+// would make a network request. The static directory is staticDir, or if
+// that is empty, the one next to the deck.
+func verifyOffline(deckFile, staticDir string) error {
+	data, err := os.ReadFile(deckFile)
+	if err != nil {
+		return err
+	}
+	if staticDir == "" {
+		staticDir = filepath.Join(filepath.Dir(deckFile), "static")
+	}
+	files := []string{deckFile}
+	for _, name := range staticAssets(string(data)) {
+		files = append(files, filepath.Join(staticDir, name))
+	}
+	var refs []string
+	for i, file := range files {
+		f, err := os.Open(file)
+		if i > 0 && errors.Is(err, fs.ErrNotExist) {
+			continue // -dry-run reports missing files
+		}
+		if err != nil {
+			return err
+		}
+		rs, err := networkRefs(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		for _, r := range rs {
+			refs = append(refs, file+":"+r)
+		}
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("deck is not offline-safe:\n%s", strings.Join(refs, "\n"))
+}
+
+// staticAssets returns the names, relative to the static directory, of the
+// scripts and stylesheets that deck loads from it, in order.
+func staticAssets(deck string) []string {
+	var names []string
+	for _, m := range assetRe.FindAllStringSubmatch(linkRe.ReplaceAllString(deck, ""), -1) {
+		name, ok := strings.CutPrefix(html.UnescapeString(m[1]), "static/")
+		name, _, _ = strings.Cut(name, "?")
+		if ok && (strings.HasSuffix(name, ".js") || strings.HasSuffix(name, ".css")) && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// offlineOK marks a line whose network reference is never used offline,
+// like one that -offline decks skip.
+const offlineOK = "offline-ok"
+
+// networkRefs returns "LINE: URL" for each network reference read from r.
+// It skips comment lines and lines marked with offlineOK.
+func networkRefs(r io.Reader) ([]string, error) {
+	var refs []string
+	scanner := bufio.NewScanner(r)
+	// Scripts may be minified into long lines.
+	scanner.Buffer(nil, 16<<20)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := linkRe.ReplaceAllString(scanner.Text(), "")
+		if commentLine(line) || strings.Contains(line, offlineOK) {
+			continue
+		}
+		for _, m := range networkRefRe.FindAllString(line, -1) {
+			refs = append(refs, fmt.Sprintf("%d: %s", lineNum, m[1:]))
+		}
+	}
+	return refs, scanner.Err()
+}
+
+// commentLine reports whether line is a comment, or part of one, in a script
+// or stylesheet.
+func commentLine(line string) bool {
+	line = strings.TrimSpace(line)
+	return strings.HasPrefix(line, "//") || strings.HasPrefix(line, "/*") || strings.HasPrefix(line, "*")
+}
@@ -0,0 +1,157 @@
+package code2slides
+
+import (
+	"go/scanner"
+	"go/token"
+	"regexp"
+	"strings"
+)
+
+// With -chan-ops, the channel operations in code blocks are styled with
+// the chanop class, so that a deck about channels draws the eye to where
+// goroutines communicate without em directives on every send and receive.
+// Likewise, -mutex-ops styles calls of Lock, Unlock, RLock and RUnlock with
+// the mutexop class, and the fields under a "mutex hat" with the guarded
+// class: the fields that follow a mutex in a struct, up to a blank line,
+// are the ones it guards.
+
+// An opToken is a token of a line of code.
+type opToken struct {
+	off int
+	tok token.Token
+	lit string
+}
+
+// scanOps returns the tokens of code, a line of Go without its trailing
+// comment.
+func scanOps(code string) []opToken {
+	var toks []opToken
+	fset := token.NewFileSet()
+	file := fset.AddFile("", -1, len(code))
+	var s scanner.Scanner
+	s.Init(file, []byte(code), nil, 0)
+	for {
+		pos, t, lit := s.Scan()
+		if t == token.EOF {
+			break
+		}
+		if t == token.SEMICOLON && lit == "\n" {
+			continue // inserted at the end of the line
+		}
+		toks = append(toks, opToken{file.Offset(pos), t, lit})
+	}
+	return toks
+}
+
+// tokenIs reports whether toks[i] exists and is t, with literal lit if
+// lit isn't empty.
+func tokenIs(toks []opToken, i int, t token.Token, lit string) bool {
+	return i >= 0 && i < len(toks) && toks[i].tok == t && (lit == "" || toks[i].lit == lit)
+}
+
+// chanOpRanges returns the byte ranges of the channel operations in code,
+// a line of Go without its trailing comment: sends and receives, calls of
+// close, and "make(chan". The arrows of channel types, like "<-chan int",
+// aren't operations.
+func chanOpRanges(code string) [][2]int {
+	toks := scanOps(code)
+	is := func(i int, t token.Token, lit string) bool { return tokenIs(toks, i, t, lit) }
+	var ranges [][2]int
+	for i, t := range toks {
+		switch {
+		case t.tok == token.ARROW:
+			if !is(i-1, token.CHAN, "") && !is(i+1, token.CHAN, "") {
+				ranges = append(ranges, [2]int{t.off, t.off + len("<-")})
+			}
+		case is(i, token.IDENT, "close") && is(i+1, token.LPAREN, "") && !is(i-1, token.PERIOD, ""):
+			ranges = append(ranges, [2]int{t.off, t.off + len("close")})
+		case is(i, token.IDENT, "make") && is(i+1, token.LPAREN, "") && is(i+2, token.CHAN, ""):
+			ranges = append(ranges, [2]int{t.off, toks[i+2].off + len("chan")})
+		}
+	}
+	return ranges
+}
+
+// mutexOpRanges returns the byte ranges of the calls in code, a line of
+// Go without its trailing comment, of the methods that lock and unlock
+// mutexes, from the method's name through the parentheses.
+func mutexOpRanges(code string) [][2]int {
+	toks := scanOps(code)
+	var ranges [][2]int
+	for i, t := range toks {
+		if t.tok != token.IDENT || !tokenIs(toks, i-1, token.PERIOD, "") {
+			continue
+		}
+		switch t.lit {
+		case "Lock", "Unlock", "RLock", "RUnlock":
+			if tokenIs(toks, i+1, token.LPAREN, "") && tokenIs(toks, i+2, token.RPAREN, "") {
+				ranges = append(ranges, [2]int{t.off, toks[i+2].off + 1})
+			}
+		}
+	}
+	return ranges
+}
+
+// mutexFieldRe matches a struct field that is a mutex, named or embedded.
+var mutexFieldRe = regexp.MustCompile(`^(?:\w+\s+)?\*?sync\.(?:RW)?Mutex$`)
+
+// mutexHat reports which of lines, the lines of a code block, declare
+// fields guarded by a mutex: those after a mutex field, up to a blank
+// line or the end of the struct. prefix begins line comments.
+func mutexHat(lines []codeLine, prefix string) []bool {
+	guarded := make([]bool, len(lines))
+	inHat := false
+	for i, line := range lines {
+		code := strings.TrimSpace(codePart(line.text, prefix))
+		switch {
+		case mutexFieldRe.MatchString(code):
+			inHat = true
+		case code == "" || strings.HasPrefix(code, "}"):
+			inHat = false
+		case inHat:
+			guarded[i] = true
+		}
+	}
+	return guarded
+}
+
+var (
+	lockRe   = regexp.MustCompile(`([\w.\[\]]+)\.R?Lock\(\)`)
+	unlockRe = regexp.MustCompile(`([\w.\[\]]+)\.R?Unlock\(\)`)
+)
+
+// criticalLines reports which of lines, the lines of a code block, are in
+// critical sections. A critical section begins at a line that locks a
+// mutex and ends at the next line that unlocks it. If that unlock is
+// deferred, or there is none, the section ends with the block it began
+// in: before the next line indented less than the line that locks.
+// prefix begins line comments.
+func criticalLines(lines []codeLine, prefix string) []bool {
+	critical := make([]bool, len(lines))
+	indent := func(s string) int { return len(s) - len(strings.TrimLeft(s, " ")) }
+	for i, line := range lines {
+		m := lockRe.FindStringSubmatch(codePart(line.text, prefix))
+		if m == nil {
+			continue
+		}
+		end := len(lines) - 1
+		for j := i + 1; j < len(lines); j++ {
+			code := codePart(lines[j].text, prefix)
+			if strings.TrimSpace(code) != "" && indent(code) < indent(line.text) {
+				end = j - 1
+				break
+			}
+			if u := unlockRe.FindStringSubmatch(code); u != nil && u[1] == m[1] && !strings.HasPrefix(strings.TrimSpace(code), "defer ") {
+				end = j
+				break
+			}
+		}
+		for end > i && strings.TrimSpace(lines[end].text) == "" {
+			end--
+		}
+		for j := i; j <= end; j++ {
+			critical[j] = true
+		}
+	}
+	return critical
+}
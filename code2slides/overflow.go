@@ -0,0 +1,121 @@
+package code2slides
+
+import (
+	"slices"
+	"strings"
+)
+
+// Code too long for its slide is cut off at the bottom. A code section can
+// instead say what to do with it, with one of the attributes
+//
+//	fit     shrink the code's font until it fits (static/fit.js)
+//	scroll  scroll the code within a pane the height of the slide
+//	split   continue the code on slides of its own, headed "(cont.)"
+//
+// and -overflow chooses one for the sections without one. Fit and scroll
+// only change code that would overflow. Split cuts code longer than
+// -split-lines lines, at a blank line if there is one near the cut.
+
+// overflowAttr returns what to do with code with the attributes attrs that
+// is too long for its slide: attrFit, attrScroll, attrSplit, or "" to cut
+// it off.
+func overflowAttr(o *options, attrs []codeAttr) codeAttr {
+	for _, a := range attrs {
+		switch a {
+		case attrFit, attrScroll, attrSplit:
+			return a
+		}
+	}
+	return o.overflowMode
+}
+
+// codeLinesFor returns the most lines of code with the attributes attrs
+// that a slide holds, from o.maxCodeLines and the size of the code.
+func codeLinesFor(o *options, attrs []codeAttr) int {
+	// In proportion to the line heights in styles.css.
+	n := o.maxCodeLines
+	switch {
+	case slices.Contains(attrs, attrLarge):
+		n = n * 40 / 44
+	case slices.Contains(attrs, attrSmall):
+		n = n * 40 / 32
+	case slices.Contains(attrs, attrSmaller):
+		n = n * 40 / 28
+	}
+	return max(n, 1)
+}
+
+// splitOverflow ends the slide s with the first part of each code section
+// that should be split and is too long, and returns the continuation
+// slides that hold the rest. The sections after the code go on the last
+// of them. Code in columns or a div isn't split, since a continuation
+// couldn't close them, and neither is code in an answer.
+func splitOverflow(o *options, s *Slide) []*Slide {
+	if o.scroll || s.isTitle {
+		// A scrolling page has room for all of the code.
+		return nil
+	}
+	if slices.ContainsFunc(s.sections, func(sec section) bool {
+		return sec.kind == sectionColumns || sec.kind == sectionHTML && strings.HasPrefix(sec.content, "<div")
+	}) {
+		return nil
+	}
+	var conts []*Slide
+	cur := s
+	for i := 0; i < len(cur.sections); i++ {
+		sec := cur.sections[i]
+		if sec.kind != sectionCode || sec.inAnswer || overflowAttr(o, sec.attrs) != attrSplit {
+			continue
+		}
+		chunks := splitCode(codeHTML(o, sec, s), codeLinesFor(o, sec.attrs))
+		if len(chunks) < 2 {
+			continue
+		}
+		rest := cur.sections[i+1:]
+		cur.sections = slices.Clone(cur.sections[:i+1])
+		cur.sections[i].rendered = chunks[0]
+		for _, c := range chunks[1:] {
+			sec.rendered = c
+			cur = &Slide{
+				heading:  s.heading + " (cont.)",
+				filename: s.filename,
+				sections: []section{sec},
+				renames:  s.renames,
+				optional: s.optional,
+				tags:     s.tags,
+				hold:     s.hold,
+				comment:  s.comment,
+			}
+			conts = append(conts, cur)
+		}
+		cur.sections = append(cur.sections, rest...)
+		i = 0 // after the code
+	}
+	return conts
+}
+
+// splitCode splits code, rendered by renderCode, into parts of at most limit
+// lines. It cuts at the last blank line of a part, if that is past its
+// middle, and drops the blank lines at the start of a part.
+func splitCode(code string, limit int) []string {
+	lines := strings.Split(code, "\n")
+	var parts []string
+	for len(lines) > limit {
+		cut := limit
+		for j := limit; j > limit/2; j-- {
+			if strings.TrimSpace(lines[j]) == "" {
+				cut = j
+				break
+			}
+		}
+		parts = append(parts, strings.Join(lines[:cut], "\n"))
+		lines = lines[cut:]
+		for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+			lines = lines[1:]
+		}
+	}
+	if len(lines) > 0 {
+		parts = append(parts, strings.Join(lines, "\n"))
+	}
+	return parts
+}
@@ -0,0 +1,134 @@
+package code2slides
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Code with the play attribute runs when the reader presses its Run
+// button: play.js posts it to /compile, as it would to the Go playground.
+// "code2slides serve -play" runs it in the sandbox, subject to the -exec
+// limits, so readers can't run anything on the presenter's machine that
+// deck code couldn't.
+
+// maxPlaySize limits the size of a program posted to /compile.
+const maxPlaySize = 64 << 10
+
+// A playEvent is a piece of a program's output, as the playground reports it.
+type playEvent struct {
+	Message string
+	Kind    string // "stdout", "stderr" or "system"
+	Delay   int    // nanoseconds to wait before showing it; always 0
+}
+
+// A playResult is the playground's reply to /compile.
+type playResult struct {
+	Errors string // build errors, or "process took too long"
+	Events []playEvent
+	Status int // the program's exit status
+}
+
+// servePlay runs the program posted to /compile with "go run", and replies
+// as the playground does.
+func (ds *deckServer) servePlay(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxPlaySize)
+	body := r.FormValue("body")
+	if body == "" {
+		http.Error(w, "missing body", http.StatusBadRequest)
+		return
+	}
+	res, err := runPlay(r.Context(), ds.opts.exec, body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// runPlay runs the program in body, the contents of a main package,
+// with e.
+func runPlay(ctx context.Context, e *executor, body string) (*playResult, error) {
+	dir, err := os.MkdirTemp("", "code2slides-play-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	gomod := "module play\n"
+	if lang, err := goLang(strings.TrimPrefix(runtime.Version(), "go")); err == nil {
+		gomod += "\ngo " + lang + "\n"
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(gomod), 0o644); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "prog.go"), []byte(body), 0o644); err != nil {
+		return nil, err
+	}
+	ev := &playEvents{dir: dir + string(filepath.Separator)}
+	code, note, err := e.run(ctx, execRequest{
+		dir:    dir,
+		args:   []string{"run", "."},
+		stdout: ev.writer("stdout"),
+		stderr: ev.writer("stderr"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := &playResult{Events: ev.events, Status: code}
+	switch {
+	case strings.Contains(note, "timed out"):
+		res.Errors = "process took too long"
+	case note != "":
+		res.Events = append(res.Events, playEvent{Message: "\n" + note, Kind: "system"})
+	case code != 0 && len(res.Events) > 0 && buildFailed(res.Events[0].Message):
+		var b strings.Builder
+		for _, e := range res.Events {
+			b.WriteString(e.Message)
+		}
+		res.Errors, res.Events = b.String(), nil
+	}
+	return res, nil
+}
+
+// buildFailed reports whether the output of "go run" begins with build
+// errors, which it reports under the package's path, rather than with the
+// output of the program.
+func buildFailed(output string) bool {
+	return strings.HasPrefix(output, "# ") || strings.HasPrefix(output, "go: ")
+}
+
+// playEvents collects the output of a program as playEvents, in order.
+// It removes the temporary directory dir from file names.
+type playEvents struct {
+	dir    string
+	mu     sync.Mutex
+	events []playEvent
+}
+
+func (p *playEvents) writer(kind string) *playWriter {
+	return &playWriter{p, kind}
+}
+
+type playWriter struct {
+	p    *playEvents
+	kind string
+}
+
+func (w *playWriter) Write(b []byte) (int, error) {
+	w.p.mu.Lock()
+	defer w.p.mu.Unlock()
+	msg := string(bytes.ReplaceAll(b, []byte(w.p.dir), nil))
+	if n := len(w.p.events); n > 0 && w.p.events[n-1].Kind == w.kind {
+		w.p.events[n-1].Message += msg
+	} else {
+		w.p.events = append(w.p.events, playEvent{Message: msg, Kind: w.kind})
+	}
+	return len(b), nil
+}
@@ -0,0 +1,102 @@
+package code2slides
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+)
+
+// A poll directive adds buttons to a slide that viewers of a served deck
+// use to answer a question. The presenter's deck shows the answers as a
+// bar chart, updated as votes arrive over the live event stream.
+// A poll is identified by the number of its slide.
+
+// A pollStore holds the votes of each poll.
+type pollStore struct {
+	mu    sync.Mutex
+	votes map[string]map[string]string // poll to viewer ID to choice
+}
+
+// vote records a viewer's choice in a poll, replacing any earlier choice,
+// and returns the poll's counts.
+func (ps *pollStore) vote(poll, viewer, choice string) map[string]int {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if ps.votes == nil {
+		ps.votes = map[string]map[string]string{}
+	}
+	if ps.votes[poll] == nil {
+		ps.votes[poll] = map[string]string{}
+	}
+	ps.votes[poll][viewer] = choice
+	return countVotes(ps.votes[poll])
+}
+
+// counts returns the counts of every poll that has votes.
+func (ps *pollStore) counts() map[string]map[string]int {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	all := map[string]map[string]int{}
+	for poll, votes := range ps.votes {
+		all[poll] = countVotes(votes)
+	}
+	return all
+}
+
+// voters returns the number of viewers who voted in each poll.
+func (ps *pollStore) voters() map[string]int {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	n := map[string]int{}
+	for poll, votes := range ps.votes {
+		n[poll] = len(votes)
+	}
+	return n
+}
+
+func countVotes(votes map[string]string) map[string]int {
+	counts := map[string]int{}
+	for _, c := range votes {
+		counts[c]++
+	}
+	return counts
+}
+
+// servePollVote records a viewer's vote and sends the poll's new counts
+// to every viewer as a "poll" event.
+func (ds *deckServer) servePollVote(w http.ResponseWriter, r *http.Request) {
+	if _, err := ds.deck(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	poll := r.PathValue("slide")
+	viewer, choice := r.FormValue("viewer"), r.FormValue("choice")
+	ds.mu.Lock()
+	choices, ok := ds.pollChoices[poll]
+	ds.mu.Unlock()
+	if !ok {
+		http.Error(w, "no poll on that slide", http.StatusNotFound)
+		return
+	}
+	if viewer == "" || !slices.Contains(choices, choice) {
+		http.Error(w, "bad vote", http.StatusBadRequest)
+		return
+	}
+	counts := ds.polls.vote(poll, viewer, choice)
+	data, err := json.Marshal(map[string]any{"poll": poll, "counts": counts})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ds.live.broadcast(fmt.Sprintf("event: poll\ndata: %s\n\n", data))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// servePolls serves the counts of all polls as JSON, for a deck that
+// was loaded after voting started.
+func (ds *deckServer) servePolls(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ds.polls.counts())
+}
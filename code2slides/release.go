@@ -0,0 +1,92 @@
+package code2slides
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Slides marked with a hold directive are left out of the deck until
+// their name is released, so that solutions aren't visible while students
+// work on an exercise. A build releases the names given by -release, and
+// a notes build shows every held slide. A served deck's presenter can
+// release a name, now or after a delay, with POST /release/{name}.
+
+// A releaseSchedule records when held slides may be shown.
+type releaseSchedule struct {
+	mu       sync.Mutex
+	releases map[string]release
+}
+
+type release struct {
+	at  time.Time // when the slides may be shown
+	set time.Time // when the release was scheduled
+}
+
+// set schedules the slides held under name for release at time at.
+func (rs *releaseSchedule) set(name string, at time.Time) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.releases == nil {
+		rs.releases = map[string]release{}
+	}
+	rs.releases[name] = release{at: at, set: now()}
+}
+
+// released reports whether the slides held under name may be shown at t.
+func (rs *releaseSchedule) released(name string, t time.Time) bool {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	r, ok := rs.releases[name]
+	return ok && !r.at.After(t)
+}
+
+// changedSince reports whether a release was scheduled or took effect
+// after t and by now.
+func (rs *releaseSchedule) changedSince(t time.Time) bool {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	n := now()
+	for _, r := range rs.releases {
+		if r.set.After(t) || r.at.After(t) && !r.at.After(n) {
+			return true
+		}
+	}
+	return false
+}
+
+// serveRelease releases the slides held under a name, immediately or
+// after the duration given by the "after" form value, and tells viewers
+// to reload the deck when they are released. Only the presenter may
+// release slides.
+func (ds *deckServer) serveRelease(w http.ResponseWriter, r *http.Request) {
+	if ds.live.presenterKey == "" || !secretEqual(r.URL.Query().Get("key"), ds.live.presenterKey) {
+		http.Error(w, "not the presenter", http.StatusForbidden)
+		return
+	}
+	name := r.PathValue("name")
+	if strings.ContainsFunc(name, unicode.IsSpace) {
+		http.Error(w, "bad name", http.StatusBadRequest)
+		return
+	}
+	var after time.Duration
+	if a := r.FormValue("after"); a != "" {
+		var err error
+		after, err = time.ParseDuration(a)
+		if err != nil || after < 0 {
+			http.Error(w, fmt.Sprintf("bad duration %q", a), http.StatusBadRequest)
+			return
+		}
+	}
+	ds.opts.releases.set(name, now().Add(after))
+	ev := fmt.Sprintf("event: release\ndata: %s\n\n", name)
+	if after == 0 {
+		ds.live.broadcast(ev)
+	} else {
+		time.AfterFunc(after, func() { ds.live.broadcast(ev) })
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,70 @@
+package code2slides
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// A manifest can name files of a version of a module, to present code from
+// a real project without a copy that goes stale, like
+//
+//	golang.org/x/sync@v0.10.0//errgroup/errgroup.go
+//
+// The part after "//" is a pattern relative to the module's root. The
+// go command downloads the module through the module proxy, as set by
+// GOPROXY, into the module cache, where the deck reads it.
+
+// moduleDirs caches the directories of downloaded modules, by their
+// "path@version".
+var moduleDirs = map[string]string{}
+
+// splitRemote splits a manifest line that names files of a module, like
+// "github.com/org/repo@v1.2.3//examples/*.go", into the module's path and
+// version and the pattern. It reports whether line names a module.
+func splitRemote(line string) (modPath, version, pattern string, ok bool) {
+	mod, pattern, ok := strings.Cut(line, "//")
+	if !ok {
+		return "", "", "", false
+	}
+	modPath, version, ok = strings.Cut(mod, "@")
+	if !ok || modPath == "" || version == "" || strings.ContainsAny(mod, " \t") {
+		return "", "", "", false
+	}
+	return modPath, version, pattern, true
+}
+
+// moduleDir returns the directory in the module cache of the version of
+// the module, downloading it if needed.
+func moduleDir(modPath, version string) (string, error) {
+	key := modPath + "@" + version
+	if dir, ok := moduleDirs[key]; ok {
+		return dir, nil
+	}
+	cmd := exec.Command("go", "mod", "download", "-json", key)
+	// Outside any module, so the download leaves the deck's go.mod and
+	// go.sum alone.
+	cmd.Dir = os.TempDir()
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	var info struct {
+		Dir   string
+		Error string
+	}
+	// The go command reports errors in the JSON as well as by its status.
+	if jerr := json.Unmarshal(out, &info); jerr == nil && info.Error != "" {
+		return "", fmt.Errorf("downloading %s: %s", key, info.Error)
+	}
+	if err != nil {
+		return "", fmt.Errorf("downloading %s: %v\n%s", key, err, stderr.Bytes())
+	}
+	if info.Dir == "" {
+		return "", fmt.Errorf("downloading %s: no directory", key)
+	}
+	moduleDirs[key] = info.Dir
+	return info.Dir, nil
+}
@@ -0,0 +1,267 @@
+package code2slides
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// The rewrite subcommand makes mechanical changes to the code in slide
+// sources, like moving to a new API. It edits the text of the source
+// at the positions of the syntax it finds, rather than printing the
+// changed syntax tree, so that directive comments stay where they are.
+// When a comment is in the way of a rewrite, it leaves the code alone
+// and reports it, to be changed by hand.
+
+// rewriteRules are the rewrites, by name.
+var rewriteRules = map[string]rewriteRule{
+	"wg-go":  wgGoEdits,
+	"wg-add": wgAddEdits,
+}
+
+// A rewriteRule returns the edits it makes to a file.
+type rewriteRule func(fset *token.FileSet, f *ast.File, src []byte) []edit
+
+// An edit replaces the bytes src[start:end] with text. An edit with a
+// reason for skipping it replaces nothing.
+type edit struct {
+	start, end int
+	text       string
+	skip       string // why the edit wasn't made
+}
+
+// rewriteCommand applies a rewrite rule to slide sources.
+func rewriteCommand(args []string) error {
+	fset := flag.NewFlagSet("rewrite", flag.ExitOnError)
+	rule := fset.String("rule", "", "apply `rule`: wg-go (wg.Add and go to wg.Go) or wg-add (the reverse)")
+	write := fset.Bool("w", false, "write the changes to the files, instead of only reporting them")
+	fset.Usage = func() {
+		fmt.Fprintln(fset.Output(), "usage: code2slides rewrite -rule R [-w] <file or dir>...")
+		fset.PrintDefaults()
+	}
+	fset.Parse(args)
+	r, ok := rewriteRules[*rule]
+	if !ok || fset.NArg() == 0 {
+		fset.Usage()
+		os.Exit(2)
+	}
+	files, err := slideFiles(fset.Args())
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		out, err := rewriteSource(os.Stdout, file, src, r)
+		if err != nil {
+			return err
+		}
+		if *write && !bytes.Equal(out, src) {
+			if err := os.WriteFile(file, out, 0o644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// rewriteSource applies r to src, the contents of file, until it makes no
+// more changes, so that rewrites nested in others are made too. It reports
+// the number of changes and the skipped rewrites on w, and returns the new
+// contents.
+func rewriteSource(w io.Writer, file string, src []byte, r rewriteRule) ([]byte, error) {
+	n := 0
+	for {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, file, src, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		var made, skipped []edit
+		for _, e := range r(fset, f, src) {
+			switch {
+			case e.skip != "":
+				skipped = append(skipped, e)
+			// Make edits that contain others first; the next round gets the rest.
+			case !slices.ContainsFunc(made, func(m edit) bool { return e.start < m.end && m.start < e.end }):
+				made = append(made, e)
+			}
+		}
+		if len(made) == 0 {
+			// Report the skipped rewrites by their lines in the result.
+			tf := fset.File(f.Pos())
+			for _, e := range skipped {
+				fmt.Fprintf(w, "%s:%d: skipped: %s\n", file, tf.Line(tf.Pos(e.start)), e.skip)
+			}
+			if n > 0 {
+				fmt.Fprintf(w, "%s: %d rewritten\n", file, n)
+			}
+			return src, nil
+		}
+		slices.SortFunc(made, func(a, b edit) int { return b.start - a.start })
+		for _, e := range made {
+			src = slices.Concat(src[:e.start], []byte(e.text), src[e.end:])
+		}
+		n += len(made)
+	}
+}
+
+// stmtLists calls f with each list of statements in file.
+func stmtLists(file *ast.File, f func([]ast.Stmt)) {
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			f(n.List)
+		case *ast.CaseClause:
+			f(n.Body)
+		case *ast.CommClause:
+			f(n.Body)
+		}
+		return true
+	})
+}
+
+// methodCall returns the receiver and method name of a call statement
+// like "x.M(args)", and its arguments.
+func methodCall(s ast.Stmt) (recv ast.Expr, name string, args []ast.Expr) {
+	es, ok := s.(*ast.ExprStmt)
+	if !ok {
+		return nil, "", nil
+	}
+	call, ok := es.X.(*ast.CallExpr)
+	if !ok {
+		return nil, "", nil
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return nil, "", nil
+	}
+	return sel.X, sel.Sel.Name, call.Args
+}
+
+// blank reports whether b is only white space.
+func blank(b []byte) bool {
+	return len(bytes.TrimSpace(b)) == 0
+}
+
+// lineIndent returns the indentation of the line containing src[i].
+func lineIndent(src []byte, i int) string {
+	start := bytes.LastIndexByte(src[:i], '\n') + 1
+	end := start
+	for end < len(src) && (src[end] == ' ' || src[end] == '\t') {
+		end++
+	}
+	return string(src[start:end])
+}
+
+// wgGoEdits rewrites
+//
+//	wg.Add(1)
+//	go func() {
+//		defer wg.Done()
+//		BODY
+//	}()
+//
+// to
+//
+//	wg.Go(func() {
+//		BODY
+//	})
+func wgGoEdits(fset *token.FileSet, f *ast.File, src []byte) []edit {
+	off := func(p token.Pos) int { return fset.Position(p).Offset }
+	text := func(n ast.Node) string { return string(src[off(n.Pos()):off(n.End())]) }
+	var edits []edit
+	stmtLists(f, func(list []ast.Stmt) {
+		for i := 0; i+1 < len(list); i++ {
+			recv, name, args := methodCall(list[i])
+			if name != "Add" || len(args) != 1 {
+				continue
+			}
+			if lit, ok := args[0].(*ast.BasicLit); !ok || lit.Value != "1" {
+				continue
+			}
+			gs, ok := list[i+1].(*ast.GoStmt)
+			if !ok || len(gs.Call.Args) != 0 {
+				continue
+			}
+			fn, ok := gs.Call.Fun.(*ast.FuncLit)
+			if !ok || fn.Type.Params.NumFields() != 0 || len(fn.Body.List) == 0 {
+				continue
+			}
+			ds, ok := fn.Body.List[0].(*ast.DeferStmt)
+			if !ok || len(ds.Call.Args) != 0 {
+				continue
+			}
+			sel, ok := ds.Call.Fun.(*ast.SelectorExpr)
+			if !ok || sel.Sel.Name != "Done" || text(sel.X) != text(recv) {
+				continue
+			}
+			e := edit{start: off(list[i].Pos()), end: off(gs.End())}
+			// The text after the defer, to the end of its line.
+			restOfLine := src[off(ds.End()):]
+			restOfLine = restOfLine[:max(0, bytes.IndexByte(restOfLine, '\n'))]
+			switch {
+			case !blank(src[off(list[i].End()):off(gs.Pos())]),
+				!blank(src[off(fn.Body.Lbrace)+1 : off(ds.Pos())]),
+				!blank(restOfLine):
+				e.skip = "comment in the way of wg.Go"
+			default:
+				e.text = text(recv) + ".Go(func() {" + string(src[off(ds.End()):off(fn.Body.Rbrace)]) + "})"
+			}
+			edits = append(edits, e)
+		}
+	})
+	return edits
+}
+
+// wgAddEdits reverses wgGoEdits, rewriting
+//
+//	wg.Go(func() {
+//		BODY
+//	})
+//
+// to
+//
+//	wg.Add(1)
+//	go func() {
+//		defer wg.Done()
+//		BODY
+//	}()
+func wgAddEdits(fset *token.FileSet, f *ast.File, src []byte) []edit {
+	off := func(p token.Pos) int { return fset.Position(p).Offset }
+	text := func(n ast.Node) string { return string(src[off(n.Pos()):off(n.End())]) }
+	var edits []edit
+	stmtLists(f, func(list []ast.Stmt) {
+		for _, s := range list {
+			recv, name, args := methodCall(s)
+			if name != "Go" || len(args) != 1 {
+				continue
+			}
+			fn, ok := args[0].(*ast.FuncLit)
+			if !ok || fn.Type.Params.NumFields() != 0 {
+				continue
+			}
+			e := edit{start: off(s.Pos()), end: off(s.End())}
+			body := string(src[off(fn.Body.Lbrace)+1 : off(fn.Body.Rbrace)])
+			if !strings.HasPrefix(strings.TrimLeft(body, " \t"), "\n") {
+				e.skip = "body of wg.Go must start on a new line"
+			} else {
+				indent := lineIndent(src, e.start)
+				e.text = text(recv) + ".Add(1)\n" +
+					indent + "go func() {\n" +
+					indent + "\tdefer " + text(recv) + ".Done()" + strings.TrimLeft(body, " \t") + "}()"
+			}
+			edits = append(edits, e)
+		}
+	})
+	return edits
+}
@@ -0,0 +1,321 @@
+package code2slides
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A sandbox runs the go command on code from a deck. Decks may include
+// code written by students, so it shouldn't run directly on the
+// presenter's machine unless the presenter trusts it.
+type sandbox interface {
+	// run runs "go ARGS..." in req.dir. It returns the command's exit code,
+	// or an error if the command could not be run at all.
+	run(ctx context.Context, req execRequest) (exitCode int, err error)
+}
+
+// An execRequest describes a run of the go command.
+type execRequest struct {
+	dir            string   // directory to run in
+	args           []string // arguments to the go command
+	env            []string // additional environment variables, as KEY=VALUE
+	mounts         []string // other directories the command reads, like those of -overlay files
+	stdout, stderr io.Writer
+}
+
+// The defaults of the options that say how deck code runs.
+const (
+	defaultSandbox      = "local"
+	defaultSandboxImage = "golang:1.26"
+	defaultExecTimeout  = 2 * time.Minute
+	defaultExecOutput   = 1 << 20
+)
+
+// defaultOutputCache returns the default directory for cached output,
+// in the user's cache directory.
+func defaultOutputCache() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "code2slides", "output")
+}
+
+// SandboxFlags defines in fs the flags that say how deck code runs,
+// which set the fields of c of the same names.
+func (c *Config) SandboxFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Sandbox, "sandbox", cmp.Or(c.Sandbox, defaultSandbox), "run deck code in `sandbox`: local, docker or gvisor")
+	fs.StringVar(&c.SandboxImage, "sandbox-image", cmp.Or(c.SandboxImage, defaultSandboxImage), "container `image` for the docker and gvisor sandboxes")
+	fs.DurationVar(&c.ExecTimeout, "exec-timeout", cmp.Or(c.ExecTimeout, defaultExecTimeout), "stop deck code that runs longer than `duration`")
+	fs.IntVar(&c.ExecOutput, "exec-output", cmp.Or(c.ExecOutput, defaultExecOutput), "stop deck code that writes more than `n` bytes of output")
+	fs.IntVar(&c.ExecParallel, "exec-parallel", cmp.Or(c.ExecParallel, runtime.NumCPU()), "run at most `n` pieces of deck code at once")
+	fs.BoolVar(&c.NoExec, "no-exec", c.NoExec, "don't run code for output auto, but use its cached output")
+	fs.StringVar(&c.OutputCache, "output-cache", cmp.Or(c.OutputCache, defaultOutputCache()), "cache the output of output auto in `dir`")
+}
+
+// An executor runs deck code in a sandbox, within limits.
+type executor struct {
+	sandbox   sandbox
+	timeout   time.Duration // stop code that runs longer
+	maxOutput int           // stop code that writes more bytes of output
+	sem       chan struct{} // limits the number of concurrent runs; none if nil
+}
+
+// newExecutor returns the executor that the sandbox options of cfg
+// describe.
+func newExecutor(cfg Config) (*executor, error) {
+	e := &executor{
+		timeout:   cmp.Or(cfg.ExecTimeout, defaultExecTimeout),
+		maxOutput: cmp.Or(cfg.ExecOutput, defaultExecOutput),
+	}
+	parallel := cmp.Or(cfg.ExecParallel, runtime.NumCPU())
+	if parallel < 1 {
+		return nil, fmt.Errorf("-exec-parallel must be positive")
+	}
+	e.sem = make(chan struct{}, parallel)
+	switch name := cmp.Or(cfg.Sandbox, defaultSandbox); name {
+	case "local":
+		e.sandbox = localSandbox{}
+	case "docker", "gvisor":
+		modCache, err := exec.Command("go", "env", "GOMODCACHE").Output()
+		if err != nil {
+			return nil, fmt.Errorf("finding GOMODCACHE: %w", err)
+		}
+		sb := containerSandbox{image: cmp.Or(cfg.SandboxImage, defaultSandboxImage), modCache: strings.TrimSpace(string(modCache))}
+		if name == "gvisor" {
+			sb.runtime = "runsc"
+		}
+		e.sandbox = sb
+	default:
+		return nil, fmt.Errorf("unknown sandbox %q: want local, docker or gvisor", name)
+	}
+	return e, nil
+}
+
+// localSandbox runs code as an ordinary process.
+type localSandbox struct{}
+
+func (localSandbox) run(ctx context.Context, req execRequest) (int, error) {
+	cmd := exec.CommandContext(ctx, "go", req.args...)
+	cmd.Dir = req.dir
+	cmd.Env = append(os.Environ(), req.env...)
+	cmd.Stdout = req.stdout
+	cmd.Stderr = req.stderr
+	// If the command is killed, don't wait long for its children
+	// (like a test binary) to close their output.
+	cmd.WaitDelay = time.Second
+	return exitCode(cmd.Run())
+}
+
+// containerSandbox runs code in a Docker container without network access.
+// The module containing the code is mounted read-only at the same path it
+// has on the host, so file names in the output are the same as for
+// localSandbox. So is the host's module cache, since the container can't
+// download modules: a module's dependencies must be in the cache or
+// vendored.
+type containerSandbox struct {
+	image    string // must contain the go command
+	runtime  string // OCI runtime, like "runsc" for gVisor; if empty, Docker's default
+	modCache string // the host's GOMODCACHE; none if empty
+	name     string // container name; set by run
+}
+
+func (s containerSandbox) run(ctx context.Context, req execRequest) (int, error) {
+	s.name = fmt.Sprintf("code2slides-%d-%x", os.Getpid(), rand.Uint64())
+	args, err := s.dockerArgs(req)
+	if err != nil {
+		return 0, err
+	}
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Stdout = req.stdout
+	cmd.Stderr = req.stderr
+	// Killing the docker command doesn't stop the container.
+	cmd.Cancel = func() error {
+		exec.Command("docker", "kill", s.name).Run()
+		return cmd.Process.Kill()
+	}
+	cmd.WaitDelay = time.Second
+	code, err := exitCode(cmd.Run())
+	// docker run exits with 125 if it can't start the container.
+	if err == nil && code == 125 {
+		err = errors.New("docker run failed")
+	}
+	return code, err
+}
+
+// dockerArgs returns the arguments to the docker command for req.
+func (s containerSandbox) dockerArgs(req execRequest) ([]string, error) {
+	dir, err := filepath.Abs(req.dir)
+	if err != nil {
+		return nil, err
+	}
+	root := moduleRoot(dir)
+	args := []string{"run", "--rm", "--network=none"}
+	if s.name != "" {
+		args = append(args, "--name="+s.name)
+	}
+	if s.runtime != "" {
+		args = append(args, "--runtime="+s.runtime)
+	}
+	mod := "-mod=readonly"
+	if _, err := os.Stat(filepath.Join(root, "vendor", "modules.txt")); err == nil {
+		mod = "-mod=vendor"
+	}
+	if err := s.checkToolchain(req.env); err != nil {
+		return nil, err
+	}
+	args = append(args, "--tmpfs=/tmp")
+	env := []string{"GOCACHE=/tmp/gocache", "GOTOOLCHAIN=local", "GOFLAGS=" + mod, "GOPROXY=off"}
+	mounts := req.mounts
+	if s.modCache != "" {
+		env = append(env, "GOMODCACHE="+s.modCache)
+		mounts = append([]string{s.modCache}, mounts...)
+	}
+	// The request's settings replace the defaults.
+	env = slices.DeleteFunc(env, func(e string) bool {
+		k, _, _ := strings.Cut(e, "=")
+		return slices.ContainsFunc(req.env, func(r string) bool { return strings.HasPrefix(r, k+"=") })
+	})
+	for _, e := range append(env, req.env...) {
+		args = append(args, "--env="+e)
+	}
+	for _, m := range mounts {
+		args = append(args, "--volume="+m+":"+m+":ro")
+	}
+	args = append(args,
+		"--volume="+root+":"+root+":ro",
+		"--workdir="+dir,
+		s.image, "go")
+	return append(args, req.args...), nil
+}
+
+// checkToolchain returns an error if env selects a Go toolchain with
+// GOTOOLCHAIN that the container would have to download. Toolchains are
+// modules, so the container finds those in the host's module cache; it
+// also has the one in the image, if the image's tag names its exact version.
+func (s containerSandbox) checkToolchain(env []string) error {
+	var version string
+	for _, e := range env {
+		if v, ok := strings.CutPrefix(e, "GOTOOLCHAIN="); ok {
+			version, _, _ = strings.Cut(v, "+")
+		}
+	}
+	if version == "" || version == "local" || version == "auto" || version == "path" {
+		return nil
+	}
+	if _, tag, _ := strings.Cut(s.image, ":"); "go"+tag == version {
+		return nil
+	}
+	zip := fmt.Sprintf("v0.0.1-%s.linux-%s.zip", version, runtime.GOARCH)
+	if s.modCache != "" {
+		if _, err := os.Stat(filepath.Join(s.modCache, "cache", "download", "golang.org", "toolchain", "@v", zip)); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("GOTOOLCHAIN=%s: the toolchain is not in the module cache, and the sandbox can't download it; run \"GOTOOLCHAIN=%[1]s go version\" to get it", version)
+}
+
+// moduleRoot returns the directory of the go.mod file for dir, or dir
+// itself if there is none.
+func moduleRoot(dir string) string {
+	for d := dir; ; {
+		if _, err := os.Stat(filepath.Join(d, "go.mod")); err == nil {
+			return d
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return dir
+		}
+		d = parent
+	}
+}
+
+// run runs req in the executor's sandbox, subject to its limits. If the
+// code is stopped because it reached a limit, run returns a note saying
+// so, for display after its output.
+func (e *executor) run(ctx context.Context, req execRequest) (code int, note string, err error) {
+	if e.sem != nil {
+		e.sem <- struct{}{}
+		defer func() { <-e.sem }()
+	}
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	lim := &outputLimit{remaining: e.maxOutput, exceeded: cancel}
+	if req.stdout != nil {
+		req.stdout = &limitWriter{req.stdout, lim}
+	}
+	if req.stderr != nil {
+		req.stderr = &limitWriter{req.stderr, lim}
+	}
+	code, err = e.sandbox.run(ctx, req)
+	switch {
+	case lim.truncated():
+		return code, fmt.Sprintf("... (stopped after %d bytes of output)\n", e.maxOutput), nil
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return code, fmt.Sprintf("... (timed out after %s)\n", e.timeout), nil
+	}
+	return code, "", err
+}
+
+// An outputLimit is the amount of output that code may still write.
+// It is shared between the code's stdout and stderr.
+type outputLimit struct {
+	mu        sync.Mutex
+	remaining int
+	over      bool
+	exceeded  func() // called when the limit is exceeded
+}
+
+func (l *outputLimit) truncated() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.over
+}
+
+// A limitWriter writes to w until its limit is exceeded, then
+// discards its input.
+type limitWriter struct {
+	w   io.Writer
+	lim *outputLimit
+}
+
+func (lw *limitWriter) Write(p []byte) (int, error) {
+	l := lw.lim
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := len(p)
+	if len(p) > l.remaining {
+		p = p[:l.remaining]
+		if !l.over {
+			l.over = true
+			l.exceeded()
+		}
+	}
+	l.remaining -= len(p)
+	if _, err := lw.w.Write(p); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// exitCode converts the error from running a command into an exit code.
+func exitCode(err error) (int, error) {
+	var ee *exec.ExitError
+	if errors.As(err, &ee) {
+		return ee.ExitCode(), nil
+	}
+	return 0, err
+}
@@ -0,0 +1,419 @@
+package code2slides
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"html"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// The sequence directive draws the communication between the goroutines
+// of a small example as it actually happened. The build runs the package
+// with each send, receive and close replaced by a call that does the same
+// and reports it, using go's -overlay flag so the source files are left
+// alone. Then it draws a sequence diagram from the reports: a lifeline for
+// each goroutine, and an arrow from each send to the receive that took the
+// value, so the arrows of an unbuffered channel are level and those of a
+// buffered one slope down from the send to the later receive.
+//
+// Sends and receives in select statements, and receives by range loops,
+// are not reported.
+
+// traceEventPrefix begins the lines of output that report events.
+const traceEventPrefix = "\x00code2slides-trace "
+
+// maxTraceEvents is the most events a sequence diagram shows.
+const maxTraceEvents = 60
+
+// A traceEvent is a send, receive or close reported by instrumented code.
+type traceEvent struct {
+	G     int    `json:"g"`     // goroutine ID
+	Op    string `json:"op"`    // send, recv, closed (a receive of a closed channel's zero value) or close
+	Ch    string `json:"ch"`    // address of the channel
+	Name  string `json:"name"`  // channel expression, as written
+	Value string `json:"value"` // value sent or received
+}
+
+// sequenceDiagram returns an SVG sequence diagram of the channel operations
+// of the package in dir, run as for autoOutput with args. The diagram is
+// cached like output.
+func sequenceDiagram(o *options, dir string, args []string) (string, error) {
+	key, err := outputKey(dir, append([]string{"sequence"}, args...))
+	if err != nil {
+		return "", err
+	}
+	var cacheFile string
+	if o.outputCache != "" {
+		cacheFile = filepath.Join(o.outputCache, key+".svg")
+		if svg, err := os.ReadFile(cacheFile); err == nil {
+			return string(svg), nil
+		}
+	}
+	if o.noExec {
+		return "<p>(no diagram: built with -no-exec, and none is cached)</p>", nil
+	}
+
+	tmp, err := os.MkdirTemp("", "code2slides-trace")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	overlay, err := traceOverlay(dir, tmp)
+	if err != nil {
+		return "", err
+	}
+	var name string // of the test to run
+	if len(args) > 0 && testNameRe.MatchString(args[0]) {
+		name, args = args[0], args[1:]
+	}
+	flags, env := splitEnv(args)
+	goArgs := slices.Concat([]string{"run", "-overlay", overlay}, flags, []string{"."})
+	if name != "" {
+		goArgs = slices.Concat([]string{"test", "-count=1", "-run", "^" + name + "$", "-overlay", overlay}, flags)
+	}
+	var out bytes.Buffer
+	code, note, err := o.exec.run(o.ctx, execRequest{
+		dir:    dir,
+		args:   goArgs,
+		env:    env,
+		mounts: []string{tmp},
+		stdout: &out,
+		stderr: &out,
+	})
+	if err != nil {
+		return "", fmt.Errorf("sequence: %w", err)
+	}
+	if note != "" {
+		return "", fmt.Errorf("sequence: %s", strings.TrimPrefix(strings.TrimSpace(note), "... "))
+	}
+	var events []traceEvent
+	var other strings.Builder // output that isn't events
+	sc := bufio.NewScanner(&out)
+	for sc.Scan() {
+		line := sc.Text()
+		i := strings.Index(line, traceEventPrefix)
+		if i < 0 {
+			fmt.Fprintln(&other, line)
+			continue
+		}
+		var e traceEvent
+		if err := json.Unmarshal([]byte(line[i+len(traceEventPrefix):]), &e); err != nil {
+			return "", fmt.Errorf("sequence: bad event: %v", err)
+		}
+		events = append(events, e)
+	}
+	if code != 0 {
+		return "", fmt.Errorf("sequence: go %s failed:\n%s", goArgs[0], other.String())
+	}
+	if len(events) == 0 {
+		return "", fmt.Errorf("sequence: no channel operations to draw")
+	}
+	svg := sequenceSVG(events)
+	if cacheFile != "" {
+		if err := os.MkdirAll(o.outputCache, 0o755); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(cacheFile, []byte(svg), 0o644); err != nil {
+			return "", err
+		}
+	}
+	return svg, nil
+}
+
+// traceOverlay writes instrumented copies of the Go files of the package
+// in dir to tmp, along with the functions they call to report events, and
+// returns the name of an overlay file for go's -overlay flag that puts
+// them in place of the originals.
+func traceOverlay(dir, tmp string) (string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	files, err := filepath.Glob(filepath.Join(absDir, "*.go"))
+	if err != nil {
+		return "", err
+	}
+	replace := map[string]string{}
+	pkg := ""
+	for i, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, file, src, parser.SkipObjectResolution)
+		if err != nil {
+			return "", fmt.Errorf("sequence: %v", err)
+		}
+		// External tests can't see the package's reporting functions.
+		if strings.HasSuffix(f.Name.Name, "_test") {
+			continue
+		}
+		pkg = f.Name.Name
+		edits := traceEdits(fset, f, src)
+		if len(edits) == 0 {
+			continue
+		}
+		name := filepath.Join(tmp, fmt.Sprintf("%d-%s", i, filepath.Base(file)))
+		if err := os.WriteFile(name, applyEdits(src, edits), 0o644); err != nil {
+			return "", err
+		}
+		replace[file] = name
+	}
+	if pkg == "" {
+		return "", fmt.Errorf("sequence: no Go files in %s", dir)
+	}
+	helper := filepath.Join(tmp, "trace.go")
+	if err := os.WriteFile(helper, fmt.Appendf(nil, traceHelper, pkg, strconv.Quote(traceEventPrefix)), 0o644); err != nil {
+		return "", err
+	}
+	replace[filepath.Join(absDir, "zz_code2slides_trace.go")] = helper
+	data, err := json.Marshal(map[string]any{"Replace": replace})
+	if err != nil {
+		return "", err
+	}
+	overlay := filepath.Join(tmp, "overlay.json")
+	return overlay, os.WriteFile(overlay, data, 0o644)
+}
+
+// traceEdits returns the edits to src, the source of f, that replace its
+// channel operations with calls of the functions in traceHelper.
+func traceEdits(fset *token.FileSet, f *ast.File, src []byte) []edit {
+	tf := fset.File(f.Pos())
+	off := func(p token.Pos) int { return tf.Offset(p) }
+	text := func(n ast.Node) string { return strconv.Quote(string(src[off(n.Pos()):off(n.End())])) }
+	var edits []edit
+	pairs := map[*ast.UnaryExpr]bool{} // receives of a value and ok
+	recv := func(u *ast.UnaryExpr, fn string) {
+		edits = append(edits,
+			edit{start: off(u.OpPos), end: off(u.OpPos) + len("<-"), text: fn + "("},
+			edit{start: off(u.X.End()), end: off(u.X.End()), text: ", " + text(u.X) + ")"})
+	}
+	var visit func(n ast.Node) bool
+	visit = func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectStmt:
+			for _, c := range n.Body.List {
+				for _, s := range c.(*ast.CommClause).Body {
+					ast.Inspect(s, visit)
+				}
+			}
+			return false
+		case *ast.SendStmt:
+			edits = append(edits,
+				edit{start: off(n.Pos()), end: off(n.Pos()), text: "code2slidesSend("},
+				edit{start: off(n.Arrow), end: off(n.Arrow) + len("<-"), text: ","},
+				edit{start: off(n.End()), end: off(n.End()), text: ", " + text(n.Chan) + ")"})
+		case *ast.AssignStmt:
+			if len(n.Lhs) == 2 && len(n.Rhs) == 1 {
+				if u, ok := n.Rhs[0].(*ast.UnaryExpr); ok && u.Op == token.ARROW {
+					pairs[u] = true
+				}
+			}
+		case *ast.ValueSpec:
+			if len(n.Names) == 2 && len(n.Values) == 1 {
+				if u, ok := n.Values[0].(*ast.UnaryExpr); ok && u.Op == token.ARROW {
+					pairs[u] = true
+				}
+			}
+		case *ast.UnaryExpr:
+			if n.Op != token.ARROW {
+				break
+			}
+			if pairs[n] {
+				recv(n, "code2slidesRecv2")
+			} else {
+				recv(n, "code2slidesRecv")
+			}
+		case *ast.CallExpr:
+			if id, ok := n.Fun.(*ast.Ident); ok && id.Name == "close" && len(n.Args) == 1 {
+				edits = append(edits,
+					edit{start: off(id.Pos()), end: off(id.End()), text: "code2slidesClose"},
+					edit{start: off(n.Rparen), end: off(n.Rparen), text: ", " + text(n.Args[0])})
+			}
+		}
+		return true
+	}
+	ast.Inspect(f, visit)
+	return edits
+}
+
+// applyEdits returns src with edits made. The edits must not overlap,
+// though an insertion can be at the start or end of another edit.
+func applyEdits(src []byte, edits []edit) []byte {
+	slices.SortStableFunc(edits, func(a, b edit) int {
+		if a.start != b.start {
+			return b.start - a.start
+		}
+		// Going backward, replace before inserting, so that an
+		// insertion ends up before the text that replaces.
+		return (b.end - b.start) - (a.end - a.start)
+	})
+	for _, e := range edits {
+		src = slices.Concat(src[:e.start], []byte(e.text), src[e.end:])
+	}
+	return src
+}
+
+// traceHelper is the source of the functions that instrumented code calls
+// in place of channel operations. Its arguments are the package name and
+// the quoted traceEventPrefix.
+const traceHelper = `package %s
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var code2slidesTraceMu sync.Mutex
+
+func code2slidesTrace(op string, ch any, name string, v any) {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	g, _ := strconv.Atoi(strings.Fields(string(buf))[1])
+	value := ""
+	if v != nil {
+		value = fmt.Sprint(v)
+	}
+	data, _ := json.Marshal(map[string]any{"g": g, "op": op, "ch": fmt.Sprintf("%%p", ch), "name": name, "value": value})
+	code2slidesTraceMu.Lock()
+	defer code2slidesTraceMu.Unlock()
+	fmt.Fprintf(os.Stderr, "%%s%%s\n", %s, data)
+}
+
+func code2slidesSend[T any](ch chan<- T, v T, name string) {
+	ch <- v
+	code2slidesTrace("send", ch, name, v)
+}
+
+func code2slidesRecv[T any](ch <-chan T, name string) T {
+	v, _ := code2slidesRecv2(ch, name)
+	return v
+}
+
+func code2slidesRecv2[T any](ch <-chan T, name string) (T, bool) {
+	v, ok := <-ch
+	if ok {
+		code2slidesTrace("recv", ch, name, v)
+	} else {
+		code2slidesTrace("closed", ch, name, nil)
+	}
+	return v, ok
+}
+
+func code2slidesClose[T any](ch chan<- T, name string) {
+	close(ch)
+	code2slidesTrace("close", ch, name, nil)
+}
+`
+
+// Dimensions of sequence diagrams, in pixels.
+const (
+	seqLeft    = 60  // x of the first lifeline
+	seqSpacing = 140 // between lifelines
+	seqTop     = 50  // y of the first event
+	seqRow     = 26  // between events
+)
+
+// sequenceSVG returns a sequence diagram of events.
+func sequenceSVG(events []traceEvent) string {
+	more := 0
+	if len(events) > maxTraceEvents {
+		more = len(events) - maxTraceEvents
+		events = events[:maxTraceEvents]
+	}
+	var gs []int // goroutines, in order of appearance
+	for _, e := range events {
+		if !slices.Contains(gs, e.G) {
+			gs = append(gs, e.G)
+		}
+	}
+	x := func(g int) int { return seqLeft + seqSpacing*slices.Index(gs, g) }
+	y := func(i int) int { return seqTop + seqRow*i }
+	width := seqLeft*2 + seqSpacing*(len(gs)-1)
+	height := y(len(events)) + 10
+	if more > 0 {
+		height += seqRow
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<svg xmlns='http://www.w3.org/2000/svg' class='sequence' width='%d' height='%d' viewBox='0 0 %[1]d %[2]d'>\n", width, height)
+	b.WriteString("<defs><marker id='seq-arrow' viewBox='0 0 10 10' refX='10' refY='5' markerWidth='8' markerHeight='8' orient='auto-start-reverse'><path d='M 0 0 L 10 5 L 0 10 z'/></marker></defs>\n")
+	for i, g := range gs {
+		fmt.Fprintf(&b, "<text class='goroutine' x='%d' y='20' text-anchor='middle'>G%d</text>\n", x(g), i+1)
+		fmt.Fprintf(&b, "<line class='lifeline' x1='%d' y1='28' x2='%[1]d' y2='%d' stroke='#aaa' stroke-dasharray='4 4'/>\n", x(g), height-10)
+	}
+	label := func(x, y int, s string) {
+		fmt.Fprintf(&b, "<text x='%d' y='%d' text-anchor='middle' font-size='12'>%s</text>\n", x, y-4, html.EscapeString(s))
+	}
+	arrow := func(x1, y1, x2, y2 int, class string) {
+		dash := ""
+		if class == "closed" {
+			dash = " stroke-dasharray='6 3'"
+		}
+		fmt.Fprintf(&b, "<line class='%s' x1='%d' y1='%d' x2='%d' y2='%d' stroke='black'%s marker-end='url(#seq-arrow)'/>\n", class, x1, y1, x2, y2, dash)
+	}
+
+	// Match each receive with the send of its value: channels are FIFO.
+	sends := map[string][]int{} // channel to the events of its unreceived sends
+	closer := map[string]int{}  // channel to the event that closed it
+	received := map[int]bool{}  // sends that were received
+	for i, e := range events {
+		switch e.Op {
+		case "send":
+			sends[e.Ch] = append(sends[e.Ch], i)
+		case "close":
+			closer[e.Ch] = i
+			fmt.Fprintf(&b, "<circle cx='%d' cy='%d' r='4' fill='black'/>\n", x(e.G), y(i))
+			label(x(e.G), y(i)-4, "close("+e.Name+")")
+		}
+	}
+	for i, e := range events {
+		switch e.Op {
+		case "recv":
+			q := sends[e.Ch]
+			if len(q) == 0 {
+				// The send isn't among the events shown.
+				continue
+			}
+			s := q[0]
+			sends[e.Ch] = q[1:]
+			received[s] = true
+			arrow(x(events[s].G), y(s), x(e.G), y(i), "message")
+			label((x(events[s].G)+x(e.G))/2, (y(s)+y(i))/2, e.Name+": "+e.Value)
+		case "closed":
+			c, ok := closer[e.Ch]
+			if !ok {
+				continue
+			}
+			arrow(x(events[c].G), y(c), x(e.G), y(i), "closed")
+			label((x(events[c].G)+x(e.G))/2, (y(c)+y(i))/2, e.Name+" closed")
+		}
+	}
+	for i, e := range events {
+		if e.Op == "send" && !received[i] {
+			// Still in the buffer, or received after the last event shown.
+			fmt.Fprintf(&b, "<circle cx='%d' cy='%d' r='4' fill='white' stroke='black'/>\n", x(e.G), y(i))
+			label(x(e.G), y(i)-4, e.Name+" <- "+e.Value)
+		}
+	}
+	if more > 0 {
+		label(width/2, height-4, fmt.Sprintf("... (%d more events)", more))
+	}
+	b.WriteString("</svg>")
+	return b.String()
+}
@@ -0,0 +1,387 @@
+package code2slides
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// serveCommand implements "code2slides serve", which serves a deck over
+// HTTP, rebuilding it whenever one of its source files changes.
+func serveCommand(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	var cfg Config
+	addr := fs.String("addr", "localhost:8080", "listen on `address`")
+	fs.StringVar(&cfg.Title, "title", "Title", "HTML page title")
+	fs.StringVar(&cfg.Manifest, "manifest", "", "read the deck's files and parts from `file`")
+	staticDir := fs.String("static", "static", "serve /static/ from `dir`")
+	fs.BoolVar(&cfg.Notes, "notes", false, "include notes and answers in output")
+	fs.StringVar(&cfg.Analytics, "analytics", "", "report slide viewing times and revealed answers to `URL`")
+	fs.BoolVar(&cfg.Presenter, "presenter", false, "show notes in a presenter window, opened with 'N'")
+	fs.Func("release", "include the slides held under the comma-separated `names`", listFlag(&cfg.Release))
+	fs.Func("tags", "keep the lines in if directives for the comma-separated `tags`", listFlag(&cfg.Tags))
+	fs.BoolVar(&cfg.NumberByPart, "number-by-part", false, "number exercises and questions from 1 in each part")
+	fs.BoolVar(&cfg.TOC, "toc", false, "add a table of contents after the title slide, unless the deck has a toc directive")
+	fs.StringVar(&cfg.Since, "since", "", "build only the slides that are new or changed since the git `ref`")
+	fs.StringVar(&cfg.Headings, "headings", "", "take headings and subtitles for slide files from the headings `file`")
+	fs.StringVar(&cfg.HeadingFallback, "heading-fallback", "file", "head a file's first slide, if it has no heading, with its `file` name, or a name made from it")
+	fs.StringVar(&cfg.Overflow, "overflow", "", "`fit`, scroll or split code too long for its slide, unless its code directive says")
+	fs.IntVar(&cfg.SplitLines, "split-lines", 20, "split code onto the next slide after `n` lines")
+	fs.StringVar(&cfg.AnswerSummary, "answer-summary", "Answer", "show `text` to reveal an answer that follows hints or has no question text")
+	fs.Func("doc-links", "link references to the comma-separated `packages` to their documentation", listFlag(&cfg.DocLinks))
+	fs.BoolVar(&cfg.ChanOps, "chan-ops", false, "style channel operations in code")
+	fs.BoolVar(&cfg.MutexOps, "mutex-ops", false, "style mutex operations and the fields that mutexes guard in code")
+	fs.Func("transform", "rewrite the slides of each file with the `command`, as JSON on its stdin and stdout (repeatable)", func(s string) error {
+		if strings.TrimSpace(s) == "" {
+			return fmt.Errorf("empty transform command")
+		}
+		cfg.Transforms = append(cfg.Transforms, s)
+		return nil
+	})
+	fs.StringVar(&cfg.Footer, "footer", "", "put the license or attribution `markdown` at the foot of every slide")
+	fs.StringVar(&cfg.Comment, "comment", "", "directives follow line comments beginning with `prefix`, in every file")
+	cfg.SandboxFlags(fs)
+	basicAuth := fs.String("auth", "", "require HTTP basic authentication with `user:password`")
+	token := fs.String("token", "", "require `token`, given as ?token=, a bearer token or a cookie")
+	certFile := fs.String("cert", "", "serve HTTPS using the certificate in `file`")
+	keyFile := fs.String("key", "", "private key `file` for -cert")
+	annotations := fs.String("annotations", "", "save drawings on slides to `file`")
+	presenterKey := fs.String("presenter-key", "", "share the pointer of the browser that opens the deck with ?presenter=`key`")
+	play := fs.Bool("play", false, "run code with the play attribute in the -sandbox when readers press Run")
+	drain := fs.Duration("drain", 5*time.Second, "on interrupt, wait up to `duration` for connections to finish")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: code2slides serve [flags] [-manifest file] <file>...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	cfg.Files = fs.Args()
+	o, err := cfg.options(context.Background())
+	if err != nil {
+		return err
+	}
+	parts, err := cfg.parts()
+	if err != nil {
+		return err
+	}
+	if len(partFiles(parts)) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if (*certFile == "") != (*keyFile == "") {
+		return fmt.Errorf("-cert and -key must be used together")
+	}
+
+	ds := &deckServer{title: cfg.Title, parts: parts, opts: o}
+	ds.annotations.file = *annotations
+	ds.live.presenterKey = *presenterKey
+	ds.live.hasSlide = ds.isSlide
+	if err := ds.annotations.load(); err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/{$}", ds)
+	mux.HandleFunc("GET /live", ds.live.serveEvents)
+	mux.HandleFunc("POST /live/slide", ds.live.serveSlide)
+	mux.HandleFunc("POST /live/pointer", ds.live.servePointer)
+	mux.HandleFunc("POST /release/{name}", ds.serveRelease)
+	mux.HandleFunc("POST /poll/{slide}", ds.servePollVote)
+	mux.HandleFunc("GET /polls", ds.servePolls)
+	mux.HandleFunc("GET /metrics", ds.serveMetrics)
+	mux.HandleFunc("GET /livetest", ds.serveLiveTest)
+	mux.HandleFunc("GET /annotations", ds.annotations.serveAll)
+	mux.HandleFunc("PUT /annotations/{slide}", ds.serveAnnotation)
+	if *play {
+		mux.HandleFunc("POST /compile", ds.servePlay)
+	}
+	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir(*staticDir))))
+	// Images and links refer to files relative to the current directory.
+	mux.HandleFunc("/", ds.serveFile)
+
+	var auth authConfig
+	if *basicAuth != "" {
+		var ok bool
+		auth.user, auth.password, ok = strings.Cut(*basicAuth, ":")
+		if !ok || auth.user == "" {
+			return fmt.Errorf("-auth: want user:password")
+		}
+	}
+	auth.token = *token
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: auth.wrap(mux)}
+	srv.RegisterOnShutdown(ds.live.shutdown)
+	scheme := "http"
+	if *certFile != "" {
+		scheme = "https"
+	}
+	log.Printf("serving on %s://%s", scheme, ln.Addr())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return runServer(ctx, srv, ln, *certFile, *keyFile, *drain)
+}
+
+// listFlag returns the function of a flag that adds the items of a
+// comma-separated list to *list.
+func listFlag(list *[]string) func(string) error {
+	return func(s string) error {
+		for item := range strings.SplitSeq(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*list = append(*list, item)
+			}
+		}
+		return nil
+	}
+}
+
+// runServer serves on ln until ctx is done. Then it stops accepting
+// connections and waits up to drain for in-flight requests to finish
+// before closing the remaining connections.
+func runServer(ctx context.Context, srv *http.Server, ln net.Listener, certFile, keyFile string, drain time.Duration) error {
+	errc := make(chan error, 1)
+	go func() {
+		if certFile != "" {
+			errc <- srv.ServeTLS(ln, certFile, keyFile)
+		} else {
+			errc <- srv.Serve(ln)
+		}
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("shutting down")
+	sctx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+	if err := srv.Shutdown(sctx); err != nil {
+		log.Printf("not all connections drained after %s; closing them", drain)
+		return srv.Close()
+	}
+	return nil
+}
+
+// A deckServer serves the HTML for a deck, rebuilding it when a source
+// file has changed since the last build.
+type deckServer struct {
+	title string
+	parts []part
+	opts  *options // the options to build with
+
+	live        liveHub
+	annotations annotationStore
+	polls       pollStore
+
+	mu            sync.Mutex
+	html          []byte
+	built         time.Time // when html was built
+	numSlides     int
+	files         map[string]bool     // the files the deck refers to, which it may serve
+	liveTests     map[liveTest]bool   // the deck's livetest directives
+	pollChoices   map[string][]string // the choices of each poll, by slide number
+	rebuilds      int
+	rebuildTime   time.Duration // total time spent rebuilding
+	rebuildErrors int
+}
+
+// liveScript connects a served deck to the server. See [liveHub].
+const liveScript = "<script src='static/live.js'></script>\n"
+
+func (ds *deckServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	html, err := ds.deck()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	i := bytes.LastIndex(html, []byte("</body>"))
+	if i < 0 {
+		i = len(html)
+	}
+	w.Write(html[:i])
+	io.WriteString(w, liveScript)
+	w.Write(html[i:])
+}
+
+// deck returns the deck's HTML, rebuilding it if necessary.
+func (ds *deckServer) deck() ([]byte, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	if ds.html != nil && !ds.changedSince(ds.built) && !ds.opts.releases.changedSince(ds.built) {
+		return ds.html, nil
+	}
+	start := time.Now()
+	var buf bytes.Buffer
+	slides, err := writeDeck(ds.opts, &buf, "", ds.title, ds.parts)
+	ds.rebuilds++
+	ds.rebuildTime += time.Since(start)
+	if err != nil {
+		ds.rebuildErrors++
+		return nil, err
+	}
+	ds.html = buf.Bytes()
+	ds.built = start
+	ds.numSlides = len(slides)
+	ds.files = referencedFiles(ds.html)
+	ds.liveTests = map[liveTest]bool{}
+	ds.pollChoices = map[string][]string{}
+	for i, s := range slides {
+		for _, sec := range s.sections {
+			switch sec.kind {
+			case sectionLiveTest:
+				ds.liveTests[liveTest{sec.content, strings.Join(sec.options, " ")}] = true
+			case sectionPoll:
+				ds.pollChoices[strconv.Itoa(i+1)] = sec.options
+			}
+		}
+	}
+	return ds.html, nil
+}
+
+// hasSlide reports whether s is the number of a slide of the deck, written
+// as strconv.Itoa writes it, so each slide has just one.
+func (ds *deckServer) hasSlide(s string) (bool, error) {
+	if _, err := ds.deck(); err != nil {
+		return false, err
+	}
+	n, err := strconv.Atoi(s)
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	return err == nil && strconv.Itoa(n) == s && 1 <= n && n <= ds.numSlides, nil
+}
+
+// isSlide is like hasSlide, but reports false if the deck doesn't build.
+func (ds *deckServer) isSlide(s string) bool {
+	ok, _ := ds.hasSlide(s)
+	return ok
+}
+
+// serveFile serves a file that the deck refers to, like an image or the
+// target of a link. Other files, like the deck's sources, notes and
+// solutions, are not served.
+func (ds *deckServer) serveFile(w http.ResponseWriter, r *http.Request) {
+	if _, err := ds.deck(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	name := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+	ds.mu.Lock()
+	ok := ds.files[name]
+	ds.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeFile(w, r, name)
+}
+
+// refAttrRe matches the src or href attribute of an HTML tag.
+var refAttrRe = regexp.MustCompile(`\s(?:src|href)=(?:"([^"]*)"|'([^']*)')`)
+
+// referencedFiles returns the paths of the local files, relative to the
+// current directory, that the src and href attributes in deck, its HTML,
+// refer to, except those in static/, which is served separately.
+func referencedFiles(deck []byte) map[string]bool {
+	files := map[string]bool{}
+	for _, m := range refAttrRe.FindAllSubmatch(deck, -1) {
+		ref := html.UnescapeString(string(m[1]) + string(m[2]))
+		u, err := url.Parse(ref)
+		if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" || strings.HasPrefix(u.Path, "/") {
+			continue
+		}
+		p := path.Clean(u.Path)
+		if p == ".." || strings.HasPrefix(p, "../") || strings.HasPrefix(p, "static/") {
+			continue
+		}
+		files[p] = true
+	}
+	return files
+}
+
+// changedSince reports whether any of the deck's files was modified after t.
+func (ds *deckServer) changedSince(t time.Time) bool {
+	for _, f := range partFiles(ds.parts) {
+		info, err := os.Stat(f)
+		if err != nil || info.ModTime().After(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// authConfig describes how clients must authenticate.
+// If both basic authentication and a token are configured, either will do.
+type authConfig struct {
+	user, password string // for basic authentication
+	token          string
+}
+
+const tokenCookie = "code2slides-token"
+
+// wrap returns a handler that serves h to authenticated clients.
+func (a authConfig) wrap(h http.Handler) http.Handler {
+	if a.user == "" && a.token == "" {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.token != "" {
+			if t := r.URL.Query().Get("token"); t != "" && secretEqual(t, a.token) {
+				// Remember the token, so links within the deck work.
+				http.SetCookie(w, &http.Cookie{
+					Name:     tokenCookie,
+					Value:    t,
+					Path:     "/",
+					HttpOnly: true,
+					Secure:   r.TLS != nil,
+					SameSite: http.SameSiteStrictMode,
+				})
+				h.ServeHTTP(w, r)
+				return
+			}
+			if c, err := r.Cookie(tokenCookie); err == nil && secretEqual(c.Value, a.token) {
+				h.ServeHTTP(w, r)
+				return
+			}
+			if t, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && secretEqual(t, a.token) {
+				h.ServeHTTP(w, r)
+				return
+			}
+		}
+		if a.user != "" {
+			if u, p, ok := r.BasicAuth(); ok && secretEqual(u, a.user) && secretEqual(p, a.password) {
+				h.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Basic realm="slides", charset="UTF-8"`)
+		}
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	})
+}
+
+// secretEqual compares secrets in constant time.
+func secretEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,63 @@
+package code2slides
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+)
+
+// A deck built with -since REF has only the slides that are new or have
+// changed since the git commit REF, for a refresher session on what's new
+// in this year's edition. Each file is compared with its version at REF
+// slide by slide, as the change feed compares slides with a snapshot
+// (see -changes-since). The title slide stays, and so do the dividers of
+// parts that have changed slides.
+
+// checkGitRef returns an error if ref doesn't name a commit of the git
+// repo containing dir.
+func checkGitRef(dir, ref string) error {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--verify", "--quiet", ref+"^{commit}").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("-since: %q is not a commit: %v %s", ref, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// fileAtRef returns the content of filename at the git ref, and whether
+// the file existed there.
+func fileAtRef(filename, ref string) ([]byte, bool) {
+	// "./" makes the path relative to dir, not the root of the repo.
+	cmd := exec.Command("git", "-C", filepath.Dir(filename), "show", ref+":./"+filepath.Base(filename))
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, false
+	}
+	return out, true
+}
+
+// changedSince returns the slides of filename that are new or have
+// changed since the git ref, and its title slides. If the file can't be
+// read or scanned at ref, all of its slides are new.
+func changedSince(o *options, filename string, slides []*Slide, ref string) []*Slide {
+	content, ok := fileAtRef(filename, ref)
+	if !ok {
+		return slides
+	}
+	old, err := scanContent(o, filename, content, false)
+	if err != nil {
+		return slides
+	}
+	oldHashes := map[string]string{}
+	for i, id := range slideIDs(old) {
+		oldHashes[id] = slideHash(old[i])
+	}
+	var changed []*Slide
+	for i, id := range slideIDs(slides) {
+		s := slides[i]
+		if h, ok := oldHashes[id]; !ok || h != slideHash(s) || s.isTitle && !s.isDivider {
+			changed = append(changed, s)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,115 @@
+package code2slides
+
+import (
+	"cmp"
+	"go/scanner"
+	"go/token"
+	"strings"
+)
+
+// Go code is highlighted by its tokens, as go/scanner finds them, so that
+// a "//" in a string isn't taken for a comment, nor "type" in a comment
+// for a definition. The tokens are those of the whole code section, as it
+// is displayed, so strings and comments can span lines. Code in other
+// languages, and code with the noescape attribute, whose text is HTML, only
+// has its comments and definitions found line by line (see defnRange).
+
+// goSyntaxTags returns the start tag for each byte of each of lines, Go
+// code, that says what kind of token the byte is in, like "<keyword>" or
+// "<comment>", or "" for none.
+func goSyntaxTags(lines []codeLine) [][]string {
+	texts := make([]string, len(lines))
+	for i, l := range lines {
+		texts[i] = l.text
+	}
+	src := []byte(strings.Join(texts, "\n"))
+	tags := make([]string, len(src))
+
+	fset := token.NewFileSet()
+	var s scanner.Scanner
+	// Code on slides is often fragments, with errors to ignore.
+	s.Init(fset.AddFile("", -1, len(src)), src, func(token.Position, string) {}, scanner.ScanComments)
+	var (
+		offs  []int
+		kinds []token.Token
+		lits  []string
+	)
+	for {
+		pos, t, lit := s.Scan()
+		if t == token.EOF {
+			break
+		}
+		if t == token.SEMICOLON && lit == "\n" {
+			continue // inserted, not in the source
+		}
+		offs = append(offs, int(pos)-1)
+		kinds = append(kinds, t)
+		lits = append(lits, cmp.Or(lit, t.String()))
+	}
+	mark := func(k int, tag string) {
+		for i := offs[k]; i < offs[k]+len(lits[k]) && i < len(tags); i++ {
+			if src[i] != '\n' {
+				tags[i] = tag
+			}
+		}
+	}
+	for k, t := range kinds {
+		switch {
+		case t == token.COMMENT:
+			mark(k, "<comment>")
+		case t == token.STRING || t == token.CHAR:
+			mark(k, "<string>")
+		case t == token.INT || t == token.FLOAT || t == token.IMAG:
+			mark(k, "<number>")
+		case t.IsKeyword():
+			mark(k, "<keyword>")
+			if j := definedName(t, kinds[k+1:]); j >= 0 {
+				mark(k+1+j, "<defn>")
+			}
+		}
+	}
+
+	res := make([][]string, len(lines))
+	off := 0
+	for i, text := range texts {
+		res[i] = tags[off : off+len(text)]
+		off += len(text) + 1
+	}
+	return res
+}
+
+// definedName returns the index in after, the tokens after the keyword
+// kw, of the name that it defines, or -1 if none: the type of "type T",
+// the function of "func F(" and the method of "func (r R) M(".
+func definedName(kw token.Token, after []token.Token) int {
+	switch kw {
+	case token.TYPE:
+		if len(after) > 0 && after[0] == token.IDENT {
+			return 0
+		}
+	case token.FUNC:
+		k := 0
+		if len(after) > 0 && after[0] == token.LPAREN {
+			// Skip a method's receiver.
+			depth := 0
+			for ; k < len(after); k++ {
+				switch after[k] {
+				case token.LPAREN:
+					depth++
+				case token.RPAREN:
+					depth--
+				}
+				if depth == 0 {
+					break
+				}
+			}
+			k++
+		}
+		// A name, then its parameters or type parameters. After a
+		// function literal's parameters comes its result or body.
+		if k+1 < len(after) && after[k] == token.IDENT && (after[k+1] == token.LPAREN || after[k+1] == token.LBRACK) {
+			return k
+		}
+	}
+	return -1
+}
@@ -0,0 +1,34 @@
+package testdata
+
+// heading Answers
+
+// question
+// Is this fine?
+// answer
+// Yes, because the mutex protects count.
+// !question
+
+// question
+// Empty?
+// answer
+// !question
+
+// question
+// Placeholder?
+// answer
+// TODO
+// !question
+
+// question
+// Short?
+// answer
+// Yes.
+// !question
+
+// question
+// Code only?
+// answer
+// code
+x := 1
+// !code
+// !question
@@ -0,0 +1,29 @@
+package testdata
+
+// title Attendee Deck
+
+// heading Hello, world
+// rename msg=greeting
+// code play
+package main
+
+import "fmt"
+
+func main() {
+	msg := "hello"
+	fmt.Println(msg)
+}
+// !code
+// note
+// Instructors only.
+// !note
+// image gopher.png
+
+// heading Hello, world
+// question Why?
+// answer Because.
+// code play
+package main
+
+func main() {}
+// !code
@@ -0,0 +1,15 @@
+package main
+
+import "fmt"
+
+// heading Hello
+// code
+func main() {
+	fmt.Println("hello, world")
+}
+
+// !code
+// output auto
+
+// heading Hello, tested
+// output auto TestHello -v
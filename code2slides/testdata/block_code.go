@@ -0,0 +1,7 @@
+package testdata
+
+// heading H
+
+/* code
+x := 1
+*/
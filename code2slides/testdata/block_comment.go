@@ -0,0 +1,16 @@
+package testdata
+
+// heading Block comments
+
+/* note
+A note that doesn't need
+// prefixes.
+*/
+
+/* output
+panic: oops
+    main.main()
+*/
+
+/* text
+Some *text*. */
@@ -0,0 +1,7 @@
+package p
+
+// heading Nothing to Point At
+// code
+// callout here
+x := foo()
+// !code
@@ -0,0 +1,11 @@
+package p
+
+// heading Callouts Test
+// code
+func (g *Gauge) Inc() {
+	if g.count.Load() < g.max { // callout TOCTOU race here
+		g.count.Add(1)
+	}
+	// callout <checked & changed>
+}
+// !code
@@ -0,0 +1,7 @@
+package p
+
+// heading Caption
+// text
+// Some text.
+// !text
+// caption Nothing to caption
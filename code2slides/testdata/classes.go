@@ -0,0 +1,18 @@
+package testdata
+
+// heading Classes
+// text .small .right
+// Some text.
+// !text
+
+// text .note A one-line text.
+
+// code .wide bad
+x := 1
+// !code
+
+// question .tight
+// Why?
+// answer .small
+// Because.
+// !question
@@ -0,0 +1,12 @@
+package testdata
+
+// heading Buffered
+// code align nonumbers
+c := make(chan int, 2) // buffer of 2
+c <- 1 // doesn't block
+c_2 <- 2 // doesn't block
+// just a comment
+func() {
+	c <- 3 // blocks
+}()
+// !code
@@ -0,0 +1,12 @@
+package testdata
+
+// heading Attributes
+// code play
+package main
+
+func main() {}
+// !code
+
+// code noescape weak
+x := <b>1</b> // a < b
+// !code
@@ -0,0 +1,29 @@
+package testdata
+
+// heading Counting
+// code
+func count(n int) int {
+	c := 0
+	for range n {
+		c++
+	}
+	return c
+}
+
+// !code
+
+// heading Counting concurrently
+// code diff
+func count(n int) int {
+	var wg sync.WaitGroup
+	c := 0
+	for range n {
+		wg.Go(func() {
+			c++
+		})
+	}
+	wg.Wait()
+	return c
+}
+
+// !code
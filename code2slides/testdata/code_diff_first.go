@@ -0,0 +1,7 @@
+package testdata
+
+// heading Nothing to diff
+// code diff
+var x int
+
+// !code
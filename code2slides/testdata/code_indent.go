@@ -0,0 +1,9 @@
+package testdata
+
+// heading Indent
+
+// code
+func f() int {
+  return 1
+}
+// !code
@@ -0,0 +1,6 @@
+package testdata
+
+// heading H
+
+// text a
+// nextcol
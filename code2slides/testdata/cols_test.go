@@ -0,0 +1,17 @@
+package testdata
+
+// heading Columns
+
+// cols
+// text left
+// nextcol
+// text right
+// !cols
+
+// heading Uneven columns
+
+// cols 60 40
+// text left
+// nextcol
+// text right
+// !cols
@@ -0,0 +1,8 @@
+package testdata
+
+// heading H
+
+// cols
+// text a
+
+// heading I
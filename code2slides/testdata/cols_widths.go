@@ -0,0 +1,9 @@
+package testdata
+
+// heading Columns
+
+// cols 2 1 1
+// text left
+// nextcol
+// text right
+// !cols
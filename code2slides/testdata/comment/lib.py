@@ -0,0 +1,4 @@
+# region helper
+def helper():
+    pass
+# !region helper
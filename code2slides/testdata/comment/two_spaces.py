@@ -0,0 +1,7 @@
+# heading Two spaces
+
+# code
+def f():
+  if True:
+    return 1
+# !code
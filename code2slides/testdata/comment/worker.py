@@ -0,0 +1,23 @@
+import threading
+
+# heading Threads in Python
+
+# text Start a thread with `threading.Thread`.
+
+# code
+def main():
+    # omit
+    import sys
+    # !omit
+    t = threading.Thread(target=work)  # em Thread
+    t.start()
+    # elide
+    print("waiting")
+    # !elide
+    t.join()  # wait for it
+# !code
+
+# code align
+n = total // 2  # half
+# include lib.py helper
+# !code
@@ -0,0 +1,14 @@
+package p
+
+// heading Compare Test
+// compare
+// output
+// count = 1000
+// ok
+// !output
+// output -race
+// count = 1000
+// WARNING: DATA RACE
+// ok
+// !output
+// !compare
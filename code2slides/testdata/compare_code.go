@@ -0,0 +1,11 @@
+package p
+
+// heading Compare Code
+// compare
+// output
+// count = 1000
+// !output
+// code
+x := 1
+// !code
+// !compare
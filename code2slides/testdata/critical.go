@@ -0,0 +1,19 @@
+package testdata
+
+// heading Keep critical sections small
+// code critical
+func (c *Cache) Get(key string) string {
+	c.mu.Lock()
+	v, ok := c.m[key]
+	c.mu.Unlock()
+	if !ok {
+		v = compute(key)
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.m[key] = v
+
+	}
+	return v
+}
+
+// !code
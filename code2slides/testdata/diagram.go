@@ -0,0 +1,16 @@
+package testdata
+
+// heading A pipeline
+/* diagram dot
+digraph {
+    gen -> sq -> print
+}
+*/
+// code
+func main() {
+	for x := range sq(gen(1, 2, 3)) {
+		fmt.Println(x)
+	}
+}
+
+// !code
@@ -0,0 +1,6 @@
+package testdata
+
+// heading Unknown
+// diagram plantuml
+// A -> B
+// !diagram
@@ -0,0 +1,25 @@
+package testdata
+
+// heading Directive words in code
+
+// code
+func wait() {
+	// time out after a second
+	// exercise the slow path
+	// optional retry on error
+	// label the result
+	// label sum
+	// hold the lock
+	// same-as above
+	// title case the name
+	// author of the change
+	// date of the last change
+	// event loop
+	// heading north
+	// rename the file
+	time.Sleep(time.Second)
+}
+// !code
+
+// heading Not an exercise
+// exercise the slow path
@@ -0,0 +1,7 @@
+package mutexes
+
+////////////////////////////////////
+// heading Mutexes
+// code
+var mu sync.Mutex
+// !code
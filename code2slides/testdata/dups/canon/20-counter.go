@@ -0,0 +1,16 @@
+package mutexes
+
+////////////////////////////////////
+// heading A counter
+// code
+type Counter struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (c *Counter) Inc() {
+	c.mu.Lock()
+	c.n++
+	c.mu.Unlock()
+}
+// !code
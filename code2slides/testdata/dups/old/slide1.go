@@ -0,0 +1,7 @@
+package slide1
+
+// heading Mutexes
+
+// code
+var mu sync.Mutex
+// !code
@@ -0,0 +1,15 @@
+package slide2
+
+// heading A counter
+// code
+type Counter struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (c *Counter) Inc() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.n++
+}
+// !code
@@ -0,0 +1,4 @@
+package slide3
+
+// heading RWMutex
+// text Many readers, one writer.
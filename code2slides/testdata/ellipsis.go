@@ -0,0 +1,12 @@
+package testdata
+
+// heading Ellipsis
+// code
+func (c *Cache) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// ...
+	return v, ok
+}
+
+// !code
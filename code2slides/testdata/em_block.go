@@ -0,0 +1,12 @@
+package testdata
+
+// heading Block Em
+// code
+func f() {
+	// em
+	mu.Lock()
+	defer mu.Unlock()
+	// !em
+	count++
+}
+// !code
@@ -0,0 +1,7 @@
+package p
+
+// heading Nothing to Emphasize
+// code
+// em foo
+x := foo()
+// !code
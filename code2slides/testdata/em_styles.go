@@ -0,0 +1,13 @@
+package p
+
+// heading Em Styles Test
+// code
+mu.Lock() // em.red Lock
+// em.del
+count++
+// !em.del
+// em.add
+count.Add(1) // em
+// !em.add
+return // em.red
+// !code
@@ -0,0 +1,6 @@
+package ex
+
+func F() int {
+	// TODO
+	return 0
+}
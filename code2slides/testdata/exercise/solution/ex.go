@@ -0,0 +1,5 @@
+package ex
+
+func F() int {
+	return 42
+}
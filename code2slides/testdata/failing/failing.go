@@ -0,0 +1,5 @@
+package failing
+
+// heading A failing test
+
+// testfail TestFails
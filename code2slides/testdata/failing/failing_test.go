@@ -0,0 +1,9 @@
+package failing
+
+import "testing"
+
+func TestFails(t *testing.T) {
+	t.Error("count = 49, want 50")
+}
+
+func TestPasses(t *testing.T) {}
@@ -0,0 +1,5 @@
+package failing
+
+// heading Watch it fail
+
+// livetest -run TestFails -v
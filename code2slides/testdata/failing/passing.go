@@ -0,0 +1,5 @@
+package failing
+
+// heading A passing test
+
+// testfail TestPasses
@@ -0,0 +1,5 @@
+package failing
+
+// heading A test run with pinned settings
+
+// testfail TestReportsProcs GOMAXPROCS=3 -v
@@ -0,0 +1,10 @@
+package failing
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestReportsProcs(t *testing.T) {
+	t.Errorf("GOMAXPROCS=%d", runtime.GOMAXPROCS(0))
+}
@@ -0,0 +1,5 @@
+package failing
+
+// heading A test whose output varies
+
+// testfail TestVaries
@@ -0,0 +1,5 @@
+package failing
+
+// heading A test whose output is known to vary
+
+// testfail TestVaries -v nondeterministic
@@ -0,0 +1,10 @@
+package failing
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVaries(t *testing.T) {
+	t.Errorf("finished at %d", time.Now().UnixNano())
+}
@@ -0,0 +1,32 @@
+package fmtdemo
+
+// title Formatting   slides
+// author Someone
+
+// heading First
+// code .wide bad small nonumbers
+var x   =   1 // em
+// !code
+// text
+A  paragraph,  as  written.
+
+// !text
+
+// heading Second
+// optional deep extra
+// code fit mystery
+var y = 2
+// !code
+// A plain comment introduces the next slide.
+// heading Third
+/*
+ text
+   untouched
+*/
+// question
+// hint Think.
+// answer
+// code bad play
+var z = 3
+// !code
+// !question
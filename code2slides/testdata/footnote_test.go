@@ -0,0 +1,10 @@
+package testdata
+
+// heading Footnotes
+// footnote The real `sync.WaitGroup` uses atomics.
+// text A `WaitGroup` counts goroutines.
+// footnote
+// It also has a `Go` method,
+// since Go 1.25.
+// !footnote
+// text Call `Wait` to wait for them.
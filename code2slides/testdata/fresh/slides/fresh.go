@@ -0,0 +1,18 @@
+package fresh
+
+import (
+	"math/rand"
+	"time"
+)
+
+// heading Timers
+// code
+func f() {
+	t := time.NewTimer(time.Second)
+	t.Stop()
+	rand.Seed(1)
+}
+// !code
+
+// heading Timeouts
+// text Use `time.After` in a select.
@@ -0,0 +1,18 @@
+package testdata
+
+// heading Where does it run?
+// code
+// goroutine G1
+func printTree(t *Tree) {
+	var wg sync.WaitGroup
+	for _, c := range t.children {
+		wg.Go(func() {
+			// goroutine G2
+			printTree(c)
+			// !goroutine
+		})
+	}
+	wg.Wait()
+}
+
+// !code
@@ -0,0 +1,7 @@
+package testdata
+
+// heading Unmatched
+// code
+func f() {}
+// !goroutine
+// !code
@@ -0,0 +1,6 @@
+package adder
+
+func Add(a, b int) int {
+	// TODO
+	return 0
+}
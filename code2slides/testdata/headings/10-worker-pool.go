@@ -0,0 +1,5 @@
+package headings
+
+// code
+func worker() {}
+// !code
@@ -0,0 +1,10 @@
+package headings
+
+// heading Shutdown
+// text First.
+
+// heading Draining
+// text Second.
+
+// heading Waiting
+// text Third.
@@ -0,0 +1,14 @@
+package testdata
+
+// heading Highlight
+// code
+// highlight 2,4-5
+func f() {
+	x := 1 // line 2
+
+	// indented, so numbered 3
+	y := 2 // line 4
+	z := 3 // line 5
+	_ = x + y + z
+}
+// !code
@@ -0,0 +1,7 @@
+package testdata
+
+// heading Highlight
+// code
+// highlight 5-3
+func f() {}
+// !code
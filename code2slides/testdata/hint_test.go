@@ -0,0 +1,12 @@
+package testdata
+
+// heading Hints
+
+// question
+// Why does the program deadlock?
+// hint
+// Who receives from `c`?
+// hint Nobody.
+// answer
+// The send blocks forever.
+// !question
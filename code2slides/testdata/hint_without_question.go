@@ -0,0 +1,6 @@
+package testdata
+
+// heading Hint Without Question
+
+// text Some text.
+// hint A hint.
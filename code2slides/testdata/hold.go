@@ -0,0 +1,8 @@
+package testdata
+
+// heading Exercise
+// text Do it.
+
+// heading Solution
+// hold ex1
+// text Done.
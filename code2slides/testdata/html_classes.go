@@ -0,0 +1,6 @@
+package p
+
+// heading Classes
+// html <div class="flex nosuch"><br></div>
+// html <!-- <p class='commented'> -->
+// html <span class=missing>x</span>
@@ -0,0 +1,5 @@
+package p
+
+// heading Mismatch
+// html <div><p>text
+// html </div>
@@ -0,0 +1,12 @@
+package p
+
+// heading Unclosed
+// html <div style="line-height:6rem">
+// text
+// Tall.
+// !text
+
+// heading Next
+// text
+// Swallowed.
+// !text
@@ -0,0 +1,36 @@
+package testdata
+
+// heading Waiting
+
+// if advanced
+// text Use `sync.WaitGroup.Go`.
+// !if
+// if !advanced
+// text Call `Add` before starting each goroutine.
+// if the text above is shown, this comment is ignored.
+// !if
+
+// code
+func run() {
+	var wg sync.WaitGroup
+	// if !advanced
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		work()
+	}()
+	// !if
+	// if advanced
+	wg.Go(work)
+	// if race
+	wg.Go(work)
+	// !if
+	// !if
+	wg.Wait()
+}
+// !code
+
+// if advanced
+// heading Advanced only
+// text Deep dive.
+// !if
@@ -0,0 +1,6 @@
+package testdata
+
+// heading Unclosed If
+
+// if advanced
+// text Only for some.
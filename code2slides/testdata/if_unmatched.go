@@ -0,0 +1,6 @@
+package testdata
+
+// heading Unmatched If
+
+// text Everyone.
+// !if
@@ -0,0 +1,6 @@
+package p
+
+// heading Images
+// image diagram.png Two goroutines sharing a counter
+// caption The *race* on `count`
+// img photo.jpg
@@ -0,0 +1,23 @@
+package testdata
+
+import (
+	"fmt"
+	// Only the slide that was removed used strings.
+	"strings"
+	"sync"
+	"time" // for sleeping
+	rand "math/rand/v2"
+)
+
+import "os"
+
+////////////////////////////////////
+// heading Counter
+// code
+var mu sync.Mutex
+
+func main() {
+	fmt.Println(rand.N(10))
+}
+
+// !code
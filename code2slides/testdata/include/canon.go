@@ -0,0 +1,19 @@
+package canon
+
+import "sync"
+
+// region counter
+type Counter struct {
+	mu sync.Mutex
+	n  int
+}
+
+// !region counter
+
+func (c *Counter) Inc() {
+	// region body
+	c.mu.Lock()
+	c.n++
+	c.mu.Unlock()
+	// !region body
+}
@@ -0,0 +1,19 @@
+package testdata
+
+// heading Including Code
+
+// code
+// include include/canon.go counter
+// !code
+
+// code
+// include include/canon.go body
+// !code
+
+// code
+// include include/canon.go 3,4
+// !code
+
+// code
+// include include/canon.go /^func/,/^}/
+// !code
@@ -0,0 +1,8 @@
+package p
+
+// heading Em on the Previous Line
+// code
+wg.Go(func() { close(c) })
+// em close\(c\)
+y := bar()
+// !code
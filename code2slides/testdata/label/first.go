@@ -0,0 +1,10 @@
+package testdata
+
+// heading WaitGroup
+// label waitgroup
+// text A `WaitGroup` waits for goroutines to finish.
+
+// heading Mutex
+// text Like the [WaitGroup](#waitgroup), a `Mutex` must not be copied.
+// question Does an [errgroup](#errgroup) need one?
+// answer No, it has its own. See [slide 1](#1).
@@ -0,0 +1,5 @@
+package testdata
+
+// heading Errgroup
+// label errgroup
+// text As we saw in the [WaitGroup chapter](#waitgroup)...
@@ -0,0 +1,4 @@
+package testdata
+
+// heading Dangling
+// text See [the missing slide](#missing).
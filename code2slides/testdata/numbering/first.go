@@ -0,0 +1,11 @@
+package testdata
+
+// heading Bank Account
+// exercise
+// text Make `Account` safe for concurrent use.
+
+// heading Races
+// question Is `x++` atomic?
+// answer No, it's a read, an add and a write.
+// question Does the race detector find every race?
+// answer Only races that happen while it watches.
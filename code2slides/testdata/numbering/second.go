@@ -0,0 +1,6 @@
+package testdata
+
+// heading Hedging
+// exercise
+// question What happens to the slower request?
+// answer It should be canceled, so it stops using resources.
@@ -0,0 +1,16 @@
+package testdata
+
+// heading Omit Test
+
+// code
+func example() error {
+	f, err := os.Open(name)
+	// omit
+	if err != nil {
+		return err
+	}
+	// !omit
+	defer f.Close()
+	return nil
+}
+// !code
@@ -0,0 +1,9 @@
+package testdata
+
+// heading Unclosed Omit
+
+// code
+func f() {
+	// omit
+}
+// !code
@@ -0,0 +1,15 @@
+package testdata
+
+// heading Always
+
+// text Core material.
+
+// heading Extra
+// optional advanced race
+
+// text Nice to have.
+
+// heading Bonus
+// optional
+
+// text If there is time.
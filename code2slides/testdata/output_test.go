@@ -0,0 +1,11 @@
+package testdata
+
+// heading Output
+
+// output -race
+// WARNING: DATA RACE
+// Read at 0x00c000012345 by goroutine 7:
+//   main.count()
+//       m.go:26 +0x2c
+// got <-done
+// !output
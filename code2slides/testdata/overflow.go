@@ -0,0 +1,29 @@
+package testdata
+
+// heading Long code
+// code split
+func one() {
+	a := 1
+	b := 2
+	_ = a + b
+}
+
+func two() {
+	c := 3
+	d := 4
+	_ = c + d
+}
+
+func three() {
+	e := 5
+	_ = e
+}
+// !code
+// text
+// After the code.
+// !text
+
+// heading Fit
+// code fit
+func four() {}
+// !code
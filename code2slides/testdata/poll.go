@@ -0,0 +1,6 @@
+package testdata
+
+// heading Which is safe?
+// question Which map access is safe?
+// answer B
+// poll A B
@@ -0,0 +1,14 @@
+package testdata
+
+// heading Deadlock
+// code
+func main() {
+	c := make(chan int)
+	c <- 1 // Why does this deadlock?
+}
+
+// !code
+// question
+// answer
+// Nobody receives from `c`.
+// !question
@@ -0,0 +1,10 @@
+package testdata
+
+// heading Quiz
+// quiz
+// What does this program print?
+// - [ ] `0`
+// - [x] `1`
+// - [ ] Nothing: it
+//   deadlocks
+// !quiz
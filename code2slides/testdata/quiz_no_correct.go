@@ -0,0 +1,8 @@
+package testdata
+
+// heading Quiz
+// quiz
+// What does this program print?
+// - [ ] `0`
+// - [ ] `1`
+// !quiz
@@ -0,0 +1,8 @@
+package testdata
+
+// heading Collision
+// code
+nc1 := nc
+// !code
+
+// rename nc1=nc
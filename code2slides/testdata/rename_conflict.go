@@ -0,0 +1,4 @@
+package testdata
+
+// rename nc1=nc
+// rename nc1=count
@@ -0,0 +1,15 @@
+package testdata
+
+// rename printTree1=printTree nc1=nc
+
+// heading Rename
+// code nonumbers
+func printTree1(nc1 int) {
+	printTree1(nc1 - 1)
+}
+// !code
+
+// heading Still renamed on later slides
+// code nonumbers
+x := nc1 + foo_2
+// !code
@@ -0,0 +1,27 @@
+package rewrite
+
+// code
+func run(items []int) {
+	var wg sync.WaitGroup
+	for _, it := range items {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			process(it) // em
+			// Nested.
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				log(it)
+			}()
+		}()
+	}
+	wg.Add(1)
+	// A comment in the way.
+	go func() {
+		defer wg.Done()
+		cleanup()
+	}()
+	wg.Wait()
+}
+// !code
@@ -0,0 +1,23 @@
+package rewrite
+
+// code
+func run(items []int) {
+	var wg sync.WaitGroup
+	for _, it := range items {
+		wg.Go(func() {
+			process(it) // em
+			// Nested.
+			wg.Go(func() {
+				log(it)
+			})
+		})
+	}
+	wg.Add(1)
+	// A comment in the way.
+	go func() {
+		defer wg.Done()
+		cleanup()
+	}()
+	wg.Wait()
+}
+// !code
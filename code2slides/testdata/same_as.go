@@ -0,0 +1,37 @@
+package testdata
+
+// heading Counting concurrently, again
+// same-as code_diff.go
+// code
+func count(n int) int {
+	var wg sync.WaitGroup
+	c := 0
+	for range n {
+		wg.Go(func() {
+			c++ // em
+		})
+	}
+	wg.Wait()
+	return c
+}
+// !code
+
+// heading The race
+// same-as
+// text Two goroutines can increment `c` at once.
+// code
+func count(n int) int {
+	var wg sync.WaitGroup
+	c := 0
+	for range n {
+		wg.Go(func() {
+			c++
+		})
+	}
+	// em
+	wg.Wait()
+	// !em
+	return c
+}
+
+// !code
@@ -0,0 +1,24 @@
+package testdata
+
+// heading Counting
+// code
+func count(n int) int {
+	c := 0
+	for range n {
+		c++
+	}
+	return c
+}
+// !code
+
+// heading Counting, again
+// same-as
+// code
+func count(n int) int {
+	c := 0
+	for range n {
+		c += 1
+	}
+	return c
+}
+// !code
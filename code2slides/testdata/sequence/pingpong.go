@@ -0,0 +1,28 @@
+package main
+
+// heading Unbuffered
+// sequence
+// code
+func main() {
+	ch := make(chan int)
+	done := make(chan bool)
+	go func() {
+		for i := range 2 {
+			ch <- i
+		}
+		close(ch)
+	}()
+	go func() {
+		for {
+			v, ok := <-ch
+			if !ok {
+				break
+			}
+			_ = v
+		}
+		done <- true
+	}()
+	<-done
+}
+
+// !code
@@ -0,0 +1,5 @@
+package lib
+
+// heading The race detector
+
+// text Run your tests with -race.
@@ -0,0 +1,3 @@
+package lib
+
+// heading Nobody uses this
@@ -0,0 +1,10 @@
+package testdata
+
+// heading First
+// code
+func (g *WaitGroup) Add(n int) {
+	g.count += n
+}
+
+func (g *WaitGroup) Done() { g.Add(-1) }
+// !code
@@ -0,0 +1,25 @@
+package testdata
+
+// rename WaitGroup3=WaitGroup
+
+// heading Second
+// code
+func (g *WaitGroup_2) Add(delta int) { // parameter names don't matter
+	g.mu.Lock()
+}
+// !code
+
+// heading Third
+// code
+func (g *WaitGroup3) Add(n int64) {
+	g.count.Add(n)
+}
+func (g *WaitGroup3) Done() error { return nil }
+// !code
+
+// heading Fourth
+// code
+func (g *WaitGroup_4) Add(n uint) { // a new version, so not checked
+	g.count += n
+}
+// !code
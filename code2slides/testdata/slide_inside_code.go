@@ -0,0 +1,7 @@
+package testdata
+
+// heading H
+
+// code
+// slide
+// !code
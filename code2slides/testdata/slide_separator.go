@@ -0,0 +1,12 @@
+package testdata
+
+// heading Counting
+
+// text Start here.
+
+// slide
+
+// text Still counting.
+
+// slide Done
+// text Finished.
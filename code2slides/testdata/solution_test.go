@@ -0,0 +1,4 @@
+package testdata
+
+// heading Exercise debrief
+// solution exercise
@@ -0,0 +1,18 @@
+package testdata
+
+// heading Steps
+// code
+type counter struct {
+	mu sync.Mutex
+	n  int
+}
+
+// step
+func (c *counter) inc() {
+	c.mu.Lock()
+	// step
+	c.n++
+	c.mu.Unlock()
+}
+
+// !code
@@ -0,0 +1,11 @@
+package testdata
+
+// heading Stepper
+// stepper c=0
+// G1 R0 = c
+// G2 R0 = c
+// G1 R0++
+// G2 R0++
+// G1 c = R0
+// G2 c = R0
+// !stepper
@@ -0,0 +1,6 @@
+package testdata
+
+// heading Stepper
+// stepper
+// G1 c += 1
+// !stepper
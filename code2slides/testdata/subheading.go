@@ -0,0 +1,8 @@
+package testdata
+
+// heading Channels
+
+// subheading Sending
+// text `c <- v` blocks.
+
+// heading2 Receiving
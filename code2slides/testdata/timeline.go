@@ -0,0 +1,15 @@
+package testdata
+
+// heading Timeline
+// question
+// What happens to the goroutine if there is a timeout?
+// answer
+// timeline
+// 1. `time.After` case executes
+// 2. `select` finishes
+// 3. goroutine tries to send to `c`
+//    and blocks forever
+// !timeline
+//
+// The goroutine leaks.
+// !question
@@ -0,0 +1,19 @@
+package testdata
+
+// heading Worker pools
+// time 3m
+// text
+// Start a fixed number of workers.
+// !text
+
+// heading Draining
+// time 1m30s
+// optional
+// text
+// Close the channel of work.
+// !text
+
+// heading Questions
+// text
+// Any questions?
+// !text
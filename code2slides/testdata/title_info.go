@@ -0,0 +1,14 @@
+package testdata
+
+// title Concurrency
+// subtitle
+// Demystifying it
+// !subtitle
+// author Ann Gopher
+// event [GopherCon](https://gophercon.eu)
+// date May 2026
+
+// divider Part 2: Channels
+
+// heading Channels
+// text Hi.
@@ -0,0 +1,4 @@
+package testdata
+
+// heading Channels
+// author Ann Gopher
@@ -0,0 +1,15 @@
+package testdata
+
+// title Concurrency
+
+// toc Agenda
+// text We'll cover:
+
+// heading Goroutines
+// text One.
+
+// slide
+// text More goroutines.
+
+// heading Channels
+// text Two.
@@ -0,0 +1,22 @@
+package testdata
+
+// heading Unfinished
+
+// todo check this output on 1.26
+// code
+x := 1
+// todo: remove x
+// !code
+
+// question
+// What is x?
+// answer
+// TODO
+// !question
+
+// heading Half done
+// text
+// The first line is done.
+// TODO: the second isn't.
+// !text
+// text TODO inline
@@ -0,0 +1,116 @@
+package code2slides
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// The time directive plans how long to spend on a slide. Planned times go
+// in the deck as the data-time attribute of their slides, in seconds, and
+// the presenter window shows each slide's time along with how much of the
+// deck's time should have passed when it began. -timing reports the
+// planned time of each file and part, and of the whole deck, instead of
+// building it.
+
+// parsePlannedTime parses the argument of a time directive, a positive
+// duration like "3m" or "1m30s".
+func parsePlannedTime(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("time needs a duration like 3m or 1m30s, not %q", s)
+	}
+	return d, nil
+}
+
+// formatPlanned formats d without the zero units that Duration.String
+// writes, as "2h5m" rather than "2h5m0s".
+func formatPlanned(d time.Duration) string {
+	s := d.Round(time.Second).String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// writeTimingScript writes the planned times of slides for notes.js: for
+// each slide, its time and the total time of the slides before it, or
+// null if it has no time.
+func writeTimingScript(w io.Writer, slides []*Slide) error {
+	type timing struct {
+		Time   string
+		Before string
+	}
+	var (
+		times []*timing
+		total time.Duration
+		timed bool
+	)
+	for _, s := range slides {
+		if s.planned == 0 {
+			times = append(times, nil)
+			continue
+		}
+		times = append(times, &timing{formatPlanned(s.planned), formatPlanned(total)})
+		total += s.planned
+		timed = true
+	}
+	if !timed {
+		return nil
+	}
+	j, err := json.Marshal(times)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "    <script>\n      var plannedTimes = %s;\n      var plannedTotal = %q;\n    </script>\n", j, formatPlanned(total))
+	return err
+}
+
+// reportTiming writes the planned time of each file of parts, and of each
+// part and the deck, with how much of it is for optional slides, and the
+// files' slides that have no time.
+func reportTiming(o *options, w io.Writer, parts []part) error {
+	var total, optional time.Duration
+	for _, p := range parts {
+		var partTotal time.Duration
+		for _, file := range p.files {
+			slides, err := scanFile(o, file)
+			if err != nil {
+				return err
+			}
+			var fileTotal time.Duration
+			var untimed []string
+			for _, s := range slides {
+				if s.planned == 0 {
+					untimed = append(untimed, s.heading)
+					continue
+				}
+				fileTotal += s.planned
+				if s.optional {
+					optional += s.planned
+				}
+			}
+			fmt.Fprintf(w, "%-8s %s", formatPlanned(fileTotal), file)
+			if len(untimed) > 0 {
+				fmt.Fprintf(w, " (no time: %s)", strings.Join(untimed, "; "))
+			}
+			fmt.Fprintln(w)
+			partTotal += fileTotal
+		}
+		if p.name != "" {
+			fmt.Fprintf(w, "%-8s part %s\n", formatPlanned(partTotal), p.name)
+		}
+		total += partTotal
+	}
+	fmt.Fprintf(w, "%-8s total", formatPlanned(total))
+	if optional > 0 {
+		fmt.Fprintf(w, ", %s of it optional", formatPlanned(optional))
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
@@ -0,0 +1,168 @@
+package code2slides
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// A transform is a program that rewrites the sections of slides after they
+// are scanned and before they are rendered, so an organization can change
+// every deck it builds, as by adding a compliance note to each module,
+// without changing code2slides. Each -transform runs once for each slide
+// file, in order, reading the file's slides from its standard input as
+// JSON and writing them, changed as it likes, to its standard output:
+//
+//	{
+//	  "file": "slides/mutexes/mutexes.go",
+//	  "slides": [
+//	    {
+//	      "heading": "Mutexes",
+//	      "sections": [
+//	        {"kind": "code", "content": "var mu sync.Mutex", "attrs": ["small"], "line": 12},
+//	        {"kind": "note", "content": "Ask who has used one."}
+//	      ]
+//	    }
+//	  ]
+//	}
+//
+// Sections have the kinds of the directives that make them, like "text",
+// "note", "code" or "output"; "html" sections are raw HTML. A transform can
+// change, add, remove and reorder the sections of a slide, and change its
+// heading, but the file must keep its number of slides. The content of
+// code sections can hold marks, for emphasis and the like, that begin and
+// end with NUL characters; a transform should leave them alone.
+
+// irFile, irSlide and irSection are the JSON form of a file's slides that
+// transforms read and write.
+type (
+	irFile struct {
+		File   string    `json:"file"`
+		Slides []irSlide `json:"slides"`
+	}
+
+	irSlide struct {
+		Heading  string      `json:"heading"`
+		Sections []irSection `json:"sections"`
+	}
+
+	irSection struct {
+		Kind      string      `json:"kind"`
+		Content   string      `json:"content"`
+		Options   []string    `json:"options,omitempty"`
+		Attrs     []string    `json:"attrs,omitempty"`   // of code
+		Classes   []string    `json:"classes,omitempty"` // from a class list
+		Highlight [][2]int    `json:"highlight,omitempty"`
+		InAnswer  bool        `json:"inAnswer,omitempty"` // for code in an answer
+		Outputs   []irSection `json:"outputs,omitempty"`  // of compare
+		Line      int         `json:"line,omitempty"`     // where the section begins in the file
+	}
+)
+
+// transformSlides runs the transforms on the slides of filename, and
+// returns the slides that the last one writes.
+func transformSlides(o *options, filename string, slides []*Slide) ([]*Slide, error) {
+	for _, t := range o.transforms {
+		in := irFile{File: filename}
+		for _, s := range slides {
+			in.Slides = append(in.Slides, irSlide{Heading: s.heading, Sections: toIR(s.sections)})
+		}
+		data, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		args := strings.Fields(t)
+		cmd := exec.CommandContext(o.ctx, args[0], args[1:]...)
+		cmd.Stdin = bytes.NewReader(data)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("transform %q: %v\n%s", t, err, stderr.Bytes())
+		}
+		var res irFile
+		if err := json.Unmarshal(out, &res); err != nil {
+			return nil, fmt.Errorf("transform %q: %v", t, err)
+		}
+		if len(res.Slides) != len(slides) {
+			return nil, fmt.Errorf("transform %q: wrote %d slides for %d", t, len(res.Slides), len(slides))
+		}
+		for i, rs := range res.Slides {
+			secs, err := fromIR(rs.Sections)
+			if err != nil {
+				return nil, fmt.Errorf("transform %q: slide %d: %v", t, i+1, err)
+			}
+			slides[i].heading = rs.Heading
+			slides[i].sections = secs
+		}
+	}
+	return slides, nil
+}
+
+// toIR returns the JSON form of secs.
+func toIR(secs []section) []irSection {
+	var irs []irSection
+	for _, sec := range secs {
+		is := irSection{
+			Kind:     sec.kind.String(),
+			Content:  sec.content,
+			Options:  sec.options,
+			Classes:  sec.classes,
+			InAnswer: sec.inAnswer,
+			Outputs:  toIR(sec.outputs),
+			Line:     sec.line,
+		}
+		for _, a := range sec.attrs {
+			is.Attrs = append(is.Attrs, string(a))
+		}
+		for _, r := range sec.highlight {
+			is.Highlight = append(is.Highlight, [2]int{r.first, r.last})
+		}
+		irs = append(irs, is)
+	}
+	return irs
+}
+
+// fromIR returns the sections whose JSON form is irs.
+func fromIR(irs []irSection) ([]section, error) {
+	var secs []section
+	for _, is := range irs {
+		kind := sectionUndefined
+		for k := sectionNote; k <= sectionCompare; k++ {
+			if k.String() == is.Kind {
+				kind = k
+			}
+		}
+		if kind == sectionUndefined {
+			return nil, fmt.Errorf("unknown section kind %q", is.Kind)
+		}
+		attrs, err := parseCodeAttrs(is.Attrs)
+		if err != nil {
+			return nil, err
+		}
+		outputs, err := fromIR(is.Outputs)
+		if err != nil {
+			return nil, err
+		}
+		if kind == sectionCompare && (len(outputs) != 2 || outputs[0].kind != sectionOutput || outputs[1].kind != sectionOutput) {
+			return nil, fmt.Errorf("compare needs two outputs")
+		}
+		sec := section{
+			kind:     kind,
+			options:  is.Options,
+			attrs:    attrs,
+			content:  is.Content,
+			inAnswer: is.InAnswer,
+			line:     is.Line,
+			classes:  is.Classes,
+			outputs:  outputs,
+		}
+		for _, r := range is.Highlight {
+			sec.highlight = append(sec.highlight, lineRange{r[0], r[1]})
+		}
+		secs = append(secs, sec)
+	}
+	return secs, nil
+}
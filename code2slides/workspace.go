@@ -0,0 +1,110 @@
+package code2slides
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// Students get the exercises as a workspace: each exercise is its own
+// module, and a go.work file at the top lists them all. An exercise that
+// doesn't compile then doesn't stop the others from building. The grader
+// image gives each exercise's tests the same go.mod, so student code is
+// graded as it was built.
+
+// workspaceCommand writes a workspace of exercises for students.
+func workspaceCommand(args []string) error {
+	fs := flag.NewFlagSet("workspace", flag.ExitOnError)
+	out := fs.String("o", "workspace", "write the workspace to `dir`")
+	goVersion := fs.String("go", strings.TrimPrefix(runtime.Version(), "go"), "require Go `version` in each go.mod")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: code2slides workspace [flags] <exercises dir>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	return writeWorkspace(*out, fs.Arg(0), *goVersion)
+}
+
+// writeWorkspace writes to dir a copy of each exercise in exercisesDir,
+// without its solution, as a module of a workspace using Go goVersion.
+func writeWorkspace(dir, exercisesDir, goVersion string) error {
+	lang, err := goLang(goVersion)
+	if err != nil {
+		return err
+	}
+	exs, err := exercises(exercisesDir)
+	if err != nil {
+		return err
+	}
+	if len(exs) == 0 {
+		return fmt.Errorf("no exercises in %s", exercisesDir)
+	}
+	var work strings.Builder
+	fmt.Fprintf(&work, "go %s\n\nuse (\n", lang)
+	for _, ex := range exs {
+		files, err := filepath.Glob(filepath.Join(exercisesDir, ex, "*.go"))
+		if err != nil {
+			return err
+		}
+		if err := copyExercise(filepath.Join(dir, ex), ex, lang, files); err != nil {
+			return err
+		}
+		fmt.Fprintf(&work, "\t./%s\n", ex)
+	}
+	work.WriteString(")\n")
+	return os.WriteFile(filepath.Join(dir, "go.work"), []byte(work.String()), 0o644)
+}
+
+// exercises returns the names of the exercises in dir: its subdirectories
+// that have a solution.
+func exercises(dir string) ([]string, error) {
+	sols, err := filepath.Glob(filepath.Join(dir, "*", "solution"))
+	if err != nil {
+		return nil, err
+	}
+	var exs []string
+	for _, s := range sols {
+		exs = append(exs, filepath.Base(filepath.Dir(s)))
+	}
+	return exs, nil
+}
+
+// copyExercise copies files to dir, and writes the go.mod of exercise ex
+// there.
+func copyExercise(dir, ex, lang string, files []string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, filepath.Base(f)), data, 0o644); err != nil {
+			return err
+		}
+	}
+	gomod := fmt.Sprintf("module workshop/%s\n\ngo %s\n", ex, lang)
+	return os.WriteFile(filepath.Join(dir, "go.mod"), []byte(gomod), 0o644)
+}
+
+// goVersionRe matches a Go release version, like 1.26 or 1.26.1.
+var goVersionRe = regexp.MustCompile(`^(1\.\d+)(\.\d+)?$`)
+
+// goLang returns the language version, like 1.26, of the Go release
+// version goVersion.
+func goLang(goVersion string) (string, error) {
+	m := goVersionRe.FindStringSubmatch(goVersion)
+	if m == nil {
+		return "", fmt.Errorf("bad Go version %q; set one with -go", goVersion)
+	}
+	return m[1], nil
+}
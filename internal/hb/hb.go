@@ -0,0 +1,255 @@
+// Package hb checks happens-before edges in exercise code, so a test can
+// explain why a program is wrong instead of just reporting "race detected".
+//
+// Code under test records its events with a Recorder: reads and writes of
+// shared variables, and the synchronizing operations that order them. A
+// release, like unlocking a mutex, closing a channel or calling Done,
+// happens before an acquire of the same object that follows it, like
+// locking the mutex, receiving from the channel or returning from Wait.
+// Goroutines started with Recorder.Go happen after what their starter did
+// before starting them.
+//
+//	var rec hb.Recorder
+//	rec.Go("G1", func() {
+//		w = rec.Write("count")
+//		rec.Release("wg")
+//	})
+//	rec.Acquire("wg")
+//	r = rec.Read("count")
+//	...
+//	if err := rec.HappensBefore(w, r); err != nil {
+//		t.Error(err)
+//	}
+package hb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A Recorder records the events of a program. The zero Recorder is ready
+// to use.
+type Recorder struct {
+	mu         sync.Mutex
+	goroutines map[int64]*goroutine // by runtime ID
+	objects    map[string]clock     // the clocks released to each object
+	events     []*Event
+}
+
+type goroutine struct {
+	index int    // in clocks
+	name  string // from Go, or "goroutine N"
+	clock clock
+}
+
+// A clock is a vector clock, indexed by goroutine.
+type clock []int
+
+func (c clock) get(i int) int {
+	if i < len(c) {
+		return c[i]
+	}
+	return 0
+}
+
+// join returns the clock that is the later of c and d in each goroutine.
+func (c clock) join(d clock) clock {
+	j := make(clock, max(len(c), len(d)))
+	for i := range j {
+		j[i] = max(c.get(i), d.get(i))
+	}
+	return j
+}
+
+// A Kind is the kind of an event.
+type Kind int
+
+const (
+	Read Kind = iota
+	Write
+	Release
+	Acquire
+	Start // of a goroutine, by Go
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Read:
+		return "read"
+	case Write:
+		return "write"
+	case Release:
+		return "release"
+	case Acquire:
+		return "acquire"
+	case Start:
+		return "start"
+	default:
+		return "unknown"
+	}
+}
+
+// An Event is something a goroutine did.
+type Event struct {
+	Kind      Kind
+	Name      string // the variable or synchronizing object
+	Goroutine string
+	Seq       int // order of recording, from 1
+
+	g     int // goroutine index
+	clock clock
+}
+
+func (e *Event) String() string {
+	return fmt.Sprintf("%s of %q by %s (event %d)", e.Kind, e.Name, e.Goroutine, e.Seq)
+}
+
+// Go runs f in a new goroutine named name. Everything the calling
+// goroutine recorded before calling Go happens before what f records.
+func (r *Recorder) Go(name string, f func()) {
+	start := r.record(Start, name)
+	go func() {
+		r.mu.Lock()
+		g := r.newGoroutine(goid(), name)
+		g.clock = g.clock.join(start.clock)
+		r.mu.Unlock()
+		f()
+	}()
+}
+
+// Read records a read of the shared variable v by the calling goroutine.
+func (r *Recorder) Read(v string) *Event { return r.record(Read, v) }
+
+// Write records a write of the shared variable v by the calling goroutine.
+func (r *Recorder) Write(v string) *Event { return r.record(Write, v) }
+
+// Release records that the calling goroutine released the synchronizing
+// object obj, as by unlocking a mutex or closing a channel.
+func (r *Recorder) Release(obj string) *Event { return r.record(Release, obj) }
+
+// Acquire records that the calling goroutine acquired the synchronizing
+// object obj, as by locking a mutex or receiving from a channel. The
+// acquire happens after every release of obj recorded before it.
+func (r *Recorder) Acquire(obj string) *Event { return r.record(Acquire, obj) }
+
+func (r *Recorder) record(kind Kind, name string) *Event {
+	id := goid()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	g := r.goroutines[id]
+	if g == nil {
+		g = r.newGoroutine(id, "goroutine "+strconv.FormatInt(id, 10))
+	}
+	if kind == Acquire {
+		g.clock = g.clock.join(r.objects[name])
+	}
+	g.clock[g.index]++
+	e := &Event{
+		Kind:      kind,
+		Name:      name,
+		Goroutine: g.name,
+		Seq:       len(r.events) + 1,
+		g:         g.index,
+		clock:     append(clock(nil), g.clock...),
+	}
+	if kind == Release {
+		if r.objects == nil {
+			r.objects = map[string]clock{}
+		}
+		r.objects[name] = r.objects[name].join(g.clock)
+	}
+	r.events = append(r.events, e)
+	return e
+}
+
+// newGoroutine adds the goroutine with runtime ID id. r.mu is held.
+func (r *Recorder) newGoroutine(id int64, name string) *goroutine {
+	if r.goroutines == nil {
+		r.goroutines = map[int64]*goroutine{}
+	}
+	g := &goroutine{index: len(r.goroutines), name: name}
+	g.clock = make(clock, g.index+1)
+	r.goroutines[id] = g
+	return g
+}
+
+// HappensBefore returns nil if a happens before b, and otherwise an error
+// that explains what is missing: the releases of a's goroutine after a,
+// and the acquires of b's goroutine before b, none of which pair up.
+func (r *Recorder) HappensBefore(a, b *Event) error {
+	if a == b || a.clock.get(a.g) <= b.clock.get(a.g) {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s does not happen before %s", a, b)
+	if a.g == b.g {
+		sb.WriteString(": it comes after it in their goroutine")
+		return errors.New(sb.String())
+	}
+	fmt.Fprintf(&sb, ": nothing %s releases after the %s is acquired by %s before the %s", a.Goroutine, a.Kind, b.Goroutine, b.Kind)
+	var releases, acquires []string
+	for _, e := range r.events {
+		switch {
+		case e.g == a.g && e.Seq > a.Seq && (e.Kind == Release || e.Kind == Start):
+			releases = append(releases, e.String())
+		case e.g == b.g && e.Seq < b.Seq && e.Kind == Acquire:
+			acquires = append(acquires, e.String())
+		}
+	}
+	list := func(what string, es []string) {
+		if len(es) == 0 {
+			fmt.Fprintf(&sb, "\n\t%s: none", what)
+		} else {
+			fmt.Fprintf(&sb, "\n\t%s:\n\t\t%s", what, strings.Join(es, "\n\t\t"))
+		}
+	}
+	list(fmt.Sprintf("releases by %s after the %s", a.Goroutine, a.Kind), releases)
+	list(fmt.Sprintf("acquires by %s before the %s", b.Goroutine, b.Kind), acquires)
+	return errors.New(sb.String())
+}
+
+// Ordered returns nil if every two accesses of the shared variable v, at
+// least one of them a write, are ordered by happens-before: that is, if
+// there is no data race on v. Otherwise it explains the first pair that
+// isn't ordered.
+func (r *Recorder) Ordered(v string) error {
+	r.mu.Lock()
+	var accesses []*Event
+	for _, e := range r.events {
+		if e.Name == v && (e.Kind == Read || e.Kind == Write) {
+			accesses = append(accesses, e)
+		}
+	}
+	r.mu.Unlock()
+	for i, a := range accesses {
+		for _, b := range accesses[i+1:] {
+			if a.Kind == Read && b.Kind == Read {
+				continue
+			}
+			if r.HappensBefore(a, b) != nil && r.HappensBefore(b, a) != nil {
+				return fmt.Errorf("data race on %q: %w", v, r.HappensBefore(a, b))
+			}
+		}
+	}
+	return nil
+}
+
+// goid returns the runtime's ID for the calling goroutine.
+func goid() int64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	b, _, _ = bytes.Cut(b, []byte(" "))
+	id, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		panic("hb: can't parse goroutine ID: " + err.Error())
+	}
+	return id
+}
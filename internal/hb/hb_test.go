@@ -0,0 +1,92 @@
+package hb
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+// waitGroup is a WaitGroup for the tests, instrumented like an exercise's.
+// If release is false, Done forgets to release, as if it only decremented
+// a counter that Wait polls.
+type waitGroup struct {
+	rec     *Recorder
+	wg      sync.WaitGroup
+	release bool
+}
+
+func (w *waitGroup) Go(name string, f func()) {
+	w.wg.Add(1)
+	w.rec.Go(name, func() {
+		f()
+		if w.release {
+			w.rec.Release("wg")
+		}
+		w.wg.Done()
+	})
+}
+
+func (w *waitGroup) Wait() {
+	w.wg.Wait()
+	w.rec.Acquire("wg")
+}
+
+func TestHappensBefore(t *testing.T) {
+	var rec Recorder
+	wg := waitGroup{rec: &rec, release: true}
+	var write *Event
+	wg.Go("G1", func() { write = rec.Write("count") })
+	wg.Wait()
+	read := rec.Read("count")
+	if err := rec.HappensBefore(write, read); err != nil {
+		t.Error(err)
+	}
+	if err := rec.HappensBefore(read, write); err == nil {
+		t.Error("read happens before write, want error")
+	}
+	if err := rec.Ordered("count"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMissingRelease(t *testing.T) {
+	var rec Recorder
+	wg := waitGroup{rec: &rec}
+	var write *Event
+	wg.Go("G1", func() { write = rec.Write("count") })
+	wg.Wait()
+	read := rec.Read("count")
+	err := rec.HappensBefore(write, read)
+	if err == nil {
+		t.Fatal("got nil, want error")
+	}
+	for _, want := range []string{
+		`write of "count" by G1 (event 2) does not happen before read of "count" by goroutine `,
+		"releases by G1 after the write: none",
+		`acquires by goroutine `,
+		`acquire of "wg" by goroutine `,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not contain %q:\n%v", want, err)
+		}
+	}
+	if err := rec.Ordered("count"); err == nil || !strings.HasPrefix(err.Error(), `data race on "count": `) {
+		t.Errorf("Ordered: got %v, want a data race", err)
+	}
+}
+
+func TestGoHappensAfter(t *testing.T) {
+	var rec Recorder
+	write := rec.Write("x")
+	var read *Event
+	var wg sync.WaitGroup
+	wg.Add(1)
+	rec.Go("G1", func() {
+		defer wg.Done()
+		read = rec.Read("x")
+	})
+	wg.Wait()
+	if err := rec.HappensBefore(write, read); err != nil {
+		t.Error(err)
+	}
+}
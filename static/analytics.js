@@ -0,0 +1,72 @@
+// analytics.js reports how viewers use the deck to analyticsURL, set by
+// the deck when it is built with -analytics: how long each slide is shown,
+// and which answers and hints are revealed. Slides are known by their
+// data-slide attribute, which survives edits to the deck better than
+// their position does.
+//
+// Each event is a JSON object POSTed with navigator.sendBeacon:
+//
+//	{"type": "dwell", "slide": "20-waitgroup.go:3", "ms": 41250}
+//	{"type": "reveal", "slide": "20-waitgroup.go:3", "kind": "hint"}
+//
+// along with "deck", the deck's path, and "exercise" for the slides of
+// exercises.
+
+var analyticsSlide = null; // the slide being shown
+var analyticsSince = 0; // when it was shown
+
+function analyticsSend(event, el) {
+  if (typeof analyticsURL === 'undefined' || !analyticsURL) return;
+  event.deck = location.pathname;
+  if (el) {
+    event.slide = el.dataset.slide || '';
+    if (el.dataset.exercise) event.exercise = +el.dataset.exercise;
+  }
+  var body = JSON.stringify(event);
+  if (navigator.sendBeacon) {
+    navigator.sendBeacon(analyticsURL, body);
+  } else {
+    fetch(analyticsURL, { method: 'POST', body: body, keepalive: true });
+  }
+}
+
+// analyticsLeave reports the time spent on the slide being shown.
+function analyticsLeave() {
+  if (analyticsSlide) {
+    analyticsSend({ type: 'dwell', ms: Date.now() - analyticsSince }, analyticsSlide);
+  }
+  analyticsSlide = null;
+}
+
+// trackPageview is called by slides.js whenever the current slide changes.
+function trackPageview() {
+  var el = getSlideEl(curSlide);
+  if (el === analyticsSlide) return;
+  analyticsLeave();
+  analyticsSlide = el;
+  analyticsSince = Date.now();
+}
+
+// Time spent in another tab doesn't count.
+document.addEventListener('visibilitychange', function() {
+  if (document.visibilityState === 'hidden') {
+    var el = analyticsSlide;
+    analyticsLeave();
+    analyticsSlide = el;
+  } else {
+    analyticsSince = Date.now();
+  }
+});
+
+// toggle events don't bubble, so listen for them as they go down.
+document.addEventListener(
+  'toggle',
+  function(event) {
+    var d = event.target;
+    if (d.tagName !== 'DETAILS' || !d.open) return;
+    var kind = d.dataset.kind;
+    if (kind === 'question') kind = 'answer';
+    analyticsSend({ type: 'reveal', kind: kind || '' }, d.closest('article'));
+  },
+  true
+);
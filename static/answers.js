@@ -0,0 +1,44 @@
+// answers.js reveals the answers to questions from the keyboard.
+//
+// Press 'A' to open the next closed answer or hint on the current slide,
+// or, if a question's summary has the focus, to open or close that one.
+// When everything on the slide is open, 'A' closes it all again.
+// Enter and space also toggle a focused summary, instead of changing slides.
+
+function answersOnSlide() {
+  var el = getSlideEl(curSlide);
+  return el ? Array.prototype.slice.call(el.querySelectorAll('details')) : [];
+}
+
+// answerVisible reports whether all the details around d are open,
+// so that opening d shows it.
+function answerVisible(d) {
+  for (var p = d.parentElement; p; p = p.parentElement) {
+    if (p.tagName === 'DETAILS' && !p.open) return false;
+  }
+  return true;
+}
+
+function answersHandleKeyDown(event) {
+  if (event.target.classList.contains('code')) return;
+  if (event.ctrlKey || event.metaKey || event.altKey) return;
+  if (event.keyCode !== 65) return; // 'A'
+  if (event.target.tagName === 'SUMMARY') {
+    var d = event.target.parentElement;
+    d.open = !d.open;
+    return;
+  }
+  var all = answersOnSlide();
+  for (var i = 0; i < all.length; i++) {
+    if (!all[i].open && answerVisible(all[i])) {
+      all[i].open = true;
+      all[i].querySelector('summary').focus();
+      return;
+    }
+  }
+  all.forEach(function(d) {
+    d.open = false;
+  });
+}
+
+document.addEventListener('keydown', answersHandleKeyDown, false);
@@ -0,0 +1,128 @@
+// bookmarks.js remembers where the viewer was in a deck, so a workshop
+// that runs over several sessions can pick up where it left off.
+//
+// The last slide viewed is saved, and when the deck is opened again
+// without a slide number, a banner offers to resume there. Press 'B' to
+// bookmark the current slide under a name, and 'G' to list the bookmarks.
+
+function bookmarkKey(what) {
+  return what + ':' + location.pathname;
+}
+
+function bookmarkLoad() {
+  try {
+    return JSON.parse(localStorage.getItem(bookmarkKey('bookmarks'))) || {};
+  } catch (e) {
+    return {};
+  }
+}
+
+function bookmarkSlideEntered(event) {
+  localStorage.setItem(bookmarkKey('lastSlide'), event.slideNumber);
+}
+
+function bookmarkGoTo(slide) {
+  location.hash = '#' + slide;
+}
+
+// bookmarkBanner shows a banner with the given text and buttons, each a
+// [label, action] pair. It returns a function that removes the banner.
+function bookmarkBanner(text, buttons) {
+  var old = document.getElementById('bookmark-banner');
+  if (old) old.parentNode.removeChild(old);
+  var banner = document.createElement('div');
+  banner.id = 'bookmark-banner';
+  var span = document.createElement('span');
+  span.textContent = text;
+  banner.appendChild(span);
+  function remove() {
+    if (banner.parentNode) banner.parentNode.removeChild(banner);
+  }
+  buttons.forEach(function(b) {
+    var button = document.createElement('button');
+    button.textContent = b[0];
+    button.addEventListener('click', function() {
+      remove();
+      b[1]();
+    });
+    banner.appendChild(button);
+  });
+  var close = document.createElement('button');
+  close.textContent = '×';
+  close.addEventListener('click', remove);
+  banner.appendChild(close);
+  document.body.appendChild(banner);
+  return remove;
+}
+
+// bookmarkLastSlide is the last slide viewed in a previous visit. It's read
+// before the deck is displayed, which records slide 1 as the last slide.
+var bookmarkLastSlide = parseInt(localStorage.getItem(bookmarkKey('lastSlide')));
+
+function bookmarkOfferResume() {
+  var last = bookmarkLastSlide;
+  if (location.hash || !last || last <= 1) return;
+  var remove = bookmarkBanner('Resume at slide ' + last + '?', [
+    [
+      'Resume',
+      function() {
+        bookmarkGoTo(last);
+      },
+    ],
+  ]);
+  // Moving on means the viewer doesn't want to resume.
+  document.addEventListener('slideleave', remove, { once: true });
+}
+
+function bookmarkAdd() {
+  var slide = curSlide + 1;
+  var name = prompt('Bookmark slide ' + slide + ' as:');
+  if (!name) return;
+  var bookmarks = bookmarkLoad();
+  bookmarks[name] = slide;
+  localStorage.setItem(bookmarkKey('bookmarks'), JSON.stringify(bookmarks));
+}
+
+function bookmarkList() {
+  var bookmarks = bookmarkLoad();
+  var names = Object.keys(bookmarks).sort(function(a, b) {
+    return bookmarks[a] - bookmarks[b];
+  });
+  if (names.length === 0) {
+    bookmarkBanner("No bookmarks. Press 'B' to add one.", []);
+    return;
+  }
+  bookmarkBanner(
+    'Go to:',
+    names.map(function(name) {
+      return [
+        name + ' (' + bookmarks[name] + ')',
+        function() {
+          bookmarkGoTo(bookmarks[name]);
+        },
+      ];
+    })
+  );
+}
+
+function bookmarkHandleKeyDown(event) {
+  if (event.target.classList.contains('code')) return;
+  if (event.ctrlKey || event.metaKey || event.altKey) return;
+  switch (event.keyCode) {
+    case 66: // 'B' bookmarks the current slide
+      bookmarkAdd();
+      break;
+    case 71: // 'G' lists the bookmarks
+      bookmarkList();
+      break;
+  }
+}
+
+document.addEventListener('keydown', bookmarkHandleKeyDown, false);
+document.addEventListener('slideenter', bookmarkSlideEntered, false);
+
+if (document.readyState === 'loading') {
+  document.addEventListener('DOMContentLoaded', bookmarkOfferResume, false);
+} else {
+  bookmarkOfferResume();
+}
@@ -0,0 +1,169 @@
+// draw.js lets the presenter draw on slides with the mouse or a touch
+// screen. Press 'D' to draw with a pen, 'M' to use a highlighter, 'C' to
+// clear the current slide's drawing, and 'D', 'M' or Escape again to stop.
+//
+// Drawings are saved per slide: to the server when the deck is shown by
+// "code2slides serve", and otherwise to localStorage.
+
+var drawTool = null; // 'pen', 'highlighter' or null
+
+var drawStrokes = {}; // slide number (from 1) to list of strokes
+
+var DRAW_STYLES = {
+  pen: { color: 'rgba(220, 30, 30, 0.9)', width: 4 },
+  highlighter: { color: 'rgba(255, 220, 0, 0.35)', width: 24 },
+};
+
+function drawServed() {
+  return typeof liveID !== 'undefined';
+}
+
+function drawStorageKey() {
+  return 'annotations:' + location.pathname;
+}
+
+function drawCanvas(no) {
+  var el = getSlideEl(no - 1);
+  if (!el) return null;
+  var canvas = el.querySelector('canvas.annotations');
+  if (!canvas) {
+    canvas = document.createElement('canvas');
+    canvas.className = 'annotations';
+    canvas.width = el.offsetWidth;
+    canvas.height = el.offsetHeight;
+    canvas.addEventListener('pointerdown', drawStart, false);
+    el.appendChild(canvas);
+  }
+  return canvas;
+}
+
+function drawStroke(ctx, stroke) {
+  var style = DRAW_STYLES[stroke.tool];
+  var pts = stroke.points;
+  if (!style || pts.length === 0) return;
+  ctx.strokeStyle = style.color;
+  ctx.lineWidth = style.width;
+  ctx.lineCap = 'round';
+  ctx.lineJoin = 'round';
+  ctx.beginPath();
+  ctx.moveTo(pts[0][0], pts[0][1]);
+  for (var i = 1; i < pts.length; i++) {
+    ctx.lineTo(pts[i][0], pts[i][1]);
+  }
+  ctx.stroke();
+}
+
+function drawRedraw(no) {
+  var strokes = drawStrokes[no] || [];
+  var canvas = drawCanvas(no);
+  if (!canvas || (strokes.length === 0 && !canvas.drawn)) return;
+  var ctx = canvas.getContext('2d');
+  ctx.clearRect(0, 0, canvas.width, canvas.height);
+  for (var i = 0; i < strokes.length; i++) {
+    drawStroke(ctx, strokes[i]);
+  }
+  canvas.drawn = strokes.length > 0;
+}
+
+function drawStart(event) {
+  if (!drawTool) return;
+  event.preventDefault();
+  var canvas = event.target;
+  var no = curSlide + 1;
+  var stroke = { tool: drawTool, points: [[event.offsetX, event.offsetY]] };
+  (drawStrokes[no] = drawStrokes[no] || []).push(stroke);
+  canvas.setPointerCapture(event.pointerId);
+
+  function move(e) {
+    stroke.points.push([e.offsetX, e.offsetY]);
+    drawRedraw(no);
+  }
+  function end() {
+    canvas.removeEventListener('pointermove', move, false);
+    canvas.removeEventListener('pointerup', end, false);
+    canvas.removeEventListener('pointercancel', end, false);
+    drawSave(no);
+  }
+  canvas.addEventListener('pointermove', move, false);
+  canvas.addEventListener('pointerup', end, false);
+  canvas.addEventListener('pointercancel', end, false);
+}
+
+function drawSave(no) {
+  var strokes = drawStrokes[no] || [];
+  if (drawServed()) {
+    // Only the presenter's drawings are shared.
+    if (!livePresenterKey) return;
+    fetch('annotations/' + no + '?key=' + encodeURIComponent(livePresenterKey), {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(strokes),
+    });
+  } else {
+    localStorage.setItem(drawStorageKey(), JSON.stringify(drawStrokes));
+  }
+}
+
+function drawLoad() {
+  function show(all) {
+    drawStrokes = all || {};
+    for (var no in drawStrokes) drawRedraw(+no);
+  }
+  if (drawServed()) {
+    fetch('annotations')
+      .then(function(r) {
+        return r.json();
+      })
+      .then(show);
+  } else {
+    try {
+      show(JSON.parse(localStorage.getItem(drawStorageKey())));
+    } catch (e) {
+      show({});
+    }
+  }
+}
+
+function drawSetTool(tool) {
+  drawTool = drawTool === tool ? null : tool;
+  document.body.classList.toggle('drawing', drawTool !== null);
+  if (drawTool) drawCanvas(curSlide + 1);
+}
+
+function drawHandleKeyDown(event) {
+  if (event.target.classList.contains('code')) return;
+  if (event.ctrlKey || event.metaKey || event.altKey) return;
+  switch (event.keyCode) {
+    case 68: // 'D' toggles the pen
+      drawSetTool('pen');
+      break;
+    case 77: // 'M' toggles the highlighter
+      drawSetTool('highlighter');
+      break;
+    case 67: // 'C' clears the current slide's drawing
+      if (!drawTool) return;
+      drawStrokes[curSlide + 1] = [];
+      drawRedraw(curSlide + 1);
+      drawSave(curSlide + 1);
+      break;
+    case 27: // escape
+      if (drawTool) drawSetTool(drawTool);
+      break;
+  }
+}
+
+document.addEventListener('keydown', drawHandleKeyDown, false);
+// The canvas of a new slide must exist before the pointer touches it.
+document.addEventListener(
+  'slideenter',
+  function(e) {
+    if (drawTool) drawCanvas(e.slideNumber);
+  },
+  false
+);
+
+if (document.readyState === 'loading') {
+  document.addEventListener('DOMContentLoaded', drawLoad, false);
+} else {
+  drawLoad();
+}
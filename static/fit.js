@@ -0,0 +1,37 @@
+// fit.js shrinks the code of "code fit" sections that would overflow their
+// slide, a pixel of font size at a time, until the code fits. Slides are
+// only laid out when shown, so each is fit as it is entered.
+
+// fitMinFontSize is as small as code gets; code that still doesn't fit is
+// cut off, as it would be without fit.
+var fitMinFontSize = 14;
+
+// fitCode shrinks the font of pre, in slide el, until it fits.
+function fitCode(el, pre) {
+  pre.style.fontSize = '';
+  pre.style.lineHeight = '';
+  var style = getComputedStyle(pre);
+  var size = parseFloat(style.fontSize);
+  var ratio = parseFloat(style.lineHeight) / size || 4 / 3;
+  // Slides are scaled to the window, so compare in the slide's pixels.
+  var scale = el.getBoundingClientRect().height / el.offsetHeight || 1;
+  var bottom = el.getBoundingClientRect().bottom - parseFloat(getComputedStyle(el).paddingBottom) * scale;
+  function overflows() {
+    return pre.getBoundingClientRect().bottom > bottom + 1 ||
+      pre.scrollWidth > pre.clientWidth + 1;
+  }
+  while (size > fitMinFontSize && overflows()) {
+    size--;
+    pre.style.fontSize = size + 'px';
+    pre.style.lineHeight = Math.round(size * ratio) + 'px';
+  }
+}
+
+function fitSlideEntered(event) {
+  var el = event.target;
+  el.querySelectorAll('div.code.fit pre').forEach(function(pre) {
+    fitCode(el, pre);
+  });
+}
+
+document.addEventListener('slideenter', fitSlideEntered, false);
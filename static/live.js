@@ -0,0 +1,268 @@
+// live.js connects a deck to the "code2slides serve" command that is
+// serving it. It is only loaded by served decks.
+
+var liveID = Math.random().toString(36).slice(2);
+
+var liveEvents = null;
+
+function liveConnect() {
+  var slide = curSlide === undefined ? 1 : curSlide + 1;
+  liveEvents = new EventSource('live?id=' + liveID + '&slide=' + slide);
+  liveEvents.addEventListener('shutdown', function() {
+    liveEvents.close();
+  });
+  liveEvents.addEventListener('release', function() {
+    // Held slides were released; rebuild the deck with them.
+    location.reload();
+  });
+  if (!livePresenterKey) {
+    liveEvents.addEventListener('pointer', function(e) {
+      liveShowPointer(JSON.parse(e.data));
+    });
+  }
+  liveEvents.addEventListener('poll', function(e) {
+    var p = JSON.parse(e.data);
+    livePollChart(p.poll, p.counts);
+  });
+}
+
+function liveSlideEntered(event) {
+  navigator.sendBeacon('live/slide?id=' + liveID + '&slide=' + event.slideNumber);
+}
+
+document.addEventListener('slideenter', liveSlideEntered, false);
+
+// Live test panes, added by the livetest directive.
+
+function liveTestClass(line) {
+  if (/^(--- FAIL|FAIL)|WARNING: DATA RACE|^panic:/.test(line)) return 'fail';
+  if (/^(--- PASS|PASS|ok\s)/.test(line)) return 'pass';
+  return '';
+}
+
+function liveTestAppend(pre, text, className) {
+  var span = document.createElement('span');
+  span.textContent = text;
+  if (className) span.className = className;
+  pre.appendChild(span);
+  pre.scrollTop = pre.scrollHeight;
+}
+
+function liveTestRun(pane) {
+  var button = pane.querySelector('button');
+  var pre = pane.querySelector('pre');
+  pre.textContent = '';
+  button.disabled = true;
+  var url =
+    'livetest?dir=' +
+    encodeURIComponent(pane.dataset.dir) +
+    '&args=' +
+    encodeURIComponent(pane.dataset.args);
+  var events = new EventSource(url);
+  events.onmessage = function(e) {
+    var ev;
+    try {
+      ev = JSON.parse(e.data);
+    } catch (err) {
+      // Not a test2json event; probably a build error.
+      liveTestAppend(pre, e.data + '\n', 'fail');
+      return;
+    }
+    if (ev.Action === 'output') {
+      liveTestAppend(pre, ev.Output, liveTestClass(ev.Output));
+    }
+  };
+  events.addEventListener('done', function(e) {
+    var done = JSON.parse(e.data);
+    if (done.note) liveTestAppend(pre, done.note + '\n', 'fail');
+    events.close();
+    button.disabled = false;
+  });
+  events.onerror = function() {
+    events.close();
+    button.disabled = false;
+  };
+}
+
+function liveTestSetup() {
+  var panes = document.querySelectorAll('div.livetest');
+  for (var i = 0; i < panes.length; i++) {
+    (function(pane) {
+      pane.querySelector('pre').textContent = '';
+      pane.querySelector('button').addEventListener('click', function() {
+        liveTestRun(pane);
+      });
+    })(panes[i]);
+  }
+}
+
+if (document.readyState === 'loading') {
+  document.addEventListener('DOMContentLoaded', liveTestSetup, false);
+} else {
+  liveTestSetup();
+}
+
+// Pointer sharing. When the presenter, who opened the deck with
+// ?presenter=KEY, turns on the laser pointer or spotlight (see pointer.js),
+// its position is shown to every viewer.
+
+var livePresenterKey = new URLSearchParams(location.search).get('presenter');
+
+var liveLastPointerSend = 0;
+
+var liveLastPointerMode = null;
+
+function liveSendPointer(event) {
+  if (!livePresenterKey) return;
+  var mode = typeof pointerMode === 'undefined' ? null : pointerMode;
+  if (!mode && !liveLastPointerMode) return;
+  var now = Date.now();
+  if (mode === liveLastPointerMode && now - liveLastPointerSend < 50) return;
+  liveLastPointerSend = now;
+  liveLastPointerMode = mode;
+  var pos = { slide: curSlide + 1, mode: mode };
+  var el = getSlideEl(curSlide);
+  if (el && event) {
+    // Send the position as a fraction of the slide's size, since viewers'
+    // slides may be scaled differently.
+    var rect = el.getBoundingClientRect();
+    pos.x = (event.clientX - rect.left) / rect.width;
+    pos.y = (event.clientY - rect.top) / rect.height;
+  }
+  fetch('live/pointer?key=' + encodeURIComponent(livePresenterKey), {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(pos),
+  });
+}
+
+function liveShowPointer(pos) {
+  var dot = document.getElementById('remote-pointer');
+  if (!dot) {
+    dot = document.createElement('div');
+    dot.id = 'remote-pointer';
+  }
+  var el = getSlideEl(pos.slide - 1);
+  if (!pos.mode || pos.x === undefined || !el || pos.slide !== curSlide + 1) {
+    dot.style.display = 'none';
+    return;
+  }
+  if (dot.parentNode !== el) el.appendChild(dot);
+  dot.style.display = 'block';
+  dot.style.left = pos.x * el.offsetWidth + 'px';
+  dot.style.top = pos.y * el.offsetHeight + 'px';
+}
+
+// Polls, added by the poll directive. Viewers vote with the buttons;
+// the presenter sees a chart of the votes instead.
+
+function livePollChart(poll, counts) {
+  if (!livePresenterKey) return;
+  var div = document.querySelector("div.poll[data-poll='" + poll + "']");
+  if (!div) return;
+  var chart = div.querySelector('.poll-chart');
+  var buttons = div.querySelectorAll('button');
+  var most = 1;
+  for (var c in counts) most = Math.max(most, counts[c]);
+  chart.textContent = '';
+  for (var i = 0; i < buttons.length; i++) {
+    var choice = buttons[i].dataset.choice;
+    var n = counts[choice] || 0;
+    var row = document.createElement('div');
+    row.className = 'poll-row';
+    var label = document.createElement('span');
+    label.textContent = choice;
+    var bar = document.createElement('span');
+    bar.className = 'poll-bar';
+    bar.style.width = (n / most) * 80 + '%';
+    var count = document.createElement('span');
+    count.textContent = n;
+    row.appendChild(label);
+    row.appendChild(bar);
+    row.appendChild(count);
+    chart.appendChild(row);
+  }
+}
+
+function livePollVote(div, button) {
+  var body = new URLSearchParams({ viewer: liveID, choice: button.dataset.choice });
+  fetch('poll/' + div.dataset.poll, { method: 'POST', body: body }).then(function(res) {
+    if (!res.ok) return;
+    var buttons = div.querySelectorAll('button');
+    for (var i = 0; i < buttons.length; i++) {
+      buttons[i].classList.toggle('chosen', buttons[i] === button);
+    }
+  });
+}
+
+function livePollSetup() {
+  var polls = document.querySelectorAll('div.poll');
+  for (var i = 0; i < polls.length; i++) {
+    (function(div) {
+      if (livePresenterKey) {
+        div.classList.add('presenter');
+        livePollChart(div.dataset.poll, {});
+        return;
+      }
+      div.querySelector('.poll-chart').textContent = '';
+      var buttons = div.querySelectorAll('button');
+      for (var j = 0; j < buttons.length; j++) {
+        (function(button) {
+          button.addEventListener('click', function() {
+            livePollVote(div, button);
+          });
+        })(buttons[j]);
+      }
+    })(polls[i]);
+  }
+  if (livePresenterKey && polls.length > 0) {
+    fetch('polls')
+      .then(function(res) {
+        return res.json();
+      })
+      .then(function(all) {
+        for (var poll in all) livePollChart(poll, all[poll]);
+      });
+  }
+}
+
+if (document.readyState === 'loading') {
+  document.addEventListener('DOMContentLoaded', livePollSetup, false);
+} else {
+  livePollSetup();
+}
+
+// The presenter presses 'R' to release held slides.
+function liveRelease(event) {
+  if (!livePresenterKey || event.keyCode !== 82) return;
+  if (event.target.classList.contains('code')) return;
+  if (event.ctrlKey || event.metaKey || event.altKey) return;
+  var answer = prompt('Release held slides: name [delay, like 10m]');
+  if (!answer) return;
+  var words = answer.trim().split(/\s+/);
+  var url =
+    'release/' + encodeURIComponent(words[0]) +
+    '?key=' + encodeURIComponent(livePresenterKey);
+  if (words[1]) url += '&after=' + encodeURIComponent(words[1]);
+  fetch(url, { method: 'POST' }).then(function(res) {
+    if (!res.ok) res.text().then(alert);
+  });
+}
+
+document.addEventListener('keydown', liveRelease, false);
+
+document.addEventListener('pointermove', liveSendPointer, false);
+document.addEventListener(
+  'keydown',
+  function() {
+    // Hide the shared pointer when the presenter turns it off.
+    setTimeout(liveSendPointer, 0);
+  },
+  false
+);
+
+if (document.readyState === 'loading') {
+  document.addEventListener('DOMContentLoaded', liveConnect, false);
+} else {
+  liveConnect();
+}
@@ -72,7 +72,7 @@ function initNotes() {
 
   var notes = w.document.createElement('div');
   notes.id = 'presenter-notes';
-  notes.innerHTML = formattedNotes;
+  notes.innerHTML = formatTiming(curSlide) + formattedNotes;
   w.document.body.appendChild(notes);
 
   w.document.close();
@@ -103,6 +103,16 @@ function formatNotes(notes) {
   return formattedNotes;
 }
 
+// formatTiming returns the planned time of slide n, counting from 0, from
+// its time directive, and how much of the deck's time should have passed
+// when it began.
+function formatTiming(n) {
+  if (typeof plannedTimes === 'undefined' || !plannedTimes[n]) return '';
+  var t = plannedTimes[n];
+  return "<p class='timing'>" + t.Time + ' for this slide, starting at ' +
+    t.Before + ' of ' + plannedTotal + '</p>';
+}
+
 function updateNotes() {
   // When triggered from parent window, notesWindow is null
   // The storage event listener on notesWindow will update notes
@@ -120,6 +130,7 @@ function updateNotes() {
   } else {
     el.innerHTML = '';
   }
+  el.innerHTML = formatTiming(destSlide) + el.innerHTML;
 }
 
 /* Playground syncing */
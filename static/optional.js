@@ -0,0 +1,93 @@
+// optional.js lets the presenter skip optional slides when running late.
+// Slides marked with the optional directive are skipped by the arrow keys
+// when skipping is on; they can still be reached by their slide number.
+//
+// Press 'O' to turn skipping of all optional slides on or off, and
+// Shift-O to choose the tags of the optional slides to skip.
+
+// optionalSkip is null when nothing is skipped, '*' when all optional
+// slides are skipped, or a list of the tags whose slides are skipped.
+var optionalSkip = null;
+
+function optionalKey() {
+  return 'skipOptional:' + location.pathname;
+}
+
+function skipSlide(no) {
+  if (optionalSkip === null) return false;
+  var el = getSlideEl(no);
+  if (!el || !el.classList.contains('optional')) return false;
+  if (optionalSkip === '*') return true;
+  var tags = (el.dataset.tags || '').split(' ');
+  return tags.some(function(t) {
+    return optionalSkip.indexOf(t) >= 0;
+  });
+}
+
+function optionalSet(skip) {
+  optionalSkip = skip;
+  if (skip === null) {
+    localStorage.removeItem(optionalKey());
+  } else {
+    localStorage.setItem(optionalKey(), JSON.stringify(skip));
+  }
+  var badge = document.getElementById('optional-badge');
+  if (!badge) {
+    badge = document.createElement('div');
+    badge.id = 'optional-badge';
+    document.body.appendChild(badge);
+  }
+  if (skip === null) {
+    badge.style.display = 'none';
+  } else {
+    badge.style.display = 'block';
+    badge.textContent =
+      'Skipping ' + (skip === '*' ? 'optional slides' : skip.join(', '));
+  }
+}
+
+function optionalTags() {
+  var tags = {};
+  var els = document.querySelectorAll('article.optional[data-tags]');
+  for (var i = 0; i < els.length; i++) {
+    els[i].dataset.tags.split(' ').forEach(function(t) {
+      tags[t] = true;
+    });
+  }
+  return Object.keys(tags).sort();
+}
+
+function optionalHandleKeyDown(event) {
+  if (event.target.classList.contains('code')) return;
+  if (event.ctrlKey || event.metaKey || event.altKey) return;
+  if (event.keyCode !== 79) return; // 'O'
+  if (!event.shiftKey) {
+    optionalSet(optionalSkip === null ? '*' : null);
+    return;
+  }
+  var answer = prompt(
+    'Skip optional slides with these tags (separated by spaces):\n' +
+      optionalTags().join(' '),
+    Array.isArray(optionalSkip) ? optionalSkip.join(' ') : ''
+  );
+  if (answer === null) return;
+  var tags = answer.split(/\s+/).filter(Boolean);
+  optionalSet(tags.length ? tags : null);
+}
+
+document.addEventListener('keydown', optionalHandleKeyDown, false);
+
+(function() {
+  try {
+    var saved = JSON.parse(localStorage.getItem(optionalKey()));
+    if (saved) {
+      if (document.readyState === 'loading') {
+        document.addEventListener('DOMContentLoaded', function() {
+          optionalSet(saved);
+        });
+      } else {
+        optionalSet(saved);
+      }
+    }
+  } catch (e) {}
+})();
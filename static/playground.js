@@ -517,7 +517,7 @@ function PlaygroundOutput(el) {
       sharing = true;
 
       var sharingData = body();
-      $.ajax('https://play.golang.org/share', {
+      $.ajax('https://play.golang.org/share', { // offline-ok: only if the reader shares
         processData: false,
         data: sharingData,
         type: 'POST',
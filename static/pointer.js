@@ -0,0 +1,50 @@
+// pointer.js adds a laser pointer and a spotlight for pointing at parts
+// of a slide, which is hard to do with a hand over a video call.
+// Press 'L' to toggle the laser pointer and 'S' to toggle the spotlight.
+
+var pointerMode = null; // 'laser', 'spotlight' or null
+
+var pointerEl = null;
+
+function pointerElement() {
+  if (!pointerEl) {
+    pointerEl = document.createElement('div');
+    pointerEl.id = 'pointer';
+    document.body.appendChild(pointerEl);
+  }
+  return pointerEl;
+}
+
+function pointerMove(event) {
+  if (!pointerMode) return;
+  var el = pointerElement();
+  el.style.setProperty('--x', event.clientX + 'px');
+  el.style.setProperty('--y', event.clientY + 'px');
+}
+
+function pointerSetMode(mode) {
+  pointerMode = pointerMode === mode ? null : mode;
+  var el = pointerElement();
+  el.className = pointerMode || '';
+  document.body.classList.toggle('pointing', pointerMode !== null);
+}
+
+function pointerHandleKeyDown(event) {
+  if (event.target.classList.contains('code')) return;
+  if (event.ctrlKey || event.metaKey || event.altKey) return;
+  switch (event.keyCode) {
+    case 76: // 'L' toggles the laser pointer
+      pointerSetMode('laser');
+      break;
+    case 83: // 'S' toggles the spotlight
+      pointerSetMode('spotlight');
+      break;
+    case 27: // escape
+      if (pointerMode) pointerSetMode(pointerMode);
+      break;
+  }
+}
+
+document.addEventListener('keydown', pointerHandleKeyDown, false);
+document.addEventListener('mousemove', pointerMove, false);
+document.addEventListener('pointermove', pointerMove, false);
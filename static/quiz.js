@@ -0,0 +1,18 @@
+// quiz.js gives feedback on the choices of quizzes, from the quiz
+// directive. A wrong choice is marked wrong, leaving the others to try;
+// the correct choice is marked right, and ends the quiz.
+
+document.addEventListener('click', function(event) {
+  var b = event.target.closest('button.quiz-choice');
+  if (!b) return;
+  var quiz = b.closest('div.quiz');
+  if (quiz.classList.contains('solved')) return;
+  if (b.dataset.correct) {
+    b.classList.add('right');
+    quiz.classList.add('solved');
+  } else {
+    b.classList.add('wrong');
+  }
+  // Leave the keyboard to the slides.
+  b.blur();
+});
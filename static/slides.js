@@ -212,12 +212,27 @@ function updateSlides() {
   updateHash();
 }
 
+// isSkipped reports whether navigation should pass over slide no.
+// optional.js defines skipSlide.
+function isSkipped(no) {
+  return typeof skipSlide === 'function' && skipSlide(no);
+}
+
+// hasStep calls f, one of the functions of steps.js, on the current slide,
+// and reports whether it changed which of the slide's steps are shown.
+function hasStep(f) {
+  return typeof f === 'function' && f(getSlideEl(curSlide));
+}
+
 function prevSlide() {
   hideHelpText();
-  if (curSlide > 0) {
-    curSlide--;
-
-    updateSlides();
+  if (hasStep(window.hideStep)) return;
+  for (var no = curSlide - 1; no >= 0; no--) {
+    if (!isSkipped(no)) {
+      curSlide = no;
+      updateSlides();
+      break;
+    }
   }
 
   if (notesEnabled) localStorage.setItem(destSlideKey(), curSlide);
@@ -225,10 +240,13 @@ function prevSlide() {
 
 function nextSlide() {
   hideHelpText();
-  if (curSlide < slideEls.length - 1) {
-    curSlide++;
-
-    updateSlides();
+  if (hasStep(window.revealStep)) return;
+  for (var no = curSlide + 1; no < slideEls.length; no++) {
+    if (!isSkipped(no)) {
+      curSlide = no;
+      updateSlides();
+      break;
+    }
   }
 
   if (notesEnabled) localStorage.setItem(destSlideKey(), curSlide);
@@ -274,7 +292,59 @@ function triggerLeaveEvent(no) {
 
 /* Touch events */
 
+// Swiping left or right changes slides. Swipes that begin in code that
+// overflows scroll the code instead, and pinching code zooms it.
+
+// scrollableCode returns the code block containing el, if it is wider
+// than its box.
+function scrollableCode(el) {
+  var pre = el.closest ? el.closest('pre') : null;
+  if (pre && pre.scrollWidth > pre.clientWidth) return pre;
+  return null;
+}
+
+var pinch = null; // the code block being pinched, and its starting size
+
+function touchDistance(touches) {
+  var dx = touches[0].pageX - touches[1].pageX;
+  var dy = touches[0].pageY - touches[1].pageY;
+  return Math.sqrt(dx * dx + dy * dy);
+}
+
+function handlePinchMove(event) {
+  if (!pinch || event.touches.length != 2) return;
+  var scale = touchDistance(event.touches) / pinch.distance;
+  var size = Math.min(Math.max(pinch.fontSize * scale, 8), 60);
+  pinch.pre.style.fontSize = size + 'px';
+  pinch.pre.style.lineHeight = '1.35';
+  pinch.pre.style.overflowX = 'auto';
+  event.preventDefault();
+}
+
+function handlePinchEnd(event) {
+  if (event.touches.length < 2) {
+    pinch = null;
+    document.body.removeEventListener('touchmove', handlePinchMove, true);
+    document.body.removeEventListener('touchend', handlePinchEnd, true);
+  }
+}
+
 function handleTouchStart(event) {
+  if (event.touches.length == 2) {
+    var pre = event.target.closest ? event.target.closest('pre') : null;
+    if (pre) {
+      cancelTouch();
+      pinch = {
+        pre: pre,
+        distance: touchDistance(event.touches),
+        fontSize: parseFloat(getComputedStyle(pre).fontSize),
+      };
+      document.body.addEventListener('touchmove', handlePinchMove, true);
+      document.body.addEventListener('touchend', handlePinchEnd, true);
+    }
+    return;
+  }
+  if (scrollableCode(event.target)) return;
   if (event.touches.length == 1) {
     touchDX = 0;
     touchDY = 0;
@@ -293,7 +363,8 @@ function handleTouchMove(event) {
   } else {
     touchDX = event.touches[0].pageX - touchStartX;
     touchDY = event.touches[0].pageY - touchStartY;
-    event.preventDefault();
+    // Let vertical moves scroll a slide that is taller than the screen.
+    if (Math.abs(touchDX) > Math.abs(touchDY)) event.preventDefault();
   }
 }
 
@@ -408,6 +479,8 @@ function updateHash() {
 function handleBodyKeyDown(event) {
   // If we're in a code element, only handle pgup/down.
   var inCode = event.target.classList.contains('code');
+  // Enter and space toggle a focused question's answer.
+  var inSummary = event.target.tagName === 'SUMMARY';
 
   switch (event.keyCode) {
     case 78: // 'N' opens presenter notes window
@@ -421,7 +494,7 @@ function handleBodyKeyDown(event) {
     case 39: // right arrow
     case 13: // Enter
     case 32: // space
-      if (inCode) break;
+      if (inCode || inSummary) break;
     case 34: // PgDn
       nextSlide();
       event.preventDefault();
@@ -449,8 +522,19 @@ function handleBodyKeyDown(event) {
   }
 }
 
+// NARROW_QUERY matches screens, like phones held upright, that are too
+// narrow to show a whole slide legibly. On them, slides are laid out to
+// fit the screen's width, and scroll if they are too tall.
+var NARROW_QUERY = '(max-width: 700px) and (orientation: portrait)';
+
 function scaleSmallViewports() {
   var el = document.querySelector('section.slides');
+  var narrow = window.matchMedia && window.matchMedia(NARROW_QUERY).matches;
+  document.body.classList.toggle('narrow', narrow);
+  if (narrow) {
+    el.style.transform = '';
+    return;
+  }
   var transform = '';
   var sWidthPx = 1250;
   var sHeightPx = 750;
@@ -467,6 +551,12 @@ function scaleSmallViewports() {
 
 function addEventListeners() {
   document.addEventListener('keydown', handleBodyKeyDown, false);
+  // Follow links to other slides, like those on the contents slide.
+  window.addEventListener('hashchange', function() {
+    var prev = curSlide;
+    getCurSlideFromHash();
+    if (curSlide != prev) updateSlides();
+  });
   var resizeTimeout;
   window.addEventListener('resize', function() {
     // throttle resize events
@@ -495,12 +585,17 @@ function addEventListeners() {
 /* Initialization */
 
 function addFontStyle() {
+  // Decks built with -offline set fontURL to '' and use local fonts.
+  if (typeof fontURL !== 'undefined' && !fontURL) return;
+
   var el = document.createElement('link');
   el.rel = 'stylesheet';
   el.type = 'text/css';
   el.href =
-    '//fonts.googleapis.com/css?family=' +
-    'Open+Sans:regular,semibold,italic,italicsemibold|Droid+Sans+Mono';
+    typeof fontURL !== 'undefined'
+      ? fontURL
+      : '//fonts.googleapis.com/css?family=' + // offline-ok: -offline decks return above
+        'Open+Sans:regular,semibold,italic,italicsemibold|Droid+Sans+Mono';
 
   document.body.appendChild(el);
 }
@@ -512,6 +607,15 @@ function addGeneralStyle() {
   el.href = PERMANENT_URL_PREFIX + 'styles.css';
   document.body.appendChild(el);
 
+  // Decks built with -theme restyle the deck after styles.css.
+  if (typeof themeURL !== 'undefined' && themeURL) {
+    var el = document.createElement('link');
+    el.rel = 'stylesheet';
+    el.type = 'text/css';
+    el.href = themeURL;
+    document.body.appendChild(el);
+  }
+
   var el = document.createElement('meta');
   el.name = 'viewport';
   el.content = 'width=device-width,height=device-height,initial-scale=1';
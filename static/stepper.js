@@ -0,0 +1,47 @@
+// stepper.js steps through the interleavings of stepper sections. The
+// build runs the program and puts its state after each step in the
+// section's data-steps attribute, as JSON (see interleave.go); the buttons
+// move between the states, marking the statement just executed.
+
+function stepperVars(names, values) {
+  return names
+    .map(function(v) {
+      return v + ' = ' + values[v];
+    })
+    .join('<br>');
+}
+
+function stepperShow(el, n) {
+  var data = JSON.parse(el.dataset.steps);
+  n = Math.max(0, Math.min(n, data.steps.length - 1));
+  el.dataset.step = n;
+  var st = data.steps[n];
+  el.querySelectorAll('.stepper-stmt').forEach(function(s) {
+    s.classList.toggle('current', +s.dataset.g === st.g && +s.dataset.stmt === st.stmt);
+    // Statements before the current state have run.
+    var ran = data.steps.slice(1, n + 1).some(function(p) {
+      return p.g === +s.dataset.g && p.stmt === +s.dataset.stmt;
+    });
+    s.classList.toggle('ran', ran);
+  });
+  el.querySelectorAll('.stepper-vars[data-g]').forEach(function(v) {
+    var g = +v.dataset.g;
+    v.innerHTML = stepperVars(data.goroutines[g].registers, st.registers[g]);
+  });
+  el.querySelector('.stepper-shared').innerHTML = stepperVars(data.shared, st.shared);
+  el.querySelector('.stepper-prev').disabled = n === 0;
+  el.querySelector('.stepper-next').disabled = n === data.steps.length - 1;
+}
+
+document.querySelectorAll('div.stepper').forEach(function(el) {
+  stepperShow(el, 0);
+});
+
+document.addEventListener('click', function(event) {
+  var b = event.target.closest('.stepper-prev, .stepper-next');
+  if (!b) return;
+  var el = b.closest('div.stepper');
+  stepperShow(el, +el.dataset.step + (b.classList.contains('stepper-next') ? 1 : -1));
+  // Leave the keyboard to the slides.
+  b.blur();
+});
@@ -0,0 +1,63 @@
+// steps.js reveals the code of a slide in steps, from the "// step" lines
+// in its code blocks, and then the steps of its timelines, one at a time.
+// Moving forward shows the next step before going on to the next slide,
+// and moving back hides the last step shown.
+
+// stepSpans returns the lines of step n of slide el.
+function stepSpans(el, n) {
+  return el.querySelectorAll("span.step[data-step='" + n + "']");
+}
+
+// timelineSteps returns the steps of the timelines of slide el that can be
+// seen: those of a timeline in an answer wait for the answer to be opened.
+function timelineSteps(el) {
+  return Array.from(el.querySelectorAll('li.tstep')).filter(function(li) {
+    return !li.closest('details:not([open])');
+  });
+}
+
+// revealStep shows the first hidden step of slide el, and reports whether
+// there was one.
+function revealStep(el) {
+  if (!el) return false;
+  var hidden = el.querySelectorAll('span.step:not(.shown)');
+  if (hidden.length === 0) {
+    var next = timelineSteps(el).find(function(li) {
+      return !li.classList.contains('shown');
+    });
+    if (!next) return false;
+    next.classList.add('shown');
+    return true;
+  }
+  var n = Infinity;
+  hidden.forEach(function(s) {
+    n = Math.min(n, +s.dataset.step);
+  });
+  stepSpans(el, n).forEach(function(s) {
+    s.classList.add('shown');
+  });
+  return true;
+}
+
+// hideStep hides the last shown step of slide el, and reports whether
+// there was one.
+function hideStep(el) {
+  if (!el) return false;
+  var last = timelineSteps(el).filter(function(li) {
+    return li.classList.contains('shown');
+  }).pop();
+  if (last) {
+    last.classList.remove('shown');
+    return true;
+  }
+  var shown = el.querySelectorAll('span.step.shown');
+  if (shown.length === 0) return false;
+  var n = 0;
+  shown.forEach(function(s) {
+    n = Math.max(n, +s.dataset.step);
+  });
+  stepSpans(el, n).forEach(function(s) {
+    s.classList.remove('shown');
+  });
+  return true;
+}